@@ -0,0 +1,103 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/vultisig/vultisigner/common"
+	"github.com/vultisig/vultisigner/storage"
+)
+
+// backupDownloadAttempts is how many wrong vault passwords a download link takes before it is deleted
+const backupDownloadAttempts = 3
+
+// backupDownloadPage asks for the vault password , opening the link alone downloads nothing
+var backupDownloadPage = template.Must(template.New("backup").Parse(`<!DOCTYPE html>
+<html><body>
+<p>Enter the password of your vault to download your vault share. The link works once.</p>
+<form method="post" action="{{.}}"><input type="password" name="password" autocomplete="off"><button type="submit">Download</button></form>
+</body></html>`))
+
+// BackupDownloadPage is a handler to show the password form of a backup download link
+func (s *Server) BackupDownloadPage(c echo.Context) error {
+	var buf strings.Builder
+	if err := backupDownloadPage.Execute(&buf, c.Request().URL.Path); err != nil {
+		return fmt.Errorf("fail to render backup download page, err: %w", err)
+	}
+	return c.HTML(http.StatusOK, buf.String())
+}
+
+// DownloadBackup is a handler to download the vault share backup of a download link with the vault password.
+// The link email never carries the password , so the link alone downloads nothing.
+func (s *Server) DownloadBackup(c echo.Context) error {
+	password := c.FormValue("password")
+	if password == "" {
+		return c.NoContent(http.StatusBadRequest)
+	}
+	ctx := c.Request().Context()
+	token := c.Param("token")
+	publicKeyECDSA, err := s.redis.GetBackupDownload(ctx, token)
+	if errors.Is(err, storage.ErrBackupDownloadNotFound) {
+		return c.NoContent(http.StatusNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("fail to get backup download, err: %w", err)
+	}
+	content, err := s.blockStorage.GetFile(publicKeyECDSA + ".bak")
+	if err != nil {
+		return fmt.Errorf("fail to read file, err: %w", err)
+	}
+	if _, err := common.DecryptVaultFromBackup(password, content); err != nil {
+		if err := s.redis.FailBackupDownload(ctx, token, backupDownloadAttempts); err != nil {
+			return fmt.Errorf("fail to count backup download attempt, err: %w", err)
+		}
+		return c.NoContent(http.StatusForbidden)
+	}
+	fileName, contentType, content, err := s.backupDownloadContent(ctx, publicKeyECDSA, content)
+	if err != nil {
+		return err
+	}
+	if _, err := s.redis.ClaimBackupDownload(ctx, token); err != nil {
+		if errors.Is(err, storage.ErrBackupDownloadNotFound) {
+			return c.NoContent(http.StatusNotFound)
+		}
+		return fmt.Errorf("fail to claim backup download, err: %w", err)
+	}
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", fileName))
+	return c.Blob(http.StatusOK, contentType, content)
+}
+
+// backupDownloadContent returns the file name , content type and content of a download ,
+// the vault share is encrypted to the OpenPGP key of the vault when it has one , like the backup email
+func (s *Server) backupDownloadContent(ctx context.Context, publicKeyECDSA string, content []byte) (string, string, []byte, error) {
+	fileName := publicKeyECDSA + ".bak"
+	metadata, err := s.redis.GetVaultMetadata(ctx, publicKeyECDSA)
+	if errors.Is(err, storage.ErrVaultMetadataNotFound) {
+		return fileName, "application/octet-stream", content, nil
+	}
+	if err != nil {
+		return "", "", nil, fmt.Errorf("fail to get vault metadata, err: %w", err)
+	}
+	if metadata.FileName != "" {
+		fileName = metadata.FileName
+	}
+	if metadata.PGPFingerprint == "" {
+		return fileName, "application/octet-stream", content, nil
+	}
+	// never fall back to the plain vault share , the owner asked for it to be encrypted
+	publicKey, err := s.redis.GetPGPPublicKey(ctx, metadata.PGPFingerprint)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("fail to get OpenPGP key %s, err: %w", metadata.PGPFingerprint, err)
+	}
+	encrypted, err := common.EncryptPGP(publicKey, fileName, content)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("fail to encrypt vault share, err: %w", err)
+	}
+	return fileName + ".asc", "application/pgp-encrypted", encrypted, nil
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/vultisig/vultisigner/common"
+	"github.com/vultisig/vultisigner/internal/types"
+	"github.com/vultisig/vultisigner/storage"
+)
+
+// reportPage asks for confirmation before freezing , so link scanners that open every url in an email don't freeze the vault
+var reportPage = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html><body>
+<p>Report the signature as unauthorized? Your vault will be frozen and VultiServer won't co-sign until support unfreezes it.</p>
+<form method="post" action="{{.}}"><button type="submit">Report and freeze my vault</button></form>
+</body></html>`))
+
+// SetNotificationSettings is a handler to opt a vault in or out of co-sign notifications
+func (s *Server) SetNotificationSettings(c echo.Context) error {
+	var req types.NotificationSettingsRequest
+	if err := c.Bind(&req); err != nil {
+		return fmt.Errorf("fail to parse request, err: %w", err)
+	}
+	defer req.Password.Wipe()
+	if err := req.IsValid(); err != nil {
+		s.logger.Errorf("invalid notification settings request: %v", err)
+		return c.NoContent(http.StatusBadRequest)
+	}
+	if !s.isValidHash(req.PublicKeyECDSA) {
+		return c.NoContent(http.StatusBadRequest)
+	}
+	content, err := s.blockStorage.GetFile(req.PublicKeyECDSA + ".bak")
+	if err != nil {
+		s.logger.Errorf("fail to read file, err: %v", err)
+		return c.NoContent(http.StatusBadRequest)
+	}
+	if _, err := common.DecryptVaultFromBackupSecret(req.Password, content); err != nil {
+		s.logger.Errorf("fail to decrypt vault from the backup, err: %v", err)
+		return c.NoContent(http.StatusBadRequest)
+	}
+	settings := types.NotificationSettings{
+		Email:      strings.TrimSpace(req.Email),
+		WebhookURL: req.WebhookURL,
+	}
+	if err := s.redis.SaveNotificationSettings(c.Request().Context(), req.PublicKeyECDSA, settings); err != nil {
+		return fmt.Errorf("fail to save notification settings, err: %w", err)
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+// ReportPage is a handler to show the confirmation page of a report link
+func (s *Server) ReportPage(c echo.Context) error {
+	var buf strings.Builder
+	if err := reportPage.Execute(&buf, c.Request().URL.Path); err != nil {
+		return fmt.Errorf("fail to render report page, err: %w", err)
+	}
+	return c.HTML(http.StatusOK, buf.String())
+}
+
+// ReportKeysign is a handler to report a co-signed keysign as unauthorized , it freezes the vault
+func (s *Server) ReportKeysign(c echo.Context) error {
+	publicKeyECDSA, sessionID, err := s.redis.ClaimReportToken(c.Request().Context(), c.Param("token"))
+	if errors.Is(err, storage.ErrReportTokenNotFound) {
+		return c.NoContent(http.StatusNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("fail to claim report token, err: %w", err)
+	}
+	freeze := types.VaultFreeze{
+		Reason:    "keysign reported as unauthorized by the owner",
+		SessionID: sessionID,
+		FrozenAt:  time.Now().UTC(),
+	}
+	if err := s.redis.FreezeVault(c.Request().Context(), publicKeyECDSA, freeze); err != nil {
+		return fmt.Errorf("fail to freeze vault, err: %w", err)
+	}
+	s.logger.Warnf("vault %s frozen , keysign session %s reported as unauthorized", publicKeyECDSA, sessionID)
+	if err := s.sdClient.Count("vault.frozen", 1, nil, 1); err != nil {
+		s.logger.Errorf("fail to count metric, err: %v", err)
+	}
+	return c.HTML(http.StatusOK, "<!DOCTYPE html><html><body><p>Your vault is frozen. Please contact support.</p></body></html>")
+}
+
+// UnfreezeVault is a handler for admins to let a frozen vault sign again
+func (s *Server) UnfreezeVault(c echo.Context) error {
+	publicKeyECDSA := c.Param("publicKeyECDSA")
+	if !s.isValidHash(publicKeyECDSA) {
+		return c.NoContent(http.StatusBadRequest)
+	}
+	if err := s.redis.UnfreezeVault(c.Request().Context(), publicKeyECDSA); err != nil {
+		return fmt.Errorf("fail to unfreeze vault, err: %w", err)
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+// isVaultFrozen reports whether the vault is frozen , a failure to check counts as frozen
+func (s *Server) isVaultFrozen(c echo.Context, publicKeyECDSA string) bool {
+	freeze, err := s.redis.GetVaultFreeze(c.Request().Context(), publicKeyECDSA)
+	if err != nil {
+		s.logger.Errorf("fail to check vault freeze, err: %v", err)
+		return true
+	}
+	return freeze != nil
+}
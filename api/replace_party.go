@@ -0,0 +1,136 @@
+package api
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/vultisig/vultisigner/common"
+	"github.com/vultisig/vultisigner/internal/types"
+	"github.com/vultisig/vultisigner/storage"
+)
+
+// ReplaceParty is a handler to replace a lost device of a vault.
+// The email and the verification code sent to it are checked , then the remaining parties reshare with the new device ,
+// and the party id of the lost device is revoked. The reshare fails unless exactly the remaining parties and the new device join.
+func (s *Server) ReplaceParty(c echo.Context) error {
+	var req types.ReplacePartyRequest
+	if err := c.Bind(&req); err != nil {
+		return fmt.Errorf("fail to parse request, err: %w", err)
+	}
+	defer req.EncryptionPassword.Wipe()
+	if err := req.IsValid(); err != nil {
+		return fmt.Errorf("invalid request, err: %w", err)
+	}
+	if !s.isValidHash(req.PublicKey) {
+		return c.NoContent(http.StatusBadRequest)
+	}
+	if s.isVaultFrozen(c, req.PublicKey) {
+		return c.NoContent(http.StatusForbidden)
+	}
+	if err := s.sdClient.Count("vault.replace_party", 1, nil, 1); err != nil {
+		s.logger.Errorf("fail to count metric, err: %v", err)
+	}
+
+	// the email has to be the one the vault share was sent to
+	metadata, err := s.redis.GetVaultMetadata(c.Request().Context(), req.PublicKey)
+	if errors.Is(err, storage.ErrVaultMetadataNotFound) {
+		return c.NoContent(http.StatusBadRequest)
+	}
+	if err != nil {
+		return fmt.Errorf("fail to get vault metadata, err: %w", err)
+	}
+	if metadata.EmailHash == "" {
+		s.logger.Errorf("vault %s has no email to verify", req.PublicKey)
+		return c.NoContent(http.StatusBadRequest)
+	}
+	if subtle.ConstantTimeCompare([]byte(common.HashEmail(s.emailSalt, req.Email)), []byte(metadata.EmailHash)) != 1 {
+		return c.NoContent(http.StatusForbidden)
+	}
+	codeKey := fmt.Sprintf("verification_code_%s", req.PublicKey)
+	code, err := s.redis.Get(c.Request().Context(), codeKey)
+	if err != nil || subtle.ConstantTimeCompare([]byte(code), []byte(req.Code)) != 1 {
+		return c.NoContent(http.StatusForbidden)
+	}
+
+	content, err := s.blockStorage.GetFile(req.PublicKey + ".bak")
+	if err != nil {
+		return fmt.Errorf("fail to read file, err: %w", err)
+	}
+	vault, err := common.DecryptVaultFromBackupSecret(req.EncryptionPassword, content)
+	if err != nil {
+		s.logger.Errorf("fail to decrypt vault from the backup, err: %v", err)
+		return c.NoContent(http.StatusBadRequest)
+	}
+	remaining, threshold, err := replacementCommittee(vault.Signers, vault.LocalPartyId, req)
+	if err != nil {
+		s.logger.Errorf("invalid party replacement of %s: %v", req.PublicKey, err)
+		return c.NoContent(http.StatusBadRequest)
+	}
+	if err := s.blockStorage.CheckRevokedParties(req.PublicKey, []string{req.NewParty}); err != nil {
+		s.logger.Errorf("invalid party replacement of %s: %v", req.PublicKey, err)
+		return c.NoContent(http.StatusBadRequest)
+	}
+	if currentThreshold, err := s.blockStorage.GetVaultThreshold(vault); err != nil {
+		return fmt.Errorf("fail to get vault threshold, err: %w", err)
+	} else if len(remaining) < currentThreshold {
+		s.logger.Errorf("%d remaining parties of %s can't reach the threshold %d", len(remaining), req.PublicKey, currentThreshold)
+		return c.NoContent(http.StatusBadRequest)
+	} else if threshold == 0 {
+		threshold = currentThreshold
+	}
+	// the code can only be used once
+	if err := s.redis.Delete(c.Request().Context(), codeKey); err != nil {
+		s.logger.Errorf("fail to delete verification code, err: %v", err)
+	}
+
+	return s.enqueueReshare(c, types.ReshareRequest{
+		Name:                  vault.Name,
+		PublicKey:             req.PublicKey,
+		SessionID:             req.SessionID,
+		HexEncryptionKey:      req.HexEncryptionKey,
+		HexChainCode:          vault.HexChainCode,
+		LocalPartyId:          vault.LocalPartyId,
+		OldParties:            remaining,
+		EncryptionPassword:    req.EncryptionPassword,
+		Email:                 req.Email,
+		OldResharePrefix:      vault.ResharePrefix,
+		LibType:               types.VaultLibType(vault.LibType),
+		Threshold:             threshold,
+		RevokedParty:          req.LostParty,
+		ExpectedParties:       append(slices.Clone(remaining), req.NewParty),
+		BackupDeliveryOptions: req.BackupDeliveryOptions,
+	})
+}
+
+// replacementCommittee validates the replacement against the signers of the vault ,
+// and returns the signers that remain together with the requested threshold of the new committee
+func replacementCommittee(signers []string, localPartyID string, req types.ReplacePartyRequest) ([]string, int, error) {
+	if !slices.Contains(signers, req.LostParty) {
+		return nil, 0, fmt.Errorf("lost party %s is not a signer", req.LostParty)
+	}
+	if req.LostParty == localPartyID {
+		return nil, 0, fmt.Errorf("the server party can't be replaced")
+	}
+	if slices.Contains(signers, req.NewParty) {
+		return nil, 0, fmt.Errorf("new party %s is already a signer", req.NewParty)
+	}
+	remaining := make([]string, 0, len(signers)-1)
+	for _, signer := range signers {
+		if signer != req.LostParty {
+			remaining = append(remaining, signer)
+		}
+	}
+	if req.Threshold == 0 {
+		return remaining, 0, nil
+	}
+	threshold, err := common.ResolveThreshold(req.Threshold, len(remaining)+1)
+	if err != nil {
+		return nil, 0, err
+	}
+	return remaining, threshold, nil
+}
@@ -2,9 +2,11 @@ package api
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math/rand"
@@ -18,10 +20,11 @@ import (
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/labstack/gommon/log"
 	"github.com/sirupsen/logrus"
-	keygen "github.com/vultisig/commondata/go/vultisig/keygen/v1"
 	"github.com/vultisig/mobile-tss-lib/tss"
 
 	"github.com/vultisig/vultisigner/common"
+	"github.com/vultisig/vultisigner/config"
+	"github.com/vultisig/vultisigner/internal/protocol"
 	"github.com/vultisig/vultisigner/internal/tasks"
 	"github.com/vultisig/vultisigner/internal/types"
 	"github.com/vultisig/vultisigner/storage"
@@ -36,6 +39,10 @@ type Server struct {
 	sdClient      *statsd.Client
 	logger        *logrus.Logger
 	blockStorage  *storage.BlockStorage
+	adminToken    string
+	emailSalt     string
+	queues        config.Queues
+	vaultCache    *storage.VaultCache
 }
 
 // NewServer returns a new server.
@@ -45,7 +52,11 @@ func NewServer(port int64,
 	inspector *asynq.Inspector,
 	vaultFilePath string,
 	sdClient *statsd.Client,
-	blockStorage *storage.BlockStorage) *Server {
+	blockStorage *storage.BlockStorage,
+	adminToken string,
+	emailSalt string,
+	queues config.Queues,
+	vaultCache *storage.VaultCache) *Server {
 	return &Server{
 		port:          port,
 		redis:         redis,
@@ -55,6 +66,10 @@ func NewServer(port int64,
 		sdClient:      sdClient,
 		logger:        logrus.WithField("service", "api").Logger,
 		blockStorage:  blockStorage,
+		adminToken:    adminToken,
+		emailSalt:     emailSalt,
+		queues:        queues,
+		vaultCache:    vaultCache,
 	}
 }
 
@@ -73,11 +88,16 @@ func (s *Server) StartServer() error {
 	e.Use(middleware.RateLimiter(limiterStore))
 	e.GET("/ping", s.Ping)
 	e.GET("/getDerivedPublicKey", s.GetDerivedPublicKey)
+	e.GET("/session/:sessionID", s.GetSession)       // state of the operation of a session
+	e.DELETE("/session/:sessionID", s.CancelSession) // cancel a session , authenticated with its hex encryption key
 	grp := e.Group("/vault")
 
 	grp.POST("/create", s.CreateVault)
 	grp.POST("/reshare", s.ReshareVault)
+	grp.POST("/replace-party", s.ReplaceParty) // replace a lost device , the remaining parties reshare with the new device
 	grp.POST("/migrate", s.MigrateVault)
+	grp.POST("/refresh", s.RefreshVault)
+	grp.POST("/import", s.ImportVault)
 	// grp.POST("/upload", s.UploadVault)
 	// grp.GET("/download/:publicKeyECDSA", s.DownloadVault)
 	grp.GET("/get/:publicKeyECDSA", s.GetVault)     // Get Vault Data
@@ -86,7 +106,21 @@ func (s *Server) StartServer() error {
 	grp.POST("/sign", s.SignMessages)       // Sign messages
 	grp.POST("/resend", s.ResendVaultEmail) // request server to send vault share , code through email again
 	grp.GET("/verify/:publicKeyECDSA/:code", s.VerifyCode)
+	grp.POST("/resend-by-email", s.ResendVaultsByEmail)                // email a verification code to the email
+	grp.POST("/resend-by-email/confirm", s.ConfirmResendVaultsByEmail) // email every vault share sent to the email again
+	grp.POST("/notifications", s.SetNotificationSettings)              // opt in or out of co-sign notifications
+	grp.GET("/report/:token", s.ReportPage)                            // confirmation page of a report link
+	grp.POST("/report/:token", s.ReportKeysign)                        // report a co-signed keysign as unauthorized , freezes the vault
+	grp.GET("/backup/:token", s.BackupDownloadPage)                    // verification code form of a backup download link
+	grp.POST("/backup/:token", s.DownloadBackup)                       // download the vault share backup of a download link
+	grp.GET("/result/:taskId", s.GetTaskResult)                        // result of a create , reshare or migrate task
 	// grp.GET("/sign/response/:taskId", s.GetKeysignResult) // Get keysign result
+
+	adminGrp := e.Group("/admin", s.adminAuthMiddleware)
+	adminGrp.GET("/vaults", s.QueryVaults)
+	adminGrp.GET("/vaults/stats", s.GetVaultStats)
+	adminGrp.GET("/vaults/:publicKeyECDSA", s.GetVaultMetadata)
+	adminGrp.DELETE("/vaults/:publicKeyECDSA/freeze", s.UnfreezeVault)
 	return e.Start(fmt.Sprintf(":%d", s.port))
 }
 
@@ -141,6 +175,7 @@ func (s *Server) CreateVault(c echo.Context) error {
 	if err := c.Bind(&req); err != nil {
 		return fmt.Errorf("fail to parse request, err: %w", err)
 	}
+	defer req.EncryptionPassword.Wipe()
 	if err := req.IsValid(); err != nil {
 		return fmt.Errorf("invalid request, err: %w", err)
 	}
@@ -152,29 +187,16 @@ func (s *Server) CreateVault(c echo.Context) error {
 		s.logger.Errorf("fail to count metric, err: %v", err)
 	}
 
-	result, err := s.redis.Get(c.Request().Context(), req.SessionID)
-	if err == nil && result != "" {
-		return c.NoContent(http.StatusOK)
-	}
-
-	if err := s.redis.Set(c.Request().Context(), req.SessionID, req.SessionID, 5*time.Minute); err != nil {
-		s.logger.Errorf("fail to set session, err: %v", err)
-	}
-	var typeName = ""
-	if req.LibType == types.GG20 {
-		typeName = tasks.TypeKeyGeneration
-	} else {
-		typeName = tasks.TypeKeyGenerationDKLS
-	}
-	_, err = s.client.Enqueue(asynq.NewTask(typeName, buf),
-		asynq.MaxRetry(-1),
-		asynq.Timeout(7*time.Minute),
-		asynq.Retention(10*time.Minute),
-		asynq.Queue(tasks.QUEUE_NAME))
+	typeName, err := protocol.TaskType(req.LibType, protocol.OperationKeygen)
 	if err != nil {
-		return fmt.Errorf("fail to enqueue task, err: %w", err)
+		return c.NoContent(http.StatusBadRequest)
 	}
-	return c.NoContent(http.StatusOK)
+	ti, err := s.enqueueSession(c, req.SessionID, req.HexEncryptionKey, string(protocol.OperationKeygen), asynq.NewTask(typeName, buf),
+		asynq.MaxRetry(-1))
+	if ti == nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, ti.ID)
 }
 
 // ReshareVault is a handler to reshare a vault
@@ -183,68 +205,122 @@ func (s *Server) ReshareVault(c echo.Context) error {
 	if err := c.Bind(&req); err != nil {
 		return fmt.Errorf("fail to parse request, err: %w", err)
 	}
+	defer req.EncryptionPassword.Wipe()
 	if err := req.IsValid(); err != nil {
 		return fmt.Errorf("invalid request, err: %w", err)
 	}
+	if req.RevokedParty != "" {
+		// parties are only revoked through the verified replace-party flow
+		return c.NoContent(http.StatusBadRequest)
+	}
+	if req.PublicKey != "" && s.isVaultFrozen(c, req.PublicKey) {
+		return c.NoContent(http.StatusForbidden)
+	}
+	return s.enqueueReshare(c, req)
+}
+
+func (s *Server) enqueueReshare(c echo.Context, req types.ReshareRequest) error {
 	buf, err := json.Marshal(req)
 	if err != nil {
 		return fmt.Errorf("fail to marshal to json, err: %w", err)
 	}
-	result, err := s.redis.Get(c.Request().Context(), req.SessionID)
-	if err == nil && result != "" {
-		return c.NoContent(http.StatusOK)
+	typeName, err := protocol.TaskType(req.LibType, protocol.OperationReshare)
+	if err != nil {
+		return c.NoContent(http.StatusBadRequest)
+	}
+	ti, err := s.enqueueSession(c, req.SessionID, req.HexEncryptionKey, string(protocol.OperationReshare), asynq.NewTask(typeName, buf),
+		asynq.MaxRetry(-1))
+	if ti == nil {
+		return err
 	}
+	return c.JSON(http.StatusOK, ti.ID)
+}
 
-	if err := s.redis.Set(c.Request().Context(), req.SessionID, req.SessionID, 5*time.Minute); err != nil {
-		s.logger.Errorf("fail to set session, err: %v", err)
+// MigrateVault is a handler to migrate a vault from GG20 to DKLS
+func (s *Server) MigrateVault(c echo.Context) error {
+	var req types.MigrationRequest
+	if err := c.Bind(&req); err != nil {
+		return fmt.Errorf("fail to parse request, err: %w", err)
 	}
-	var typeName = ""
-	if req.LibType == types.GG20 {
-		typeName = tasks.TypeReshare
-	} else {
-		typeName = tasks.TypeReshareDKLS
+	defer req.EncryptionPassword.Wipe()
+	if err := req.IsValid(); err != nil {
+		return fmt.Errorf("invalid request, err: %w", err)
 	}
-	_, err = s.client.Enqueue(asynq.NewTask(typeName, buf),
-		asynq.MaxRetry(-1),
-		asynq.Timeout(7*time.Minute),
-		asynq.Retention(10*time.Minute),
-		asynq.Queue(tasks.QUEUE_NAME))
+	if s.isVaultFrozen(c, req.PublicKey) {
+		return c.NoContent(http.StatusForbidden)
+	}
+	buf, err := json.Marshal(req)
 	if err != nil {
-		return fmt.Errorf("fail to enqueue task, err: %w", err)
+		return fmt.Errorf("fail to marshal to json, err: %w", err)
 	}
-	return c.NoContent(http.StatusOK)
+	typeName, err := protocol.TaskType(types.DKLS, protocol.OperationMigrate)
+	if err != nil {
+		return c.NoContent(http.StatusBadRequest)
+	}
+	ti, err := s.enqueueSession(c, req.SessionID, req.HexEncryptionKey, string(protocol.OperationMigrate), asynq.NewTask(typeName, buf),
+		asynq.MaxRetry(-1))
+	if ti == nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, ti.ID)
 }
 
-// MigrateVault is a handler to migrate a vault from GG20 to DKLS
-func (s *Server) MigrateVault(c echo.Context) error {
-	var req types.MigrationRequest
+// RefreshVault is a handler to refresh the key shares of a DKLS vault , the public keys stay the same
+func (s *Server) RefreshVault(c echo.Context) error {
+	var req types.RefreshRequest
 	if err := c.Bind(&req); err != nil {
 		return fmt.Errorf("fail to parse request, err: %w", err)
 	}
+	defer req.EncryptionPassword.Wipe()
 	if err := req.IsValid(); err != nil {
 		return fmt.Errorf("invalid request, err: %w", err)
 	}
+	if !s.isValidHash(req.PublicKey) {
+		return c.NoContent(http.StatusBadRequest)
+	}
+	if s.isVaultFrozen(c, req.PublicKey) {
+		return c.NoContent(http.StatusForbidden)
+	}
 	buf, err := json.Marshal(req)
 	if err != nil {
 		return fmt.Errorf("fail to marshal to json, err: %w", err)
 	}
-	result, err := s.redis.Get(c.Request().Context(), req.SessionID)
-	if err == nil && result != "" {
-		return c.NoContent(http.StatusOK)
+	// only DKLS vaults can be refreshed , the worker rejects the share of any other lib type
+	ti, err := s.enqueueSession(c, req.SessionID, req.HexEncryptionKey, string(protocol.OperationRefresh), asynq.NewTask(tasks.TypeRefreshDKLS, buf),
+		asynq.MaxRetry(-1))
+	if ti == nil {
+		return err
 	}
+	return c.JSON(http.StatusOK, ti.ID)
+}
 
-	if err := s.redis.Set(c.Request().Context(), req.SessionID, req.SessionID, 5*time.Minute); err != nil {
-		s.logger.Errorf("fail to set session, err: %v", err)
+// ImportVault is a handler to create a DKLS vault from an existing single-signer private key
+func (s *Server) ImportVault(c echo.Context) error {
+	var req types.KeyImportRequest
+	if err := c.Bind(&req); err != nil {
+		return fmt.Errorf("fail to parse request, err: %w", err)
 	}
-	_, err = s.client.Enqueue(asynq.NewTask(tasks.TypeMigrate, buf),
-		asynq.MaxRetry(-1),
-		asynq.Timeout(7*time.Minute),
-		asynq.Retention(10*time.Minute),
-		asynq.Queue(tasks.QUEUE_NAME))
+	defer req.EncryptionPassword.Wipe()
+	if err := req.IsValid(); err != nil {
+		return fmt.Errorf("invalid request, err: %w", err)
+	}
+	buf, err := json.Marshal(req)
 	if err != nil {
-		return fmt.Errorf("fail to enqueue task, err: %w", err)
+		return fmt.Errorf("fail to marshal to json, err: %w", err)
 	}
-	return c.NoContent(http.StatusOK)
+	if err := s.sdClient.Count("vault.import", 1, nil, 1); err != nil {
+		s.logger.Errorf("fail to count metric, err: %v", err)
+	}
+	typeName, err := protocol.TaskType(types.DKLS, protocol.OperationImport)
+	if err != nil {
+		return c.NoContent(http.StatusBadRequest)
+	}
+	ti, err := s.enqueueSession(c, req.SessionID, req.HexEncryptionKey, string(protocol.OperationImport), asynq.NewTask(typeName, buf),
+		asynq.MaxRetry(-1))
+	if ti == nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, ti.ID)
 }
 
 // UploadVault is a handler that receives a vault file from integration.
@@ -267,6 +343,9 @@ func (s *Server) UploadVault(c echo.Context) error {
 	if err := s.blockStorage.UploadFile(content, vault.PublicKeyEcdsa+".bak"); err != nil {
 		return fmt.Errorf("fail to upload file, err: %w", err)
 	}
+	if err := s.vaultCache.Invalidate(c.Request().Context(), vault.PublicKeyEcdsa); err != nil {
+		s.logger.Errorf("fail to invalidate cached vault, err: %v", err)
+	}
 
 	return c.NoContent(http.StatusOK)
 }
@@ -334,12 +413,18 @@ func (s *Server) GetVault(c echo.Context) error {
 		return fmt.Errorf("fail to decrypt vault from the backup, err: %w", err)
 	}
 
+	threshold, err := s.blockStorage.GetVaultThreshold(vault)
+	if err != nil {
+		return fmt.Errorf("fail to get vault threshold, err: %w", err)
+	}
+
 	return c.JSON(http.StatusOK, types.VaultGetResponse{
 		Name:           vault.Name,
 		PublicKeyEcdsa: vault.PublicKeyEcdsa,
 		PublicKeyEddsa: vault.PublicKeyEddsa,
 		HexChainCode:   vault.HexChainCode,
 		LocalPartyId:   vault.LocalPartyId,
+		Threshold:      threshold,
 	})
 }
 func (s *Server) DeleteVault(c echo.Context) error {
@@ -370,6 +455,15 @@ func (s *Server) DeleteVault(c echo.Context) error {
 	if err != nil {
 		return fmt.Errorf("fail to remove file, err: %w", err)
 	}
+	if err := s.vaultCache.Invalidate(c.Request().Context(), publicKeyECDSA); err != nil {
+		s.logger.Errorf("fail to invalidate cached vault, err: %v", err)
+	}
+	if err := s.blockStorage.DeleteFile(storage.VaultSettingsFileName(publicKeyECDSA)); err != nil {
+		s.logger.Errorf("fail to remove vault settings, err: %v", err)
+	}
+	if err := s.redis.DeleteVaultMetadata(c.Request().Context(), publicKeyECDSA); err != nil {
+		s.logger.Errorf("fail to remove vault from the index, err: %v", err)
+	}
 
 	return c.NoContent(http.StatusOK)
 }
@@ -380,50 +474,51 @@ func (s *Server) SignMessages(c echo.Context) error {
 	if err := c.Bind(&req); err != nil {
 		return fmt.Errorf("fail to parse request, err: %w", err)
 	}
+	defer req.VaultPassword.Wipe()
 	if err := req.IsValid(); err != nil {
 		return fmt.Errorf("invalid request, err: %w", err)
 	}
 	if !s.isValidHash(req.PublicKey) {
 		return c.NoContent(http.StatusBadRequest)
 	}
-	result, err := s.redis.Get(c.Request().Context(), req.SessionID)
-	if err == nil && result != "" {
-		return c.NoContent(http.StatusOK)
-	}
-
-	if err := s.redis.Set(c.Request().Context(), req.SessionID, req.SessionID, 30*time.Minute); err != nil {
-		s.logger.Errorf("fail to set session, err: %v", err)
+	if s.isVaultFrozen(c, req.PublicKey) {
+		return c.NoContent(http.StatusForbidden)
 	}
-
-	filePathName := req.PublicKey + ".bak"
-	content, err := s.blockStorage.GetFile(filePathName)
-	if err != nil {
-		return fmt.Errorf("fail to read file, err: %w", err)
+	if req.ConfirmPending {
+		pending, err := s.redis.GetPendingVault(c.Request().Context(), req.PublicKey)
+		if err != nil {
+			return fmt.Errorf("fail to get pending vault, err: %w", err)
+		}
+		if pending == nil {
+			return c.NoContent(http.StatusNotFound)
+		}
 	}
 
-	vault, err := common.DecryptVaultFromBackup(req.VaultPassword, content)
+	vault, err := s.vaultCache.LoadVault(c.Request().Context(), req.VaultFile(), req.VaultPassword)
 	if err != nil {
-		return fmt.Errorf("fail to decrypt vault from the backup, err: %w", err)
+		return fmt.Errorf("fail to load vault, err: %w", err)
 	}
 	buf, err := json.Marshal(req)
 	if err != nil {
 		return fmt.Errorf("fail to marshal to json, err: %w", err)
 	}
-	var typeName = ""
-	if vault.LibType == keygen.LibType_LIB_TYPE_GG20 {
-		typeName = tasks.TypeKeySign
-	} else {
-		typeName = tasks.TypeKeySignDKLS
+	info, err := protocol.Get(types.VaultLibType(vault.LibType))
+	if err != nil {
+		return c.NoContent(http.StatusBadRequest)
 	}
-	ti, err := s.client.EnqueueContext(c.Request().Context(),
-		asynq.NewTask(typeName, buf),
-		asynq.MaxRetry(-1),
-		asynq.Timeout(2*time.Minute),
-		asynq.Retention(5*time.Minute),
-		asynq.Queue(tasks.QUEUE_NAME))
-
+	if info.CheckKeysign != nil {
+		if err := info.CheckKeysign(req); err != nil {
+			return c.NoContent(http.StatusBadRequest)
+		}
+	}
+	typeName, err := protocol.TaskType(info.LibType, protocol.OperationKeysign)
 	if err != nil {
-		return fmt.Errorf("fail to enqueue task, err: %w", err)
+		return c.NoContent(http.StatusBadRequest)
+	}
+	ti, err := s.enqueueSession(c, req.SessionID, req.HexEncryptionKey, string(protocol.OperationKeysign), asynq.NewTask(typeName, buf),
+		asynq.MaxRetry(-1))
+	if ti == nil {
+		return err
 	}
 
 	return c.JSON(http.StatusOK, ti.ID)
@@ -432,11 +527,19 @@ func (s *Server) SignMessages(c echo.Context) error {
 
 // GetKeysignResult is a handler to get the keysign response
 func (s *Server) GetKeysignResult(c echo.Context) error {
+	return s.GetTaskResult(c)
+}
+
+// GetTaskResult is a handler to get the result of a task , like the vault share backup of the result backup delivery
+func (s *Server) GetTaskResult(c echo.Context) error {
 	taskID := c.Param("taskId")
 	if taskID == "" {
 		return fmt.Errorf("task id is required")
 	}
-	task, err := s.inspector.GetTaskInfo(tasks.QUEUE_NAME, taskID)
+	task, err := s.inspector.GetTaskInfo(s.taskQueue(c.Request().Context(), taskID), taskID)
+	if errors.Is(err, asynq.ErrTaskNotFound) {
+		return c.NoContent(http.StatusNotFound)
+	}
 	if err != nil {
 		return fmt.Errorf("fail to find task, err: %w", err)
 	}
@@ -445,16 +548,57 @@ func (s *Server) GetKeysignResult(c echo.Context) error {
 		return fmt.Errorf("task not found")
 	}
 
-	if task.State == asynq.TaskStatePending {
+	if !s.authorizeTaskResult(c, taskID, task) {
+		return c.NoContent(http.StatusUnauthorized)
+	}
+
+	if task.State == asynq.TaskStatePending || task.State == asynq.TaskStateActive || task.State == asynq.TaskStateScheduled {
 		return c.JSON(http.StatusOK, "Task is still in progress")
 	}
 
 	if task.State == asynq.TaskStateCompleted {
-		return c.JSON(http.StatusOK, task.Result)
+		return c.JSONBlob(http.StatusOK, task.Result)
 	}
 
 	return fmt.Errorf("task state is invalid")
 }
+
+// authorizeTaskResult reports whether the request may read the result of the task , the task id is the session id
+// which every party of the session knows , so it takes the session key in `x-encryption-key` ,
+// or the password of the vault in the result in `x-password` once the task completed
+func (s *Server) authorizeTaskResult(c echo.Context, taskID string, task *asynq.TaskInfo) bool {
+	ctx := c.Request().Context()
+	if hexEncryptionKey := c.Request().Header.Get("x-encryption-key"); hexEncryptionKey != "" {
+		state, err := s.redis.GetSessionState(ctx, taskID)
+		if err != nil {
+			if !errors.Is(err, storage.ErrSessionStateNotFound) {
+				s.logger.Errorf("fail to get session state, err: %v", err)
+			}
+			return false
+		}
+		return state.KeyHash != "" && subtle.ConstantTimeCompare([]byte(sessionKeyHash(hexEncryptionKey)), []byte(state.KeyHash)) == 1
+	}
+	if c.Request().Header.Get("x-password") == "" || task.State != asynq.TaskStateCompleted {
+		return false
+	}
+	passwd, err := s.extractXPassword(c)
+	if err != nil {
+		return false
+	}
+	var result struct {
+		ECDSAPublicKey string
+	}
+	if err := json.Unmarshal(task.Result, &result); err != nil || !s.isValidHash(result.ECDSAPublicKey) {
+		return false
+	}
+	content, err := s.blockStorage.GetFile(result.ECDSAPublicKey + ".bak")
+	if err != nil {
+		s.logger.Errorf("fail to read file, err: %v", err)
+		return false
+	}
+	_, err = common.DecryptVaultFromBackup(passwd, content)
+	return err == nil
+}
 func (s *Server) isValidHash(hash string) bool {
 	if len(hash) != 66 {
 		return false
@@ -484,6 +628,7 @@ func (s *Server) ResendVaultEmail(c echo.Context) error {
 	if err := c.Bind(&req); err != nil {
 		return fmt.Errorf("fail to parse request, err: %w", err)
 	}
+	defer req.Password.Wipe()
 	publicKeyECDSA := req.PublicKeyECDSA
 	if publicKeyECDSA == "" {
 		s.logger.Errorln("public key is required")
@@ -504,7 +649,7 @@ func (s *Server) ResendVaultEmail(c echo.Context) error {
 	if err := s.sdClient.Count("vault.resend", 1, nil, 1); err != nil {
 		s.logger.Errorf("fail to count metric, err: %v", err)
 	}
-	if req.Password == "" {
+	if req.Password.Len() == 0 {
 		s.logger.Errorln("password is required")
 		return c.NoContent(http.StatusBadRequest)
 	}
@@ -514,7 +659,7 @@ func (s *Server) ResendVaultEmail(c echo.Context) error {
 		return c.NoContent(http.StatusBadRequest)
 	}
 
-	vault, err := common.DecryptVaultFromBackup(req.Password, content)
+	vault, err := common.DecryptVaultFromBackupSecret(req.Password, content)
 	if err != nil {
 		s.logger.Errorf("fail to decrypt vault from the backup, err: %v", err)
 		return c.NoContent(http.StatusBadRequest)
@@ -531,6 +676,12 @@ func (s *Server) ResendVaultEmail(c echo.Context) error {
 		VaultName:   vault.Name,
 		Code:        code,
 	}
+	// a vault whose shares are emailed encrypted stays encrypted on resend
+	if metadata, err := s.redis.GetVaultMetadata(c.Request().Context(), publicKeyECDSA); err == nil {
+		emailRequest.PGPFingerprint = metadata.PGPFingerprint
+	} else if !errors.Is(err, storage.ErrVaultMetadataNotFound) {
+		return fmt.Errorf("fail to get vault metadata, err: %w", err)
+	}
 	buf, err := json.Marshal(emailRequest)
 	if err != nil {
 		return fmt.Errorf("json.Marshal failed: %w", err)
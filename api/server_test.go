@@ -0,0 +1,90 @@
+package api
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hibiken/asynq"
+	"github.com/labstack/echo/v4"
+	vaultType "github.com/vultisig/commondata/go/vultisig/vault/v1"
+
+	"github.com/vultisig/vultisigner/common"
+	"github.com/vultisig/vultisigner/config"
+	"github.com/vultisig/vultisigner/storage"
+)
+
+const testPublicKeyECDSA = "027e897b35aa9f9fff223b6c826ff42da37e8169fae7be57cbd38be86938a746c6"
+
+// withTestBlockStorage serves the objects of the block storage of the server from memory , path style requests /bucket/key
+func withTestBlockStorage(t *testing.T, s *Server, objects map[string][]byte) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)[1]
+		content, ok := objects[key]
+		if r.Method != http.MethodGet || !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?><Error><Code>NoSuchKey</Code><Message>not found</Message></Error>`))
+			return
+		}
+		_, _ = w.Write(content)
+	}))
+	t.Cleanup(server.Close)
+	var cfg config.Config
+	cfg.BlockStorage.Host = server.URL
+	cfg.BlockStorage.Region = "us-east-1"
+	cfg.BlockStorage.AccessKey = "access"
+	cfg.BlockStorage.SecretKey = "secret"
+	cfg.BlockStorage.Bucket = "vaults"
+	blockStorage, err := storage.NewBlockStorage(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.blockStorage = blockStorage
+}
+
+func TestAuthorizeTaskResult(t *testing.T) {
+	backup, err := common.EncryptVaultToBackup("password", 0, &vaultType.Vault{
+		Name:           "test vault",
+		PublicKeyEcdsa: testPublicKeyECDSA,
+		LocalPartyId:   "server",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	completed := &asynq.TaskInfo{State: asynq.TaskStateCompleted, Result: []byte(`{"ECDSAPublicKey":"` + testPublicKeyECDSA + `"}`)}
+	tests := map[string]struct {
+		taskID     string
+		headers    map[string]string
+		task       *asynq.TaskInfo
+		authorized bool
+	}{
+		"session key":                  {taskID: testSessionID, headers: map[string]string{"x-encryption-key": "first"}, task: completed, authorized: true},
+		"wrong session key":            {taskID: testSessionID, headers: map[string]string{"x-encryption-key": "second"}, task: completed},
+		"no session state":             {taskID: "other-session", headers: map[string]string{"x-encryption-key": "first"}, task: completed},
+		"password":                     {taskID: testSessionID, headers: map[string]string{"x-password": base64.StdEncoding.EncodeToString([]byte("password"))}, task: completed, authorized: true},
+		"wrong password":               {taskID: testSessionID, headers: map[string]string{"x-password": "wrong"}, task: completed},
+		"password of a running task":   {taskID: testSessionID, headers: map[string]string{"x-password": "password"}, task: &asynq.TaskInfo{State: asynq.TaskStateActive}},
+		"password without a vault":     {taskID: testSessionID, headers: map[string]string{"x-password": "password"}, task: &asynq.TaskInfo{State: asynq.TaskStateCompleted, Result: []byte(`{}`)}},
+		"wrong key overrides password": {taskID: testSessionID, headers: map[string]string{"x-encryption-key": "second", "x-password": "password"}, task: completed},
+		"no credentials":               {taskID: testSessionID, task: completed},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			s, _ := newTestServer(t)
+			withTestBlockStorage(t, s, map[string][]byte{testPublicKeyECDSA + ".bak": backup})
+			if ti, _ := enqueueTestSession(t, s, "first"); ti == nil {
+				t.Fatal("session wasn't enqueued")
+			}
+			req := httptest.NewRequest(http.MethodGet, "/getTaskResult/"+test.taskID, nil)
+			for name, value := range test.headers {
+				req.Header.Set(name, value)
+			}
+			c := echo.New().NewContext(req, httptest.NewRecorder())
+			if authorized := s.authorizeTaskResult(c, test.taskID, test.task); authorized != test.authorized {
+				t.Fatalf("expected authorized %v, got %v", test.authorized, authorized)
+			}
+		})
+	}
+}
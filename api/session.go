@@ -0,0 +1,198 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/labstack/echo/v4"
+
+	"github.com/vultisig/vultisigner/internal/tasks"
+	"github.com/vultisig/vultisigner/internal/types"
+	"github.com/vultisig/vultisigner/storage"
+)
+
+// sessionStateTTL is the minimum ttl of a session state , the state outlives the timeout and retention of its task
+const sessionStateTTL = 30 * time.Minute
+
+// sessionKeyHash returns the hash of the hex encryption key of a session that is kept in the session state
+func sessionKeyHash(hexEncryptionKey string) string {
+	hash := sha256.Sum256([]byte(hexEncryptionKey))
+	return hex.EncodeToString(hash[:])
+}
+
+// enqueueSession creates the state of the session and enqueues its task to the queue of the operation ,
+// with the timeout and retention of the queue. The session id is the task id.
+// A duplicate request of the session gets 409 with the existing session state written to the response ,
+// then the returned task info is nil.
+func (s *Server) enqueueSession(c echo.Context, sessionID, hexEncryptionKey, operation string, task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error) {
+	ctx := c.Request().Context()
+	policy, ok := s.queues.Policy(operation)
+	if !ok {
+		return nil, fmt.Errorf("no queue for operation %s", operation)
+	}
+	queue := tasks.OperationQueue(operation)
+	timeout := time.Duration(policy.Timeout) * time.Second
+	retention := time.Duration(policy.RetentionMinutes) * time.Minute
+	ttl := max(sessionStateTTL, timeout+retention)
+	now := time.Now().UTC()
+	state, created, err := s.redis.CreateSessionState(ctx, types.SessionState{
+		SessionID: sessionID,
+		Operation: operation,
+		TaskID:    sessionID,
+		Queue:     queue,
+		Status:    types.SessionQueued,
+		CreatedAt: now,
+		UpdatedAt: now,
+		KeyHash:   sessionKeyHash(hexEncryptionKey),
+	}, ttl)
+	if err != nil {
+		return nil, fmt.Errorf("fail to create session state, err: %w", err)
+	}
+	if !created {
+		return nil, c.JSON(http.StatusConflict, state.Public())
+	}
+	opts = append(opts,
+		asynq.Queue(queue),
+		asynq.Timeout(timeout),
+		asynq.Retention(retention),
+		asynq.TaskID(sessionID),
+		asynq.Unique(ttl))
+	ti, err := s.client.EnqueueContext(ctx, task, opts...)
+	if errors.Is(err, asynq.ErrTaskIDConflict) || errors.Is(err, asynq.ErrDuplicateTask) {
+		// asynq still keeps the task of the session after its state expired , the state just created isn't the real one
+		if err := s.redis.DeleteSessionState(ctx, sessionID); err != nil {
+			s.logger.Errorf("fail to delete session state, err: %v", err)
+		}
+		state := types.SessionState{
+			SessionID: sessionID,
+			Operation: operation,
+			TaskID:    sessionID,
+			Queue:     queue,
+			Status:    types.SessionQueued,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		ti, err := s.inspector.GetTaskInfo(queue, sessionID)
+		if err != nil {
+			s.logger.Errorf("fail to get task info, err: %v", err)
+		} else {
+			state.Status, state.Error = taskSessionStatus(ti)
+			if updatedAt := taskUpdatedAt(ti); !updatedAt.IsZero() {
+				state.UpdatedAt = updatedAt
+			}
+		}
+		return nil, c.JSON(http.StatusConflict, state.Public())
+	}
+	if err != nil {
+		// the session isn't running , let the client try again
+		if err := s.redis.DeleteSessionState(ctx, sessionID); err != nil {
+			s.logger.Errorf("fail to delete session state, err: %v", err)
+		}
+		return nil, fmt.Errorf("fail to enqueue task, err: %w", err)
+	}
+	return ti, nil
+}
+
+// taskSessionStatus maps the state of the task of a session to the status of the session , with the error of a failed task
+func taskSessionStatus(ti *asynq.TaskInfo) (types.SessionStatus, string) {
+	switch ti.State {
+	case asynq.TaskStateActive:
+		return types.SessionRunning, ""
+	case asynq.TaskStateCompleted:
+		return types.SessionCompleted, ""
+	case asynq.TaskStateArchived:
+		return types.SessionFailed, ti.LastErr
+	default:
+		// pending , scheduled , aggregating and retry tasks wait for a worker
+		return types.SessionQueued, ""
+	}
+}
+
+// taskUpdatedAt returns the time the task of a session last finished or failed , zero when it didn't yet
+func taskUpdatedAt(ti *asynq.TaskInfo) time.Time {
+	if ti.State == asynq.TaskStateCompleted {
+		return ti.CompletedAt
+	}
+	return ti.LastFailedAt
+}
+
+// GetSession is a handler to get the state of the operation of a session
+func (s *Server) GetSession(c echo.Context) error {
+	sessionID := c.Param("sessionID")
+	if sessionID == "" {
+		return c.NoContent(http.StatusBadRequest)
+	}
+	state, err := s.redis.GetSessionState(c.Request().Context(), sessionID)
+	if errors.Is(err, storage.ErrSessionStateNotFound) {
+		return c.NoContent(http.StatusNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("fail to get session state, err: %w", err)
+	}
+	return c.JSON(http.StatusOK, state.Public())
+}
+
+// CancelSession is a handler to cancel a session , the request is authenticated with the hex encryption key of the session.
+// A queued task is deleted , a running task is cancelled and the worker ends the relay session.
+func (s *Server) CancelSession(c echo.Context) error {
+	sessionID := c.Param("sessionID")
+	hexEncryptionKey := c.Request().Header.Get("x-encryption-key")
+	if sessionID == "" || hexEncryptionKey == "" {
+		return c.NoContent(http.StatusBadRequest)
+	}
+	ctx := c.Request().Context()
+	state, err := s.redis.GetSessionState(ctx, sessionID)
+	if errors.Is(err, storage.ErrSessionStateNotFound) {
+		return c.NoContent(http.StatusNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("fail to get session state, err: %w", err)
+	}
+	if state.KeyHash == "" || subtle.ConstantTimeCompare([]byte(sessionKeyHash(hexEncryptionKey)), []byte(state.KeyHash)) != 1 {
+		return c.NoContent(http.StatusUnauthorized)
+	}
+	updated, err := s.redis.UpdateSessionStatus(ctx, sessionID, types.SessionCancelled, "cancelled by the client")
+	if err != nil {
+		return fmt.Errorf("fail to cancel session, err: %w", err)
+	}
+	if !updated {
+		// the session finished already
+		if state, err = s.redis.GetSessionState(ctx, sessionID); err != nil {
+			return fmt.Errorf("fail to get session state, err: %w", err)
+		}
+		return c.JSON(http.StatusConflict, state.Public())
+	}
+	if err := s.sdClient.Count("session.cancel", 1, []string{"operation:" + state.Operation}, 1); err != nil {
+		s.logger.Errorf("fail to count metric, err: %v", err)
+	}
+	err = s.inspector.DeleteTask(state.Queue, state.TaskID)
+	switch {
+	case err == nil, errors.Is(err, asynq.ErrTaskNotFound), errors.Is(err, asynq.ErrQueueNotFound):
+	default:
+		// an active task can't be deleted , the worker stops it once its context is cancelled
+		if err := s.inspector.CancelProcessing(state.TaskID); err != nil {
+			return fmt.Errorf("fail to cancel task, err: %w", err)
+		}
+	}
+	if state, err = s.redis.GetSessionState(ctx, sessionID); err != nil {
+		return fmt.Errorf("fail to get session state, err: %w", err)
+	}
+	return c.JSON(http.StatusOK, state.Public())
+}
+
+// taskQueue returns the queue of a task , session tasks are in the queue kept in the session state.
+// Other tasks , and tasks enqueued before the operation queues , are in the default queue.
+func (s *Server) taskQueue(ctx context.Context, taskID string) string {
+	state, err := s.redis.GetSessionState(ctx, taskID)
+	if err != nil || state.Queue == "" {
+		return tasks.QUEUE_NAME
+	}
+	return state.Queue
+}
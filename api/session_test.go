@@ -0,0 +1,133 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/hibiken/asynq"
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+
+	"github.com/vultisig/vultisigner/config"
+	"github.com/vultisig/vultisigner/internal/protocol"
+	"github.com/vultisig/vultisigner/internal/tasks"
+	"github.com/vultisig/vultisigner/internal/types"
+	"github.com/vultisig/vultisigner/storage"
+)
+
+const testSessionID = "ab2ec1a4-8ee4-4e4e-9a4c-2d9c3a6f1e11"
+
+// newTestServer returns a server backed by an in-memory redis
+func newTestServer(t *testing.T) (*Server, *miniredis.Miniredis) {
+	server := miniredis.RunT(t)
+	var cfg config.Config
+	cfg.Redis.Host = server.Host()
+	cfg.Redis.Port = server.Port()
+	redis, err := storage.NewRedisStorage(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := asynq.NewClient(asynq.RedisClientOpt{Addr: server.Addr()})
+	inspector := asynq.NewInspector(asynq.RedisClientOpt{Addr: server.Addr()})
+	t.Cleanup(func() {
+		_ = client.Close()
+		_ = inspector.Close()
+	})
+	var queues config.Queues
+	queues.Keygen = config.QueuePolicy{Timeout: 600, RetentionMinutes: 10}
+	return &Server{
+		redis:     redis,
+		client:    client,
+		inspector: inspector,
+		logger:    logrus.New(),
+		queues:    queues,
+	}, server
+}
+
+func enqueueTestSession(t *testing.T, s *Server, hexEncryptionKey string) (*asynq.TaskInfo, *httptest.ResponseRecorder) {
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/vault/create", nil), rec)
+	ti, err := s.enqueueSession(c, testSessionID, hexEncryptionKey, string(protocol.OperationKeygen), asynq.NewTask(tasks.TypeKeyGenerationDKLS, []byte("{}")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ti, rec
+}
+
+func TestEnqueueSessionOnce(t *testing.T) {
+	s, server := newTestServer(t)
+	ti, _ := enqueueTestSession(t, s, "first")
+	if ti == nil || ti.ID != testSessionID {
+		t.Fatalf("session wasn't enqueued: %+v", ti)
+	}
+
+	ti, rec := enqueueTestSession(t, s, "second")
+	if ti != nil || rec.Code != http.StatusConflict {
+		t.Fatalf("duplicate session enqueued: %+v %d", ti, rec.Code)
+	}
+	var state types.SessionState
+	if err := json.Unmarshal(rec.Body.Bytes(), &state); err != nil {
+		t.Fatal(err)
+	}
+	if state.SessionID != testSessionID || state.Status != types.SessionQueued || state.KeyHash != "" {
+		t.Fatalf("unexpected session state: %+v", state)
+	}
+	stored, err := s.redis.GetSessionState(context.Background(), testSessionID)
+	if err != nil || stored.KeyHash != sessionKeyHash("first") {
+		t.Fatalf("duplicate replaced the session state: %+v %v", stored, err)
+	}
+	pending, err := server.List("asynq:{" + tasks.OperationQueue(string(protocol.OperationKeygen)) + "}:pending")
+	if err != nil || len(pending) != 1 {
+		t.Fatalf("expected one task , got %v %v", pending, err)
+	}
+}
+
+func TestEnqueueSessionAfterStateExpired(t *testing.T) {
+	s, _ := newTestServer(t)
+	if ti, _ := enqueueTestSession(t, s, "first"); ti == nil {
+		t.Fatal("session wasn't enqueued")
+	}
+	// asynq still has the task of the session
+	if err := s.redis.DeleteSessionState(context.Background(), testSessionID); err != nil {
+		t.Fatal(err)
+	}
+	ti, rec := enqueueTestSession(t, s, "second")
+	if ti != nil || rec.Code != http.StatusConflict {
+		t.Fatalf("duplicate session enqueued: %+v %d", ti, rec.Code)
+	}
+	var state types.SessionState
+	if err := json.Unmarshal(rec.Body.Bytes(), &state); err != nil {
+		t.Fatal(err)
+	}
+	if state.Status != types.SessionQueued || state.CreatedAt.IsZero() || state.UpdatedAt.IsZero() || state.KeyHash != "" {
+		t.Fatalf("unexpected session state: %+v", state)
+	}
+	if _, err := s.redis.GetSessionState(context.Background(), testSessionID); err == nil {
+		t.Fatal("state of the duplicate was kept")
+	}
+}
+
+func TestTaskSessionStatus(t *testing.T) {
+	tests := []struct {
+		state  asynq.TaskState
+		status types.SessionStatus
+		err    string
+	}{
+		{asynq.TaskStatePending, types.SessionQueued, ""},
+		{asynq.TaskStateScheduled, types.SessionQueued, ""},
+		{asynq.TaskStateRetry, types.SessionQueued, ""},
+		{asynq.TaskStateActive, types.SessionRunning, ""},
+		{asynq.TaskStateCompleted, types.SessionCompleted, ""},
+		{asynq.TaskStateArchived, types.SessionFailed, "keygen failed"},
+	}
+	for _, test := range tests {
+		status, errMsg := taskSessionStatus(&asynq.TaskInfo{State: test.state, LastErr: "keygen failed"})
+		if status != test.status || errMsg != test.err {
+			t.Errorf("%s: expected %s %q, got %s %q", test.state, test.status, test.err, status, errMsg)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/labstack/echo/v4"
+
+	"github.com/vultisig/vultisigner/common"
+	"github.com/vultisig/vultisigner/internal/tasks"
+	"github.com/vultisig/vultisigner/internal/types"
+	"github.com/vultisig/vultisigner/storage"
+)
+
+const (
+	defaultVaultQueryLimit = 100
+	emailVerificationTTL   = 15 * time.Minute
+)
+
+// adminAuthMiddleware only lets requests with the admin bearer token through , admin endpoints are disabled without a token
+func (s *Server) adminAuthMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if s.adminToken == "" {
+			return c.NoContent(http.StatusForbidden)
+		}
+		token, ok := strings.CutPrefix(c.Request().Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) != 1 {
+			return c.NoContent(http.StatusUnauthorized)
+		}
+		return next(c)
+	}
+}
+
+// QueryVaults is a handler to list the vault metadata matching the query parameters
+func (s *Server) QueryVaults(c echo.Context) error {
+	query := types.VaultMetadataQuery{
+		EmailHash: c.QueryParam("email_hash"),
+		Limit:     defaultVaultQueryLimit,
+	}
+	if email := c.QueryParam("email"); email != "" {
+		query.EmailHash = common.HashEmail(s.emailSalt, email)
+	}
+	if value := c.QueryParam("lib_type"); value != "" {
+		libType, err := strconv.Atoi(value)
+		if err != nil {
+			return c.NoContent(http.StatusBadRequest)
+		}
+		query.LibType = (*types.LibType)(&libType)
+	}
+	if value := c.QueryParam("unused_days"); value != "" {
+		days, err := strconv.Atoi(value)
+		if err != nil || days < 0 {
+			return c.NoContent(http.StatusBadRequest)
+		}
+		query.UnusedSince = time.Now().AddDate(0, 0, -days)
+	}
+	if value := c.QueryParam("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return c.NoContent(http.StatusBadRequest)
+		}
+		query.Limit = limit
+	}
+	result, err := s.redis.QueryVaultMetadata(c.Request().Context(), query)
+	if err != nil {
+		return fmt.Errorf("fail to query vault index, err: %w", err)
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+// GetVaultStats is a handler to count the vaults in the index
+func (s *Server) GetVaultStats(c echo.Context) error {
+	stats, err := s.redis.VaultIndexStats(c.Request().Context())
+	if err != nil {
+		return fmt.Errorf("fail to get vault index stats, err: %w", err)
+	}
+	return c.JSON(http.StatusOK, stats)
+}
+
+// GetVaultMetadata is a handler to get the metadata of one vault
+func (s *Server) GetVaultMetadata(c echo.Context) error {
+	publicKeyECDSA := c.Param("publicKeyECDSA")
+	if !s.isValidHash(publicKeyECDSA) {
+		return c.NoContent(http.StatusBadRequest)
+	}
+	metadata, err := s.redis.GetVaultMetadata(c.Request().Context(), publicKeyECDSA)
+	if errors.Is(err, storage.ErrVaultMetadataNotFound) {
+		return c.NoContent(http.StatusNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("fail to get vault metadata, err: %w", err)
+	}
+	return c.JSON(http.StatusOK, metadata)
+}
+
+// ResendVaultsByEmail is a handler to email a verification code to the owner of the email , the code confirms the resend.
+// The response never tells whether the email has vaults.
+func (s *Server) ResendVaultsByEmail(c echo.Context) error {
+	var req types.VaultEmailLookupRequest
+	if err := c.Bind(&req); err != nil {
+		return fmt.Errorf("fail to parse request, err: %w", err)
+	}
+	if strings.TrimSpace(req.Email) == "" {
+		return c.NoContent(http.StatusBadRequest)
+	}
+	ctx := c.Request().Context()
+	emailHash := common.HashEmail(s.emailSalt, req.Email)
+	key := fmt.Sprintf("resend_email_%s", emailHash)
+	ok, err := s.redis.SetNX(ctx, key, key, 3*time.Minute)
+	if err != nil {
+		return fmt.Errorf("fail to set rate limit, err: %w", err)
+	}
+	if !ok {
+		return c.NoContent(http.StatusTooManyRequests)
+	}
+	if err := s.sdClient.Count("vault.resend_by_email", 1, nil, 1); err != nil {
+		s.logger.Errorf("fail to count metric, err: %v", err)
+	}
+	vaults, err := s.redis.QueryVaultMetadata(ctx, types.VaultMetadataQuery{EmailHash: emailHash})
+	if err != nil {
+		return fmt.Errorf("fail to query vault index, err: %w", err)
+	}
+	if len(vaults) == 0 {
+		return c.NoContent(http.StatusOK)
+	}
+	code, err := newEmailVerificationCode()
+	if err != nil {
+		return err
+	}
+	if err := s.redis.CreateEmailVerification(ctx, emailHash, code, emailVerificationTTL); err != nil {
+		return fmt.Errorf("fail to save email verification code, err: %w", err)
+	}
+	names := make([]string, 0, len(vaults))
+	for _, vault := range vaults {
+		names = append(names, vault.Name)
+	}
+	// an email request without a vault share is a verification email
+	buf, err := json.Marshal(types.EmailRequest{
+		Email:     req.Email,
+		VaultName: strings.Join(names, ", "),
+		Code:      code,
+	})
+	if err != nil {
+		return fmt.Errorf("json.Marshal failed: %w", err)
+	}
+	if _, err := s.client.Enqueue(asynq.NewTask(tasks.TypeEmailVaultBackup, buf),
+		asynq.Retention(10*time.Minute),
+		asynq.Queue(tasks.EMAIL_QUEUE_NAME)); err != nil {
+		s.logger.Errorf("fail to enqueue email task: %v", err)
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+// ConfirmResendVaultsByEmail is a handler to email every vault share that was sent to the email again ,
+// once the requester proved with the emailed code that it owns the email
+func (s *Server) ConfirmResendVaultsByEmail(c echo.Context) error {
+	var req types.VaultEmailLookupRequest
+	if err := c.Bind(&req); err != nil {
+		return fmt.Errorf("fail to parse request, err: %w", err)
+	}
+	if strings.TrimSpace(req.Email) == "" || req.Code == "" {
+		return c.NoContent(http.StatusBadRequest)
+	}
+	ctx := c.Request().Context()
+	emailHash := common.HashEmail(s.emailSalt, req.Email)
+	err := s.redis.ClaimEmailVerification(ctx, emailHash, req.Code)
+	if errors.Is(err, storage.ErrEmailVerification) {
+		return c.NoContent(http.StatusUnauthorized)
+	}
+	if err != nil {
+		return fmt.Errorf("fail to check email verification code, err: %w", err)
+	}
+	vaults, err := s.redis.QueryVaultMetadata(ctx, types.VaultMetadataQuery{EmailHash: emailHash})
+	if err != nil {
+		return fmt.Errorf("fail to query vault index, err: %w", err)
+	}
+	for _, vault := range vaults {
+		content, err := s.blockStorage.GetFile(vault.PublicKeyEcdsa + ".bak")
+		if err != nil {
+			s.logger.Errorf("fail to read file, err: %v", err)
+			continue
+		}
+		code, err := s.createVerificationCode(ctx, vault.PublicKeyEcdsa)
+		if err != nil {
+			return fmt.Errorf("failed to create verification code: %w", err)
+		}
+		buf, err := json.Marshal(types.EmailRequest{
+			Email:          req.Email,
+			FileName:       vault.FileName,
+			FileContent:    string(content),
+			VaultName:      vault.Name,
+			Code:           code,
+			PGPFingerprint: vault.PGPFingerprint,
+		})
+		if err != nil {
+			return fmt.Errorf("json.Marshal failed: %w", err)
+		}
+		if _, err := s.client.Enqueue(asynq.NewTask(tasks.TypeEmailVaultBackup, buf),
+			asynq.Retention(10*time.Minute),
+			asynq.Queue(tasks.EMAIL_QUEUE_NAME)); err != nil {
+			s.logger.Errorf("fail to enqueue email task: %v", err)
+		}
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+// newEmailVerificationCode returns a random six digit code
+func newEmailVerificationCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", fmt.Errorf("rand.Int failed: %w", err)
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
@@ -0,0 +1,101 @@
+// Package summary describes a keysign payload in human readable form.
+// It only reads the payload fields , so unlike chainhelper it doesn't need walletcore , and the worker can use it without cgo.
+package summary
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"strings"
+
+	v1 "github.com/vultisig/commondata/go/vultisig/keysign/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// Summary is what a keysign payload says it does , as described by the signing device
+type Summary struct {
+	Chain     string `json:"chain"`
+	Ticker    string `json:"ticker"`
+	From      string `json:"from"`
+	ToAddress string `json:"to_address"`
+	Amount    string `json:"amount"`         // in units of the coin , like 1.5
+	Fee       string `json:"fee,omitempty"`  // in the unit of the chain , like 21 sats/vbyte
+	Memo      string `json:"memo,omitempty"` // memo of the transaction
+	Action    string `json:"action"`         // send , swap or approve
+}
+
+// Decode summarizes a base64 encoded vultisig.keysign.v1.KeysignPayload
+func Decode(payload string) (*Summary, error) {
+	buf, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode keysign payload: %w", err)
+	}
+	var keysignPayload v1.KeysignPayload
+	if err := proto.Unmarshal(buf, &keysignPayload); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal keysign payload: %w", err)
+	}
+	return FromPayload(&keysignPayload), nil
+}
+
+// FromPayload summarizes the keysign payload
+func FromPayload(payload *v1.KeysignPayload) *Summary {
+	coin := payload.GetCoin()
+	result := &Summary{
+		Chain:     coin.GetChain(),
+		Ticker:    coin.GetTicker(),
+		From:      coin.GetAddress(),
+		ToAddress: payload.GetToAddress(),
+		Amount:    FormatUnits(payload.GetToAmount(), coin.GetDecimals()),
+		Memo:      payload.GetMemo(),
+		Action:    "send",
+	}
+	switch {
+	case payload.GetSwapPayload() != nil:
+		result.Action = "swap"
+	case payload.GetErc20ApprovePayload() != nil:
+		result.Action = "approve"
+	}
+	nativeTicker := "native"
+	if coin.GetIsNativeToken() {
+		nativeTicker = coin.GetTicker()
+	}
+	switch specific := payload.GetBlockchainSpecific().(type) {
+	case *v1.KeysignPayload_EthereumSpecific:
+		maxFee, ok1 := new(big.Int).SetString(specific.EthereumSpecific.GetMaxFeePerGasWei(), 10)
+		gasLimit, ok2 := new(big.Int).SetString(specific.EthereumSpecific.GetGasLimit(), 10)
+		if ok1 && ok2 {
+			result.Fee = fmt.Sprintf("max %s %s", FormatUnits(new(big.Int).Mul(maxFee, gasLimit).String(), 18), nativeTicker)
+		}
+	case *v1.KeysignPayload_UtxoSpecific:
+		result.Fee = specific.UtxoSpecific.GetByteFee() + " sats/vbyte"
+	case *v1.KeysignPayload_ThorchainSpecific:
+		result.Fee = FormatUnits(fmt.Sprint(specific.ThorchainSpecific.GetFee()), 8) + " RUNE"
+	case *v1.KeysignPayload_CosmosSpecific:
+		result.Fee = FormatUnits(fmt.Sprint(specific.CosmosSpecific.GetGas()), coin.GetDecimals()) + " " + nativeTicker
+	case *v1.KeysignPayload_SolanaSpecific:
+		result.Fee = specific.SolanaSpecific.GetPriorityFee() + " micro-lamports priority fee"
+	}
+	return result
+}
+
+// FormatUnits formats an integer amount of the smallest unit as decimal , it returns the value unchanged when it isn't an integer
+func FormatUnits(value string, decimals int32) string {
+	amount, ok := new(big.Int).SetString(value, 10)
+	if !ok || decimals <= 0 {
+		return value
+	}
+	negative := amount.Sign() < 0
+	digits := new(big.Int).Abs(amount).String()
+	if len(digits) <= int(decimals) {
+		digits = strings.Repeat("0", int(decimals)-len(digits)+1) + digits
+	}
+	whole, fraction := digits[:len(digits)-int(decimals)], strings.TrimRight(digits[len(digits)-int(decimals):], "0")
+	result := whole
+	if fraction != "" {
+		result += "." + fraction
+	}
+	if negative {
+		result = "-" + result
+	}
+	return result
+}
@@ -0,0 +1,58 @@
+package summary
+
+import (
+	"encoding/base64"
+	"testing"
+
+	v1 "github.com/vultisig/commondata/go/vultisig/keysign/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestFormatUnits(t *testing.T) {
+	for _, tc := range []struct {
+		value    string
+		decimals int32
+		expected string
+	}{
+		{"10000000", 18, "0.00000000001"},
+		{"1500000000000000000", 18, "1.5"},
+		{"100000000", 8, "1"},
+		{"0", 8, "0"},
+		{"abc", 8, "abc"},
+	} {
+		if result := FormatUnits(tc.value, tc.decimals); result != tc.expected {
+			t.Fatalf("FormatUnits(%s, %d): %s, expected: %s", tc.value, tc.decimals, result, tc.expected)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	payload := &v1.KeysignPayload{
+		Coin: &v1.Coin{
+			Chain:         "Ethereum",
+			Ticker:        "ETH",
+			Decimals:      18,
+			Address:       "0xe5F238C95142be312852e864B830daADB9B7D290",
+			IsNativeToken: true,
+		},
+		ToAddress: "0xfA0635a1d083D0bF377EFbD48DA46BB17e0106cA",
+		ToAmount:  "1500000000000000000",
+		BlockchainSpecific: &v1.KeysignPayload_EthereumSpecific{
+			EthereumSpecific: &v1.EthereumSpecific{
+				MaxFeePerGasWei: "10000000000",
+				GasLimit:        "21000",
+			},
+		},
+	}
+	buf, err := proto.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := Decode(base64.StdEncoding.EncodeToString(buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Amount != "1.5" || result.Fee != "max 0.00021 ETH" || result.Action != "send" {
+		t.Fatalf("unexpected summary: %+v", result)
+	}
+}
@@ -0,0 +1,109 @@
+// vault-recover reconstructs the private keys of a vault from threshold many .vult backups.
+// It works fully offline , run it on an air-gapped machine.
+//
+//	vault-recover -chain ethereum share1.vult share2.vult
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	vaultType "github.com/vultisig/commondata/go/vultisig/vault/v1"
+
+	"github.com/vultisig/vultisigner/common"
+	"github.com/vultisig/vultisigner/recovery"
+	"github.com/vultisig/vultisigner/recovery/dkls"
+)
+
+func main() {
+	chain := flag.String("chain", "", "chain to derive the key and address for: bitcoin, ethereum, thorchain, cosmos, solana , empty prints the root keys only")
+	derivePath := flag.String("path", "", "derive path , defaults to the path the vultisig apps use for the chain")
+	threshold := flag.Int("threshold", 0, "number of parties required to sign , the vault shares don't carry it so 0 means the default threshold of the signers")
+	sign := flag.String("sign", "", "hex encoded message to sign with the solana key , wallets can't import the solana key of a vault")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [-chain chain] [-path path] [-threshold n] [-sign message] share.vult...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *sign != "" && recovery.Chain(*chain) != recovery.Solana {
+		fmt.Fprintln(os.Stderr, "-sign requires -chain solana")
+		os.Exit(2)
+	}
+	if err := run(flag.Args(), recovery.Chain(*chain), *derivePath, *threshold, *sign); err != nil {
+		fmt.Fprintln(os.Stderr, "recovery failed:", err)
+		os.Exit(1)
+	}
+}
+
+func run(files []string, chain recovery.Chain, derivePath string, threshold int, sign string) error {
+	reader := bufio.NewReader(os.Stdin)
+	vaults := make([]*vaultType.Vault, 0, len(files))
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		fmt.Fprintf(os.Stderr, "password for %s (empty if not encrypted): ", file)
+		password, err := reader.ReadString('\n')
+		if err != nil && password == "" {
+			return fmt.Errorf("failed to read password: %w", err)
+		}
+		vault, err := common.DecryptVaultFromBackup(strings.TrimRight(password, "\r\n"), content)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt %s: %w", file, err)
+		}
+		vaults = append(vaults, vault)
+	}
+	threshold, err := recovery.CheckShares(vaults, threshold)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("vault: %s , %d of %d shares , threshold %d\n", vaults[0].Name, len(vaults), len(vaults[0].Signers), threshold)
+	keys, err := recovery.Recover(vaults, threshold, dkls.Export)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("ECDSA public key: %s\n", keys.PublicKeyEcdsa)
+	fmt.Printf("ECDSA private key: %s\n", hex.EncodeToString(keys.ECDSA))
+	fmt.Printf("EdDSA public key: %s\n", keys.PublicKeyEddsa)
+	fmt.Printf("EdDSA private key: %s\n", hex.EncodeToString(keys.EdDSA))
+	fmt.Printf("chain code: %s\n", hex.EncodeToString(keys.ChainCode))
+	if chain == "" {
+		return nil
+	}
+	if derivePath == "" {
+		derivePath, err = recovery.DefaultDerivePath(chain)
+		if err != nil {
+			return err
+		}
+	}
+	derived, err := keys.Derive(chain, derivePath)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s derive path: %s\n", derived.Chain, derived.DerivePath)
+	fmt.Printf("%s private key: %s\n", derived.Chain, derived.PrivateKey)
+	fmt.Printf("%s public key: %s\n", derived.Chain, derived.PublicKey)
+	fmt.Printf("%s address: %s\n", derived.Chain, derived.Address)
+	if sign == "" {
+		return nil
+	}
+	message, err := hex.DecodeString(sign)
+	if err != nil {
+		return fmt.Errorf("invalid message to sign: %w", err)
+	}
+	signature, err := keys.SignEdDSA(message)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s signature: %s\n", derived.Chain, hex.EncodeToString(signature))
+	return nil
+}
@@ -0,0 +1,193 @@
+// vault-tool inspects , verifies and re-encrypts .bak and .vult vault containers.
+//
+//	vault-tool info share.vult
+//	vault-tool verify share.vult
+//	vault-tool reencrypt -out new.vult [-version 1] share.vult
+//	vault-tool json [-secrets] share.vult
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	vaultType "github.com/vultisig/commondata/go/vultisig/vault/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/vultisig/vultisigner/common"
+	"github.com/vultisig/vultisigner/service"
+)
+
+var stdin = bufio.NewReader(os.Stdin)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	var err error
+	switch os.Args[1] {
+	case "info":
+		err = info(os.Args[2:])
+	case "verify":
+		err = verify(os.Args[2:])
+	case "reencrypt":
+		err = reencrypt(os.Args[2:])
+	case "json":
+		err = toJSON(os.Args[2:])
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s info|verify|reencrypt|json [flags] file\n", os.Args[0])
+}
+
+func info(args []string) error {
+	flags := flag.NewFlagSet("info", flag.ExitOnError)
+	_ = flags.Parse(args)
+	container, vault, err := load(flags)
+	if err != nil {
+		return err
+	}
+	// the vault share doesn't carry the threshold , the server keeps it with the vault settings
+	threshold, err := common.DefaultThreshold(len(vault.Signers))
+	if err != nil {
+		return fmt.Errorf("failed to get default threshold: %w", err)
+	}
+	fmt.Printf("container version: %d\n", container.Version)
+	fmt.Printf("encrypted: %t\n", container.IsEncrypted)
+	fmt.Printf("name: %s\n", vault.Name)
+	fmt.Printf("public key ecdsa: %s\n", vault.PublicKeyEcdsa)
+	fmt.Printf("public key eddsa: %s\n", vault.PublicKeyEddsa)
+	fmt.Printf("hex chain code: %s\n", vault.HexChainCode)
+	fmt.Printf("signers: %s\n", strings.Join(vault.Signers, ", "))
+	fmt.Printf("default threshold: %d\n", threshold)
+	fmt.Printf("local party id: %s\n", vault.LocalPartyId)
+	fmt.Printf("lib type: %s\n", vault.LibType)
+	if vault.CreatedAt != nil {
+		fmt.Printf("created at: %s\n", vault.CreatedAt.AsTime().Format(time.RFC3339))
+	}
+	fmt.Printf("reshare prefix: %s\n", vault.ResharePrefix)
+	return nil
+}
+
+func verify(args []string) error {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	_ = flags.Parse(args)
+	_, vault, err := load(flags)
+	if err != nil {
+		return err
+	}
+	if err := service.VerifyVaultKeyshares(vault); err != nil {
+		return fmt.Errorf("verification failed: %w", err)
+	}
+	fmt.Printf("%d keyshares match the vault public keys\n", len(vault.KeyShares))
+	return nil
+}
+
+func reencrypt(args []string) error {
+	flags := flag.NewFlagSet("reencrypt", flag.ExitOnError)
+	out := flags.String("out", "", "file to write the re-encrypted container to")
+	version := flags.Uint64("version", 0, "container version , defaults to the version of the input container")
+	_ = flags.Parse(args)
+	if *out == "" {
+		return errors.New("-out is required")
+	}
+	container, vault, err := load(flags)
+	if err != nil {
+		return err
+	}
+	newPassword, err := prompt("new password: ")
+	if err != nil {
+		return err
+	}
+	confirm, err := prompt("confirm new password: ")
+	if err != nil {
+		return err
+	}
+	if newPassword != confirm {
+		return errors.New("passwords don't match")
+	}
+	if *version == 0 {
+		*version = container.Version
+	}
+	content, err := common.EncryptVaultToBackup(newPassword, *version, vault)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(*out, content, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", *out, err)
+	}
+	return nil
+}
+
+func toJSON(args []string) error {
+	flags := flag.NewFlagSet("json", flag.ExitOnError)
+	secrets := flags.Bool("secrets", false, "include the keyshares , they are redacted by default")
+	_ = flags.Parse(args)
+	_, vault, err := load(flags)
+	if err != nil {
+		return err
+	}
+	if !*secrets {
+		vault = proto.Clone(vault).(*vaultType.Vault)
+		for _, item := range vault.KeyShares {
+			item.Keyshare = "<redacted>"
+		}
+	}
+	buf, err := protojson.MarshalOptions{Multiline: true}.Marshal(vault)
+	if err != nil {
+		return fmt.Errorf("failed to marshal vault: %w", err)
+	}
+	fmt.Println(string(buf))
+	return nil
+}
+
+// load reads the container named by the only argument , and decrypts the vault in it
+func load(flags *flag.FlagSet) (*vaultType.VaultContainer, *vaultType.Vault, error) {
+	if flags.NArg() != 1 {
+		return nil, nil, fmt.Errorf("%s expects one file", flags.Name())
+	}
+	file := flags.Arg(0)
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	container, err := common.DecodeVaultContainer(content)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode %s: %w", file, err)
+	}
+	var password string
+	if container.IsEncrypted {
+		password, err = prompt(fmt.Sprintf("password for %s: ", file))
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	vault, err := common.DecryptVaultFromBackup(password, content)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decrypt %s: %w", file, err)
+	}
+	return container, vault, nil
+}
+
+func prompt(message string) (string, error) {
+	fmt.Fprint(os.Stderr, message)
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("failed to read from stdin: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
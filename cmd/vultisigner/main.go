@@ -1,12 +1,15 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	"github.com/DataDog/datadog-go/statsd"
 	"github.com/hibiken/asynq"
 
 	"github.com/vultisig/vultisigner/api"
+	"github.com/vultisig/vultisigner/common"
 	"github.com/vultisig/vultisigner/config"
 	"github.com/vultisig/vultisigner/storage"
 )
@@ -16,6 +19,7 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
+	common.LockSecretMemory(cfg.Security.LockMemory)
 
 	sdClient, err := statsd.New("127.0.0.1:8125")
 	if err != nil {
@@ -27,6 +31,10 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
+	emailSalt, err := redisStorage.EmailSalt(context.Background())
+	if err != nil {
+		panic(err)
+	}
 	redisOptions := asynq.RedisClientOpt{
 		Addr:     cfg.Redis.Host + ":" + cfg.Redis.Port,
 		Username: cfg.Redis.User,
@@ -48,11 +56,17 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
+	vaultCache, err := storage.NewVaultCache(blockStorage, redisStorage, sdClient,
+		time.Duration(cfg.VaultCache.TTLSeconds)*time.Second, cfg.VaultCache.MaxEntries)
+	if err != nil {
+		panic(err)
+	}
 	server := api.NewServer(port,
 		redisStorage,
 		client,
 		inspector,
-		cfg.Server.VaultsFilePath, sdClient, blockStorage)
+		cfg.Server.VaultsFilePath, sdClient, blockStorage,
+		cfg.Admin.Token, emailSalt, cfg.Queues, vaultCache)
 	if err := server.StartServer(); err != nil {
 		panic(err)
 	}
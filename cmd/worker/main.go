@@ -1,14 +1,22 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/DataDog/datadog-go/statsd"
 	"github.com/hibiken/asynq"
 	"github.com/sirupsen/logrus"
 
+	"github.com/vultisig/vultisigner/common"
 	"github.com/vultisig/vultisigner/config"
+	"github.com/vultisig/vultisigner/internal/protocol"
 	"github.com/vultisig/vultisigner/internal/tasks"
+	"github.com/vultisig/vultisigner/relay"
 	"github.com/vultisig/vultisigner/service"
 	"github.com/vultisig/vultisigner/storage"
 )
@@ -18,6 +26,7 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
+	common.LockSecretMemory(cfg.Security.LockMemory)
 	sdClient, err := statsd.New("127.0.0.1:8125")
 	if err != nil {
 		panic(err)
@@ -37,30 +46,54 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
+	// sessions move to joined and running as the relay reports them
+	relay.SetSessionObserver(workerServce)
 
-	srv := asynq.NewServer(
+	// mux maps a type to a handler
+	mux := asynq.NewServeMux()
+	for _, typeName := range protocol.TaskTypes() {
+		mux.HandleFunc(typeName, workerServce.HandleProtocolTask)
+	}
+	mux.HandleFunc(tasks.TypeEmailVaultBackup, workerServce.HandleEmailVaultBackup)
+	mux.HandleFunc(tasks.TypeRefreshReminder, workerServce.HandleRefreshReminder)
+	mux.HandleFunc(tasks.TypeKeysignNotify, workerServce.HandleKeysignNotification)
+	mux.HandleFunc(tasks.TypeBackupUpload, workerServce.HandleBackupUpload)
+	mux.HandleFunc(tasks.TypePromotePendingVault, workerServce.HandlePromotePendingVault)
+	mux.HandleFunc(tasks.TypeExpirePendingVault, workerServce.HandleExpirePendingVault)
+
+	// every operation type has its own pool , so a burst of keygen can't take the workers of keysign.
+	// The default pool serves the email queue , the default queue , which drains tasks enqueued before the operation queues ,
+	// and the operation queues with a weight.
+	var servers []*asynq.Server
+	for _, pool := range service.WorkerPools(cfg.Queues) {
+		logrus.Infof("worker pool %s runs %d workers on queues %v", pool.Name, pool.Concurrency, pool.Queues)
+		servers = append(servers, newServer(redisOptions, pool.Concurrency, pool.Queues))
+	}
+	for _, srv := range servers {
+		if err := srv.Start(mux); err != nil {
+			panic(fmt.Errorf("could not run server: %w", err))
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go workerServce.ReportQueueMetrics(ctx, asynq.NewInspector(redisOptions), 10*time.Second)
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM, syscall.SIGINT)
+	<-sigs
+	cancel()
+	for _, srv := range servers {
+		srv.Shutdown()
+	}
+}
+
+func newServer(redisOptions asynq.RedisClientOpt, concurrency int, queues map[string]int) *asynq.Server {
+	return asynq.NewServer(
 		redisOptions,
 		asynq.Config{
 			Logger:      logrus.StandardLogger(),
-			Concurrency: 10,
-			Queues: map[string]int{
-				tasks.QUEUE_NAME:       10,
-				tasks.EMAIL_QUEUE_NAME: 100,
-			},
+			Concurrency: concurrency,
+			Queues:      queues,
 		},
 	)
-
-	// mux maps a type to a handler
-	mux := asynq.NewServeMux()
-	mux.HandleFunc(tasks.TypeKeyGeneration, workerServce.HandleKeyGeneration)
-	mux.HandleFunc(tasks.TypeKeySign, workerServce.HandleKeySign)
-	mux.HandleFunc(tasks.TypeEmailVaultBackup, workerServce.HandleEmailVaultBackup)
-	mux.HandleFunc(tasks.TypeReshare, workerServce.HandleReshare)
-	mux.HandleFunc(tasks.TypeKeyGenerationDKLS, workerServce.HandleKeyGenerationDKLS)
-	mux.HandleFunc(tasks.TypeKeySignDKLS, workerServce.HandleKeySignDKLS)
-	mux.HandleFunc(tasks.TypeReshareDKLS, workerServce.HandleReshareDKLS)
-	mux.HandleFunc(tasks.TypeMigrate, workerServce.HandleMigrateDKLS)
-	if err := srv.Run(mux); err != nil {
-		panic(fmt.Errorf("could not run server: %w", err))
-	}
 }
@@ -0,0 +1,15 @@
+package common
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// HashEmail returns the salted hash of the email saved in the vault index , the email is normalized first
+func HashEmail(salt, email string) string {
+	mac := hmac.New(sha256.New, []byte(salt))
+	mac.Write([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return hex.EncodeToString(mac.Sum(nil))
+}
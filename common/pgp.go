@@ -0,0 +1,69 @@
+package common
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+)
+
+// ParsePGPPublicKey parses an ASCII-armored OpenPGP public key , the key must have exactly one entity that can encrypt
+func ParsePGPPublicKey(armored string) (*openpgp.Entity, error) {
+	entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(armored))
+	if err != nil {
+		return nil, fmt.Errorf("openpgp.ReadArmoredKeyRing failed: %w", err)
+	}
+	if len(entities) != 1 {
+		return nil, fmt.Errorf("expected one OpenPGP key , got %d", len(entities))
+	}
+	entity := entities[0]
+	if entity.PrivateKey != nil {
+		return nil, errors.New("OpenPGP key is a private key")
+	}
+	// openpgp doesn't expose whether the key has a usable encryption subkey , so try to encrypt
+	w, err := openpgp.Encrypt(io.Discard, entities, nil, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("OpenPGP key can't encrypt: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("OpenPGP key can't encrypt: %w", err)
+	}
+	return entity, nil
+}
+
+// PGPFingerprint returns the upper case hex fingerprint of the primary key
+func PGPFingerprint(entity *openpgp.Entity) string {
+	return strings.ToUpper(hex.EncodeToString(entity.PrimaryKey.Fingerprint))
+}
+
+// EncryptPGP encrypts the content to the ASCII-armored public key , and returns an ASCII-armored OpenPGP message
+func EncryptPGP(armoredPublicKey string, fileName string, content []byte) ([]byte, error) {
+	entity, err := ParsePGPPublicKey(armoredPublicKey)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	armorWriter, err := armor.Encode(&buf, "PGP MESSAGE", nil)
+	if err != nil {
+		return nil, fmt.Errorf("armor.Encode failed: %w", err)
+	}
+	w, err := openpgp.Encrypt(armorWriter, openpgp.EntityList{entity}, nil, &openpgp.FileHints{IsBinary: true, FileName: fileName}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("openpgp.Encrypt failed: %w", err)
+	}
+	if _, err := w.Write(content); err != nil {
+		return nil, fmt.Errorf("fail to write OpenPGP message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("fail to close OpenPGP message: %w", err)
+	}
+	if err := armorWriter.Close(); err != nil {
+		return nil, fmt.Errorf("fail to close armor: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,60 @@
+package common
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+)
+
+func TestEncryptPGP(t *testing.T) {
+	entity, err := openpgp.NewEntity("vault owner", "", "owner@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var publicKey bytes.Buffer
+	w, err := armor.Encode(&publicKey, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := entity.Serialize(w); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParsePGPPublicKey(publicKey.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if PGPFingerprint(parsed) != PGPFingerprint(entity) {
+		t.Fatalf("fingerprint mismatch: %s , expected: %s", PGPFingerprint(parsed), PGPFingerprint(entity))
+	}
+
+	content := []byte("vault backup")
+	encrypted, err := EncryptPGP(publicKey.String(), "vault.vult", content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := armor.Decode(bytes.NewReader(encrypted))
+	if err != nil {
+		t.Fatal(err)
+	}
+	md, err := openpgp.ReadMessage(block.Body, openpgp.EntityList{entity}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted, err := io.ReadAll(md.UnverifiedBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted, content) {
+		t.Fatalf("decrypted %q , expected: %q", decrypted, content)
+	}
+
+	if _, err := ParsePGPPublicKey("not a key"); err == nil {
+		t.Fatal("expected error for an invalid key")
+	}
+}
@@ -0,0 +1,62 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// ErrNonPublicAddress is returned when a user supplied url resolves to an internal address
+var ErrNonPublicAddress = errors.New("address is not public")
+
+// sharedAddressSpace is the carrier grade NAT range , netip doesn't count it as private
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// IsPublicAddress reports whether the address can be reached from the internet ,
+// loopback , private , link local , multicast and unspecified addresses are not
+func IsPublicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsValid() &&
+		addr.IsGlobalUnicast() &&
+		!addr.IsPrivate() &&
+		!sharedAddressSpace.Contains(addr)
+}
+
+// NewPublicHTTPClient returns the http client for user supplied urls , like webhooks and backup uploads.
+// It only connects to public addresses , checked after the name is resolved so DNS can't point it inside ,
+// doesn't follow redirects and ignores the proxy settings of the environment.
+func NewPublicHTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return fmt.Errorf("invalid address %s: %w", address, err)
+			}
+			if !IsPublicAddress(addrPort.Addr()) {
+				return fmt.Errorf("%s: %w", addrPort.Addr(), ErrNonPublicAddress)
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy: nil,
+			DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, address)
+			},
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
@@ -0,0 +1,59 @@
+package common
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestIsPublicAddress(t *testing.T) {
+	tests := map[string]bool{
+		"8.8.8.8":                true,
+		"2606:4700::1111":        true,
+		"127.0.0.1":              false,
+		"10.1.2.3":               false,
+		"172.16.0.1":             false,
+		"192.168.1.1":            false,
+		"169.254.169.254":        false,
+		"100.64.0.1":             false,
+		"0.0.0.0":                false,
+		"224.0.0.1":              false,
+		"::1":                    false,
+		"fd00::1":                false,
+		"fe80::1":                false,
+		"::ffff:127.0.0.1":       false,
+		"::ffff:169.254.169.254": false,
+	}
+	for address, expected := range tests {
+		if got := IsPublicAddress(netip.MustParseAddr(address)); got != expected {
+			t.Errorf("%s: expected %v , got %v", address, expected, got)
+		}
+	}
+}
+
+func TestPublicHTTPClientRejectsLoopback(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+	client := NewPublicHTTPClient(time.Second)
+	_, err := client.Get(server.URL)
+	if !errors.Is(err, ErrNonPublicAddress) {
+		t.Fatalf("expected ErrNonPublicAddress , got %v", err)
+	}
+	if called {
+		t.Fatal("the request reached the loopback server")
+	}
+}
+
+func TestPublicHTTPClientDoesNotFollowRedirects(t *testing.T) {
+	client := NewPublicHTTPClient(time.Second)
+	req := httptest.NewRequest(http.MethodGet, "https://example.com", nil)
+	if err := client.CheckRedirect(req, []*http.Request{req}); !errors.Is(err, http.ErrUseLastResponse) {
+		t.Fatalf("redirect followed: %v", err)
+	}
+}
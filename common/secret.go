@@ -0,0 +1,246 @@
+package common
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"runtime"
+	"sync/atomic"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+)
+
+var lockSecretMemory atomic.Bool
+
+// LockSecretMemory allocates the secrets created afterwards in locked memory , so key material is never swapped to disk.
+// A secret falls back to the go heap when the memory can't be locked , like when RLIMIT_MEMLOCK is too low.
+func LockSecretMemory(enabled bool) {
+	lockSecretMemory.Store(enabled)
+}
+
+// Secret is a buffer of key material , Wipe zeroes it once it's no longer needed.
+// Unlike a string , the buffer is owned by the secret and can be wiped , copies of Bytes must be wiped by the caller.
+type Secret struct {
+	buf    []byte
+	locked bool
+}
+
+// NewSecret allocates a secret of the size
+func NewSecret(size int) *Secret {
+	if size > 0 && lockSecretMemory.Load() {
+		buf, err := allocLocked(size)
+		if err == nil {
+			return &Secret{buf: buf, locked: true}
+		}
+		logrus.Warnf("fail to allocate locked memory , the secret is kept on the heap: %v", err)
+	}
+	return &Secret{buf: make([]byte, size)}
+}
+
+// SecretFromBytes moves buf into a new secret , buf is wiped
+func SecretFromBytes(buf []byte) *Secret {
+	s := NewSecret(len(buf))
+	copy(s.buf, buf)
+	Wipe(buf)
+	return s
+}
+
+// DecodeBase64Secret decodes the base64 encoded key material into a new secret
+func DecodeBase64Secret(encoded string) (*Secret, error) {
+	src := []byte(encoded)
+	defer Wipe(src)
+	return decodeBase64Secret(src)
+}
+
+// DecodeBase64 decodes the base64 encoded key material of the secret into a new secret
+func (s *Secret) DecodeBase64() (*Secret, error) {
+	return decodeBase64Secret(s.Bytes())
+}
+
+func decodeBase64Secret(src []byte) (*Secret, error) {
+	s := NewSecret(base64.StdEncoding.DecodedLen(len(src)))
+	n, err := base64.StdEncoding.Decode(s.buf, src)
+	if err != nil {
+		s.Wipe()
+		return nil, err
+	}
+	s.buf = s.buf[:n]
+	return s, nil
+}
+
+// DecodeHexSecret decodes the hex encoded key material into a new secret
+func DecodeHexSecret(encoded string) (*Secret, error) {
+	src := []byte(encoded)
+	defer Wipe(src)
+	s := NewSecret(hex.DecodedLen(len(src)))
+	if _, err := hex.Decode(s.buf, src); err != nil {
+		s.Wipe()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Bytes returns the key material , it's only valid until Wipe
+func (s *Secret) Bytes() []byte {
+	if s == nil {
+		return nil
+	}
+	return s.buf
+}
+
+// Len returns the length of the key material
+func (s *Secret) Len() int {
+	return len(s.Bytes())
+}
+
+// EncodeBase64 returns the base64 encoding of the key material in a buffer the caller wipes
+func (s *Secret) EncodeBase64() []byte {
+	encoded := make([]byte, base64.StdEncoding.EncodedLen(s.Len()))
+	base64.StdEncoding.Encode(encoded, s.Bytes())
+	return encoded
+}
+
+// Wipe zeroes the key material and releases the locked memory , it's safe to call more than once
+func (s *Secret) Wipe() {
+	if s == nil || s.buf == nil {
+		return
+	}
+	Wipe(s.buf)
+	if s.locked {
+		if err := freeLocked(s.buf[:cap(s.buf)]); err != nil {
+			logrus.Errorf("fail to free locked memory: %v", err)
+		}
+	}
+	s.buf = nil
+	s.locked = false
+}
+
+// NewSecretFromString copies the string into a new secret , for key material that already is a string like a header
+func NewSecretFromString(value string) *Secret {
+	s := NewSecret(len(value))
+	copy(s.buf, value)
+	return s
+}
+
+// String hides the key material , so a secret is never logged
+func (s *Secret) String() string {
+	return "[secret]"
+}
+
+// MarshalJSON encodes the key material as a JSON string , without copying it into a go string
+func (s *Secret) MarshalJSON() ([]byte, error) {
+	const hexDigits = "0123456789abcdef"
+	// the buffer never grows , every byte takes at most 6 bytes escaped
+	buf := make([]byte, 0, 6*s.Len()+2)
+	buf = append(buf, '"')
+	for _, c := range s.Bytes() {
+		switch {
+		case c == '"' || c == '\\':
+			buf = append(buf, '\\', c)
+		case c < 0x20:
+			buf = append(buf, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xf])
+		default:
+			buf = append(buf, c)
+		}
+	}
+	return append(buf, '"'), nil
+}
+
+// UnmarshalJSON decodes a JSON string into the secret , without copying it into a go string
+func (s *Secret) UnmarshalJSON(data []byte) error {
+	if len(data) < 2 || data[0] != '"' || data[len(data)-1] != '"' {
+		return errors.New("secret must be a JSON string")
+	}
+	// the unescaped string is never longer than the quoted one , so the buffer never grows
+	buf, err := appendUnquoted(make([]byte, 0, len(data)), data[1:len(data)-1])
+	if err != nil {
+		Wipe(buf)
+		return err
+	}
+	s.Wipe()
+	*s = *SecretFromBytes(buf)
+	return nil
+}
+
+// appendUnquoted appends the unescaped content of a JSON string to buf
+func appendUnquoted(buf, quoted []byte) ([]byte, error) {
+	for idx := 0; idx < len(quoted); idx++ {
+		c := quoted[idx]
+		if c != '\\' {
+			buf = append(buf, c)
+			continue
+		}
+		idx++
+		if idx == len(quoted) {
+			return buf, errors.New("invalid escape in JSON string")
+		}
+		switch quoted[idx] {
+		case '"', '\\', '/':
+			buf = append(buf, quoted[idx])
+		case 'b':
+			buf = append(buf, '\b')
+		case 'f':
+			buf = append(buf, '\f')
+		case 'n':
+			buf = append(buf, '\n')
+		case 'r':
+			buf = append(buf, '\r')
+		case 't':
+			buf = append(buf, '\t')
+		case 'u':
+			r, ok := decodeHexRune(quoted[idx+1:])
+			if !ok {
+				return buf, errors.New("invalid unicode escape in JSON string")
+			}
+			idx += 4
+			if utf16.IsSurrogate(r) {
+				// a surrogate pair is two escapes , an unpaired surrogate is invalid
+				pair := utf8.RuneError
+				if idx+6 < len(quoted) && quoted[idx+1] == '\\' && quoted[idx+2] == 'u' {
+					if r2, ok := decodeHexRune(quoted[idx+3:]); ok {
+						pair = utf16.DecodeRune(r, r2)
+					}
+				}
+				if pair != utf8.RuneError {
+					idx += 6
+				}
+				r = pair
+			}
+			buf = utf8.AppendRune(buf, r)
+		default:
+			return buf, errors.New("invalid escape in JSON string")
+		}
+	}
+	return buf, nil
+}
+
+// decodeHexRune decodes the 4 hex digits of a unicode escape
+func decodeHexRune(digits []byte) (rune, bool) {
+	if len(digits) < 4 {
+		return 0, false
+	}
+	var r rune
+	for _, c := range digits[:4] {
+		switch {
+		case '0' <= c && c <= '9':
+			c -= '0'
+		case 'a' <= c && c <= 'f':
+			c = c - 'a' + 10
+		case 'A' <= c && c <= 'F':
+			c = c - 'A' + 10
+		default:
+			return 0, false
+		}
+		r = r<<4 | rune(c)
+	}
+	return r, true
+}
+
+// Wipe zeroes the buffer
+func Wipe(buf []byte) {
+	clear(buf)
+	// keep the buffer alive until it's cleared , so the stores aren't dropped as dead
+	runtime.KeepAlive(buf)
+}
@@ -0,0 +1,28 @@
+//go:build linux || darwin
+
+package common
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// allocLocked maps anonymous memory outside the go heap and locks it , so it's never swapped or moved
+func allocLocked(size int) ([]byte, error) {
+	buf, err := syscall.Mmap(-1, 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	if err != nil {
+		return nil, fmt.Errorf("mmap failed: %w", err)
+	}
+	if err := syscall.Mlock(buf); err != nil {
+		_ = syscall.Munmap(buf)
+		return nil, fmt.Errorf("mlock failed: %w", err)
+	}
+	return buf, nil
+}
+
+func freeLocked(buf []byte) error {
+	if err := syscall.Munlock(buf); err != nil {
+		return fmt.Errorf("munlock failed: %w", err)
+	}
+	return syscall.Munmap(buf)
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin
+
+package common
+
+import "errors"
+
+func allocLocked(size int) ([]byte, error) {
+	return nil, errors.New("locked memory is not supported on this platform")
+}
+
+func freeLocked(buf []byte) error {
+	return nil
+}
@@ -0,0 +1,100 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestSecretWipe(t *testing.T) {
+	for _, locked := range []bool{false, true} {
+		LockSecretMemory(locked)
+		buf := []byte("keyshare")
+		secret := SecretFromBytes(buf)
+		if !bytes.Equal(buf, make([]byte, len(buf))) {
+			t.Errorf("locked %v: the source buffer wasn't wiped", locked)
+		}
+		if string(secret.Bytes()) != "keyshare" {
+			t.Errorf("locked %v: unexpected secret %q", locked, secret.Bytes())
+		}
+		data := secret.Bytes()
+		if !locked {
+			secret.Wipe()
+			if !bytes.Equal(data, make([]byte, len(data))) {
+				t.Errorf("the secret wasn't wiped")
+			}
+		} else {
+			// the locked memory is unmapped by Wipe , it can't be read afterwards
+			secret.Wipe()
+		}
+		if secret.Len() != 0 {
+			t.Errorf("locked %v: wiped secret has %d bytes", locked, secret.Len())
+		}
+		secret.Wipe()
+	}
+	LockSecretMemory(false)
+}
+
+func TestDecodeBase64Secret(t *testing.T) {
+	secret, err := DecodeBase64Secret("a2V5c2hhcmU=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer secret.Wipe()
+	if string(secret.Bytes()) != "keyshare" {
+		t.Errorf("unexpected secret %q", secret.Bytes())
+	}
+	if _, err := DecodeBase64Secret("not base64"); err == nil {
+		t.Error("invalid base64 decoded")
+	}
+}
+
+func TestSecretJSON(t *testing.T) {
+	type request struct {
+		Password *Secret `json:"password"`
+	}
+	for _, password := range []string{"password", `quote " backslash \\ slash /`, "tab\t newline\n \x01", "unicode é 🔑", ""} {
+		buf, err := json.Marshal(request{Password: NewSecretFromString(password)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the encoding matches encoding/json , other services decode it as a plain string
+		var plain struct {
+			Password string `json:"password"`
+		}
+		if err := json.Unmarshal(buf, &plain); err != nil || plain.Password != password {
+			t.Fatalf("%q: decoded as %q %v", password, plain.Password, err)
+		}
+		var req request
+		if err := json.Unmarshal(buf, &req); err != nil {
+			t.Fatal(err)
+		}
+		if string(req.Password.Bytes()) != password {
+			t.Fatalf("expected %q , got %q", password, req.Password.Bytes())
+		}
+	}
+
+	// escapes other encoders use
+	var req request
+	if err := json.Unmarshal([]byte(`{"password":"\u00e9\ud83d\udd11\/\ud83d"}`), &req); err != nil {
+		t.Fatal(err)
+	}
+	if string(req.Password.Bytes()) != "é🔑/\ufffd" {
+		t.Fatalf("unexpected secret %q", req.Password.Bytes())
+	}
+	if err := json.Unmarshal([]byte(`{"password":1}`), &req); err == nil {
+		t.Fatal("number decoded as a secret")
+	}
+	if err := json.Unmarshal([]byte(`{"password":null}`), &req); err != nil || req.Password != nil {
+		t.Fatalf("null decoded as %v %v", req.Password, err)
+	}
+}
+
+func TestSecretString(t *testing.T) {
+	secret := NewSecretFromString("password")
+	defer secret.Wipe()
+	if printed := fmt.Sprintf("%v %s", secret, secret); printed != "[secret] [secret]" {
+		t.Fatalf("secret printed as %s", printed)
+	}
+}
@@ -0,0 +1,78 @@
+package common
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	secpECDSA "github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+)
+
+// NormalizeLowS moves s to the lower half of the curve order, as required by Bitcoin and Ethereum.
+// Negating s flips the parity of R, so the recovery id is flipped as well.
+func NormalizeLowS(s []byte, recoveryID byte) ([]byte, byte) {
+	order := secp256k1.S256().N
+	sInt := new(big.Int).SetBytes(s)
+	if sInt.Cmp(new(big.Int).Rsh(order, 1)) <= 0 {
+		return s, recoveryID
+	}
+	sInt.Sub(order, sInt)
+	return sInt.FillBytes(make([]byte, 32)), recoveryID ^ 1
+}
+
+// VerifyECDSA checks the signature against the public key , and that the recovery id recovers the same public key
+func VerifyECDSA(publicKey []byte, messageHash []byte, r []byte, s []byte, recoveryID byte) error {
+	if len(r) != 32 || len(s) != 32 {
+		return errors.New("invalid signature length")
+	}
+	if recoveryID > 3 {
+		return fmt.Errorf("invalid recovery id: %d", recoveryID)
+	}
+	compact := make([]byte, 0, 65)
+	compact = append(compact, 27+4+recoveryID)
+	compact = append(compact, r...)
+	compact = append(compact, s...)
+	recovered, _, err := secpECDSA.RecoverCompact(compact, messageHash)
+	if err != nil {
+		return fmt.Errorf("failed to recover public key: %w", err)
+	}
+	expected, err := secp256k1.ParsePubKey(publicKey)
+	if err != nil {
+		return fmt.Errorf("failed to parse public key: %w", err)
+	}
+	if !bytes.Equal(recovered.SerializeCompressed(), expected.SerializeCompressed()) {
+		return errors.New("recovery id doesn't match the public key")
+	}
+	var rScalar, sScalar secp256k1.ModNScalar
+	rScalar.SetByteSlice(r)
+	sScalar.SetByteSlice(s)
+	if !secpECDSA.NewSignature(&rScalar, &sScalar).Verify(messageHash, expected) {
+		return errors.New("ecdsa signature is invalid")
+	}
+	return nil
+}
+
+// EthereumSignature encodes r || s || v , v is 27 + recovery id , or 35 + 2 * chain id + recovery id with EIP-155
+func EthereumSignature(r []byte, s []byte, recoveryID byte, chainID uint64) []byte {
+	v := new(big.Int).SetUint64(uint64(recoveryID) + 27)
+	if chainID > 0 {
+		v = new(big.Int).SetUint64(chainID)
+		v.Mul(v, big.NewInt(2))
+		v.Add(v, big.NewInt(int64(recoveryID)+35))
+	}
+	result := make([]byte, 0, 65)
+	result = append(result, r...)
+	result = append(result, s...)
+	return append(result, v.Bytes()...)
+}
+
+// BitcoinSignature encodes the DER signature followed by the sighash type byte , as it goes into a script or witness
+func BitcoinSignature(r []byte, s []byte, sigHashType byte) ([]byte, error) {
+	der, err := GetDerSignature(r, s)
+	if err != nil {
+		return nil, err
+	}
+	return append(der, sigHashType), nil
+}
@@ -0,0 +1,51 @@
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	secpECDSA "github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+)
+
+func TestVerifyECDSA(t *testing.T) {
+	privateKey, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	messageHash := sha256.Sum256([]byte("message"))
+	compact := secpECDSA.SignCompact(privateKey, messageHash[:], true)
+	recoveryID := compact[0] - 27 - 4
+	r, s := compact[1:33], compact[33:65]
+	publicKey := privateKey.PubKey().SerializeCompressed()
+	if err := VerifyECDSA(publicKey, messageHash[:], r, s, recoveryID); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyECDSA(publicKey, messageHash[:], r, s, recoveryID^1); err == nil {
+		t.Fatal("expected error for wrong recovery id")
+	}
+
+	// a high s signature is valid too , normalizing it must keep the recovery id consistent
+	var sScalar secp256k1.ModNScalar
+	sScalar.SetByteSlice(s)
+	sScalar.Negate()
+	highS := sScalar.Bytes()
+	normalizedS, normalizedID := NormalizeLowS(highS[:], recoveryID^1)
+	if hex.EncodeToString(normalizedS) != hex.EncodeToString(s) || normalizedID != recoveryID {
+		t.Fatalf("normalized s: %x, recovery id: %d, expected: %x, %d", normalizedS, normalizedID, s, recoveryID)
+	}
+}
+
+func TestEthereumSignature(t *testing.T) {
+	r := make([]byte, 32)
+	s := make([]byte, 32)
+	sig := EthereumSignature(r, s, 1, 0)
+	if len(sig) != 65 || sig[64] != 28 {
+		t.Fatalf("v: %d, expected: 28", sig[64])
+	}
+	sig = EthereumSignature(r, s, 1, 1)
+	if len(sig) != 65 || sig[64] != 38 {
+		t.Fatalf("v: %d, expected: 38", sig[64])
+	}
+}
@@ -0,0 +1,28 @@
+package common
+
+import (
+	"fmt"
+)
+
+// DefaultThreshold returns the number of parties required to sign when the request doesn't set one
+func DefaultThreshold(committeeSize int) (int, error) {
+	threshold, err := GetThreshold(committeeSize)
+	if err != nil {
+		return 0, err
+	}
+	return threshold + 1, nil
+}
+
+// ResolveThreshold validates the requested threshold against the committee size , 0 means the default threshold
+func ResolveThreshold(threshold, committeeSize int) (int, error) {
+	if threshold == 0 {
+		return DefaultThreshold(committeeSize)
+	}
+	if threshold < 2 {
+		return 0, fmt.Errorf("threshold %d is less than 2", threshold)
+	}
+	if threshold > committeeSize {
+		return 0, fmt.Errorf("threshold %d is larger than committee size %d", threshold, committeeSize)
+	}
+	return threshold, nil
+}
@@ -0,0 +1,20 @@
+package common
+
+import (
+	"testing"
+)
+
+func TestResolveThreshold(t *testing.T) {
+	if threshold, err := ResolveThreshold(0, 2); err != nil || threshold != 2 {
+		t.Fatalf("threshold: %d, err: %v, expected: 2", threshold, err)
+	}
+	if _, err := ResolveThreshold(4, 3); err == nil {
+		t.Fatal("expected error for threshold larger than committee")
+	}
+	if _, err := ResolveThreshold(1, 3); err == nil {
+		t.Fatal("expected error for threshold less than 2")
+	}
+	if threshold, err := DefaultThreshold(3); err != nil || threshold != 2 {
+		t.Fatalf("default threshold: %d, err: %v, expected: 2", threshold, err)
+	}
+}
@@ -7,7 +7,6 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
-	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
@@ -16,6 +15,7 @@ import (
 	"github.com/ulikunitz/xz"
 	v1 "github.com/vultisig/commondata/go/vultisig/keygen/v1"
 	vaultType "github.com/vultisig/commondata/go/vultisig/vault/v1"
+	"google.golang.org/protobuf/encoding/protowire"
 	"google.golang.org/protobuf/proto"
 )
 
@@ -60,9 +60,20 @@ func DecompressData(compressedData []byte) ([]byte, error) {
 }
 
 func EncryptVault(password string, vault []byte) ([]byte, error) {
+	return encryptVault([]byte(password), vault)
+}
+
+// EncryptVaultSecret encrypts the vault with the password in a secret
+func EncryptVaultSecret(password *Secret, vault []byte) ([]byte, error) {
+	return encryptVault(password.Bytes(), vault)
+}
+
+// vaultCipher returns the cipher of the vault file , keyed by the hash of the password
+func vaultCipher(password []byte) (cipher.AEAD, error) {
 	// Hash the password to create a key
-	hash := sha256.Sum256([]byte(password))
+	hash := sha256.Sum256(password)
 	key := hash[:]
+	defer Wipe(key)
 
 	// Create a new AES cipher using the key
 	block, err := aes.NewCipher(key)
@@ -71,7 +82,11 @@ func EncryptVault(password string, vault []byte) ([]byte, error) {
 	}
 
 	// Use GCM (Galois/Counter Mode)
-	gcm, err := cipher.NewGCM(block)
+	return cipher.NewGCM(block)
+}
+
+func encryptVault(password, vault []byte) ([]byte, error) {
+	gcm, err := vaultCipher(password)
 	if err != nil {
 		return nil, err
 	}
@@ -88,18 +103,11 @@ func EncryptVault(password string, vault []byte) ([]byte, error) {
 }
 
 func DecryptVault(password string, vault []byte) ([]byte, error) {
-	// Hash the password to create a key
-	hash := sha256.Sum256([]byte(password))
-	key := hash[:]
-
-	// Create a new AES cipher using the key
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return nil, err
-	}
+	return decryptVault([]byte(password), vault)
+}
 
-	// Use GCM (Galois/Counter Mode)
-	gcm, err := cipher.NewGCM(block)
+func decryptVault(password, vault []byte) ([]byte, error) {
+	gcm, err := vaultCipher(password)
 	if err != nil {
 		return nil, err
 	}
@@ -122,7 +130,8 @@ func DecryptVault(password string, vault []byte) ([]byte, error) {
 	return plaintext, nil
 }
 
-func DecryptVaultFromBackup(password string, vaultBackupRaw []byte) (*vaultType.Vault, error) {
+// DecodeVaultContainer decodes the base64 encoded container of a .bak or .vult file , the vault stays encrypted
+func DecodeVaultContainer(vaultBackupRaw []byte) (*vaultType.VaultContainer, error) {
 	var vaultBackup vaultType.VaultContainer
 	base64DecodeVaultBackup, err := base64.StdEncoding.DecodeString(string(vaultBackupRaw))
 	if err != nil {
@@ -131,6 +140,104 @@ func DecryptVaultFromBackup(password string, vaultBackupRaw []byte) (*vaultType.
 	if err := proto.Unmarshal(base64DecodeVaultBackup, &vaultBackup); err != nil {
 		return nil, err
 	}
+	return &vaultBackup, nil
+}
+
+// EncryptVaultToBackup encrypts the vault with the password and returns the base64 encoded container ,
+// the same content the server saves as .bak file
+func EncryptVaultToBackup(password string, version uint64, vault *vaultType.Vault) ([]byte, error) {
+	return encryptVaultToBackup([]byte(password), version, vault, nil)
+}
+
+// VaultKeyshare is a keyshare of a vault kept in a secret , the local state of the TSS library
+type VaultKeyshare struct {
+	PublicKey string
+	Keyshare  *Secret
+}
+
+// EncryptVaultToBackupSecret encrypts the vault with the password in a secret , like EncryptVaultToBackup.
+// The keyshares are added to the key shares of the vault , they're marshalled from their secrets and never become strings.
+func EncryptVaultToBackupSecret(password *Secret, version uint64, vault *vaultType.Vault, keyshares []VaultKeyshare) ([]byte, error) {
+	return encryptVaultToBackup(password.Bytes(), version, vault, keyshares)
+}
+
+func encryptVaultToBackup(password []byte, version uint64, vault *vaultType.Vault, keyshares []VaultKeyshare) ([]byte, error) {
+	if len(password) == 0 {
+		return nil, errors.New("password is empty")
+	}
+	plaintext, err := marshalVault(vault, keyshares)
+	if err != nil {
+		return nil, fmt.Errorf("failed to Marshal vault: %w", err)
+	}
+	vaultData, err := encryptVault(password, plaintext)
+	Wipe(plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("common.EncryptVault failed: %w", err)
+	}
+	vaultBackup := &vaultType.VaultContainer{
+		Version:     version,
+		Vault:       base64.StdEncoding.EncodeToString(vaultData),
+		IsEncrypted: true,
+	}
+	vaultBackupData, err := proto.Marshal(vaultBackup)
+	if err != nil {
+		return nil, fmt.Errorf("failed to Marshal vaultBackup: %w", err)
+	}
+	return []byte(base64.StdEncoding.EncodeToString(vaultBackupData)), nil
+}
+
+// field numbers of the key shares in the protobuf encoding of the vault
+var (
+	vaultKeySharesField    = (&vaultType.Vault{}).ProtoReflect().Descriptor().Fields().ByName("key_shares").Number()
+	keySharePublicKeyField = (&vaultType.Vault_KeyShare{}).ProtoReflect().Descriptor().Fields().ByName("public_key").Number()
+	keyShareKeyshareField  = (&vaultType.Vault_KeyShare{}).ProtoReflect().Descriptor().Fields().ByName("keyshare").Number()
+)
+
+// marshalVault marshals the vault and appends the keyshares to its key shares , into a buffer the caller wipes
+func marshalVault(vault *vaultType.Vault, keyshares []VaultKeyshare) ([]byte, error) {
+	buf, err := proto.Marshal(vault)
+	if err != nil {
+		return nil, err
+	}
+	if len(keyshares) == 0 {
+		return buf, nil
+	}
+	keyShareSize := func(keyshare VaultKeyshare) int {
+		return protowire.SizeTag(keySharePublicKeyField) + protowire.SizeBytes(len(keyshare.PublicKey)) +
+			protowire.SizeTag(keyShareKeyshareField) + protowire.SizeBytes(keyshare.Keyshare.Len())
+	}
+	size := len(buf)
+	for _, keyshare := range keyshares {
+		size += protowire.SizeTag(vaultKeySharesField) + protowire.SizeBytes(keyShareSize(keyshare))
+	}
+	// the buffer is allocated once , so no copy of the keyshares is left behind when it grows
+	plaintext := append(make([]byte, 0, size), buf...)
+	Wipe(buf)
+	for _, keyshare := range keyshares {
+		plaintext = protowire.AppendTag(plaintext, vaultKeySharesField, protowire.BytesType)
+		plaintext = protowire.AppendVarint(plaintext, uint64(keyShareSize(keyshare)))
+		plaintext = protowire.AppendTag(plaintext, keySharePublicKeyField, protowire.BytesType)
+		plaintext = protowire.AppendString(plaintext, keyshare.PublicKey)
+		plaintext = protowire.AppendTag(plaintext, keyShareKeyshareField, protowire.BytesType)
+		plaintext = protowire.AppendBytes(plaintext, keyshare.Keyshare.Bytes())
+	}
+	return plaintext, nil
+}
+
+func DecryptVaultFromBackup(password string, vaultBackupRaw []byte) (*vaultType.Vault, error) {
+	return decryptVaultFromBackup([]byte(password), vaultBackupRaw)
+}
+
+// DecryptVaultFromBackupSecret decrypts the vault with the password in a secret
+func DecryptVaultFromBackupSecret(password *Secret, vaultBackupRaw []byte) (*vaultType.Vault, error) {
+	return decryptVaultFromBackup(password.Bytes(), vaultBackupRaw)
+}
+
+func decryptVaultFromBackup(password, vaultBackupRaw []byte) (*vaultType.Vault, error) {
+	vaultBackup, err := DecodeVaultContainer(vaultBackupRaw)
+	if err != nil {
+		return nil, err
+	}
 
 	vaultRaw := []byte(vaultBackup.Vault)
 	if vaultBackup.IsEncrypted {
@@ -139,12 +246,14 @@ func DecryptVaultFromBackup(password string, vaultBackupRaw []byte) (*vaultType.
 		if err != nil {
 			return nil, err
 		}
-		vaultRaw, err = DecryptVault(password, vaultBytes)
+		vaultRaw, err = decryptVault(password, vaultBytes)
 		if err != nil {
 			return nil, err
 		}
 	}
 
+	// the decrypted vault holds the keyshares , proto.Unmarshal copies what it keeps
+	defer Wipe(vaultRaw)
 	var vault vaultType.Vault
 	if err := proto.Unmarshal(vaultRaw, &vault); err != nil {
 		return nil, err
@@ -191,14 +300,16 @@ func GetThreshold(value int) (int, error) {
 }
 
 func DecryptGCM(rawData []byte, hexEncryptKey string) ([]byte, error) {
-	password, err := hex.DecodeString(hexEncryptKey)
+	password, err := DecodeHexSecret(hexEncryptKey)
 	if err != nil {
 		return nil, err
 	}
+	defer password.Wipe()
 
 	// Hash the password to create a key
-	hash := sha256.Sum256([]byte(password))
+	hash := sha256.Sum256(password.Bytes())
 	key := hash[:]
+	defer Wipe(key)
 
 	// Create a new AES cipher using the key
 	block, err := aes.NewCipher(key)
@@ -4,6 +4,8 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+
+	vaultType "github.com/vultisig/commondata/go/vultisig/vault/v1"
 )
 
 func TestDataCompression(t *testing.T) {
@@ -78,3 +80,73 @@ func TestVaultBackupCompatible(t *testing.T) {
 		t.Fatalf("ios backup is not compatible with android backup")
 	}
 }
+
+func TestVaultBackupReencryption(t *testing.T) {
+	content, err := os.ReadFile(filepath.Join("test_vault_backup_files", "test_ios_vault_backup.bak"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vault, err := DecryptVaultFromBackup("ios_test_pwd", content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reencrypted, err := EncryptVaultToBackup("new_pwd", 1, vault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	container, err := DecodeVaultContainer(reencrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !container.IsEncrypted || container.Version != 1 {
+		t.Fatalf("encrypted: %t, version: %d", container.IsEncrypted, container.Version)
+	}
+	if _, err := DecryptVaultFromBackup("ios_test_pwd", reencrypted); err == nil {
+		t.Fatal("expected error for old password")
+	}
+	decrypted, err := DecryptVaultFromBackup("new_pwd", reencrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decrypted.PublicKeyEcdsa != vault.PublicKeyEcdsa || len(decrypted.KeyShares) != len(vault.KeyShares) {
+		t.Fatalf("re-encrypted vault doesn't match the original vault")
+	}
+}
+
+func TestEncryptVaultToBackupSecret(t *testing.T) {
+	vault := &vaultType.Vault{
+		Name:           "vault",
+		PublicKeyEcdsa: "ecdsa",
+		PublicKeyEddsa: "eddsa",
+		Signers:        []string{"server", "device"},
+	}
+	password := NewSecretFromString("password")
+	defer password.Wipe()
+	content, err := EncryptVaultToBackupSecret(password, 1, vault, []VaultKeyshare{
+		{PublicKey: "ecdsa", Keyshare: NewSecretFromString("ecdsa keyshare")},
+		{PublicKey: "eddsa", Keyshare: NewSecretFromString("eddsa keyshare")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted, err := DecryptVaultFromBackupSecret(password, content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decrypted.Name != vault.Name || len(decrypted.Signers) != 2 || len(decrypted.KeyShares) != 2 {
+		t.Fatalf("unexpected vault %+v", decrypted)
+	}
+	for idx, publicKey := range []string{"ecdsa", "eddsa"} {
+		keyShare := decrypted.KeyShares[idx]
+		if keyShare.PublicKey != publicKey || keyShare.Keyshare != publicKey+" keyshare" {
+			t.Fatalf("unexpected key share %+v", keyShare)
+		}
+	}
+	if _, err := DecryptVaultFromBackup("wrong password", content); err == nil {
+		t.Fatal("expected error for a wrong password")
+	}
+	if _, err := EncryptVaultToBackupSecret(nil, 1, vault, nil); err == nil {
+		t.Fatal("expected error for an empty password")
+	}
+}
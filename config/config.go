@@ -22,11 +22,82 @@ type Config struct {
 	} `mapstructure:"redis" json:"redis,omitempty"`
 
 	Relay struct {
-		Server string `mapstructure:"server" json:"server"`
+		Server             string `mapstructure:"server" json:"server"`
+		PairwiseEncryption bool   `mapstructure:"pairwise_encryption" json:"pairwise_encryption"` // offer per-peer X25519 keys for DKLS messages
+		PairwiseRequired   bool   `mapstructure:"pairwise_required" json:"pairwise_required"`     // fail DKLS sessions that don't negotiate pairwise encryption
+		IdentityKey        string `mapstructure:"identity_key" json:"-"`                          // hex encoded Ed25519 seed that signs the pairwise keys
 	} `mapstructure:"relay" json:"relay,omitempty"`
 
+	Keysign struct {
+		Parallelism        int  `mapstructure:"parallelism" json:"parallelism,omitempty"`       // maximum number of messages signed concurrently in one keysign request
+		StrictVerification bool `mapstructure:"strict_verification" json:"strict_verification"` // fail keysign when the signature doesn't verify , and enforce low-S signatures
+	} `mapstructure:"keysign" json:"keysign,omitempty"`
+
+	VaultCache struct {
+		TTLSeconds int `mapstructure:"ttl_seconds" json:"ttl_seconds"` // decrypted vaults are cached in memory for the ttl , 0 disables the cache
+		MaxEntries int `mapstructure:"max_entries" json:"max_entries"` // the least recently used vault is dropped when the cache is full
+	} `mapstructure:"vault_cache" json:"vault_cache"`
+
+	Security struct {
+		LockMemory bool `mapstructure:"lock_memory" json:"lock_memory"` // keep decoded key material in locked memory , so it's never swapped to disk
+	} `mapstructure:"security" json:"security"`
+
+	Reshare struct {
+		PendingTTLMinutes int `mapstructure:"pending_ttl_minutes" json:"pending_ttl_minutes"` // a reshare or migration share not every party confirmed is deleted after the ttl
+	} `mapstructure:"reshare" json:"reshare"`
+
+	Refresh struct {
+		ReminderDays int `mapstructure:"reminder_days" json:"reminder_days,omitempty"` // email a refresh reminder every N days after a refresh, 0 disables it
+	} `mapstructure:"refresh" json:"refresh,omitempty"`
+
+	Queues Queues `mapstructure:"queues" json:"queues"`
+
+	Notification struct {
+		BaseURL        string `mapstructure:"base_url" json:"base_url"`                 // public url of the api , report and backup download links point to it , empty gives relative links
+		ReportTTLHours int    `mapstructure:"report_ttl_hours" json:"report_ttl_hours"` // report links expire after the ttl
+		WebhookTimeout int    `mapstructure:"webhook_timeout" json:"webhook_timeout"`   // webhook request timeout in seconds
+	} `mapstructure:"notification" json:"notification"`
+
+	BackupDelivery struct {
+		DownloadTTLMinutes int `mapstructure:"download_ttl_minutes" json:"download_ttl_minutes"` // backup download links expire after the ttl
+		UploadTimeout      int `mapstructure:"upload_timeout" json:"upload_timeout"`             // backup upload request timeout in seconds
+	} `mapstructure:"backup_delivery" json:"backup_delivery"`
+
+	VaultIndex struct {
+		EmailSalt string `mapstructure:"email_salt" json:"email_salt"` // salt of the email hashes saved in the vault index
+	} `mapstructure:"vault_index" json:"vault_index"`
+
+	Admin struct {
+		Token string `mapstructure:"token" json:"token"` // bearer token of the /admin endpoints , empty disables them
+	} `mapstructure:"admin" json:"admin"`
+
 	EmailServer struct {
-		ApiKey string `mapstructure:"api_key" json:"api_key"`
+		Backend       string `mapstructure:"backend" json:"backend"`               // mandrill , smtp or sink
+		From          string `mapstructure:"from" json:"from"`                     // sender address of the smtp and sink backends
+		ApiKey        string `mapstructure:"api_key" json:"api_key"`               // mandrill api key
+		MandrillURL   string `mapstructure:"mandrill_url" json:"mandrill_url"`     // mandrill send-template endpoint
+		SendingDomain string `mapstructure:"sending_domain" json:"sending_domain"` // mandrill sending domain
+		Timeout       int    `mapstructure:"timeout" json:"timeout"`               // mandrill request timeout in seconds
+		SMTP          struct {
+			Host     string `mapstructure:"host" json:"host"`
+			Port     int    `mapstructure:"port" json:"port"`
+			Username string `mapstructure:"username" json:"username"`
+			Password string `mapstructure:"password" json:"password"`
+			TLS      string `mapstructure:"tls" json:"tls"`         // starttls , implicit or none
+			Timeout  int    `mapstructure:"timeout" json:"timeout"` // timeout of the whole smtp conversation in seconds
+		} `mapstructure:"smtp" json:"smtp"`
+		Sink struct {
+			Path   string `mapstructure:"path" json:"path"`     // directory the emails are written to
+			Format string `mapstructure:"format" json:"format"` // file writes one .eml file per email , maildir writes a maildir
+		} `mapstructure:"sink" json:"sink"`
+		Templates struct {
+			Backup          EmailTemplate `mapstructure:"backup" json:"backup"`
+			BackupLink      EmailTemplate `mapstructure:"backup_link" json:"backup_link"`
+			BackupPGP       EmailTemplate `mapstructure:"backup_pgp" json:"backup_pgp"`
+			Verification    EmailTemplate `mapstructure:"verification" json:"verification"`
+			Alert           EmailTemplate `mapstructure:"alert" json:"alert"`
+			RefreshReminder EmailTemplate `mapstructure:"refresh_reminder" json:"refresh_reminder"`
+		} `mapstructure:"templates" json:"templates"`
 	} `mapstructure:"email_server" json:"email_server"`
 
 	BlockStorage struct {
@@ -38,6 +109,53 @@ type Config struct {
 	} `mapstructure:"block_storage" json:"block_storage"`
 }
 
+// EmailTemplate is the content of one kind of email. Subject and body are go text/template rendered with the merge variables ,
+// like {{.VAULT_NAME}}. The mandrill backend uses the mandrill template instead , and sends the merge variables to it.
+type EmailTemplate struct {
+	MandrillTemplate string `mapstructure:"mandrill_template" json:"mandrill_template"`
+	Subject          string `mapstructure:"subject" json:"subject"`
+	Body             string `mapstructure:"body" json:"body"`
+}
+
+// QueuePolicy is how the worker runs the tasks of one queue
+type QueuePolicy struct {
+	Concurrency      int `mapstructure:"concurrency" json:"concurrency"`             // maximum number of tasks of the queue processed concurrently by one worker
+	Weight           int `mapstructure:"weight" json:"weight"`                       // priority of the queue among the queues sharing its worker pool
+	Timeout          int `mapstructure:"timeout" json:"timeout"`                     // task timeout in seconds
+	RetentionMinutes int `mapstructure:"retention_minutes" json:"retention_minutes"` // the result of a completed task is kept for the retention
+}
+
+// Queues are the task queues. Every operation type has its own queue and its own worker pool ,
+// so a burst of one operation can't starve the others. The default pool serves the default and email queues , and the operation queues with a weight , by weight.
+type Queues struct {
+	Keygen  QueuePolicy `mapstructure:"keygen" json:"keygen"`
+	Keysign QueuePolicy `mapstructure:"keysign" json:"keysign"`
+	Reshare QueuePolicy `mapstructure:"reshare" json:"reshare"`
+	Refresh QueuePolicy `mapstructure:"refresh" json:"refresh"`
+	Migrate QueuePolicy `mapstructure:"migrate" json:"migrate"`
+	Import  QueuePolicy `mapstructure:"import" json:"import"`
+	Default QueuePolicy `mapstructure:"default" json:"default"` // pending shares , reminders and tasks enqueued before the operation queues
+	Email   QueuePolicy `mapstructure:"email" json:"email"`     // emails and notifications , served by the default pool
+}
+
+// Operations returns the policy of the queue of every operation type
+func (q Queues) Operations() map[string]QueuePolicy {
+	return map[string]QueuePolicy{
+		"keygen":  q.Keygen,
+		"keysign": q.Keysign,
+		"reshare": q.Reshare,
+		"refresh": q.Refresh,
+		"migrate": q.Migrate,
+		"import":  q.Import,
+	}
+}
+
+// Policy returns the policy of the queue of the operation type
+func (q Queues) Policy(operation string) (QueuePolicy, bool) {
+	policy, ok := q.Operations()[operation]
+	return policy, ok
+}
+
 func GetConfigure() (*Config, error) {
 	viper.SetConfigName("config")
 	viper.AddConfigPath(".")
@@ -52,6 +170,58 @@ func GetConfigure() (*Config, error) {
 	viper.SetDefault("Redis.Password", "")
 	viper.SetDefault("Redis.DB", 0)
 	viper.SetDefault("Relay.Server", "https://api.vultisig.com/router")
+	viper.SetDefault("relay.pairwise_required", false)
+	viper.SetDefault("relay.identity_key", "")
+	viper.SetDefault("keysign.parallelism", 4)
+	viper.SetDefault("keysign.strict_verification", false)
+	viper.SetDefault("refresh.reminder_days", 0)
+	viper.SetDefault("vault_cache.ttl_seconds", 0)
+	viper.SetDefault("vault_cache.max_entries", 1000)
+	viper.SetDefault("security.lock_memory", false)
+	viper.SetDefault("reshare.pending_ttl_minutes", 60)
+	setQueueDefaults("keygen", 4, 420, 10)
+	setQueueDefaults("keysign", 16, 120, 5)
+	setQueueDefaults("reshare", 4, 420, 10)
+	setQueueDefaults("refresh", 2, 420, 10)
+	setQueueDefaults("migrate", 2, 420, 10)
+	setQueueDefaults("import", 2, 420, 10)
+	viper.SetDefault("queues.default.concurrency", 10)
+	viper.SetDefault("queues.default.weight", 10)
+	viper.SetDefault("queues.email.weight", 100)
+	viper.SetDefault("notification.base_url", "")
+	viper.SetDefault("notification.report_ttl_hours", 168)
+	viper.SetDefault("notification.webhook_timeout", 10)
+	viper.SetDefault("backup_delivery.download_ttl_minutes", 60)
+	viper.SetDefault("backup_delivery.upload_timeout", 30)
+	viper.SetDefault("email_server.backend", "mandrill")
+	viper.SetDefault("email_server.from", "VultiServer <no-reply@vultisig.com>")
+	viper.SetDefault("email_server.mandrill_url", "https://mandrillapp.com/api/1.0/messages/send-template")
+	viper.SetDefault("email_server.sending_domain", "vultisig.com")
+	viper.SetDefault("email_server.timeout", 10)
+	viper.SetDefault("email_server.smtp.port", 587)
+	viper.SetDefault("email_server.smtp.tls", "starttls")
+	viper.SetDefault("email_server.smtp.timeout", 30)
+	viper.SetDefault("email_server.sink.path", "emails")
+	viper.SetDefault("email_server.sink.format", "file")
+	viper.SetDefault("email_server.templates.backup.mandrill_template", "fastvault")
+	viper.SetDefault("email_server.templates.backup.subject", "Your Vultisig vault share of {{.VAULT_NAME}}")
+	viper.SetDefault("email_server.templates.backup.body", "Your vault share of {{.VAULT_NAME}} is attached , keep it safe.\nVerification code: {{.VERIFICATION_CODE}}\n")
+	viper.SetDefault("email_server.templates.backup_link.mandrill_template", "fastvault-download")
+	viper.SetDefault("email_server.templates.backup_link.subject", "Download your Vultisig vault share of {{.VAULT_NAME}}")
+	viper.SetDefault("email_server.templates.backup_link.body", "Download your vault share of {{.VAULT_NAME}} at {{.DOWNLOAD_URL}}\nThe link works once , and asks for the password of the vault.\n")
+	viper.SetDefault("email_server.templates.backup_pgp.mandrill_template", "fastvault-pgp")
+	viper.SetDefault("email_server.templates.backup_pgp.subject", "Your Vultisig vault share of {{.VAULT_NAME}}")
+	viper.SetDefault("email_server.templates.backup_pgp.body", "Your vault share of {{.VAULT_NAME}} is attached , encrypted to your OpenPGP key {{.PGP_FINGERPRINT}}.\nVerification code: {{.VERIFICATION_CODE}}\n")
+	viper.SetDefault("email_server.templates.verification.mandrill_template", "fastvault-verification")
+	viper.SetDefault("email_server.templates.verification.subject", "Your Vultisig verification code")
+	viper.SetDefault("email_server.templates.verification.body", "Verification code of {{.VAULT_NAME}}: {{.VERIFICATION_CODE}}\n")
+	viper.SetDefault("email_server.templates.alert.mandrill_template", "fastvault-alert")
+	viper.SetDefault("email_server.templates.alert.subject", "Vultisig alert for {{.VAULT_NAME}}")
+	viper.SetDefault("email_server.templates.alert.body", "{{.ALERT_MESSAGE}}\n")
+	viper.SetDefault("email_server.templates.refresh_reminder.mandrill_template", "fastvault-refresh-reminder")
+	viper.SetDefault("email_server.templates.refresh_reminder.subject", "Time to refresh {{.VAULT_NAME}}")
+	viper.SetDefault("email_server.templates.refresh_reminder.body", "Your vault {{.VAULT_NAME}} is due for a key refresh.\n")
+	viper.SetDefault("admin.token", "")
 
 	if err := viper.ReadInConfig(); err != nil {
 		return nil, fmt.Errorf("fail to reading config file, %w", err)
@@ -63,3 +233,9 @@ func GetConfigure() (*Config, error) {
 	}
 	return &cfg, nil
 }
+
+func setQueueDefaults(operation string, concurrency, timeout, retentionMinutes int) {
+	viper.SetDefault("queues."+operation+".concurrency", concurrency)
+	viper.SetDefault("queues."+operation+".timeout", timeout)
+	viper.SetDefault("queues."+operation+".retention_minutes", retentionMinutes)
+}
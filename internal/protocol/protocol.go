@@ -0,0 +1,121 @@
+// Package protocol describes the MPC protocols , the operations every protocol supports and the task type of each operation.
+// It doesn't link the MPC libraries , so the API routes requests to the worker without cgo.
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/vultisig/vultisigner/internal/tasks"
+	"github.com/vultisig/vultisigner/internal/types"
+)
+
+// Operation is a vault operation executed by an MPC protocol
+type Operation string
+
+const (
+	OperationKeygen  Operation = "keygen"
+	OperationKeysign Operation = "keysign"
+	OperationReshare Operation = "reshare"
+	OperationRefresh Operation = "refresh"
+	OperationMigrate Operation = "migrate"
+	OperationImport  Operation = "import"
+)
+
+var ErrOperationNotSupported = errors.New("operation is not supported by the protocol")
+
+// Info describes a protocol
+type Info struct {
+	Name      string
+	LibType   types.LibType
+	TaskTypes map[Operation]string // task type name of every supported operation
+	// CheckKeysign rejects keysign requests the protocol can't serve , it is optional
+	CheckKeysign func(req types.KeysignRequest) error
+}
+
+var protocols = map[types.LibType]Info{
+	types.GG20: {
+		Name:    "gg20",
+		LibType: types.GG20,
+		TaskTypes: map[Operation]string{
+			OperationKeygen:  tasks.TypeKeyGeneration,
+			OperationKeysign: tasks.TypeKeySign,
+			OperationReshare: tasks.TypeReshare,
+		},
+		CheckKeysign: func(req types.KeysignRequest) error {
+			if req.SignatureFormat != "" {
+				return errors.New("GG20 vault doesn't support signature format")
+			}
+			return nil
+		},
+	},
+	types.DKLS: {
+		Name:    "dkls",
+		LibType: types.DKLS,
+		TaskTypes: map[Operation]string{
+			OperationKeygen:  tasks.TypeKeyGenerationDKLS,
+			OperationKeysign: tasks.TypeKeySignDKLS,
+			OperationReshare: tasks.TypeReshareDKLS,
+			OperationRefresh: tasks.TypeRefreshDKLS,
+			OperationMigrate: tasks.TypeMigrate,
+			OperationImport:  tasks.TypeKeyImportDKLS,
+		},
+	},
+}
+
+// Get returns the protocol for the lib type
+func Get(libType types.LibType) (Info, error) {
+	info, ok := protocols[libType]
+	if !ok {
+		return Info{}, fmt.Errorf("no protocol for lib type %d", libType)
+	}
+	return info, nil
+}
+
+// LibTypes returns the lib types that have a protocol , sorted
+func LibTypes() []types.LibType {
+	result := make([]types.LibType, 0, len(protocols))
+	for libType := range protocols {
+		result = append(result, libType)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result
+}
+
+// TaskType returns the task type name of the operation for the lib type
+func TaskType(libType types.LibType, op Operation) (string, error) {
+	info, err := Get(libType)
+	if err != nil {
+		return "", err
+	}
+	typeName, ok := info.TaskTypes[op]
+	if !ok {
+		return "", fmt.Errorf("%s %s: %w", info.Name, op, ErrOperationNotSupported)
+	}
+	return typeName, nil
+}
+
+// TaskTypes returns the task type names of all protocols , sorted
+func TaskTypes() []string {
+	var result []string
+	for _, info := range protocols {
+		for _, typeName := range info.TaskTypes {
+			result = append(result, typeName)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// ForTask returns the protocol and the operation of the task type
+func ForTask(typeName string) (Info, Operation, error) {
+	for _, info := range protocols {
+		for op, name := range info.TaskTypes {
+			if name == typeName {
+				return info, op, nil
+			}
+		}
+	}
+	return Info{}, "", fmt.Errorf("no protocol for task type %s", typeName)
+}
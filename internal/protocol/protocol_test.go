@@ -0,0 +1,62 @@
+package protocol
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/vultisig/vultisigner/internal/tasks"
+	"github.com/vultisig/vultisigner/internal/types"
+)
+
+func TestProtocolRegistry(t *testing.T) {
+	typeName, err := TaskType(types.DKLS, OperationKeysign)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typeName != tasks.TypeKeySignDKLS {
+		t.Fatalf("task type: %s, expected: %s", typeName, tasks.TypeKeySignDKLS)
+	}
+	if _, err := TaskType(types.GG20, OperationRefresh); !errors.Is(err, ErrOperationNotSupported) {
+		t.Fatalf("expected ErrOperationNotSupported, got: %v", err)
+	}
+	if _, err := TaskType(types.LibType(100), OperationKeygen); err == nil {
+		t.Fatal("expected error for an unregistered lib type")
+	}
+	info, op, err := ForTask(tasks.TypeReshare)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.LibType != types.GG20 || op != OperationReshare {
+		t.Fatalf("task %s resolved to %s %s", tasks.TypeReshare, info.Name, op)
+	}
+}
+
+// every task type belongs to exactly one protocol operation , so the worker routes it unambiguously
+func TestTaskTypesAreUnique(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, libType := range LibTypes() {
+		info, err := Get(libType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.LibType != libType {
+			t.Errorf("protocol %s is registered for lib type %d", info.Name, libType)
+		}
+		for op, typeName := range info.TaskTypes {
+			if seen[typeName] {
+				t.Errorf("task type %s is registered twice", typeName)
+			}
+			seen[typeName] = true
+			resolved, resolvedOp, err := ForTask(typeName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resolved.LibType != libType || resolvedOp != op {
+				t.Errorf("task %s resolved to %s %s , expected %s %s", typeName, resolved.Name, resolvedOp, info.Name, op)
+			}
+		}
+	}
+	if len(TaskTypes()) != len(seen) {
+		t.Fatalf("task types: %v , registered: %d", TaskTypes(), len(seen))
+	}
+}
@@ -2,13 +2,26 @@ package tasks
 
 const QUEUE_NAME = "vultisigner"
 const EMAIL_QUEUE_NAME = "vultisigner:email"
+
+// OperationQueue returns the queue of the tasks of an operation type , like keygen or keysign
+func OperationQueue(operation string) string {
+	return QUEUE_NAME + ":" + operation
+}
+
 const (
-	TypeKeyGeneration     = "key:generation"
-	TypeKeySign           = "key:sign"
-	TypeEmailVaultBackup  = "key:email"
-	TypeReshare           = "key:reshare"
-	TypeKeyGenerationDKLS = "key:generationDKLS"
-	TypeKeySignDKLS       = "key:signDKLS"
-	TypeReshareDKLS       = "key:reshareDKLS"
-	TypeMigrate           = "key:migrate"
+	TypeKeyGeneration       = "key:generation"
+	TypeKeySign             = "key:sign"
+	TypeEmailVaultBackup    = "key:email"
+	TypeReshare             = "key:reshare"
+	TypeKeyGenerationDKLS   = "key:generationDKLS"
+	TypeKeySignDKLS         = "key:signDKLS"
+	TypeReshareDKLS         = "key:reshareDKLS"
+	TypeMigrate             = "key:migrate"
+	TypeRefreshDKLS         = "key:refreshDKLS"
+	TypeRefreshReminder     = "key:refreshReminder"
+	TypeKeyImportDKLS       = "key:importDKLS"
+	TypeKeysignNotify       = "key:signNotification"
+	TypeBackupUpload        = "key:backupUpload"
+	TypePromotePendingVault = "key:promotePending"
+	TypeExpirePendingVault  = "key:expirePending"
 )
@@ -0,0 +1,68 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/vultisig/vultisigner/common"
+)
+
+// BackupDelivery is how the vault share backup reaches the owner after create , reshare or migrate
+type BackupDelivery string
+
+const (
+	BackupDeliveryEmail    BackupDelivery = "email"    // attached to the backup email , the default
+	BackupDeliveryDownload BackupDelivery = "download" // emailed as an expiring single-use download link , the download asks for the vault password
+	BackupDeliveryUpload   BackupDelivery = "upload"   // HTTP PUT to an S3 compatible or WebDAV url
+	BackupDeliveryResult   BackupDelivery = "result"   // returned in the task result of the operation
+)
+
+// BackupDeliveryOptions selects the backup delivery channel , it is part of the create , reshare and migrate requests
+type BackupDeliveryOptions struct {
+	BackupDelivery      BackupDelivery    `json:"backup_delivery"`       // optional , defaults to email
+	BackupUploadURL     string            `json:"backup_upload_url"`     // https url the backup is PUT to , required by upload
+	BackupUploadHeaders map[string]string `json:"backup_upload_headers"` // optional headers of the upload request , like Authorization
+	PGPPublicKey        string            `json:"pgp_public_key"`        // optional ASCII-armored OpenPGP public key , emailed backups are encrypted to it
+}
+
+// Delivery returns the selected delivery channel , email when none is selected
+func (o BackupDeliveryOptions) Delivery() BackupDelivery {
+	if o.BackupDelivery == "" {
+		return BackupDeliveryEmail
+	}
+	return o.BackupDelivery
+}
+
+// NeedsEmail reports whether the delivery channel sends an email
+func (o BackupDeliveryOptions) NeedsEmail() bool {
+	delivery := o.Delivery()
+	return delivery == BackupDeliveryEmail || delivery == BackupDeliveryDownload
+}
+
+func (o BackupDeliveryOptions) validate() error {
+	switch o.Delivery() {
+	case BackupDeliveryEmail, BackupDeliveryDownload, BackupDeliveryResult:
+	case BackupDeliveryUpload:
+		u, err := url.Parse(o.BackupUploadURL)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return errors.New("backup_upload_url must be an https url")
+		}
+	default:
+		return fmt.Errorf("backup_delivery %s is not valid", o.BackupDelivery)
+	}
+	if o.PGPPublicKey != "" {
+		if _, err := common.ParsePGPPublicKey(o.PGPPublicKey); err != nil {
+			return fmt.Errorf("pgp_public_key is not valid: %w", err)
+		}
+	}
+	return nil
+}
+
+// BackupUploadRequest is the payload of the task that uploads a vault share backup
+type BackupUploadRequest struct {
+	PublicKeyECDSA string            `json:"public_key_ecdsa"`
+	URL            string            `json:"url"`
+	Headers        map[string]string `json:"headers"`
+	FileContent    string            `json:"file_content"`
+}
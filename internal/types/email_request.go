@@ -1,9 +1,11 @@
 package types
 
 type EmailRequest struct {
-	Email       string `json:"email"`
-	FileName    string `json:"file_name"`
-	FileContent string `json:"file_content"`
-	VaultName   string `json:"vault_name"`
-	Code        string `json:"code"`
+	Email          string `json:"email"`
+	FileName       string `json:"file_name"`
+	FileContent    string `json:"file_content"`
+	VaultName      string `json:"vault_name"`
+	Code           string `json:"code"`
+	DownloadURL    string `json:"download_url,omitempty"`    // when set the email carries the download link instead of the attachment
+	PGPFingerprint string `json:"pgp_fingerprint,omitempty"` // when set the attachment is encrypted to the OpenPGP key
 }
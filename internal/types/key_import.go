@@ -0,0 +1,103 @@
+package types
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/vultisig/vultisigner/common"
+	"github.com/vultisig/vultisigner/recovery"
+)
+
+// KeyImportRequest is a struct that represents a request to turn an existing single-signer private key into a DKLS vault
+type KeyImportRequest struct {
+	Name               string         `json:"name"`
+	SessionID          string         `json:"session_id"`
+	HexEncryptionKey   string         `json:"hex_encryption_key"` // this is the key used to encrypt and decrypt the import communications
+	HexChainCode       string         `json:"hex_chain_code"`
+	LocalPartyId       string         `json:"local_party_id"`
+	EncryptionPassword *common.Secret `json:"encryption_password"` // password used to encrypt the vault file
+	Email              string         `json:"email"`               // this is the email of the user that the vault backup will be sent to
+	PublicKeyEcdsa     string         `json:"public_key_ecdsa"`    // compressed public key of the imported ECDSA key
+	PublicKeyEddsa     string         `json:"public_key_eddsa"`    // optional , public key of the imported EdDSA key , a new EdDSA key is generated when it is empty
+	Threshold          int            `json:"threshold"`           // number of parties required to sign , 0 means the default threshold of the committee
+	Chain              string         `json:"chain"`               // chain of the declared address , bitcoin , ethereum , thorchain , cosmos or solana
+	Address            string         `json:"address"`             // address of the imported wallet , it must belong to the imported key
+	DerivePath         string         `json:"derive_path"`         // optional , derive path of the address , the address of the root key when it is empty
+}
+
+func (req *KeyImportRequest) IsValid() error {
+	if req.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if req.SessionID == "" {
+		return fmt.Errorf("session_id is required")
+	}
+	if _, err := uuid.Parse(req.SessionID); err != nil {
+		return fmt.Errorf("session_id is not valid")
+	}
+	if !isValidHexString(req.HexEncryptionKey) {
+		return fmt.Errorf("hex_encryption_key is not valid")
+	}
+	if !isValidHexString(req.HexChainCode) {
+		return fmt.Errorf("hex_chain_code is not valid")
+	}
+	if req.LocalPartyId == "" {
+		return fmt.Errorf("local_party_id is required")
+	}
+	if req.EncryptionPassword.Len() == 0 {
+		return fmt.Errorf("encryption_password is required")
+	}
+	if req.Email == "" {
+		return fmt.Errorf("email is required")
+	}
+	if buf, err := hex.DecodeString(req.PublicKeyEcdsa); err != nil || len(buf) != 33 {
+		return fmt.Errorf("public_key_ecdsa is not valid")
+	}
+	if req.PublicKeyEddsa != "" && !isValidHexString(req.PublicKeyEddsa) {
+		return fmt.Errorf("public_key_eddsa is not valid")
+	}
+	if req.Threshold < 0 {
+		return fmt.Errorf("threshold is not valid")
+	}
+	if req.Chain == "" {
+		return fmt.Errorf("chain is required")
+	}
+	if req.Address == "" {
+		return fmt.Errorf("address is required")
+	}
+	return req.checkAddress()
+}
+
+// checkAddress checks the declared address belongs to the declared keys , solana addresses belong to the EdDSA key.
+// The import fails when the resulting keys don't match the declared keys , so the address belongs to the imported vault.
+func (req *KeyImportRequest) checkAddress() error {
+	chain := recovery.Chain(req.Chain)
+	publicKey := req.PublicKeyEcdsa
+	if chain == recovery.Solana {
+		if req.PublicKeyEddsa == "" {
+			return fmt.Errorf("public_key_eddsa is required to import a %s address", chain)
+		}
+		publicKey = req.PublicKeyEddsa
+	}
+	if err := recovery.CheckAddress(chain, publicKey, req.HexChainCode, req.DerivePath, req.Address); err != nil {
+		return fmt.Errorf("address is not valid: %w", err)
+	}
+	return nil
+}
+
+// VaultCreateRequest returns the keygen request the imported vault is backed up with
+func (req *KeyImportRequest) VaultCreateRequest() VaultCreateRequest {
+	return VaultCreateRequest{
+		Name:               req.Name,
+		SessionID:          req.SessionID,
+		HexEncryptionKey:   req.HexEncryptionKey,
+		HexChainCode:       req.HexChainCode,
+		LocalPartyId:       req.LocalPartyId,
+		EncryptionPassword: req.EncryptionPassword,
+		Email:              req.Email,
+		LibType:            DKLS,
+		Threshold:          req.Threshold,
+	}
+}
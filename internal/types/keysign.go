@@ -2,16 +2,43 @@ package types
 
 import (
 	"errors"
+
+	"github.com/vultisig/mobile-tss-lib/tss"
+
+	"github.com/vultisig/vultisigner/common"
+)
+
+// SignatureFormat is the optional chain specific encoding of a signature
+type SignatureFormat string
+
+const (
+	SignatureFormatEthereum SignatureFormat = "ethereum" // r || s || v , v carries the EIP-155 chain id when ChainID is set
+	SignatureFormatBitcoin  SignatureFormat = "bitcoin"  // DER signature followed by the sighash type byte
+	SignatureFormatCompact  SignatureFormat = "compact"  // 64 bytes r || s
+	SignatureFormatEd25519  SignatureFormat = "ed25519"  // raw 64 bytes ed25519 signature
 )
 
 type KeysignRequest struct {
-	PublicKey        string   `json:"public_key"`         // public key, used to identify the backup file
-	Messages         []string `json:"messages"`           // Messages need to be signed
-	SessionID        string   `json:"session"`            // Session ID , it should be an UUID
-	HexEncryptionKey string   `json:"hex_encryption_key"` // Hex encryption key, used to encrypt the keysign messages
-	DerivePath       string   `json:"derive_path"`        // Derive Path
-	IsECDSA          bool     `json:"is_ecdsa"`           // indicate use ECDSA or EDDSA key to sign the messages
-	VaultPassword    string   `json:"vault_password"`     // password used to decrypt the vault file
+	PublicKey        string          `json:"public_key"`         // public key, used to identify the backup file
+	Messages         []string        `json:"messages"`           // Messages need to be signed
+	SessionID        string          `json:"session"`            // Session ID , it should be an UUID
+	HexEncryptionKey string          `json:"hex_encryption_key"` // Hex encryption key, used to encrypt the keysign messages
+	DerivePath       string          `json:"derive_path"`        // Derive Path
+	IsECDSA          bool            `json:"is_ecdsa"`           // indicate use ECDSA or EDDSA key to sign the messages
+	VaultPassword    *common.Secret  `json:"vault_password"`     // password used to decrypt the vault file
+	SignatureFormat  SignatureFormat `json:"signature_format"`   // optional chain specific encoding returned as formatted_signature
+	ChainID          uint64          `json:"chain_id"`           // EIP-155 chain id , only used by the ethereum signature format
+	SigHashType      byte            `json:"sighash_type"`       // sighash type appended by the bitcoin signature format , 0 means SIGHASH_ALL
+	KeysignPayload   string          `json:"keysign_payload"`    // optional base64 encoded vultisig.keysign.v1.KeysignPayload , summarized in co-sign notifications
+	ConfirmPending   bool            `json:"confirm_pending"`    // sign with the pending share of the last reshare or migration , it becomes active when the keysign succeeds
+}
+
+// VaultFile returns the backup file name of the share the request signs with , without the .bak extension
+func (r KeysignRequest) VaultFile() string {
+	if r.ConfirmPending {
+		return r.PublicKey + PendingVaultSuffix
+	}
+	return r.PublicKey
 }
 
 // IsValid checks if the keysign request is valid
@@ -31,6 +58,35 @@ func (r KeysignRequest) IsValid() error {
 	if r.DerivePath == "" {
 		return errors.New("invalid derive path")
 	}
+	switch r.SignatureFormat {
+	case "", SignatureFormatCompact:
+	case SignatureFormatEthereum, SignatureFormatBitcoin:
+		if !r.IsECDSA {
+			return errors.New("signature format requires ecdsa")
+		}
+	case SignatureFormatEd25519:
+		if r.IsECDSA {
+			return errors.New("signature format requires eddsa")
+		}
+	default:
+		return errors.New("invalid signature format")
+	}
 
 	return nil
 }
+
+// KeysignMessageResult is the outcome of signing one message of a keysign request
+type KeysignMessageResult struct {
+	Message            string               `json:"message"`                       // hex encoded message
+	Signature          *tss.KeysignResponse `json:"signature,omitempty"`           // signature , empty when signing failed
+	FormattedSignature string               `json:"formatted_signature,omitempty"` // hex encoded signature in the requested SignatureFormat
+	PublicKey          string               `json:"public_key,omitempty"`          // hex encoded derived child public key the signature was verified against
+	Error              string               `json:"error,omitempty"`               // reason of the failure
+}
+
+// KeysignResponse is the result of a keysign request , Results follow the order of KeysignRequest.Messages
+type KeysignResponse struct {
+	Results   []KeysignMessageResult `json:"results"`
+	Succeeded int                    `json:"succeeded"`
+	Failed    int                    `json:"failed"`
+}
@@ -4,15 +4,18 @@ import (
 	"fmt"
 
 	"github.com/google/uuid"
+
+	"github.com/vultisig/vultisigner/common"
 )
 
 // MigrationRequest is a struct that represents a request to reshare a vault
 type MigrationRequest struct {
-	PublicKey          string `json:"public_key"`          // public key ecdsa
-	SessionID          string `json:"session_id"`          // session id
-	HexEncryptionKey   string `json:"hex_encryption_key"`  // hex encryption key
-	EncryptionPassword string `json:"encryption_password"` // password used to encrypt the vault file
-	Email              string `json:"email"`
+	PublicKey          string         `json:"public_key"`          // public key ecdsa
+	SessionID          string         `json:"session_id"`          // session id
+	HexEncryptionKey   string         `json:"hex_encryption_key"`  // hex encryption key
+	EncryptionPassword *common.Secret `json:"encryption_password"` // password used to encrypt the vault file
+	Email              string         `json:"email"`
+	BackupDeliveryOptions
 }
 
 func (req *MigrationRequest) IsValid() error {
@@ -28,11 +31,11 @@ func (req *MigrationRequest) IsValid() error {
 	if !isValidHexString(req.HexEncryptionKey) {
 		return fmt.Errorf("hex_encryption_key is not valid")
 	}
-	if req.EncryptionPassword == "" {
+	if req.EncryptionPassword.Len() == 0 {
 		return fmt.Errorf("encryption_password is required")
 	}
-	if req.Email == "" {
+	if req.Email == "" && req.NeedsEmail() {
 		return fmt.Errorf("email is required")
 	}
-	return nil
+	return req.BackupDeliveryOptions.validate()
 }
@@ -0,0 +1,73 @@
+package types
+
+import (
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/vultisig/vultisigner/chainhelper/summary"
+	"github.com/vultisig/vultisigner/common"
+)
+
+// NotificationChannel is where a co-sign notification is delivered
+type NotificationChannel string
+
+const (
+	NotificationChannelEmail   NotificationChannel = "email"
+	NotificationChannelWebhook NotificationChannel = "webhook"
+)
+
+// NotificationSettingsRequest opts a vault in or out of co-sign notifications , both channels empty opts out
+type NotificationSettingsRequest struct {
+	PublicKeyECDSA string         `json:"public_key_ecdsa"`
+	Password       *common.Secret `json:"password"`    // password to decrypt the vault share , proves the caller owns the vault
+	Email          string         `json:"email"`       // optional , email the notifications are sent to
+	WebhookURL     string         `json:"webhook_url"` // optional , https url the notifications are posted to
+}
+
+func (r NotificationSettingsRequest) IsValid() error {
+	if r.PublicKeyECDSA == "" {
+		return errors.New("public_key_ecdsa is required")
+	}
+	if r.Password.Len() == 0 {
+		return errors.New("password is required")
+	}
+	if r.WebhookURL != "" {
+		u, err := url.Parse(r.WebhookURL)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return errors.New("webhook_url must be an https url")
+		}
+	}
+	return nil
+}
+
+// NotificationSettings is where the co-sign notifications of a vault are delivered
+type NotificationSettings struct {
+	Email      string `json:"email,omitempty"`
+	WebhookURL string `json:"webhook_url,omitempty"`
+}
+
+// KeysignNotification tells the vault owner that VultiServer co-signed , it is the body of the webhook request
+type KeysignNotification struct {
+	PublicKeyECDSA string           `json:"public_key_ecdsa"`
+	VaultName      string           `json:"vault_name"`
+	SessionID      string           `json:"session_id"`
+	SignedAt       time.Time        `json:"signed_at"`
+	Parties        []string         `json:"parties"`           // party ids of the devices that joined the keysign
+	Messages       int              `json:"messages"`          // number of signed messages
+	Summary        *summary.Summary `json:"summary,omitempty"` // decoded keysign payload , as described by the signing device
+	ReportURL      string           `json:"report_url"`        // opening it reports the signature as unauthorized and freezes the vault
+}
+
+// KeysignNotificationTask is the payload of the task that delivers a notification through one channel
+type KeysignNotificationTask struct {
+	Channel      NotificationChannel `json:"channel"`
+	Notification KeysignNotification `json:"notification"`
+}
+
+// VaultFreeze records why a vault is frozen , a frozen vault can't sign until an admin unfreezes it
+type VaultFreeze struct {
+	Reason    string    `json:"reason"`
+	SessionID string    `json:"session_id,omitempty"` // keysign session reported as unauthorized
+	FrozenAt  time.Time `json:"frozen_at"`
+}
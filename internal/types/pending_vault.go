@@ -0,0 +1,27 @@
+package types
+
+import (
+	"time"
+)
+
+// PendingVaultSuffix is appended to the public key in the file name of a pending vault share
+const PendingVaultSuffix = ".pending"
+
+// PendingVault is a vault share staged by a reshare or migration that not every party confirmed.
+// It replaces the active share once all parties report completion through the relay , or a confirmation keysign succeeds.
+// Until then the backup isn't delivered , and it is deleted when it expires.
+type PendingVault struct {
+	SessionID string                `json:"session_id"`
+	Parties   []string              `json:"parties"` // parties that joined the session , all of them have to complete
+	Email     string                `json:"email"`
+	Delivery  BackupDeliveryOptions `json:"delivery"`
+	Metadata  VaultMetadata         `json:"metadata"` // vault index entry of the share once it is active
+	StagedAt  time.Time             `json:"staged_at"`
+	ExpiresAt time.Time             `json:"expires_at"`
+}
+
+// PendingVaultTask is the payload of the tasks that promote and expire a pending vault share
+type PendingVaultTask struct {
+	PublicKeyECDSA string `json:"public_key_ecdsa"`
+	SessionID      string `json:"session_id"`
+}
@@ -0,0 +1,51 @@
+package types
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/vultisig/vultisigner/common"
+)
+
+// RefreshRequest is a struct that represents a request to refresh the key shares of a DKLS vault
+type RefreshRequest struct {
+	PublicKey          string         `json:"public_key"`          // public key ecdsa
+	SessionID          string         `json:"session_id"`          // session id
+	HexEncryptionKey   string         `json:"hex_encryption_key"`  // hex encryption key
+	EncryptionPassword *common.Secret `json:"encryption_password"` // password used to encrypt the vault file
+	Email              string         `json:"email"`
+}
+
+func (req *RefreshRequest) IsValid() error {
+	if req.PublicKey == "" {
+		return fmt.Errorf("public_key is required")
+	}
+	if req.SessionID == "" {
+		return fmt.Errorf("session_id is required")
+	}
+	if _, err := uuid.Parse(req.SessionID); err != nil {
+		return fmt.Errorf("session_id is not valid")
+	}
+	if req.HexEncryptionKey == "" {
+		return fmt.Errorf("hex_encryption_key is required")
+	}
+	if !isValidHexString(req.HexEncryptionKey) {
+		return fmt.Errorf("hex_encryption_key is not valid")
+	}
+	if req.EncryptionPassword.Len() == 0 {
+		return fmt.Errorf("encryption_password is required")
+	}
+	if req.Email == "" {
+		return fmt.Errorf("email is required")
+	}
+	return nil
+}
+
+// RefreshReminder is the payload of a scheduled email reminding the user to refresh the vault
+type RefreshReminder struct {
+	PublicKey   string `json:"public_key"`   // public key ecdsa
+	VaultName   string `json:"vault_name"`   // name of the vault
+	Email       string `json:"email"`        // email address the reminder is sent to
+	ScheduledAt int64  `json:"scheduled_at"` // unix time the reminder was scheduled
+}
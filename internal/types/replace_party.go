@@ -0,0 +1,57 @@
+package types
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/vultisig/vultisigner/common"
+)
+
+// ReplacePartyRequest is a request to replace a lost device of a vault , the remaining parties reshare with the new device
+type ReplacePartyRequest struct {
+	PublicKey          string         `json:"public_key"`          // public key ecdsa
+	SessionID          string         `json:"session_id"`          // reshare session id
+	HexEncryptionKey   string         `json:"hex_encryption_key"`  // hex encryption key of the reshare session
+	EncryptionPassword *common.Secret `json:"encryption_password"` // password of the vault share , the new share is encrypted with it too
+	Email              string         `json:"email"`               // email the vault share was sent to
+	Code               string         `json:"code"`                // verification code sent to the email
+	LostParty          string         `json:"lost_party"`          // party id of the lost device , it is revoked
+	NewParty           string         `json:"new_party"`           // party id of the replacement device
+	Threshold          int            `json:"threshold"`           // number of parties required to sign after the replacement , 0 keeps the threshold of the vault
+	BackupDeliveryOptions
+}
+
+func (req *ReplacePartyRequest) IsValid() error {
+	if req.PublicKey == "" {
+		return fmt.Errorf("public_key is required")
+	}
+	if req.SessionID == "" {
+		return fmt.Errorf("session_id is required")
+	}
+	if _, err := uuid.Parse(req.SessionID); err != nil {
+		return fmt.Errorf("session_id is not valid")
+	}
+	if !isValidHexString(req.HexEncryptionKey) {
+		return fmt.Errorf("hex_encryption_key is not valid")
+	}
+	if req.EncryptionPassword.Len() == 0 {
+		return fmt.Errorf("encryption_password is required")
+	}
+	if req.Email == "" {
+		return fmt.Errorf("email is required")
+	}
+	if req.Code == "" {
+		return fmt.Errorf("code is required")
+	}
+	if req.LostParty == "" || req.NewParty == "" {
+		return fmt.Errorf("lost_party and new_party are required")
+	}
+	if req.LostParty == req.NewParty {
+		return fmt.Errorf("new_party must be different from lost_party")
+	}
+	if req.Threshold < 0 {
+		return fmt.Errorf("threshold is not valid")
+	}
+	return req.BackupDeliveryOptions.validate()
+}
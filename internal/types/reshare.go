@@ -4,21 +4,27 @@ import (
 	"fmt"
 
 	"github.com/google/uuid"
+
+	"github.com/vultisig/vultisigner/common"
 )
 
 // ReshareRequest is a struct that represents a request to reshare a vault
 type ReshareRequest struct {
-	Name               string   `json:"name"`                // name of the vault
-	PublicKey          string   `json:"public_key"`          // public key ecdsa
-	SessionID          string   `json:"session_id"`          // session id
-	HexEncryptionKey   string   `json:"hex_encryption_key"`  // hex encryption key
-	HexChainCode       string   `json:"hex_chain_code"`      // hex chain code
-	LocalPartyId       string   `json:"local_party_id"`      // local party id
-	OldParties         []string `json:"old_parties"`         // old parties
-	EncryptionPassword string   `json:"encryption_password"` // password used to encrypt the vault file
-	Email              string   `json:"email"`
-	OldResharePrefix   string   `json:"old_reshare_prefix"`
-	LibType            LibType  `json:"lib_type"`
+	Name               string         `json:"name"`                // name of the vault
+	PublicKey          string         `json:"public_key"`          // public key ecdsa
+	SessionID          string         `json:"session_id"`          // session id
+	HexEncryptionKey   string         `json:"hex_encryption_key"`  // hex encryption key
+	HexChainCode       string         `json:"hex_chain_code"`      // hex chain code
+	LocalPartyId       string         `json:"local_party_id"`      // local party id
+	OldParties         []string       `json:"old_parties"`         // old parties
+	EncryptionPassword *common.Secret `json:"encryption_password"` // password used to encrypt the vault file
+	Email              string         `json:"email"`
+	OldResharePrefix   string         `json:"old_reshare_prefix"`
+	LibType            LibType        `json:"lib_type"`
+	Threshold          int            `json:"threshold"`                  // number of parties required to sign after reshare , 0 means the default threshold of the new committee
+	RevokedParty       string         `json:"revoked_party"`              // party id removed by /vault/replace-party , it is revoked once the reshare succeeds
+	ExpectedParties    []string       `json:"expected_parties,omitempty"` // optional , the reshare fails unless exactly these parties join
+	BackupDeliveryOptions
 }
 
 func (req *ReshareRequest) IsValid() error {
@@ -43,14 +49,20 @@ func (req *ReshareRequest) IsValid() error {
 	if !isValidHexString(req.HexChainCode) {
 		return fmt.Errorf("hex_chain_code is not valid")
 	}
-	if req.EncryptionPassword == "" {
+	if req.EncryptionPassword.Len() == 0 {
 		return fmt.Errorf("encryption_password is required")
 	}
-	if req.Email == "" {
+	if req.Email == "" && req.NeedsEmail() {
 		return fmt.Errorf("email is required")
 	}
+	if req.PublicKey == "" && req.Delivery() == BackupDeliveryResult {
+		return fmt.Errorf("public_key is required by the result backup delivery")
+	}
 	if len(req.OldParties) == 0 {
 		return fmt.Errorf("old_parties is required")
 	}
-	return nil
+	if req.Threshold < 0 {
+		return fmt.Errorf("threshold is not valid")
+	}
+	return req.BackupDeliveryOptions.validate()
 }
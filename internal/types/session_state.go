@@ -0,0 +1,62 @@
+package types
+
+import (
+	"time"
+)
+
+// SessionStatus is the status of the operation of an MPC session
+type SessionStatus string
+
+const (
+	SessionQueued    SessionStatus = "queued"    // the task is enqueued
+	SessionJoined    SessionStatus = "joined"    // the worker registered in the relay session , waiting for the other parties
+	SessionRunning   SessionStatus = "running"   // all parties joined , the MPC protocol runs
+	SessionCompleted SessionStatus = "completed" // the operation succeeded
+	SessionFailed    SessionStatus = "failed"    // the operation failed
+	SessionCancelled SessionStatus = "cancelled" // the client cancelled the session
+)
+
+// order returns the position of the status in the state machine , a session only moves to a later position
+func (s SessionStatus) order() int {
+	switch s {
+	case SessionQueued:
+		return 0
+	case SessionJoined:
+		return 1
+	case SessionRunning:
+		return 2
+	case SessionCompleted, SessionFailed, SessionCancelled:
+		return 3
+	default:
+		return -1
+	}
+}
+
+// IsFinal returns true when the session can't change its status anymore
+func (s SessionStatus) IsFinal() bool {
+	return s.order() == 3
+}
+
+// CanMoveTo returns true when the session can move from the status to the next one
+func (s SessionStatus) CanMoveTo(next SessionStatus) bool {
+	return next.order() > s.order() && s.order() >= 0
+}
+
+// SessionState is the operation of an MPC session , it is created once per session id
+type SessionState struct {
+	SessionID string        `json:"session_id"`
+	Operation string        `json:"operation"` // keygen , keysign , reshare , refresh , migrate or import
+	TaskID    string        `json:"task_id"`
+	Queue     string        `json:"queue"`
+	Status    SessionStatus `json:"status"`
+	Error     string        `json:"error,omitempty"` // why the operation failed
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+	KeyHash   string        `json:"key_hash,omitempty"` // sha256 of the hex encryption key of the session , it authenticates cancellation
+}
+
+// Public returns the session state without the hash of the encryption key
+func (s SessionState) Public() SessionState {
+	s.KeyHash = ""
+	return s
+}
@@ -0,0 +1,42 @@
+package types
+
+import "testing"
+
+func TestSessionStatusCanMoveTo(t *testing.T) {
+	statuses := []SessionStatus{SessionQueued, SessionJoined, SessionRunning, SessionCompleted, SessionFailed, SessionCancelled, "unknown"}
+	allowed := map[SessionStatus][]SessionStatus{
+		SessionQueued:  {SessionJoined, SessionRunning, SessionCompleted, SessionFailed, SessionCancelled},
+		SessionJoined:  {SessionRunning, SessionCompleted, SessionFailed, SessionCancelled},
+		SessionRunning: {SessionCompleted, SessionFailed, SessionCancelled},
+	}
+	for _, from := range statuses {
+		for _, to := range statuses {
+			expected := false
+			for _, next := range allowed[from] {
+				if next == to {
+					expected = true
+				}
+			}
+			if from.CanMoveTo(to) != expected {
+				t.Errorf("%s -> %s: expected %v", from, to, expected)
+			}
+		}
+	}
+}
+
+func TestSessionStatusIsFinal(t *testing.T) {
+	final := map[SessionStatus]bool{
+		SessionQueued:    false,
+		SessionJoined:    false,
+		SessionRunning:   false,
+		SessionCompleted: true,
+		SessionFailed:    true,
+		SessionCancelled: true,
+		"unknown":        false,
+	}
+	for status, expected := range final {
+		if status.IsFinal() != expected {
+			t.Errorf("%s: expected final %v", status, expected)
+		}
+	}
+}
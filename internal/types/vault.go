@@ -5,6 +5,9 @@ import (
 	"fmt"
 
 	"github.com/google/uuid"
+	keygen "github.com/vultisig/commondata/go/vultisig/keygen/v1"
+
+	"github.com/vultisig/vultisigner/common"
 )
 
 type LibType int
@@ -14,16 +17,28 @@ const (
 	DKLS
 )
 
+// VaultLibType converts the lib type saved in a vault file , both enums share the same values
+func VaultLibType(libType keygen.LibType) LibType {
+	return LibType(libType)
+}
+
+// KeygenLibType converts the lib type to the one saved in a vault file
+func (l LibType) KeygenLibType() keygen.LibType {
+	return keygen.LibType(l)
+}
+
 // VaultCreateRequest is a struct that represents a request to create a new vault from integration.
 type VaultCreateRequest struct {
-	Name               string  `json:"name" validate:"required"`
-	SessionID          string  `json:"session_id" validate:"required"`
-	HexEncryptionKey   string  `json:"hex_encryption_key" validate:"required"` // this is the key used to encrypt and decrypt the keygen communications
-	HexChainCode       string  `json:"hex_chain_code" validate:"required"`
-	LocalPartyId       string  `json:"local_party_id"`                          // when this field is empty , then server will generate a random local party id
-	EncryptionPassword string  `json:"encryption_password" validate:"required"` // password used to encrypt the vault file
-	Email              string  `json:"email" validate:"required"`               // this is the email of the user that the vault backup will be sent to
-	LibType            LibType `json:"lib_type"`                                // this is the type of the vault
+	Name               string         `json:"name" validate:"required"`
+	SessionID          string         `json:"session_id" validate:"required"`
+	HexEncryptionKey   string         `json:"hex_encryption_key" validate:"required"` // this is the key used to encrypt and decrypt the keygen communications
+	HexChainCode       string         `json:"hex_chain_code" validate:"required"`
+	LocalPartyId       string         `json:"local_party_id"`                          // when this field is empty , then server will generate a random local party id
+	EncryptionPassword *common.Secret `json:"encryption_password" validate:"required"` // password used to encrypt the vault file
+	Email              string         `json:"email"`                                   // this is the email of the user that the vault backup will be sent to , required by the email and download backup delivery
+	LibType            LibType        `json:"lib_type"`                                // this is the type of the vault
+	Threshold          int            `json:"threshold"`                               // number of parties required to sign , 0 means the default threshold of the committee
+	BackupDeliveryOptions
 }
 
 func isValidHexString(s string) bool {
@@ -53,13 +68,16 @@ func (req *VaultCreateRequest) IsValid() error {
 	if !isValidHexString(req.HexChainCode) {
 		return fmt.Errorf("hex_chain_code is not valid")
 	}
-	if req.EncryptionPassword == "" {
+	if req.EncryptionPassword.Len() == 0 {
 		return fmt.Errorf("encryption_password is required")
 	}
-	if req.Email == "" {
+	if req.Email == "" && req.NeedsEmail() {
 		return fmt.Errorf("email is required")
 	}
-	return nil
+	if req.Threshold < 0 {
+		return fmt.Errorf("threshold is not valid")
+	}
+	return req.BackupDeliveryOptions.validate()
 }
 
 // VaultCreateResponse is a struct that represents a response to create a new vault
@@ -78,4 +96,5 @@ type VaultGetResponse struct {
 	PublicKeyEddsa string `json:"public_key_eddsa"`
 	HexChainCode   string `json:"hex_chain_code"`
 	LocalPartyId   string `json:"local_party_id"`
+	Threshold      int    `json:"threshold"`
 }
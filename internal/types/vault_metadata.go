@@ -0,0 +1,43 @@
+package types
+
+import (
+	"time"
+)
+
+// VaultMetadata is the non-secret information about a vault kept in the vault index ,
+// it can be queried without the vault password
+type VaultMetadata struct {
+	PublicKeyEcdsa string    `json:"public_key_ecdsa"`
+	PublicKeyEddsa string    `json:"public_key_eddsa"`
+	Name           string    `json:"name"`
+	FileName       string    `json:"file_name"` // file name of the vault share when it is emailed
+	LibType        LibType   `json:"lib_type"`
+	SignerCount    int       `json:"signer_count"`
+	Threshold      int       `json:"threshold"`
+	RevokedParties []string  `json:"revoked_parties,omitempty"` // party ids removed from the vault by replace-party
+	EmailHash      string    `json:"email_hash,omitempty"`      // salted hash of the email the vault share was sent to
+	PGPFingerprint string    `json:"pgp_fingerprint,omitempty"` // OpenPGP key the emailed vault shares are encrypted to
+	CreatedAt      time.Time `json:"created_at"`
+	LastUsedAt     time.Time `json:"last_used_at"` // last keygen , reshare , migrate , refresh or keysign
+}
+
+// VaultMetadataQuery filters the vault index , zero values match every vault
+type VaultMetadataQuery struct {
+	LibType     *LibType  `query:"lib_type"`
+	EmailHash   string    `query:"email_hash"`
+	UnusedSince time.Time `query:"-"` // vaults not used since the time
+	Limit       int       `query:"limit"`
+}
+
+// VaultIndexStats is the number of vaults in the index , in total and per lib type
+type VaultIndexStats struct {
+	Total     int64             `json:"total"`
+	ByLibType map[LibType]int64 `json:"by_lib_type"`
+}
+
+// VaultEmailLookupRequest asks the server to email all vault shares sent to the email ,
+// the first request emails a verification code , the request with the code emails the vault shares
+type VaultEmailLookupRequest struct {
+	Email string `json:"email"`
+	Code  string `json:"code,omitempty"`
+}
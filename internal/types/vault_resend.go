@@ -1,7 +1,9 @@
 package types
 
+import "github.com/vultisig/vultisigner/common"
+
 type VaultResendRequest struct {
-	PublicKeyECDSA string `json:"public_key_ecdsa"`
-	Password       string `json:"password"`
-	Email          string `json:"email"`
+	PublicKeyECDSA string         `json:"public_key_ecdsa"`
+	Password       *common.Secret `json:"password"`
+	Email          string         `json:"email"`
 }
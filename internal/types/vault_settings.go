@@ -0,0 +1,10 @@
+package types
+
+// VaultSettings is the server-side information about a vault that isn't part of the vault share ,
+// it's kept next to the vault share backup in the block storage
+type VaultSettings struct {
+	PublicKeyEcdsa  string            `json:"public_key_ecdsa"`
+	Threshold       int               `json:"threshold,omitempty"`        // number of parties required to sign , 0 means the default threshold of the signers
+	PartyIdentities map[string]string `json:"party_identities,omitempty"` // pinned Ed25519 identity keys used by pairwise encryption , by party id
+	RevokedParties  []string          `json:"revoked_parties,omitempty"`  // party ids removed from the vault by replace-party , sessions they join are rejected
+}
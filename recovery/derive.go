@@ -0,0 +1,260 @@
+package recovery
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/base58"
+	"github.com/btcsuite/btcd/btcutil/bech32"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/decred/dcrd/dcrec/edwards/v2"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/vultisig/mobile-tss-lib/tss"
+	"golang.org/x/crypto/sha3"
+)
+
+// Chain is a chain the recovery tool derives keys and addresses for
+type Chain string
+
+const (
+	Bitcoin   Chain = "bitcoin"
+	Ethereum  Chain = "ethereum" // the address is valid on every EVM chain
+	THORChain Chain = "thorchain"
+	Cosmos    Chain = "cosmos"
+	Solana    Chain = "solana"
+)
+
+// DefaultDerivePath returns the derive path the vultisig apps use for the chain , EdDSA chains are not derived
+func DefaultDerivePath(chain Chain) (string, error) {
+	switch chain {
+	case Bitcoin:
+		return "m/84'/0'/0'/0/0", nil
+	case Ethereum:
+		return "m/44'/60'/0'/0/0", nil
+	case THORChain:
+		return "m/44'/931'/0'/0/0", nil
+	case Cosmos:
+		return "m/44'/118'/0'/0/0", nil
+	case Solana:
+		return "", nil
+	default:
+		return "", fmt.Errorf("unsupported chain: %s", chain)
+	}
+}
+
+// DerivedKey is the private key and address of a chain
+type DerivedKey struct {
+	Chain      Chain
+	DerivePath string
+	PrivateKey string // hex encoded , WIF for bitcoin , the expanded ed25519 key for solana
+	PublicKey  string // hex encoded
+	Address    string
+}
+
+// Derive derives the private key and address of the chain from the recovered keys
+func (k *Keys) Derive(chain Chain, derivePath string) (*DerivedKey, error) {
+	if chain == Solana {
+		_, publicKey, err := edwards.PrivKeyFromScalar(k.EdDSA)
+		if err != nil {
+			return nil, fmt.Errorf("invalid EdDSA private key: %w", err)
+		}
+		publicKeyBytes := publicKey.SerializeCompressed()
+		addresses, err := Addresses(chain, publicKeyBytes)
+		if err != nil {
+			return nil, err
+		}
+		return &DerivedKey{
+			Chain:      chain,
+			PrivateKey: hex.EncodeToString(ExpandedEdDSAKey(k.EdDSA)),
+			PublicKey:  hex.EncodeToString(publicKeyBytes),
+			Address:    addresses[0],
+		}, nil
+	}
+	privateKeyBytes, err := DeriveECDSAPrivateKey(k.ECDSA, k.ChainCode, derivePath)
+	if err != nil {
+		return nil, err
+	}
+	privateKey := secp256k1.PrivKeyFromBytes(privateKeyBytes)
+	publicKey := privateKey.PubKey()
+	result := &DerivedKey{
+		Chain:      chain,
+		DerivePath: derivePath,
+		PrivateKey: hex.EncodeToString(privateKeyBytes),
+		PublicKey:  hex.EncodeToString(publicKey.SerializeCompressed()),
+	}
+	addresses, err := Addresses(chain, publicKey.SerializeCompressed())
+	if err != nil {
+		return nil, err
+	}
+	result.Address = addresses[0]
+	if chain == Bitcoin {
+		wif, err := btcutil.NewWIF(privateKey, &chaincfg.MainNetParams, true)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create WIF: %w", err)
+		}
+		result.PrivateKey = wif.String()
+	}
+	return result, nil
+}
+
+// ExpandedEdDSAKey returns the 64 bytes expanded ed25519 secret key of the big endian private scalar ,
+// the little endian scalar followed by the nonce prefix. The vault key is a scalar , not an ed25519 seed ,
+// so wallets that import a 32 bytes seed can't use it , sign with SignEdDSA or an expanded key signer instead.
+func ExpandedEdDSAKey(privateKey []byte) []byte {
+	scalar := slices.Clone(privateKey)
+	slices.Reverse(scalar)
+	prefix := sha512.Sum512(append([]byte("vultisig recovery nonce prefix"), scalar...))
+	return append(scalar, prefix[32:]...)
+}
+
+// SignEdDSA signs the message with the recovered EdDSA key , the signature is a standard 64 bytes ed25519 signature
+func (k *Keys) SignEdDSA(message []byte) ([]byte, error) {
+	privateKey, _, err := edwards.PrivKeyFromScalar(k.EdDSA)
+	if err != nil {
+		return nil, fmt.Errorf("invalid EdDSA private key: %w", err)
+	}
+	r, s, err := edwards.Sign(privateKey, message)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign: %w", err)
+	}
+	return edwards.NewSignature(r, s).Serialize(), nil
+}
+
+// Addresses returns the addresses of the public key on the chain , the address the vultisig apps use comes first.
+// Bitcoin also has the legacy P2PKH address , single-signer wallets often use it.
+func Addresses(chain Chain, publicKey []byte) ([]string, error) {
+	if chain == Solana {
+		if len(publicKey) != 32 {
+			return nil, fmt.Errorf("invalid EdDSA public key length: %d", len(publicKey))
+		}
+		return []string{base58.Encode(publicKey)}, nil
+	}
+	parsed, err := secp256k1.ParsePubKey(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ECDSA public key: %w", err)
+	}
+	keyHash := btcutil.Hash160(parsed.SerializeCompressed())
+	switch chain {
+	case Bitcoin:
+		segwit, err := btcutil.NewAddressWitnessPubKeyHash(keyHash, &chaincfg.MainNetParams)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create bitcoin address: %w", err)
+		}
+		legacy, err := btcutil.NewAddressPubKeyHash(keyHash, &chaincfg.MainNetParams)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create bitcoin address: %w", err)
+		}
+		return []string{segwit.EncodeAddress(), legacy.EncodeAddress()}, nil
+	case Ethereum:
+		return []string{ethereumAddress(parsed.SerializeUncompressed())}, nil
+	case THORChain, Cosmos:
+		prefix := "thor"
+		if chain == Cosmos {
+			prefix = "cosmos"
+		}
+		converted, err := bech32.ConvertBits(keyHash, 8, 5, true)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert bits: %w", err)
+		}
+		address, err := bech32.Encode(prefix, converted)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s address: %w", chain, err)
+		}
+		return []string{address}, nil
+	default:
+		return nil, fmt.Errorf("unsupported chain: %s", chain)
+	}
+}
+
+// CheckAddress checks the address belongs to the public key on the chain , derived with the derive path when it is set
+func CheckAddress(chain Chain, hexPublicKey, hexChainCode, derivePath, address string) error {
+	isEdDSA := chain == Solana
+	if derivePath != "" {
+		if isEdDSA {
+			return fmt.Errorf("%s addresses are not derived", chain)
+		}
+		derived, err := tss.GetDerivedPubKey(hexPublicKey, hexChainCode, derivePath, false)
+		if err != nil {
+			return fmt.Errorf("failed to derive public key: %w", err)
+		}
+		hexPublicKey = derived
+	}
+	publicKey, err := hex.DecodeString(hexPublicKey)
+	if err != nil {
+		return fmt.Errorf("invalid public key: %w", err)
+	}
+	addresses, err := Addresses(chain, publicKey)
+	if err != nil {
+		return err
+	}
+	for _, candidate := range addresses {
+		// ethereum addresses are checksummed by their case
+		if candidate == address || (chain == Ethereum && strings.EqualFold(candidate, address)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("address %s doesn't belong to the public key on %s", address, chain)
+}
+
+// DeriveECDSAPrivateKey derives the child private key with non-hardened BIP32 derivation ,
+// the same way the vault derives child public keys , hardened markers in the path are ignored
+func DeriveECDSAPrivateKey(privateKey []byte, chainCode []byte, derivePath string) ([]byte, error) {
+	if len(chainCode) != 32 {
+		return nil, errors.New("invalid chain code length")
+	}
+	path, err := tss.GetDerivePathBytes(derivePath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid derive path: %w", err)
+	}
+	order := secp256k1.S256().N
+	key := new(big.Int).SetBytes(privateKey)
+	currentChainCode := chainCode
+	for _, index := range path {
+		publicKey := secp256k1.PrivKeyFromBytes(key.FillBytes(make([]byte, 32))).PubKey().SerializeCompressed()
+		data := make([]byte, 0, 37)
+		data = append(data, publicKey...)
+		data = binary.BigEndian.AppendUint32(data, index)
+		mac := hmac.New(sha512.New, currentChainCode)
+		mac.Write(data)
+		sum := mac.Sum(nil)
+		il := new(big.Int).SetBytes(sum[:32])
+		if il.Cmp(order) >= 0 {
+			return nil, fmt.Errorf("invalid child key at index %d", index)
+		}
+		key.Add(key, il)
+		key.Mod(key, order)
+		if key.Sign() == 0 {
+			return nil, fmt.Errorf("invalid child key at index %d", index)
+		}
+		currentChainCode = sum[32:]
+	}
+	return key.FillBytes(make([]byte, 32)), nil
+}
+
+// ethereumAddress returns the EIP-55 checksummed address of the uncompressed public key
+func ethereumAddress(uncompressed []byte) string {
+	hash := sha3.NewLegacyKeccak256()
+	hash.Write(uncompressed[1:])
+	address := hex.EncodeToString(hash.Sum(nil)[12:])
+	hash = sha3.NewLegacyKeccak256()
+	hash.Write([]byte(address))
+	checksum := hex.EncodeToString(hash.Sum(nil))
+	var sb strings.Builder
+	sb.WriteString("0x")
+	for i, c := range address {
+		if c >= 'a' && checksum[i] >= '8' {
+			sb.WriteRune(c - 'a' + 'A')
+		} else {
+			sb.WriteRune(c)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,143 @@
+// Package dkls exports the private key of a DKLS vault from its keyshares , for the offline recovery tool.
+// It binds the mpc library directly , so the recovery tool doesn't link the worker service.
+package dkls
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	session "go-wrapper/go-dkls/sessions"
+	eddsaSession "go-wrapper/go-schnorr/sessions"
+
+	"github.com/vultisig/vultisigner/common"
+)
+
+// Export reconstructs the 32 bytes big endian private key from the base64 encoded keyshares of the parties.
+// It runs the key export protocol in process: the first party is the receiver , every other party encrypts
+// its share for the receiver , and the receiver combines them.
+func Export(keyshares []string, partyIDs []string, isEdDSA bool) ([]byte, error) {
+	if len(keyshares) != len(partyIDs) {
+		return nil, fmt.Errorf("%d keyshares for %d parties", len(keyshares), len(partyIDs))
+	}
+	if len(keyshares) < 2 {
+		return nil, errors.New("at least two keyshares are required")
+	}
+	var lib keyExporter = dklsExporter{}
+	if isEdDSA {
+		lib = schnorrExporter{}
+	}
+	handles := make([]int32, 0, len(keyshares))
+	defer func() {
+		for _, handle := range handles {
+			_ = lib.keyshareFree(handle)
+		}
+	}()
+	for idx, keyshare := range keyshares {
+		buf, err := common.DecodeBase64Secret(keyshare)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode keyshare of party %s: %w", partyIDs[idx], err)
+		}
+		handle, err := lib.keyshareFromBytes(buf.Bytes())
+		buf.Wipe()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load keyshare of party %s: %w", partyIDs[idx], err)
+		}
+		handles = append(handles, handle)
+	}
+
+	ids := []byte(strings.Join(partyIDs, "\x00"))
+	receiver, setup, err := lib.receiverNew(handles[0], ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create key export receiver: %w", err)
+	}
+	finished := false
+	for idx := 1; idx < len(handles); idx++ {
+		message, err := lib.exporter(setup, []byte(partyIDs[idx]), handles[idx])
+		if err != nil {
+			return nil, fmt.Errorf("failed to export keyshare of party %s: %w", partyIDs[idx], err)
+		}
+		finished, err = lib.receiverInputMessage(receiver, message)
+		if err != nil {
+			return nil, fmt.Errorf("failed to receive keyshare of party %s: %w", partyIDs[idx], err)
+		}
+	}
+	if !finished {
+		return nil, errors.New("key export didn't finish , not enough keyshares")
+	}
+	privateKey, err := lib.receiverFinish(receiver)
+	if err != nil {
+		return nil, fmt.Errorf("failed to finish key export: %w", err)
+	}
+	if isEdDSA {
+		// ed25519 scalars are little endian
+		slices.Reverse(privateKey)
+	}
+	return privateKey, nil
+}
+
+type keyExporter interface {
+	keyshareFromBytes(buf []byte) (int32, error)
+	keyshareFree(share int32) error
+	receiverNew(share int32, ids []byte) (int32, []byte, error)
+	exporter(setup []byte, id []byte, share int32) ([]byte, error)
+	receiverInputMessage(receiver int32, message []byte) (bool, error)
+	receiverFinish(receiver int32) ([]byte, error)
+}
+
+type dklsExporter struct{}
+
+func (dklsExporter) keyshareFromBytes(buf []byte) (int32, error) {
+	h, err := session.DklsKeyshareFromBytes(buf)
+	return int32(h), err
+}
+
+func (dklsExporter) keyshareFree(share int32) error {
+	return session.DklsKeyshareFree(session.Handle(share))
+}
+
+func (dklsExporter) receiverNew(share int32, ids []byte) (int32, []byte, error) {
+	h, setup, err := session.DklsKeyExportReceiverNew(session.Handle(share), ids)
+	return int32(h), setup, err
+}
+
+func (dklsExporter) exporter(setup []byte, id []byte, share int32) ([]byte, error) {
+	return session.DklsKeyExporter(setup, id, session.Handle(share))
+}
+
+func (dklsExporter) receiverInputMessage(receiver int32, message []byte) (bool, error) {
+	return session.DklsKeyExportReceiverInputMessage(session.Handle(receiver), message)
+}
+
+func (dklsExporter) receiverFinish(receiver int32) ([]byte, error) {
+	return session.DklsKeyExportReceiverFinish(session.Handle(receiver))
+}
+
+type schnorrExporter struct{}
+
+func (schnorrExporter) keyshareFromBytes(buf []byte) (int32, error) {
+	h, err := eddsaSession.SchnorrKeyshareFromBytes(buf)
+	return int32(h), err
+}
+
+func (schnorrExporter) keyshareFree(share int32) error {
+	return eddsaSession.SchnorrKeyshareFree(eddsaSession.Handle(share))
+}
+
+func (schnorrExporter) receiverNew(share int32, ids []byte) (int32, []byte, error) {
+	h, setup, err := eddsaSession.SchnorrKeyExportReceiverNew(eddsaSession.Handle(share), ids)
+	return int32(h), setup, err
+}
+
+func (schnorrExporter) exporter(setup []byte, id []byte, share int32) ([]byte, error) {
+	return eddsaSession.SchnorrKeyExporter(setup, id, eddsaSession.Handle(share))
+}
+
+func (schnorrExporter) receiverInputMessage(receiver int32, message []byte) (bool, error) {
+	return eddsaSession.SchnorrKeyExportReceiverInputMessage(eddsaSession.Handle(receiver), message)
+}
+
+func (schnorrExporter) receiverFinish(receiver int32) ([]byte, error) {
+	return eddsaSession.SchnorrKeyExportReceiverFinish(eddsaSession.Handle(receiver))
+}
@@ -0,0 +1,212 @@
+package recovery
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+
+	"github.com/decred/dcrd/dcrec/edwards/v2"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	keygenType "github.com/vultisig/commondata/go/vultisig/keygen/v1"
+	vaultType "github.com/vultisig/commondata/go/vultisig/vault/v1"
+
+	"github.com/vultisig/vultisigner/common"
+)
+
+// Keys is the root key material reconstructed from the vault shares
+type Keys struct {
+	PublicKeyEcdsa string
+	PublicKeyEddsa string
+	ChainCode      []byte
+	ECDSA          []byte // 32 bytes big endian secp256k1 private key
+	EdDSA          []byte // 32 bytes big endian ed25519 private scalar
+}
+
+// SecretShare is the Shamir share of one party , X is the evaluation point
+type SecretShare struct {
+	X      *big.Int
+	Secret *big.Int
+}
+
+// DKLSExporter reconstructs the 32 bytes big endian private key from the base64 encoded DKLS keyshares of the parties.
+// DKLS keyshares don't expose their secret share , the key is exported with the key export protocol of the mpc library.
+type DKLSExporter func(keyshares []string, partyIDs []string, isEdDSA bool) ([]byte, error)
+
+// gg20LocalState is the part of the mobile-tss-lib local state the recovery needs
+type gg20LocalState struct {
+	ECDSALocalData gg20LocalSecrets `json:"ecdsa_local_data"`
+	EDDSALocalData gg20LocalSecrets `json:"eddsa_local_data"`
+}
+
+type gg20LocalSecrets struct {
+	Xi      *big.Int `json:"Xi"`
+	ShareID *big.Int `json:"ShareID"`
+}
+
+// CheckShares makes sure the shares belong to the same vault and lib type , come from different parties ,
+// and that there are at least threshold of them. A threshold of 0 means the default threshold of the signers ,
+// the vault shares don't carry the threshold. It returns the threshold of the vault.
+func CheckShares(vaults []*vaultType.Vault, threshold int) (int, error) {
+	if len(vaults) == 0 {
+		return 0, errors.New("no vault share")
+	}
+	first := vaults[0]
+	threshold, err := common.ResolveThreshold(threshold, len(first.Signers))
+	if err != nil {
+		return 0, fmt.Errorf("invalid threshold: %w", err)
+	}
+	parties := make(map[string]bool)
+	for idx, vault := range vaults {
+		if vault.PublicKeyEcdsa != first.PublicKeyEcdsa || vault.PublicKeyEddsa != first.PublicKeyEddsa {
+			return 0, fmt.Errorf("share %d belongs to a different vault", idx)
+		}
+		if vault.HexChainCode != first.HexChainCode {
+			return 0, fmt.Errorf("share %d has a different chain code", idx)
+		}
+		if vault.LibType != first.LibType {
+			return 0, fmt.Errorf("share %d has a different lib type", idx)
+		}
+		if !slices.Equal(vault.Signers, first.Signers) {
+			return 0, fmt.Errorf("share %d has a different signer committee", idx)
+		}
+		if !slices.Contains(vault.Signers, vault.LocalPartyId) {
+			return 0, fmt.Errorf("share %d party %s is not a signer", idx, vault.LocalPartyId)
+		}
+		if parties[vault.LocalPartyId] {
+			return 0, fmt.Errorf("share %d party %s is duplicated", idx, vault.LocalPartyId)
+		}
+		parties[vault.LocalPartyId] = true
+	}
+	if len(vaults) < threshold {
+		return 0, fmt.Errorf("%d shares provided , %d required", len(vaults), threshold)
+	}
+	return threshold, nil
+}
+
+// Recover reconstructs the ECDSA and EdDSA private keys of the vault , and checks they match the vault public keys
+func Recover(vaults []*vaultType.Vault, threshold int, exporter DKLSExporter) (*Keys, error) {
+	if _, err := CheckShares(vaults, threshold); err != nil {
+		return nil, err
+	}
+	chainCode, err := hex.DecodeString(vaults[0].HexChainCode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode chain code: %w", err)
+	}
+	keys := &Keys{
+		PublicKeyEcdsa: vaults[0].PublicKeyEcdsa,
+		PublicKeyEddsa: vaults[0].PublicKeyEddsa,
+		ChainCode:      chainCode,
+	}
+	for _, isEdDSA := range []bool{false, true} {
+		privateKey, err := recoverPrivateKey(vaults, isEdDSA, exporter)
+		if err != nil {
+			return nil, err
+		}
+		if isEdDSA {
+			_, publicKey, err := edwards.PrivKeyFromScalar(privateKey)
+			if err != nil {
+				return nil, fmt.Errorf("invalid EdDSA private key: %w", err)
+			}
+			if !strings.EqualFold(hex.EncodeToString(publicKey.SerializeCompressed()), keys.PublicKeyEddsa) {
+				return nil, errors.New("reconstructed EdDSA key doesn't match the vault public key")
+			}
+			keys.EdDSA = privateKey
+		} else {
+			publicKey := secp256k1.PrivKeyFromBytes(privateKey).PubKey()
+			if !strings.EqualFold(hex.EncodeToString(publicKey.SerializeCompressed()), keys.PublicKeyEcdsa) {
+				return nil, errors.New("reconstructed ECDSA key doesn't match the vault public key")
+			}
+			keys.ECDSA = privateKey
+		}
+	}
+	return keys, nil
+}
+
+// recoverPrivateKey returns the 32 bytes big endian private key , exported from DKLS keyshares or interpolated from GG20 shares
+func recoverPrivateKey(vaults []*vaultType.Vault, isEdDSA bool, exporter DKLSExporter) ([]byte, error) {
+	keyshares := make([]string, 0, len(vaults))
+	partyIDs := make([]string, 0, len(vaults))
+	for _, vault := range vaults {
+		publicKey := vault.PublicKeyEcdsa
+		if isEdDSA {
+			publicKey = vault.PublicKeyEddsa
+		}
+		var keyshare string
+		for _, item := range vault.KeyShares {
+			if item.PublicKey == publicKey {
+				keyshare = item.Keyshare
+			}
+		}
+		if keyshare == "" {
+			return nil, fmt.Errorf("party %s has no keyshare for %s", vault.LocalPartyId, publicKey)
+		}
+		keyshares = append(keyshares, keyshare)
+		partyIDs = append(partyIDs, vault.LocalPartyId)
+	}
+	if vaults[0].LibType == keygenType.LibType_LIB_TYPE_DKLS {
+		if exporter == nil {
+			return nil, errors.New("no DKLS keyshare exporter")
+		}
+		privateKey, err := exporter(keyshares, partyIDs, isEdDSA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to export DKLS keyshares: %w", err)
+		}
+		if len(privateKey) != 32 {
+			return nil, fmt.Errorf("invalid exported private key length: %d", len(privateKey))
+		}
+		return privateKey, nil
+	}
+	shares := make([]SecretShare, 0, len(vaults))
+	for idx, keyshare := range keyshares {
+		var localState gg20LocalState
+		if err := json.Unmarshal([]byte(keyshare), &localState); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal keyshare of party %s: %w", partyIDs[idx], err)
+		}
+		secrets := localState.ECDSALocalData
+		if isEdDSA {
+			secrets = localState.EDDSALocalData
+		}
+		if secrets.Xi == nil || secrets.ShareID == nil {
+			return nil, fmt.Errorf("keyshare of party %s has no secret share", partyIDs[idx])
+		}
+		shares = append(shares, SecretShare{X: secrets.ShareID, Secret: secrets.Xi})
+	}
+	order := secp256k1.S256().N
+	if isEdDSA {
+		order = edwards.Edwards().N
+	}
+	secret, err := Interpolate(shares, order)
+	if err != nil {
+		return nil, err
+	}
+	return secret.FillBytes(make([]byte, 32)), nil
+}
+
+// Interpolate evaluates the Shamir polynomial at 0 with Lagrange interpolation over the shares
+func Interpolate(shares []SecretShare, order *big.Int) (*big.Int, error) {
+	result := big.NewInt(0)
+	for i, share := range shares {
+		coefficient := big.NewInt(1)
+		for j, other := range shares {
+			if i == j {
+				continue
+			}
+			denominator := new(big.Int).Sub(other.X, share.X)
+			denominator.Mod(denominator, order)
+			if denominator.Sign() == 0 {
+				return nil, errors.New("duplicated share evaluation point")
+			}
+			coefficient.Mul(coefficient, other.X)
+			coefficient.Mul(coefficient, new(big.Int).ModInverse(denominator, order))
+			coefficient.Mod(coefficient, order)
+		}
+		term := new(big.Int).Mul(share.Secret, coefficient)
+		result.Add(result, term)
+		result.Mod(result, order)
+	}
+	return result, nil
+}
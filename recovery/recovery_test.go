@@ -0,0 +1,219 @@
+package recovery
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"math/big"
+	"slices"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/edwards/v2"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	keygenType "github.com/vultisig/commondata/go/vultisig/keygen/v1"
+	vaultType "github.com/vultisig/commondata/go/vultisig/vault/v1"
+	"github.com/vultisig/mobile-tss-lib/tss"
+)
+
+func TestInterpolate(t *testing.T) {
+	order := secp256k1.S256().N
+	// f(x) = 42 + 7x + 3x^2 , any three points recover f(0)
+	f := func(x int64) SecretShare {
+		y := big.NewInt(42 + 7*x + 3*x*x)
+		return SecretShare{X: big.NewInt(x), Secret: y}
+	}
+	secret, err := Interpolate([]SecretShare{f(1), f(3), f(5)}, order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret.Int64() != 42 {
+		t.Fatalf("secret: %s, expected: 42", secret)
+	}
+	if _, err := Interpolate([]SecretShare{f(1), f(1)}, order); err == nil {
+		t.Fatal("expected error for duplicated evaluation point")
+	}
+}
+
+func TestCheckShares(t *testing.T) {
+	newVault := func(localPartyID string) *vaultType.Vault {
+		return &vaultType.Vault{
+			PublicKeyEcdsa: "ecdsa",
+			PublicKeyEddsa: "eddsa",
+			HexChainCode:   "chaincode",
+			Signers:        []string{"a", "b", "c", "d"},
+			LocalPartyId:   localPartyID,
+		}
+	}
+	threshold, err := CheckShares([]*vaultType.Vault{newVault("a"), newVault("c")}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if threshold != 2 {
+		t.Fatalf("threshold: %d, expected: 2", threshold)
+	}
+	if _, err := CheckShares([]*vaultType.Vault{newVault("a")}, 2); err == nil {
+		t.Fatal("expected error for insufficient shares")
+	}
+	// the default threshold of 4 signers is 3
+	if _, err := CheckShares([]*vaultType.Vault{newVault("a"), newVault("c")}, 0); err == nil {
+		t.Fatal("expected error for shares below the default threshold")
+	}
+	if _, err := CheckShares([]*vaultType.Vault{newVault("a"), newVault("a")}, 2); err == nil {
+		t.Fatal("expected error for duplicated party")
+	}
+	other := newVault("b")
+	other.PublicKeyEcdsa = "other"
+	if _, err := CheckShares([]*vaultType.Vault{newVault("a"), other}, 2); err == nil {
+		t.Fatal("expected error for shares of different vaults")
+	}
+}
+
+func TestDeriveECDSAPrivateKey(t *testing.T) {
+	privateKey, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	chainCode := make([]byte, 32)
+	chainCode[0] = 1
+	publicKey := hex.EncodeToString(privateKey.PubKey().SerializeCompressed())
+	derivePath := "m/44'/60'/0'/0/0"
+	expected, err := tss.GetDerivedPubKey(publicKey, hex.EncodeToString(chainCode), derivePath, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	derived, err := DeriveECDSAPrivateKey(privateKey.Serialize(), chainCode, derivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	derivedPublicKey := hex.EncodeToString(secp256k1.PrivKeyFromBytes(derived).PubKey().SerializeCompressed())
+	if derivedPublicKey != expected {
+		t.Fatalf("derived public key: %s, expected: %s", derivedPublicKey, expected)
+	}
+}
+
+func TestCheckAddress(t *testing.T) {
+	privateKey, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	chainCode := make([]byte, 32)
+	chainCode[0] = 1
+	keys := &Keys{ECDSA: privateKey.Serialize(), ChainCode: chainCode}
+	publicKey := hex.EncodeToString(privateKey.PubKey().SerializeCompressed())
+	for _, chain := range []Chain{Bitcoin, Ethereum, THORChain, Cosmos} {
+		derivePath, err := DefaultDerivePath(chain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		derived, err := keys.Derive(chain, derivePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := CheckAddress(chain, publicKey, hex.EncodeToString(chainCode), derivePath, derived.Address); err != nil {
+			t.Errorf("%s: %v", chain, err)
+		}
+		if err := CheckAddress(chain, publicKey, hex.EncodeToString(chainCode), "", derived.Address); err == nil {
+			t.Errorf("%s: address of the derived key matched the root key", chain)
+		}
+	}
+	other, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addresses, err := Addresses(Ethereum, other.PubKey().SerializeCompressed())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckAddress(Ethereum, publicKey, hex.EncodeToString(chainCode), "", addresses[0]); err == nil {
+		t.Error("address of another key matched")
+	}
+}
+
+func TestSignEdDSA(t *testing.T) {
+	privateKey, err := edwards.GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := &Keys{EdDSA: privateKey.GetD().FillBytes(make([]byte, 32))}
+	derived, err := keys.Derive(Solana, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, err := hex.DecodeString(derived.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := []byte("solana transaction message")
+	signature, err := keys.SignEdDSA(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ed25519.Verify(publicKey, message, signature) {
+		t.Fatal("signature doesn't verify with the solana public key")
+	}
+	expanded, err := hex.DecodeString(derived.PrivateKey)
+	if err != nil || len(expanded) != 64 {
+		t.Fatalf("invalid expanded key %s", derived.PrivateKey)
+	}
+	scalar := slices.Clone(expanded[:32])
+	slices.Reverse(scalar)
+	if !bytes.Equal(scalar, keys.EdDSA) {
+		t.Fatal("expanded key doesn't start with the little endian scalar")
+	}
+}
+
+func TestRecoverDKLS(t *testing.T) {
+	ecdsaKey, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	eddsaKey, err := edwards.GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, eddsaPublicKey, err := edwards.PrivKeyFromScalar(eddsaKey.GetD().FillBytes(make([]byte, 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signers := []string{"device", "server", "backup"}
+	var vaults []*vaultType.Vault
+	for _, party := range signers[:2] {
+		vaults = append(vaults, &vaultType.Vault{
+			PublicKeyEcdsa: hex.EncodeToString(ecdsaKey.PubKey().SerializeCompressed()),
+			PublicKeyEddsa: hex.EncodeToString(eddsaPublicKey.SerializeCompressed()),
+			HexChainCode:   hex.EncodeToString(make([]byte, 32)),
+			Signers:        signers,
+			LocalPartyId:   party,
+			LibType:        keygenType.LibType_LIB_TYPE_DKLS,
+			KeyShares: []*vaultType.Vault_KeyShare{
+				{PublicKey: hex.EncodeToString(ecdsaKey.PubKey().SerializeCompressed()), Keyshare: party + "-ecdsa"},
+				{PublicKey: hex.EncodeToString(eddsaPublicKey.SerializeCompressed()), Keyshare: party + "-eddsa"},
+			},
+		})
+	}
+	exporter := func(keyshares []string, partyIDs []string, isEdDSA bool) ([]byte, error) {
+		if !slices.Equal(partyIDs, signers[:2]) {
+			t.Errorf("unexpected parties %v", partyIDs)
+		}
+		if isEdDSA {
+			if !slices.Equal(keyshares, []string{"device-eddsa", "server-eddsa"}) {
+				t.Errorf("unexpected keyshares %v", keyshares)
+			}
+			return eddsaKey.GetD().FillBytes(make([]byte, 32)), nil
+		}
+		return ecdsaKey.Serialize(), nil
+	}
+	keys, err := Recover(vaults, 2, exporter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(keys.ECDSA, ecdsaKey.Serialize()) {
+		t.Error("unexpected ECDSA key")
+	}
+	wrongKey := func(keyshares []string, partyIDs []string, isEdDSA bool) ([]byte, error) {
+		return make([]byte, 31), nil
+	}
+	if _, err := Recover(vaults, 2, wrongKey); err == nil {
+		t.Error("recovered an invalid exported key")
+	}
+}
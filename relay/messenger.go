@@ -16,6 +16,8 @@ import (
 	"sync"
 
 	"github.com/sirupsen/logrus"
+
+	"github.com/vultisig/vultisigner/common"
 )
 
 type MessengerImp struct {
@@ -27,6 +29,7 @@ type MessengerImp struct {
 	isGCM            bool
 	messageID        string
 	counter          int
+	pairwiseKeys     *PairwiseKeyStore
 }
 
 func NewMessenger(server, sessionID, hexEncryptionKey string, isGCM bool, messageID string) *MessengerImp {
@@ -40,9 +43,28 @@ func NewMessenger(server, sessionID, hexEncryptionKey string, isGCM bool, messag
 		counter:          0,
 	}
 }
+
+// SetPairwiseKeys enables pairwise encryption , it's used once the parties negotiated pairwise mode
+func (m *MessengerImp) SetPairwiseKeys(store *PairwiseKeyStore) {
+	m.pairwiseKeys = store
+}
+
+// Send encrypts the message with the session key , or with the key shared with the receiver when the parties
+// negotiated pairwise mode. In pairwise mode a receiver without a pairwise key fails , it never falls back to the session key.
 func (m *MessengerImp) Send(from, to, body string) error {
-	if m.HexEncryptionKey != "" {
-		encryptedBody, err := encryptWrapper(body, m.HexEncryptionKey, m.isGCM)
+	if !m.pairwiseKeys.Negotiated() {
+		return m.send(from, to, body, m.HexEncryptionKey)
+	}
+	key, ok := m.pairwiseKeys.PeerKey(to)
+	if !ok || !m.isGCM {
+		return fmt.Errorf("no pairwise key for %s", to)
+	}
+	return m.send(from, to, body, key)
+}
+
+func (m *MessengerImp) send(from, to, body, hexEncryptionKey string) error {
+	if hexEncryptionKey != "" {
+		encryptedBody, err := encryptWrapper(body, hexEncryptionKey, m.isGCM)
 		if err != nil {
 			return fmt.Errorf("failed to encrypt body: %w", err)
 		}
@@ -121,12 +143,13 @@ func encryptWrapper(plainText, hexKey string, isGCM bool) (string, error) {
 }
 
 func encrypt(plainText, hexKey string) (string, error) {
-	key, err := hex.DecodeString(hexKey)
+	key, err := common.DecodeHexSecret(hexKey)
 	if err != nil {
 		return "", err
 	}
+	defer key.Wipe()
 	plainByte := []byte(plainText)
-	block, err := aes.NewCipher(key)
+	block, err := aes.NewCipher(key.Bytes())
 	if err != nil {
 		return "", err
 	}
@@ -144,13 +167,15 @@ func encrypt(plainText, hexKey string) (string, error) {
 }
 
 func encryptGCM(plainText, hexKey string) (string, error) {
-	passwd, err := hex.DecodeString(hexKey)
+	passwd, err := common.DecodeHexSecret(hexKey)
 	if err != nil {
 		return "", err
 	}
+	defer passwd.Wipe()
 
-	hash := sha256.Sum256(passwd)
+	hash := sha256.Sum256(passwd.Bytes())
 	key := hash[:]
+	defer common.Wipe(key)
 
 	// Create a new AES cipher using the key
 	block, err := aes.NewCipher(key)
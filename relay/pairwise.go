@@ -0,0 +1,388 @@
+package relay
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/vultisig/vultisigner/common"
+)
+
+const (
+	// pairwiseKeyMessagePrefix is the setup message id used to publish a party's signed ephemeral X25519 public key
+	pairwiseKeyMessagePrefix = "x25519-"
+	// pairwiseModeMessageID is the setup message id the initiator publishes the negotiated mode under
+	pairwiseModeMessageID = "pairwise-mode"
+
+	PairwiseModeSession  = "session"
+	PairwiseModePairwise = "pairwise"
+)
+
+var (
+	ErrPairwiseKeyNotPublished = errors.New("pairwise key not published")
+	ErrPairwiseModeMissing     = errors.New("peers published pairwise keys , but the initiator didn't publish the mode")
+	ErrPairwiseRequired        = errors.New("pairwise encryption is required , but the session negotiated the session key")
+	ErrPairwiseDowngrade       = errors.New("every party published a pairwise key , but the initiator negotiated the session key")
+
+	errSetupMessageMissing = errors.New("setup message not published")
+)
+
+// pairwiseKeyRecord is the ephemeral X25519 key of a party , signed with the long-term Ed25519 identity key of the party
+type pairwiseKeyRecord struct {
+	PartyID   string `json:"party_id"`
+	PublicKey string `json:"public_key"` // hex encoded X25519 public key
+	Identity  string `json:"identity"`   // hex encoded Ed25519 public key
+	Signature string `json:"signature"`  // hex encoded Ed25519 signature of pairwiseKeySigningPayload
+}
+
+// pairwiseModeMessage is the mode the initiator picked , with the transcript of the keys of every party in pairwise mode
+type pairwiseModeMessage struct {
+	Mode       string `json:"mode"`
+	Transcript string `json:"transcript,omitempty"`
+}
+
+// ParseIdentityKey parses the hex encoded Ed25519 seed of the long-term identity key
+func ParseIdentityKey(hexSeed string) (ed25519.PrivateKey, error) {
+	seed, err := hex.DecodeString(hexSeed)
+	if err != nil {
+		return nil, fmt.Errorf("fail to decode identity key: %w", err)
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("identity key must be a %d bytes Ed25519 seed", ed25519.SeedSize)
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+type pairwisePeer struct {
+	publicKey []byte
+	identity  ed25519.PublicKey
+	key       string
+}
+
+// PairwiseKeyStore holds the local ephemeral X25519 key of a session and the symmetric keys derived with each peer.
+// The keys are only used once the mode is negotiated , from then on every message from and to a peer must use its key.
+type PairwiseKeyStore struct {
+	sessionID    string
+	localPartyID string
+	privateKey   *ecdh.PrivateKey
+	identity     ed25519.PrivateKey
+	mu           sync.RWMutex
+	peers        map[string]pairwisePeer
+	negotiated   bool
+}
+
+// NewPairwiseKeyStore generates a fresh X25519 key pair for the given session , signed with the identity key
+func NewPairwiseKeyStore(sessionID, localPartyID string, identity ed25519.PrivateKey) (*PairwiseKeyStore, error) {
+	if len(identity) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("invalid identity key")
+	}
+	privateKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("fail to generate x25519 key: %w", err)
+	}
+	return &PairwiseKeyStore{
+		sessionID:    sessionID,
+		localPartyID: localPartyID,
+		privateKey:   privateKey,
+		identity:     identity,
+		peers:        make(map[string]pairwisePeer),
+	}, nil
+}
+
+// PublicKey returns the local ephemeral public key
+func (p *PairwiseKeyStore) PublicKey() []byte {
+	return p.privateKey.PublicKey().Bytes()
+}
+
+// AddPeer derives the pairwise key shared with the given peer from its X25519 public key and identity
+func (p *PairwiseKeyStore) AddPeer(partyID string, publicKey []byte, identity ed25519.PublicKey) error {
+	if partyID == p.localPartyID {
+		return fmt.Errorf("can't add local party as peer")
+	}
+	peerPublicKey, err := ecdh.X25519().NewPublicKey(publicKey)
+	if err != nil {
+		return fmt.Errorf("invalid x25519 public key: %w", err)
+	}
+	sharedSecret, err := p.privateKey.ECDH(peerPublicKey)
+	if err != nil {
+		return fmt.Errorf("fail to compute shared secret: %w", err)
+	}
+	defer common.Wipe(sharedSecret)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.peers[partyID] = pairwisePeer{
+		publicKey: publicKey,
+		identity:  identity,
+		key:       derivePairwiseKey(sharedSecret, p.sessionID, p.localPartyID, partyID),
+	}
+	return nil
+}
+
+// PeerKey returns the hex encoded pairwise key for the given peer , only once pairwise mode is negotiated
+func (p *PairwiseKeyStore) PeerKey(partyID string) (string, bool) {
+	if p == nil {
+		return "", false
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	peer, ok := p.peers[partyID]
+	return peer.key, ok && p.negotiated
+}
+
+// Negotiated returns true when the parties negotiated pairwise mode , then messages must not use the session key
+func (p *PairwiseKeyStore) Negotiated() bool {
+	if p == nil {
+		return false
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.negotiated
+}
+
+// PeerIdentities returns the hex encoded identity keys of the peers , by party id
+func (p *PairwiseKeyStore) PeerIdentities() map[string]string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	identities := make(map[string]string, len(p.peers))
+	for partyID, peer := range p.peers {
+		identities[partyID] = hex.EncodeToString(peer.identity)
+	}
+	return identities
+}
+
+// Transcript hashes the ephemeral and identity keys of every party , parties that agree on it derived their keys from the same records
+func (p *PairwiseKeyStore) Transcript() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	keys := map[string]pairwisePeer{
+		p.localPartyID: {publicKey: p.PublicKey(), identity: p.identity.Public().(ed25519.PublicKey)},
+	}
+	for partyID, peer := range p.peers {
+		keys[partyID] = peer
+	}
+	parties := make([]string, 0, len(keys))
+	for partyID := range keys {
+		parties = append(parties, partyID)
+	}
+	sort.Strings(parties)
+	hash := sha256.New()
+	writeField(hash, []byte("vultisig-pairwise-transcript-v2"))
+	writeField(hash, []byte(p.sessionID))
+	for _, partyID := range parties {
+		writeField(hash, []byte(partyID))
+		writeField(hash, keys[partyID].publicKey)
+		writeField(hash, keys[partyID].identity)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// derivePairwiseKey binds the shared secret to the session and to both party ids, so both sides derive the same key
+func derivePairwiseKey(sharedSecret []byte, sessionID, partyA, partyB string) string {
+	if partyA > partyB {
+		partyA, partyB = partyB, partyA
+	}
+	hash := sha256.New()
+	writeField(hash, []byte("vultisig-pairwise-v2"))
+	writeField(hash, sharedSecret)
+	writeField(hash, []byte(sessionID))
+	writeField(hash, []byte(partyA))
+	writeField(hash, []byte(partyB))
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// pairwiseKeySigningPayload is what the identity key signs , it binds the ephemeral key to the session and the party
+func pairwiseKeySigningPayload(sessionID, partyID string, publicKey []byte) []byte {
+	hash := sha256.New()
+	writeField(hash, []byte("vultisig-pairwise-key-v2"))
+	writeField(hash, []byte(sessionID))
+	writeField(hash, []byte(partyID))
+	writeField(hash, publicKey)
+	return hash.Sum(nil)
+}
+
+// writeField writes a length prefixed field , so fields can't be shifted into each other
+func writeField(w io.Writer, field []byte) {
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(field)))
+	_, _ = w.Write(length[:])
+	_, _ = w.Write(field)
+}
+
+// PublishPairwiseKey uploads the local ephemeral public key , signed with the identity key and encrypted with the session key
+func (c *Client) PublishPairwiseKey(store *PairwiseKeyStore, hexEncryptionKey string) error {
+	publicKey := store.PublicKey()
+	record := pairwiseKeyRecord{
+		PartyID:   store.localPartyID,
+		PublicKey: hex.EncodeToString(publicKey),
+		Identity:  hex.EncodeToString(store.identity.Public().(ed25519.PublicKey)),
+		Signature: hex.EncodeToString(ed25519.Sign(store.identity, pairwiseKeySigningPayload(store.sessionID, store.localPartyID, publicKey))),
+	}
+	buf, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("fail to marshal pairwise key: %w", err)
+	}
+	encrypted, err := encryptGCM(string(buf), hexEncryptionKey)
+	if err != nil {
+		return fmt.Errorf("fail to encrypt pairwise key: %w", err)
+	}
+	payload := base64.StdEncoding.EncodeToString([]byte(encrypted))
+	return c.UploadSetupMessage(store.sessionID, pairwiseKeyMessagePrefix+store.localPartyID, payload)
+}
+
+// NegotiatePairwiseMode fetches the signed keys of the parties and the mode the initiator picked , it returns the mode.
+// When no peer published a key , the peers don't support pairwise encryption and the session key is used.
+// When a peer published a key , the initiator must publish the mode: pairwise mode needs a verified key of every party
+// and the same transcript on every side , the session key is refused when every party published a key.
+// Any invalid key or mismatch fails the negotiation , it never falls back to the session key.
+func (c *Client) NegotiatePairwiseMode(ctx context.Context, store *PairwiseKeyStore, parties []string, hexEncryptionKey string) (string, error) {
+	records := make(map[string]*pairwiseKeyRecord)
+	fetchCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	for _, party := range parties {
+		if party == store.localPartyID {
+			continue
+		}
+		record, err := c.waitForPairwiseKey(fetchCtx, store.sessionID, party, hexEncryptionKey)
+		if errors.Is(err, ErrPairwiseKeyNotPublished) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		records[party] = record
+	}
+	if len(records) == 0 {
+		return PairwiseModeSession, nil
+	}
+	mode, err := c.waitForPairwiseMode(ctx, store.sessionID, hexEncryptionKey)
+	if err != nil {
+		return "", err
+	}
+	switch mode.Mode {
+	case PairwiseModeSession:
+		if len(records) == len(parties)-1 {
+			return "", ErrPairwiseDowngrade
+		}
+		return PairwiseModeSession, nil
+	case PairwiseModePairwise:
+	default:
+		return "", fmt.Errorf("unknown pairwise mode %q", mode.Mode)
+	}
+	for _, party := range parties {
+		if party == store.localPartyID {
+			continue
+		}
+		record, ok := records[party]
+		if !ok {
+			// the party published its key after the first fetch , the initiator already saw it
+			if record, err = c.waitForPairwiseKey(ctx, store.sessionID, party, hexEncryptionKey); err != nil {
+				return "", fmt.Errorf("party %s has no pairwise key: %w", party, err)
+			}
+		}
+		publicKey, _ := hex.DecodeString(record.PublicKey)
+		identity, _ := hex.DecodeString(record.Identity)
+		if err := store.AddPeer(party, publicKey, identity); err != nil {
+			return "", fmt.Errorf("fail to add pairwise peer %s: %w", party, err)
+		}
+	}
+	if transcript := store.Transcript(); transcript != mode.Transcript {
+		return "", fmt.Errorf("pairwise transcript mismatch , the parties didn't see the same keys")
+	}
+	store.mu.Lock()
+	store.negotiated = true
+	store.mu.Unlock()
+	return PairwiseModePairwise, nil
+}
+
+// waitForPairwiseKey waits for the signed key of the party , and verifies it.
+// ErrPairwiseKeyNotPublished is returned when the party didn't publish a key before the context expired.
+func (c *Client) waitForPairwiseKey(ctx context.Context, sessionID, party, hexEncryptionKey string) (*pairwiseKeyRecord, error) {
+	payload, err := c.waitForSessionMessage(ctx, sessionID, pairwiseKeyMessagePrefix+party, hexEncryptionKey)
+	if errors.Is(err, errSetupMessageMissing) {
+		return nil, ErrPairwiseKeyNotPublished
+	}
+	if err != nil {
+		return nil, err
+	}
+	var record pairwiseKeyRecord
+	if err := json.Unmarshal(payload, &record); err != nil {
+		return nil, fmt.Errorf("fail to unmarshal pairwise key of %s: %w", party, err)
+	}
+	if err := verifyPairwiseKeyRecord(sessionID, party, &record); err != nil {
+		return nil, fmt.Errorf("invalid pairwise key of %s: %w", party, err)
+	}
+	return &record, nil
+}
+
+func verifyPairwiseKeyRecord(sessionID, party string, record *pairwiseKeyRecord) error {
+	if record.PartyID != party {
+		return fmt.Errorf("key published for party %s", record.PartyID)
+	}
+	publicKey, err := hex.DecodeString(record.PublicKey)
+	if err != nil {
+		return fmt.Errorf("fail to decode public key: %w", err)
+	}
+	identity, err := hex.DecodeString(record.Identity)
+	if err != nil || len(identity) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid identity key")
+	}
+	signature, err := hex.DecodeString(record.Signature)
+	if err != nil {
+		return fmt.Errorf("fail to decode signature: %w", err)
+	}
+	if !ed25519.Verify(identity, pairwiseKeySigningPayload(sessionID, party, publicKey), signature) {
+		return fmt.Errorf("signature doesn't verify")
+	}
+	return nil
+}
+
+// waitForPairwiseMode waits for the mode the initiator picked , ErrPairwiseModeMissing is returned when it never does
+func (c *Client) waitForPairwiseMode(ctx context.Context, sessionID, hexEncryptionKey string) (*pairwiseModeMessage, error) {
+	payload, err := c.waitForSessionMessage(ctx, sessionID, pairwiseModeMessageID, hexEncryptionKey)
+	if errors.Is(err, errSetupMessageMissing) {
+		return nil, ErrPairwiseModeMissing
+	}
+	if err != nil {
+		return nil, err
+	}
+	var mode pairwiseModeMessage
+	if err := json.Unmarshal(payload, &mode); err != nil {
+		return nil, fmt.Errorf("fail to unmarshal pairwise mode: %w", err)
+	}
+	return &mode, nil
+}
+
+// waitForSessionMessage polls the setup message of the message id , and decrypts it with the session key
+func (c *Client) waitForSessionMessage(ctx context.Context, sessionID, messageID, hexEncryptionKey string) ([]byte, error) {
+	for {
+		payload, err := c.GetSetupMessage(sessionID, messageID)
+		if err == nil && payload != "" {
+			encrypted, err := base64.StdEncoding.DecodeString(payload)
+			if err != nil {
+				return nil, fmt.Errorf("fail to decode %s: %w", messageID, err)
+			}
+			decrypted, err := common.DecryptGCM(encrypted, hexEncryptionKey)
+			if err != nil {
+				return nil, fmt.Errorf("fail to decrypt %s: %w", messageID, err)
+			}
+			return decrypted, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, errSetupMessageMissing
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package relay
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vultisig/vultisigner/common"
+)
+
+const (
+	testSessionID     = "ab2ec1a4-8ee4-4e4e-9a4c-2d9c3a6f1e11"
+	testEncryptionKey = "d6022efdbf1cd27b2feb179341b40a800f4fdda7cdfd91ca630f1f17ee0516f3"
+)
+
+// newSetupMessageRelay serves the setup messages of the relay from memory
+func newSetupMessageRelay(t *testing.T) *Client {
+	var mu sync.Mutex
+	messages := make(map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		messageID := r.Header.Get("message_id")
+		switch r.Method {
+		case http.MethodPost:
+			body, _ := io.ReadAll(r.Body)
+			messages[messageID] = string(body)
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodGet:
+			payload, ok := messages[messageID]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write([]byte(payload))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return NewRelayClient(server.URL)
+}
+
+func newTestKeyStore(t *testing.T, partyID string) *PairwiseKeyStore {
+	_, identity, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewPairwiseKeyStore(testSessionID, partyID, identity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func publishSessionMessage(t *testing.T, client *Client, messageID string, message any) {
+	buf, err := json.Marshal(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := encryptGCM(string(buf), testEncryptionKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.UploadSetupMessage(testSessionID, messageID, base64.StdEncoding.EncodeToString([]byte(encrypted))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// initiate publishes the key of the initiator , adds the keys of the other parties and publishes the pairwise mode
+func initiate(t *testing.T, client *Client, initiator *PairwiseKeyStore, others ...*PairwiseKeyStore) {
+	if err := client.PublishPairwiseKey(initiator, testEncryptionKey); err != nil {
+		t.Fatal(err)
+	}
+	for _, other := range others {
+		if err := initiator.AddPeer(other.localPartyID, other.PublicKey(), other.identity.Public().(ed25519.PublicKey)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	publishSessionMessage(t, client, pairwiseModeMessageID, pairwiseModeMessage{Mode: PairwiseModePairwise, Transcript: initiator.Transcript()})
+}
+
+func TestPairwiseKeyAgreement(t *testing.T) {
+	client := newSetupMessageRelay(t)
+	server := newTestKeyStore(t, "server")
+	device := newTestKeyStore(t, "device")
+	if err := client.PublishPairwiseKey(server, testEncryptionKey); err != nil {
+		t.Fatal(err)
+	}
+	initiate(t, client, device, server)
+
+	mode, err := client.NegotiatePairwiseMode(context.Background(), server, []string{"device", "server"}, testEncryptionKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode != PairwiseModePairwise || !server.Negotiated() {
+		t.Fatalf("expected pairwise mode , got %s", mode)
+	}
+	serverKey, ok := server.PeerKey("device")
+	if !ok {
+		t.Fatal("server doesn't have a key for device")
+	}
+	device.negotiated = true
+	deviceKey, ok := device.PeerKey("server")
+	if !ok || serverKey != deviceKey {
+		t.Fatalf("pairwise keys mismatch: %s != %s", serverKey, deviceKey)
+	}
+
+	encrypted, err := encryptGCM("helloworld", serverKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted, err := common.DecryptGCM([]byte(encrypted), deviceKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(decrypted) != "helloworld" {
+		t.Fatalf("decrypted: %s, expected: %s", decrypted, "helloworld")
+	}
+	if _, err := common.DecryptGCM([]byte(encrypted), testEncryptionKey); err == nil {
+		t.Fatal("session key should not decrypt pairwise message")
+	}
+}
+
+func TestPairwiseKeyNotUsedBeforeNegotiation(t *testing.T) {
+	server := newTestKeyStore(t, "server")
+	device := newTestKeyStore(t, "device")
+	if err := server.AddPeer("device", device.PublicKey(), device.identity.Public().(ed25519.PublicKey)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := server.PeerKey("device"); ok {
+		t.Fatal("pairwise key used before the mode was negotiated")
+	}
+}
+
+func TestNegotiatePairwiseModeWithoutPeerKeys(t *testing.T) {
+	client := newSetupMessageRelay(t)
+	server := newTestKeyStore(t, "server")
+	mode, err := client.NegotiatePairwiseMode(context.Background(), server, []string{"device", "server"}, testEncryptionKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode != PairwiseModeSession || server.Negotiated() {
+		t.Fatalf("expected session mode , got %s", mode)
+	}
+}
+
+func TestNegotiatePairwiseModeFailsClosed(t *testing.T) {
+	parties := []string{"device", "server"}
+	tests := map[string]struct {
+		setup func(t *testing.T, client *Client, server, device *PairwiseKeyStore)
+		err   error
+	}{
+		"mode missing": {
+			setup: func(t *testing.T, client *Client, server, device *PairwiseKeyStore) {
+				if err := client.PublishPairwiseKey(device, testEncryptionKey); err != nil {
+					t.Fatal(err)
+				}
+			},
+			err: ErrPairwiseModeMissing,
+		},
+		"downgrade to session key": {
+			setup: func(t *testing.T, client *Client, server, device *PairwiseKeyStore) {
+				if err := client.PublishPairwiseKey(device, testEncryptionKey); err != nil {
+					t.Fatal(err)
+				}
+				publishSessionMessage(t, client, pairwiseModeMessageID, pairwiseModeMessage{Mode: PairwiseModeSession})
+			},
+			err: ErrPairwiseDowngrade,
+		},
+		"key replaced by a co-party": {
+			setup: func(t *testing.T, client *Client, server, device *PairwiseKeyStore) {
+				initiate(t, client, device, server)
+				// the attacker signs its own ephemeral key with its identity , the initiator saw the original key
+				attacker := newTestKeyStore(t, "device")
+				if err := client.PublishPairwiseKey(attacker, testEncryptionKey); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		"forged signature": {
+			setup: func(t *testing.T, client *Client, server, device *PairwiseKeyStore) {
+				initiate(t, client, device, server)
+				attacker := newTestKeyStore(t, "attacker")
+				publishSessionMessage(t, client, pairwiseKeyMessagePrefix+"device", pairwiseKeyRecord{
+					PartyID:   "device",
+					PublicKey: "2a" + testEncryptionKey[2:],
+					Identity:  "2a" + testEncryptionKey[2:],
+					Signature: hex.EncodeToString(ed25519.Sign(attacker.identity, []byte("device"))),
+				})
+			},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := newSetupMessageRelay(t)
+			server := newTestKeyStore(t, "server")
+			device := newTestKeyStore(t, "device")
+			if err := client.PublishPairwiseKey(server, testEncryptionKey); err != nil {
+				t.Fatal(err)
+			}
+			test.setup(t, client, server, device)
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			_, err := client.NegotiatePairwiseMode(ctx, server, parties, testEncryptionKey)
+			if err == nil {
+				t.Fatal("negotiation should fail")
+			}
+			if test.err != nil && !errors.Is(err, test.err) {
+				t.Fatalf("expected %v , got %v", test.err, err)
+			}
+			if server.Negotiated() {
+				t.Fatal("failed negotiation enabled pairwise mode")
+			}
+		})
+	}
+}
+
+func TestSendRequiresPairwiseKeyOnceNegotiated(t *testing.T) {
+	server := newTestKeyStore(t, "server")
+	server.negotiated = true
+	messenger := NewMessenger("http://127.0.0.1:0", testSessionID, testEncryptionKey, true, "")
+	messenger.SetPairwiseKeys(server)
+	if err := messenger.Send("server", "device", "body"); err == nil {
+		t.Fatal("message sent with the session key in pairwise mode")
+	}
+}
@@ -95,7 +95,7 @@ func (c *Client) RegisterSession(sessionID string, key string) error {
 	if resp.StatusCode != http.StatusCreated {
 		return fmt.Errorf("fail to register session: %s", resp.Status)
 	}
-
+	notifySessionJoined(sessionID)
 	return nil
 }
 
@@ -106,6 +106,9 @@ func (c *Client) WaitForSessionStart(ctx context.Context, sessionID string) ([]s
 		case <-ctx.Done():
 			return nil, ctx.Err()
 		default:
+			if err := SessionCancelled(sessionID); err != nil {
+				return nil, err
+			}
 			resp, err := c.client.Get(sessionURL)
 			if err != nil {
 				return nil, fmt.Errorf("fail to get session: %w", err)
@@ -128,6 +131,7 @@ func (c *Client) WaitForSessionStart(ctx context.Context, sessionID string) ([]s
 					"session": sessionID,
 					"parties": parties,
 				}).Info("All parties joined")
+				notifySessionStarted(sessionID, parties)
 				return parties, nil
 			}
 
@@ -295,14 +299,22 @@ func (c *Client) EndSession(sessionID string) error {
 	}
 	return nil
 }
-func (c *Client) UploadSetupMessage(sessionID string, payload string) error {
+func (c *Client) UploadSetupMessage(sessionID, messageID, payload string) error {
 	sessionUrl := c.relayServer + "/setup-message/" + sessionID
 	body := []byte(payload)
-	bodyReader := bytes.NewReader(body)
-	resp, err := http.Post(sessionUrl, "application/json", bodyReader)
+	req, err := http.NewRequest(http.MethodPost, sessionUrl, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("fail to upload setup message: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if messageID != "" {
+		req.Header.Set("message_id", messageID)
+	}
+	resp, err := c.client.Do(req)
 	if err != nil {
 		return fmt.Errorf("fail to upload setup message: %w", err)
 	}
+	defer c.bodyCloser(resp.Body)
 	if resp.StatusCode != http.StatusCreated {
 		return fmt.Errorf("fail to upload setup message: %s", resp.Status)
 	}
@@ -315,6 +327,9 @@ func (c *Client) WaitForSetupMessage(ctx context.Context, sessionID, messageID s
 		case <-ctx.Done():
 			return "", ctx.Err()
 		default:
+			if err := SessionCancelled(sessionID); err != nil {
+				return "", err
+			}
 			payload, err := c.GetSetupMessage(sessionID, messageID)
 			if err == nil && payload != "" {
 				return payload, err
@@ -0,0 +1,44 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrSessionCancelled is returned by the waits of a session that was cancelled
+var ErrSessionCancelled = errors.New("session cancelled")
+
+// sessionContexts keeps the context of the task that runs each session
+var sessionContexts sync.Map
+
+// BindSession binds the session to the context of the task that runs it , waits of the session stop once the context is cancelled.
+// The returned function releases the session.
+func BindSession(ctx context.Context, sessionID string) func() {
+	sessionContexts.Store(sessionID, ctx)
+	return func() {
+		sessionContexts.CompareAndDelete(sessionID, ctx)
+	}
+}
+
+// SessionCancelled returns ErrSessionCancelled when the context bound to the session is cancelled
+func SessionCancelled(sessionID string) error {
+	value, ok := sessionContexts.Load(sessionID)
+	if !ok {
+		return nil
+	}
+	if err := value.(context.Context).Err(); err != nil {
+		return fmt.Errorf("%w: %w", ErrSessionCancelled, err)
+	}
+	return nil
+}
+
+// SessionDone returns a channel that's closed once the context bound to the session is cancelled , nil when the session isn't bound
+func SessionDone(sessionID string) <-chan struct{} {
+	value, ok := sessionContexts.Load(sessionID)
+	if !ok {
+		return nil
+	}
+	return value.(context.Context).Done()
+}
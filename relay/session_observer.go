@@ -0,0 +1,34 @@
+package relay
+
+import (
+	"sync/atomic"
+)
+
+// SessionObserver is notified when this server joins a relay session , and when all parties joined and the session starts
+type SessionObserver interface {
+	SessionJoined(sessionID string)
+	SessionStarted(sessionID string, parties []string)
+}
+
+var sessionObserver atomic.Pointer[SessionObserver]
+
+// SetSessionObserver sets the observer every relay client notifies , nil removes it
+func SetSessionObserver(observer SessionObserver) {
+	if observer == nil {
+		sessionObserver.Store(nil)
+		return
+	}
+	sessionObserver.Store(&observer)
+}
+
+func notifySessionJoined(sessionID string) {
+	if observer := sessionObserver.Load(); observer != nil {
+		(*observer).SessionJoined(sessionID)
+	}
+}
+
+func notifySessionStarted(sessionID string, parties []string) {
+	if observer := sessionObserver.Load(); observer != nil {
+		(*observer).SessionStarted(sessionID, parties)
+	}
+}
@@ -3,6 +3,7 @@ package relay
 import (
 	"fmt"
 	"os"
+	"sync"
 
 	vaultType "github.com/vultisig/commondata/go/vultisig/vault/v1"
 
@@ -10,19 +11,41 @@ import (
 	"github.com/vultisig/vultisigner/storage"
 )
 
+// LocalStateAccessorImp holds the keyshares of one operation. The keyshares created by the operation are kept in secrets ,
+// Wipe zeroes them once the operation finished. A ceremony abandoned on cancel may still save its keyshares , the accessor is safe for
+// concurrent use and wipes the keyshares saved after Wipe.
 type LocalStateAccessorImp struct {
 	Folder       string
 	Vault        *vaultType.Vault
-	cache        map[string]string
+	mu           sync.Mutex
+	cache        map[string]*common.Secret
+	wiped        bool
 	blockStorage *storage.BlockStorage
 }
 
-func NewLocalStateAccessorImp(folder, vaultFileName, vaultPasswd string,
+func NewLocalStateAccessorImp(folder, vaultFileName string, vaultPasswd *common.Secret,
+	storage *storage.BlockStorage) (*LocalStateAccessorImp, error) {
+	var vault *vaultType.Vault
+	if vaultFileName != "" {
+		buf, err := storage.GetFile(vaultFileName + ".bak")
+		if err != nil {
+			return nil, fmt.Errorf("fail to get vault file: %w", err)
+		}
+		vault, err = common.DecryptVaultFromBackupSecret(vaultPasswd, buf)
+		if err != nil {
+			return nil, fmt.Errorf("fail to decrypt vault from the backup, err: %w", err)
+		}
+	}
+	return NewLocalStateAccessorWithVault(folder, vault, storage)
+}
+
+// NewLocalStateAccessorWithVault creates the local state accessor of a vault that was already read , like from the vault cache
+func NewLocalStateAccessorWithVault(folder string, vault *vaultType.Vault,
 	storage *storage.BlockStorage) (*LocalStateAccessorImp, error) {
 	localStateAccessor := &LocalStateAccessorImp{
 		Folder:       folder,
-		Vault:        nil,
-		cache:        make(map[string]string),
+		Vault:        vault,
+		cache:        make(map[string]*common.Secret),
 		blockStorage: storage,
 	}
 
@@ -34,20 +57,10 @@ func NewLocalStateAccessorImp(folder, vaultFileName, vaultPasswd string,
 		}
 	}
 
-	if vaultFileName != "" {
-		buf, err := storage.GetFile(vaultFileName + ".bak")
-		if err != nil {
-			return nil, fmt.Errorf("fail to get vault file: %w", err)
-		}
-		localStateAccessor.Vault, err = common.DecryptVaultFromBackup(vaultPasswd, buf)
-		if err != nil {
-			return nil, fmt.Errorf("fail to decrypt vault from the backup, err: %w", err)
-		}
-	}
-
 	return localStateAccessor, nil
 }
 
+// GetLocalState returns the local state as a string , for the TSS libraries. Prefer GetKeyshare , the string can't be wiped.
 func (l *LocalStateAccessorImp) GetLocalState(pubKey string) (string, error) {
 	if l.Vault != nil {
 		for _, item := range l.Vault.KeyShares {
@@ -57,14 +70,80 @@ func (l *LocalStateAccessorImp) GetLocalState(pubKey string) (string, error) {
 		}
 		return "", fmt.Errorf("%s keyshare does not exist", pubKey)
 	}
-	return l.cache[pubKey], nil
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	localState, ok := l.cache[pubKey]
+	if !ok {
+		return "", nil
+	}
+	return string(localState.Bytes()), nil
+}
+
+// GetKeyshare returns the base64 decoded keyshare of the public key , the caller wipes it after use
+func (l *LocalStateAccessorImp) GetKeyshare(pubKey string) (*common.Secret, error) {
+	if l.Vault != nil {
+		keyshare, err := l.GetLocalState(pubKey)
+		if err != nil {
+			return nil, err
+		}
+		return common.DecodeBase64Secret(keyshare)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	localState, ok := l.cache[pubKey]
+	if !ok {
+		return nil, fmt.Errorf("%s keyshare does not exist", pubKey)
+	}
+	keyshare, err := localState.DecodeBase64()
+	if err != nil {
+		return nil, fmt.Errorf("fail to decode keyshare: %w", err)
+	}
+	return keyshare, nil
 }
 
 func (l *LocalStateAccessorImp) SaveLocalState(pubKey, localState string) error {
+	return l.SaveLocalStateSecret(pubKey, common.SecretFromBytes([]byte(localState)))
+}
+
+// SaveLocalStateSecret saves the local state of the public key , the accessor owns the secret afterwards
+func (l *LocalStateAccessorImp) SaveLocalStateSecret(pubKey string, localState *common.Secret) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.wiped {
+		// the operation already finished
+		localState.Wipe()
+		return nil
+	}
+	if previous, ok := l.cache[pubKey]; ok {
+		previous.Wipe()
+	}
 	l.cache[pubKey] = localState
 	return nil
 }
 
-func (l *LocalStateAccessorImp) GetLocalCacheState(pubKey string) (string, error) {
-	return l.cache[pubKey], nil
+// GetLocalCacheState returns a copy of the local state the operation saved , nil when it saved none. The caller wipes the copy.
+func (l *LocalStateAccessorImp) GetLocalCacheState(pubKey string) (*common.Secret, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	localState, ok := l.cache[pubKey]
+	if !ok {
+		return nil, nil
+	}
+	keyshare := common.NewSecret(localState.Len())
+	copy(keyshare.Bytes(), localState.Bytes())
+	return keyshare, nil
+}
+
+// Wipe zeroes the local states the operation saved
+func (l *LocalStateAccessorImp) Wipe() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.wiped = true
+	for pubKey, localState := range l.cache {
+		localState.Wipe()
+		delete(l.cache, pubKey)
+	}
 }
@@ -0,0 +1,63 @@
+package relay
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestLocalStateAccessorConcurrentSaves(t *testing.T) {
+	l, err := NewLocalStateAccessorWithVault(t.TempDir(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Wipe()
+
+	// ECDSA and EdDSA ceremonies save their keyshares from their own goroutines
+	var wg sync.WaitGroup
+	for _, pubKey := range []string{"ecdsa", "eddsa"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := 0; idx < 100; idx++ {
+				if err := l.SaveLocalState(pubKey, fmt.Sprintf("%s-%d", pubKey, idx)); err != nil {
+					t.Error(err)
+				}
+				localState, err := l.GetLocalCacheState(pubKey)
+				if err != nil {
+					t.Error(err)
+				}
+				localState.Wipe()
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, pubKey := range []string{"ecdsa", "eddsa"} {
+		localState, err := l.GetLocalCacheState(pubKey)
+		if err != nil || string(localState.Bytes()) != pubKey+"-99" {
+			t.Fatalf("expected %s-99 , got %s %v", pubKey, localState.Bytes(), err)
+		}
+		localState.Wipe()
+	}
+}
+
+func TestLocalStateAccessorSaveAfterWipe(t *testing.T) {
+	l, err := NewLocalStateAccessorWithVault(t.TempDir(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.SaveLocalState("ecdsa", "keyshare"); err != nil {
+		t.Fatal(err)
+	}
+	l.Wipe()
+	// a ceremony abandoned on cancel saves its keyshare after the operation finished
+	if err := l.SaveLocalState("eddsa", "keyshare"); err != nil {
+		t.Fatal(err)
+	}
+	for _, pubKey := range []string{"ecdsa", "eddsa"} {
+		if localState, err := l.GetLocalCacheState(pubKey); err != nil || localState != nil {
+			t.Fatalf("%s keyshare kept after wipe: %s %v", pubKey, localState.Bytes(), err)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/sirupsen/logrus"
+
+	"github.com/vultisig/vultisigner/common"
+	"github.com/vultisig/vultisigner/contexthelper"
+	"github.com/vultisig/vultisigner/internal/tasks"
+	"github.com/vultisig/vultisigner/internal/types"
+)
+
+// deliverBackup schedules the delivery of the saved vault share backup through the selected channel
+func (s *WorkerService) deliverBackup(vault types.VaultMetadata, content string, email string, delivery types.BackupDeliveryOptions) error {
+	s.incCounter("worker.vault.backup.delivery", []string{"channel:" + string(delivery.Delivery())})
+	switch delivery.Delivery() {
+	case types.BackupDeliveryEmail:
+		code, err := s.createVerificationCode(vault.PublicKeyEcdsa)
+		if err != nil {
+			return fmt.Errorf("failed to create verification code: %w", err)
+		}
+		return s.enqueueBackupEmail(types.EmailRequest{
+			Email:          email,
+			FileName:       vault.FileName,
+			FileContent:    content,
+			VaultName:      vault.Name,
+			Code:           code,
+			PGPFingerprint: vault.PGPFingerprint,
+		})
+	case types.BackupDeliveryDownload:
+		// the link asks for the vault password , a code in the same email wouldn't protect it
+		ttl := time.Duration(s.cfg.BackupDelivery.DownloadTTLMinutes) * time.Minute
+		token, err := s.redis.CreateBackupDownload(context.Background(), vault.PublicKeyEcdsa, ttl)
+		if err != nil {
+			return fmt.Errorf("failed to create backup download link: %w", err)
+		}
+		return s.enqueueBackupEmail(types.EmailRequest{
+			Email:       email,
+			FileName:    vault.FileName,
+			VaultName:   vault.Name,
+			DownloadURL: strings.TrimRight(s.cfg.Notification.BaseURL, "/") + "/vault/backup/" + token,
+		})
+	case types.BackupDeliveryUpload:
+		buf, err := json.Marshal(types.BackupUploadRequest{
+			PublicKeyECDSA: vault.PublicKeyEcdsa,
+			URL:            delivery.BackupUploadURL,
+			Headers:        delivery.BackupUploadHeaders,
+			FileContent:    content,
+		})
+		if err != nil {
+			return fmt.Errorf("json.Marshal failed: %w", err)
+		}
+		taskInfo, err := s.queueClient.Enqueue(asynq.NewTask(tasks.TypeBackupUpload, buf),
+			asynq.Retention(10*time.Minute),
+			asynq.Queue(tasks.EMAIL_QUEUE_NAME))
+		if err != nil {
+			return fmt.Errorf("fail to enqueue backup upload task: %w", err)
+		}
+		s.logger.Info("Backup upload task enqueued: ", taskInfo.ID)
+		return nil
+	case types.BackupDeliveryResult:
+		// the operation writes the backup to its task result
+		return nil
+	default:
+		return fmt.Errorf("unknown backup delivery %s", delivery.BackupDelivery)
+	}
+}
+
+func (s *WorkerService) enqueueBackupEmail(emailRequest types.EmailRequest) error {
+	buf, err := json.Marshal(emailRequest)
+	if err != nil {
+		return fmt.Errorf("json.Marshal failed: %w", err)
+	}
+	taskInfo, err := s.queueClient.Enqueue(asynq.NewTask(tasks.TypeEmailVaultBackup, buf),
+		asynq.Retention(10*time.Minute),
+		asynq.Queue(tasks.EMAIL_QUEUE_NAME))
+	if err != nil {
+		s.logger.Errorf("fail to enqueue email task: %v", err)
+		return nil
+	}
+	s.logger.Info("Email task enqueued: ", taskInfo.ID)
+	return nil
+}
+
+// readBackupResult returns the saved vault share backup , for operations that deliver it in the task result
+func (s *WorkerService) readBackupResult(publicKeyECDSA string, delivery types.BackupDeliveryOptions) (string, error) {
+	if delivery.Delivery() != types.BackupDeliveryResult {
+		return "", nil
+	}
+	content, err := s.blockStorage.GetFile(publicKeyECDSA + ".bak")
+	if err != nil {
+		return "", fmt.Errorf("fail to read vault share backup: %w", err)
+	}
+	return string(content), nil
+}
+
+// readPendingBackupResult returns the pending vault share backup staged by the session , empty when the share isn't pending
+func (s *WorkerService) readPendingBackupResult(publicKeyECDSA, sessionID string, delivery types.BackupDeliveryOptions) (string, error) {
+	if delivery.Delivery() != types.BackupDeliveryResult {
+		return "", nil
+	}
+	pending, err := s.redis.GetPendingVault(context.Background(), publicKeyECDSA)
+	if err != nil {
+		return "", fmt.Errorf("fail to get pending vault: %w", err)
+	}
+	if pending == nil || pending.SessionID != sessionID {
+		return "", nil
+	}
+	content, err := s.blockStorage.GetFile(pendingVaultFile(publicKeyECDSA))
+	if err != nil {
+		return "", fmt.Errorf("fail to read pending vault share backup: %w", err)
+	}
+	return string(content), nil
+}
+
+// HandleBackupUpload uploads a vault share backup with an HTTP PUT , 4xx responses are not retried.
+// The url is user supplied , so only public addresses are uploaded to and redirects are not followed.
+func (s *WorkerService) HandleBackupUpload(ctx context.Context, t *asynq.Task) error {
+	if err := contexthelper.CheckCancellation(ctx); err != nil {
+		return err
+	}
+	var req types.BackupUploadRequest
+	if err := json.Unmarshal(t.Payload(), &req); err != nil {
+		s.logger.Errorf("json.Unmarshal failed: %v", err)
+		return fmt.Errorf("json.Unmarshal failed: %v: %w", err, asynq.SkipRetry)
+	}
+	s.incCounter("worker.vault.backup.upload", []string{})
+	s.logger.WithFields(logrus.Fields{
+		"public_key_ecdsa": req.PublicKeyECDSA,
+	}).Info("uploading backup")
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(s.cfg.BackupDelivery.UploadTimeout)*time.Second)
+	defer cancel()
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPut, req.URL, bytes.NewReader([]byte(req.FileContent)))
+	if err != nil {
+		return fmt.Errorf("http.NewRequest failed: %v: %w", err, asynq.SkipRetry)
+	}
+	httpReq.Header.Set("Content-Type", "application/octet-stream")
+	for name, value := range req.Headers {
+		httpReq.Header.Set(name, value)
+	}
+	resp, err := s.uploadClient.Do(httpReq)
+	if errors.Is(err, common.ErrNonPublicAddress) {
+		return fmt.Errorf("backup upload failed: %v: %w", err, asynq.SkipRetry)
+	}
+	if err != nil {
+		return fmt.Errorf("backup upload failed: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if err := httpStatusError("backup upload", resp); err != nil {
+		s.logger.Errorf("fail to upload backup of %s: %v", req.PublicKeyECDSA, err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+
+	"github.com/vultisig/vultisigner/common"
+	"github.com/vultisig/vultisigner/internal/tasks"
+	"github.com/vultisig/vultisigner/internal/types"
+)
+
+func newBackupUploadTask(t *testing.T, url string) *asynq.Task {
+	buf, err := json.Marshal(types.BackupUploadRequest{
+		PublicKeyECDSA: testPublicKeyECDSA,
+		URL:            url,
+		Headers:        map[string]string{"Authorization": "Bearer token"},
+		FileContent:    "backup",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return asynq.NewTask(tasks.TypeBackupUpload, buf)
+}
+
+func TestHandleBackupUpload(t *testing.T) {
+	tests := map[string]struct {
+		status    int
+		err       bool
+		skipRetry bool
+	}{
+		"uploaded":     {status: http.StatusOK},
+		"rejected":     {status: http.StatusForbidden, err: true, skipRetry: true},
+		"rate limited": {status: http.StatusTooManyRequests, err: true},
+		"server error": {status: http.StatusBadGateway, err: true},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var received *http.Request
+			var body string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				buf, _ := io.ReadAll(r.Body)
+				received, body = r, string(buf)
+				w.WriteHeader(test.status)
+			}))
+			defer server.Close()
+			s, _, _ := newTestWorker(t)
+			s.cfg.BackupDelivery.UploadTimeout = 5
+			// the test server listens on loopback , which the public client refuses
+			s.uploadClient = server.Client()
+			err := s.HandleBackupUpload(context.Background(), newBackupUploadTask(t, server.URL+"/upload"))
+			if (err != nil) != test.err || errors.Is(err, asynq.SkipRetry) != test.skipRetry {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if received == nil || received.Method != http.MethodPut || received.URL.Path != "/upload" {
+				t.Fatalf("unexpected upload request: %+v", received)
+			}
+			if body != "backup" || received.Header.Get("Authorization") != "Bearer token" || received.Header.Get("Content-Type") != "application/octet-stream" {
+				t.Errorf("unexpected upload: %q %v", body, received.Header)
+			}
+		})
+	}
+}
+
+func TestHandleBackupUploadPublicOnly(t *testing.T) {
+	uploaded := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		uploaded = true
+	}))
+	defer server.Close()
+	s, _, _ := newTestWorker(t)
+	s.cfg.BackupDelivery.UploadTimeout = 5
+	s.uploadClient = common.NewPublicHTTPClient(5 * time.Second)
+	err := s.HandleBackupUpload(context.Background(), newBackupUploadTask(t, server.URL))
+	if !errors.Is(err, asynq.SkipRetry) || !strings.Contains(err.Error(), common.ErrNonPublicAddress.Error()) {
+		t.Fatalf("expected the loopback address to be refused without retry, got %v", err)
+	}
+	if uploaded {
+		t.Error("backup was uploaded to a loopback address")
+	}
+}
+
+func TestDeliverBackupResult(t *testing.T) {
+	s, _, server := newTestWorker(t)
+	if err := s.blockStorage.UploadFile([]byte("backup"), testPublicKeyECDSA+".bak"); err != nil {
+		t.Fatal(err)
+	}
+	result := types.BackupDeliveryOptions{BackupDelivery: types.BackupDeliveryResult}
+	if err := s.deliverBackup(types.VaultMetadata{PublicKeyEcdsa: testPublicKeyECDSA}, "backup", "user@example.com", result); err != nil {
+		t.Fatal(err)
+	}
+	// the result channel neither emails nor uploads the backup
+	if pending, _ := server.List("asynq:{" + tasks.EMAIL_QUEUE_NAME + "}:pending"); len(pending) != 0 {
+		t.Fatalf("result delivery enqueued tasks: %v", pending)
+	}
+	content, err := s.readBackupResult(testPublicKeyECDSA, result)
+	if err != nil || content != "backup" {
+		t.Fatalf("unexpected backup in the result: %q %v", content, err)
+	}
+	content, err = s.readBackupResult(testPublicKeyECDSA, types.BackupDeliveryOptions{})
+	if err != nil || content != "" {
+		t.Fatalf("email delivery put the backup in the result: %q %v", content, err)
+	}
+}
+
+func TestDeliverBackupUpload(t *testing.T) {
+	s, _, server := newTestWorker(t)
+	upload := types.BackupDeliveryOptions{
+		BackupDelivery:      types.BackupDeliveryUpload,
+		BackupUploadURL:     "https://example.com/upload",
+		BackupUploadHeaders: map[string]string{"Authorization": "Bearer token"},
+	}
+	if err := s.deliverBackup(types.VaultMetadata{PublicKeyEcdsa: testPublicKeyECDSA}, "backup", "", upload); err != nil {
+		t.Fatal(err)
+	}
+	pending, err := server.List("asynq:{" + tasks.EMAIL_QUEUE_NAME + "}:pending")
+	if err != nil || len(pending) != 1 {
+		t.Fatalf("expected one upload task, got %v %v", pending, err)
+	}
+	inspector := asynq.NewInspector(asynq.RedisClientOpt{Addr: server.Addr()})
+	defer func() {
+		_ = inspector.Close()
+	}()
+	ti, err := inspector.GetTaskInfo(tasks.EMAIL_QUEUE_NAME, pending[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var req types.BackupUploadRequest
+	if err := json.Unmarshal(ti.Payload, &req); err != nil {
+		t.Fatal(err)
+	}
+	if ti.Type != tasks.TypeBackupUpload || req.URL != upload.BackupUploadURL || req.Headers["Authorization"] != "Bearer token" || req.FileContent != "backup" {
+		t.Fatalf("unexpected upload task: %s %+v", ti.Type, req)
+	}
+}
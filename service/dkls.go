@@ -12,6 +12,7 @@ import (
 
 	"github.com/sirupsen/logrus"
 
+	"github.com/vultisig/vultisigner/common"
 	"github.com/vultisig/vultisigner/config"
 	"github.com/vultisig/vultisigner/internal/types"
 	"github.com/vultisig/vultisigner/relay"
@@ -20,32 +21,49 @@ import (
 
 var TssKeyGenTimeout = errors.New("keygen timeout")
 
+// dklsEdDSAMessageID is the message id of the EdDSA keygen or migrate session , when the peers run it concurrently with ECDSA
+const dklsEdDSAMessageID = "eddsa"
+
+// ceremonyResult is the result of the keygen or migrate session of one key type
+type ceremonyResult struct {
+	publicKey string
+	chainCode string
+	err       error
+}
+
 type DKLSTssService struct {
 	cfg                config.Config
 	messenger          *relay.MessengerImp
 	logger             *logrus.Logger
 	localStateAccessor *relay.LocalStateAccessorImp
 	isKeygenFinished   *atomic.Bool
-	isKeysignFinished  *atomic.Bool
 	blockStorage       *storage.BlockStorage
 	backup             VaultOperation
+	pairwiseKeys       *relay.PairwiseKeyStore
+	vaultCache         *storage.VaultCache
 }
 
 func NewDKLSTssService(cfg config.Config,
 	blockStorage *storage.BlockStorage,
 	localStateAccessor *relay.LocalStateAccessorImp,
-	backupInterface VaultOperation) (*DKLSTssService, error) {
+	backupInterface VaultOperation,
+	vaultCache *storage.VaultCache) (*DKLSTssService, error) {
 	return &DKLSTssService{
 		cfg:                cfg,
 		logger:             logrus.WithField("service", "dkls").Logger,
 		isKeygenFinished:   &atomic.Bool{},
-		isKeysignFinished:  &atomic.Bool{},
 		blockStorage:       blockStorage,
 		localStateAccessor: localStateAccessor,
 		backup:             backupInterface,
+		vaultCache:         vaultCache,
 	}, nil
 }
 
+// Wipe zeroes the keyshares the operation saved , call it once the operation finished
+func (t *DKLSTssService) Wipe() {
+	t.localStateAccessor.Wipe()
+}
+
 func (t *DKLSTssService) GetMPCKeygenWrapper(isEdDSA bool) *MPCWrapperImp {
 	return NewMPCWrapperImp(isEdDSA)
 }
@@ -58,45 +76,36 @@ func (t *DKLSTssService) ProceeDKLSKeygen(req types.VaultCreateRequest) (string,
 	if err := relayClient.RegisterSession(req.SessionID, req.LocalPartyId); err != nil {
 		return "", "", fmt.Errorf("failed to register session: %w", err)
 	}
-	// wait longer for keygen start
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-	defer cancel()
-
-	partiesJoined, err := relayClient.WaitForSessionStart(ctx, req.SessionID)
-	t.logger.WithFields(logrus.Fields{
-		"sessionID":      req.SessionID,
-		"parties_joined": partiesJoined,
-	}).Info("Session started")
-
-	if err != nil {
-		return "", "", fmt.Errorf("failed to wait for session start: %w", err)
+	if err := t.startPairwiseEncryption(relayClient, req.SessionID, req.LocalPartyId, req.HexEncryptionKey); err != nil {
+		return "", "", err
 	}
-	// create ECDSA key
-	publicKeyECDSA, chainCodeECDSA, err := t.keygenWithRetry(req.SessionID, req.HexEncryptionKey, req.LocalPartyId, false, partiesJoined)
+	partiesJoined, err := waitForSession(t.logger, relayClient, req.SessionID, 5*time.Minute)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to keygen ECDSA: %w", err)
+		return "", "", err
 	}
-	time.Sleep(500 * time.Millisecond)
-	// create EdDSA key
-	publicKeyEdDSA, _, err := t.keygenWithRetry(req.SessionID, req.HexEncryptionKey, req.LocalPartyId, true, partiesJoined)
+	threshold, err := common.ResolveThreshold(req.Threshold, len(partiesJoined))
 	if err != nil {
-		return "", "", fmt.Errorf("failed to keygen EdDSA: %w", err)
+		return "", "", fmt.Errorf("invalid threshold: %w", err)
 	}
-
-	if err := relayClient.CompleteSession(req.SessionID, req.LocalPartyId); err != nil {
-		t.logger.WithFields(logrus.Fields{
-			"session": req.SessionID,
-			"error":   err,
-		}).Error("Failed to complete session")
+	req.Threshold = threshold
+	if err := t.exchangePairwiseKeys(relayClient, req.SessionID, partiesJoined, req.HexEncryptionKey, ""); err != nil {
+		return "", "", err
 	}
-
-	if isCompleted, err := relayClient.CheckCompletedParties(req.SessionID, partiesJoined); err != nil || !isCompleted {
-		t.logger.WithFields(logrus.Fields{
-			"sessionID":   req.SessionID,
-			"isCompleted": isCompleted,
-			"error":       err,
-		}).Error("Failed to check completed parties")
+	ecdsaResult, eddsaResult := t.runKeyPairCeremonies(req.SessionID, func(isEdDSA bool, messageID string) (string, string, error) {
+		return t.keygenWithRetry(req.SessionID, req.HexEncryptionKey, req.LocalPartyId, isEdDSA, messageID, partiesJoined, threshold)
+	})
+	if ecdsaResult.err != nil {
+		return "", "", fmt.Errorf("failed to keygen ECDSA: %w", ecdsaResult.err)
+	}
+	if eddsaResult.err != nil {
+		return "", "", fmt.Errorf("failed to keygen EdDSA: %w", eddsaResult.err)
 	}
+	publicKeyECDSA, chainCodeECDSA, publicKeyEdDSA := ecdsaResult.publicKey, ecdsaResult.chainCode, eddsaResult.publicKey
+	if err := t.pinPartyIdentities(publicKeyECDSA); err != nil {
+		return "", "", fmt.Errorf("failed to pin party identities: %w", err)
+	}
+
+	finishSession(t.logger, relayClient, req.SessionID, req.LocalPartyId, partiesJoined)
 	if t.backup == nil {
 		return publicKeyECDSA, publicKeyEdDSA, nil
 	}
@@ -108,13 +117,63 @@ func (t *DKLSTssService) ProceeDKLSKeygen(req types.VaultCreateRequest) (string,
 	return publicKeyECDSA, publicKeyEdDSA, nil
 }
 
+// runKeyPairCeremonies runs the ECDSA and EdDSA sessions of a keygen or migration , ceremony runs the session of one key type.
+// Peers that run both concurrently publish the EdDSA setup message under its own message id , then EdDSA starts right away
+// and its messages are separated from ECDSA by the message id. Peers that only support sequential mode publish the
+// EdDSA setup message under the ECDSA message id once ECDSA finished , then EdDSA runs after ECDSA.
+func (t *DKLSTssService) runKeyPairCeremonies(sessionID string, ceremony func(isEdDSA bool, messageID string) (string, string, error)) (ceremonyResult, ceremonyResult) {
+	ecdsaDone := make(chan ceremonyResult, 1)
+	go func() {
+		publicKey, chainCode, err := ceremony(false, "")
+		ecdsaDone <- ceremonyResult{publicKey: publicKey, chainCode: chainCode, err: err}
+	}()
+	relayClient := relay.NewRelayClient(t.cfg.Relay.Server)
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		if t.hasSetupMessage(relayClient, sessionID, dklsEdDSAMessageID) {
+			t.logger.WithField("session", sessionID).Info("EdDSA runs concurrently with ECDSA")
+			publicKey, chainCode, err := ceremony(true, dklsEdDSAMessageID)
+			return <-ecdsaDone, ceremonyResult{publicKey: publicKey, chainCode: chainCode, err: err}
+		}
+		select {
+		case ecdsaResult := <-ecdsaDone:
+			if ecdsaResult.err != nil {
+				return ecdsaResult, ceremonyResult{err: fmt.Errorf("ECDSA failed")}
+			}
+			messageID := ""
+			if t.hasSetupMessage(relayClient, sessionID, dklsEdDSAMessageID) {
+				messageID = dklsEdDSAMessageID
+			} else {
+				t.logger.WithField("session", sessionID).Info("EdDSA runs after ECDSA , the peers only support sequential mode")
+				// give the peers time to publish the EdDSA setup message
+				time.Sleep(500 * time.Millisecond)
+			}
+			publicKey, chainCode, err := ceremony(true, messageID)
+			return ecdsaResult, ceremonyResult{publicKey: publicKey, chainCode: chainCode, err: err}
+		case <-ticker.C:
+			if err := relay.SessionCancelled(sessionID); err != nil {
+				return <-ecdsaDone, ceremonyResult{err: err}
+			}
+		}
+	}
+}
+
+// hasSetupMessage returns true when the setup message of the message id is published
+func (t *DKLSTssService) hasSetupMessage(relayClient *relay.Client, sessionID, messageID string) bool {
+	payload, err := relayClient.GetSetupMessage(sessionID, messageID)
+	return err == nil && payload != ""
+}
+
 func (t *DKLSTssService) keygenWithRetry(sessionID string,
 	hexEncryptionKey string,
 	localPartyID string,
 	isEdDSA bool,
-	keygenCommittee []string) (string, string, error) {
+	messageID string,
+	keygenCommittee []string,
+	threshold int) (string, string, error) {
 	for i := 0; i < 3; i++ {
-		publicKey, chainCode, err := t.keygen(sessionID, hexEncryptionKey, localPartyID, isEdDSA, keygenCommittee, i)
+		publicKey, chainCode, err := t.keygen(sessionID, hexEncryptionKey, localPartyID, isEdDSA, messageID, keygenCommittee, threshold, i)
 		if err != nil {
 			t.logger.WithFields(logrus.Fields{
 				"session_id":       sessionID,
@@ -135,21 +194,24 @@ func (t *DKLSTssService) keygen(sessionID string,
 	hexEncryptionKey string,
 	localPartyID string,
 	isEdDSA bool,
+	messageID string,
 	keygenCommittee []string,
+	threshold int,
 	attempt int) (string, string, error) {
 	t.logger.WithFields(logrus.Fields{
 		"session_id":       sessionID,
 		"local_party_id":   localPartyID,
 		"keygen_committee": keygenCommittee,
+		"threshold":        threshold,
+		"is_eddsa":         isEdDSA,
 		"attempt":          attempt,
 	}).Info("Keygen")
-	t.isKeygenFinished.Store(false)
 	relayClient := relay.NewRelayClient(t.cfg.Relay.Server)
 	mpcKeygenWrapper := t.GetMPCKeygenWrapper(isEdDSA)
 	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
 	defer cancel()
 	// retrieve the setup Message
-	encryptedEncodedSetupMsg, err := relayClient.WaitForSetupMessage(ctx, sessionID, "")
+	encryptedEncodedSetupMsg, err := relayClient.WaitForSetupMessage(ctx, sessionID, messageID)
 	if err != nil {
 		return "", "", fmt.Errorf("failed to get setup message: %w", err)
 	}
@@ -157,6 +219,9 @@ func (t *DKLSTssService) keygen(sessionID string,
 	if err != nil {
 		return "", "", fmt.Errorf("failed to decode setup message: %w", err)
 	}
+	if err := t.checkSetupCommittee(mpcKeygenWrapper, setupMessageBytes, threshold); err != nil {
+		return "", "", err
+	}
 
 	handle, err := mpcKeygenWrapper.KeygenSessionFromSetup(setupMessageBytes, []byte(localPartyID))
 	if err != nil {
@@ -167,14 +232,27 @@ func (t *DKLSTssService) keygen(sessionID string,
 			t.logger.Error("failed to free keygen session", "error", err)
 		}
 	}()
+	return t.runKeygenSession(handle, sessionID, hexEncryptionKey, keygenCommittee, localPartyID, isEdDSA, messageID)
+}
+
+// runKeygenSession exchanges the messages of a keygen or migrate session until it finishes.
+// Every session has its own completion flag , so concurrent ECDSA and EdDSA sessions don't stop each other.
+func (t *DKLSTssService) runKeygenSession(handle Handle,
+	sessionID string,
+	hexEncryptionKey string,
+	keygenCommittee []string,
+	localPartyID string,
+	isEdDSA bool,
+	messageID string) (string, string, error) {
+	finished := &atomic.Bool{}
 	wg := &sync.WaitGroup{}
 	wg.Add(2)
 	go func() {
-		if err := t.processKeygenOutbound(handle, sessionID, hexEncryptionKey, keygenCommittee, localPartyID, isEdDSA, wg); err != nil {
+		if err := t.processKeygenOutbound(handle, sessionID, hexEncryptionKey, keygenCommittee, localPartyID, isEdDSA, messageID, finished, wg); err != nil {
 			t.logger.Error("failed to process keygen outbound", "error", err)
 		}
 	}()
-	publicKey, chainCode, err := t.processKeygenInbound(handle, sessionID, hexEncryptionKey, isEdDSA, localPartyID, wg)
+	publicKey, chainCode, err := t.processKeygenInbound(handle, sessionID, hexEncryptionKey, isEdDSA, localPartyID, messageID, finished, wg)
 	wg.Wait()
 	return publicKey, chainCode, err
 }
@@ -185,9 +263,12 @@ func (t *DKLSTssService) processKeygenOutbound(handle Handle,
 	parties []string,
 	localPartyID string,
 	isEdDSA bool,
+	messageID string,
+	finished *atomic.Bool,
 	wg *sync.WaitGroup) error {
 	defer wg.Done()
-	messenger := relay.NewMessenger(t.cfg.Relay.Server, sessionID, hexEncryptionKey, true, "")
+	messenger := relay.NewMessenger(t.cfg.Relay.Server, sessionID, hexEncryptionKey, true, messageID)
+	messenger.SetPairwiseKeys(t.pairwiseKeys)
 	mpcKeygenWrapper := t.GetMPCKeygenWrapper(isEdDSA)
 	for {
 		outbound, err := mpcKeygenWrapper.KeygenSessionOutputMessage(handle)
@@ -195,7 +276,7 @@ func (t *DKLSTssService) processKeygenOutbound(handle Handle,
 			t.logger.Error("failed to get output message", "error", err)
 		}
 		if len(outbound) == 0 {
-			if t.isKeygenFinished.Load() {
+			if finished.Load() {
 				// we are finished
 				return nil
 			}
@@ -203,6 +284,7 @@ func (t *DKLSTssService) processKeygenOutbound(handle Handle,
 			continue
 		}
 		encodedOutbound := base64.StdEncoding.EncodeToString(outbound)
+		receivers := make([]string, 0, len(parties))
 		for i := 0; i < len(parties); i++ {
 			receiver, err := mpcKeygenWrapper.KeygenSessionMessageReceiver(handle, outbound, i)
 			if err != nil {
@@ -211,13 +293,9 @@ func (t *DKLSTssService) processKeygenOutbound(handle Handle,
 			if len(receiver) == 0 {
 				continue
 			}
-
-			t.logger.Infoln("Sending message to", receiver)
-			// send the message to the receiver
-			if err := messenger.Send(localPartyID, receiver, encodedOutbound); err != nil {
-				t.logger.Errorf("failed to send message: %v", err)
-			}
+			receivers = append(receivers, receiver)
 		}
+		t.sendOutbound(messenger, localPartyID, receivers, encodedOutbound)
 	}
 }
 
@@ -226,8 +304,12 @@ func (t *DKLSTssService) processKeygenInbound(handle Handle,
 	hexEncryptionKey string,
 	isEdDSA bool,
 	localPartyID string,
+	messageID string,
+	finished *atomic.Bool,
 	wg *sync.WaitGroup) (string, string, error) {
 	defer wg.Done()
+	// stop the outbound go routine on every return , it keeps the session handle in use until then
+	defer finished.Store(true)
 	var messageCache sync.Map
 	mpcKeygenWrapper := t.GetMPCKeygenWrapper(isEdDSA)
 	relayClient := relay.NewRelayClient(t.cfg.Relay.Server)
@@ -235,12 +317,14 @@ func (t *DKLSTssService) processKeygenInbound(handle Handle,
 	for {
 		select {
 		case <-time.After(time.Millisecond * 100):
+			if err := relay.SessionCancelled(sessionID); err != nil {
+				return "", "", err
+			}
 			if time.Since(start) > (time.Minute * 2) { // 2 minute timeout
-				t.isKeygenFinished.Store(true)
 				t.logger.Error("keygen timeout")
 				return "", "", TssKeyGenTimeout
 			}
-			messages, err := relayClient.DownloadMessages(sessionID, localPartyID, "")
+			messages, err := relayClient.DownloadMessages(sessionID, localPartyID, messageID)
 			if err != nil {
 				t.logger.Error("failed to download messages", "error", err)
 				continue
@@ -255,7 +339,7 @@ func (t *DKLSTssService) processKeygenInbound(handle Handle,
 					continue
 				}
 
-				inboundBody, err := t.decodeDecryptMessage(message.Body, hexEncryptionKey)
+				inboundBody, err := t.decodeDecryptInboundMessage(message, hexEncryptionKey)
 				if err != nil {
 					t.logger.Error("fail to decode inbound message", "error", err)
 					continue
@@ -267,7 +351,7 @@ func (t *DKLSTssService) processKeygenInbound(handle Handle,
 					continue
 				}
 
-				if err := relayClient.DeleteMessageFromServer(sessionID, localPartyID, message.Hash, ""); err != nil {
+				if err := relayClient.DeleteMessageFromServer(sessionID, localPartyID, message.Hash, messageID); err != nil {
 					t.logger.Error("fail to delete message", "error", err)
 				}
 				if isFinished {
@@ -277,38 +361,52 @@ func (t *DKLSTssService) processKeygenInbound(handle Handle,
 						t.logger.Error("fail to finish keygen", "error", err)
 						return "", "", err
 					}
-					buf, err := mpcKeygenWrapper.KeyshareToBytes(result)
+					encodedPublicKey, chainCode, err := t.saveKeyshare(mpcKeygenWrapper, result, isEdDSA)
 					if err != nil {
-						t.logger.Error("fail to convert keyshare to bytes", "error", err)
+						t.logger.Error("fail to save keyshare", "error", err)
 						return "", "", err
 					}
-					encodedShare := base64.StdEncoding.EncodeToString(buf)
-					publicKeyECDSABytes, err := mpcKeygenWrapper.KeysharePublicKey(result)
-					if err != nil {
-						t.logger.Error("fail to get public key", "error", err)
-						return "", "", err
-					}
-					encodedPublicKey := hex.EncodeToString(publicKeyECDSABytes)
 					t.logger.Infof("Public key: %s", encodedPublicKey)
-					chainCode := ""
-					if !isEdDSA {
-						chainCodeBytes, err := mpcKeygenWrapper.KeyshareChainCode(result)
-						if err != nil {
-							t.logger.Error("fail to get chain code", "error", err)
-							return "", "", err
-						}
-						chainCode = hex.EncodeToString(chainCodeBytes)
-					}
-					// This sleep give the local party a chance to send last message to others
-					t.isKeygenFinished.Store(true)
-					err = t.localStateAccessor.SaveLocalState(encodedPublicKey, encodedShare)
-					return encodedPublicKey, chainCode, err
+					return encodedPublicKey, chainCode, nil
 				}
 			}
 		}
 	}
 }
 
+// saveKeyshare saves the keyshare of a finished keygen , reshare or migrate session to the local state and frees it.
+// It returns the hex encoded public key and chain code , the chain code is empty for EdDSA.
+func (t *DKLSTssService) saveKeyshare(mpcWrapper *MPCWrapperImp, keyshareHandle Handle, isEdDSA bool) (string, string, error) {
+	defer func() {
+		if err := mpcWrapper.KeyshareFree(keyshareHandle); err != nil {
+			t.logger.Error("failed to free keyshare", "error", err)
+		}
+	}()
+	buf, err := mpcWrapper.KeyshareToBytes(keyshareHandle)
+	if err != nil {
+		return "", "", fmt.Errorf("fail to convert keyshare to bytes: %w", err)
+	}
+	keyshare := common.SecretFromBytes(buf)
+	defer keyshare.Wipe()
+	publicKeyBytes, err := mpcWrapper.KeysharePublicKey(keyshareHandle)
+	if err != nil {
+		return "", "", fmt.Errorf("fail to get public key: %w", err)
+	}
+	chainCode := ""
+	if !isEdDSA {
+		chainCodeBytes, err := mpcWrapper.KeyshareChainCode(keyshareHandle)
+		if err != nil {
+			return "", "", fmt.Errorf("fail to get chain code: %w", err)
+		}
+		chainCode = hex.EncodeToString(chainCodeBytes)
+	}
+	encodedPublicKey := hex.EncodeToString(publicKeyBytes)
+	if err := t.localStateAccessor.SaveLocalStateSecret(encodedPublicKey, common.SecretFromBytes(keyshare.EncodeBase64())); err != nil {
+		return "", "", fmt.Errorf("fail to save local state: %w", err)
+	}
+	return encodedPublicKey, chainCode, nil
+}
+
 func (t *DKLSTssService) convertKeygenCommitteeToBytes(paries []string) ([]byte, error) {
 	if len(paries) == 0 {
 		return nil, fmt.Errorf("no parties provided")
@@ -324,3 +422,124 @@ func (t *DKLSTssService) convertKeygenCommitteeToBytes(paries []string) ([]byte,
 	}
 	return result, nil
 }
+
+// checkSetupCommittee makes sure the setup message encodes the expected threshold , and a committee that can reach it
+func (t *DKLSTssService) checkSetupCommittee(mpcWrapper *MPCWrapperImp, setupMessage []byte, threshold int) error {
+	setupThreshold, err := mpcWrapper.DecodeThreshold(setupMessage)
+	if err != nil {
+		return fmt.Errorf("failed to decode setup message threshold: %w", err)
+	}
+	if setupThreshold != threshold {
+		return fmt.Errorf("setup message threshold %d doesn't match threshold %d", setupThreshold, threshold)
+	}
+	parties := 0
+	for {
+		partyName, err := mpcWrapper.DecodePartyName(setupMessage, parties)
+		if err != nil || len(partyName) == 0 {
+			break
+		}
+		parties++
+	}
+	if parties < threshold {
+		return fmt.Errorf("setup message committee size %d is less than threshold %d", parties, threshold)
+	}
+	return nil
+}
+
+// startPairwiseEncryption publishes a signed ephemeral X25519 key for the session when pairwise encryption is enabled
+func (t *DKLSTssService) startPairwiseEncryption(relayClient *relay.Client, sessionID, localPartyID, hexEncryptionKey string) error {
+	t.pairwiseKeys = nil
+	if !t.cfg.Relay.PairwiseEncryption {
+		return nil
+	}
+	identity, err := relay.ParseIdentityKey(t.cfg.Relay.IdentityKey)
+	if err != nil {
+		return fmt.Errorf("invalid relay identity key: %w", err)
+	}
+	store, err := relay.NewPairwiseKeyStore(sessionID, localPartyID, identity)
+	if err != nil {
+		return fmt.Errorf("failed to create pairwise key store: %w", err)
+	}
+	if err := relayClient.PublishPairwiseKey(store, hexEncryptionKey); err != nil {
+		return fmt.Errorf("failed to publish pairwise key: %w", err)
+	}
+	t.pairwiseKeys = store
+	return nil
+}
+
+// exchangePairwiseKeys negotiates the encryption mode with the joined parties , and checks the identities of the peers
+// against the ones pinned for the vault. New vaults have no public key yet , their identities are pinned by pinPartyIdentities.
+func (t *DKLSTssService) exchangePairwiseKeys(relayClient *relay.Client, sessionID string, parties []string, hexEncryptionKey string, vaultPublicKey string) error {
+	if t.pairwiseKeys == nil {
+		if t.cfg.Relay.PairwiseRequired {
+			return relay.ErrPairwiseRequired
+		}
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	mode, err := relayClient.NegotiatePairwiseMode(ctx, t.pairwiseKeys, parties, hexEncryptionKey)
+	if err != nil {
+		return fmt.Errorf("failed to negotiate pairwise encryption: %w", err)
+	}
+	t.logger.WithFields(logrus.Fields{
+		"session": sessionID,
+		"mode":    mode,
+	}).Info("Pairwise encryption negotiated")
+	if mode != relay.PairwiseModePairwise {
+		if t.cfg.Relay.PairwiseRequired {
+			return relay.ErrPairwiseRequired
+		}
+		return nil
+	}
+	if vaultPublicKey == "" {
+		return nil
+	}
+	return t.pinPartyIdentities(vaultPublicKey)
+}
+
+// pinPartyIdentities checks the identity keys of the peers against the ones pinned for the vault , parties seen
+// for the first time are pinned. A party that shows up with another identity key fails the operation.
+func (t *DKLSTssService) pinPartyIdentities(vaultPublicKey string) error {
+	if !t.pairwiseKeys.Negotiated() {
+		return nil
+	}
+	if t.blockStorage == nil {
+		return fmt.Errorf("no storage to pin the party identities of vault %s", vaultPublicKey)
+	}
+	settings, err := t.blockStorage.GetVaultSettings(vaultPublicKey)
+	if errors.Is(err, storage.ErrVaultSettingsNotFound) {
+		settings = &types.VaultSettings{PublicKeyEcdsa: vaultPublicKey}
+	} else if err != nil {
+		return err
+	}
+	if settings.PartyIdentities == nil {
+		settings.PartyIdentities = make(map[string]string)
+	}
+	changed := false
+	for partyID, identity := range t.pairwiseKeys.PeerIdentities() {
+		pinned, ok := settings.PartyIdentities[partyID]
+		if !ok {
+			settings.PartyIdentities[partyID] = identity
+			changed = true
+			continue
+		}
+		if pinned != identity {
+			return fmt.Errorf("party %s signed its pairwise key with identity %s , but vault %s pinned identity %s", partyID, identity, vaultPublicKey, pinned)
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return t.blockStorage.SaveVaultSettings(*settings)
+}
+
+// sendOutbound sends a message to every receiver , with the pairwise key of the receiver once pairwise mode is negotiated
+func (t *DKLSTssService) sendOutbound(messenger *relay.MessengerImp, localPartyID string, receivers []string, encodedOutbound string) {
+	for _, receiver := range receivers {
+		t.logger.Infoln("Sending message to", receiver)
+		if err := messenger.Send(localPartyID, receiver, encodedOutbound); err != nil {
+			t.logger.Errorf("failed to send message: %v", err)
+		}
+	}
+}
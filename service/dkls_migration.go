@@ -5,7 +5,6 @@ import (
 	"encoding/hex"
 	"fmt"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -14,6 +13,8 @@ import (
 	"github.com/vultisig/mobile-tss-lib/tss"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
+	"github.com/vultisig/vultisigner/common"
+	"github.com/vultisig/vultisigner/internal/types"
 	"github.com/vultisig/vultisigner/relay"
 )
 
@@ -27,8 +28,9 @@ func rightPadWithZeros(input string, length int) string {
 func (t *DKLSTssService) ProceeMigration(vault *vaultType.Vault,
 	sessionID string,
 	hexEncryptionKey string,
-	encryptionPassword string,
-	email string) error {
+	encryptionPassword *common.Secret,
+	email string,
+	delivery types.BackupDeliveryOptions) error {
 	serverURL := t.cfg.Relay.Server
 	relayClient := relay.NewRelayClient(serverURL)
 	if vault.Name == "" {
@@ -71,59 +73,35 @@ func (t *DKLSTssService) ProceeMigration(vault *vaultType.Vault,
 	if err := relayClient.RegisterSession(sessionID, localPartyId); err != nil {
 		return fmt.Errorf("failed to register session: %w", err)
 	}
-	// wait longer for keygen start
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-	defer cancel()
-
-	partiesJoined, err := relayClient.WaitForSessionStart(ctx, sessionID)
-	t.logger.WithFields(logrus.Fields{
-		"sessionID":      sessionID,
-		"parties_joined": partiesJoined,
-	}).Info("Session started")
-
-	if err != nil {
-		return fmt.Errorf("failed to wait for session start: %w", err)
+	if err := t.startPairwiseEncryption(relayClient, sessionID, localPartyId, hexEncryptionKey); err != nil {
+		return err
 	}
-
-	// create ECDSA key
-	publicKeyECDSA, chainCodeECDSA, err := t.migrateWithRetry(vault.PublicKeyEcdsa,
-		vault.HexChainCode,
-		localUIEcdsa,
-		sessionID,
-		hexEncryptionKey,
-		localPartyId,
-		false,
-		partiesJoined)
-	if err != nil {
-		return fmt.Errorf("failed to keygen ECDSA: %w", err)
-	}
-	time.Sleep(500 * time.Millisecond)
-	// create EdDSA key
-	publicKeyEdDSA, _, err := t.migrateWithRetry(
-		vault.PublicKeyEddsa,
-		vault.HexChainCode,
-		localUIEddsa,
-		sessionID,
-		hexEncryptionKey,
-		localPartyId, true, partiesJoined)
+	partiesJoined, err := waitForSession(t.logger, relayClient, sessionID, 5*time.Minute)
 	if err != nil {
-		return fmt.Errorf("failed to keygen EdDSA: %w", err)
+		return err
 	}
-
-	if err := relayClient.CompleteSession(sessionID, localPartyId); err != nil {
-		t.logger.WithFields(logrus.Fields{
-			"session": sessionID,
-			"error":   err,
-		}).Error("Failed to complete session")
+	if err := t.blockStorage.CheckRevokedParties(vault.PublicKeyEcdsa, partiesJoined); err != nil {
+		return err
+	}
+	if err := t.exchangePairwiseKeys(relayClient, sessionID, partiesJoined, hexEncryptionKey, vault.PublicKeyEcdsa); err != nil {
+		return err
 	}
 
-	if isCompleted, err := relayClient.CheckCompletedParties(sessionID, partiesJoined); err != nil || !isCompleted {
-		t.logger.WithFields(logrus.Fields{
-			"sessionID":   sessionID,
-			"isCompleted": isCompleted,
-			"error":       err,
-		}).Error("Failed to check completed parties")
+	ecdsaResult, eddsaResult := t.runKeyPairCeremonies(sessionID, func(isEdDSA bool, messageID string) (string, string, error) {
+		if isEdDSA {
+			return t.migrateWithRetry(vault.PublicKeyEddsa, vault.HexChainCode, localUIEddsa, sessionID, hexEncryptionKey, localPartyId, true, messageID, partiesJoined)
+		}
+		return t.migrateWithRetry(vault.PublicKeyEcdsa, vault.HexChainCode, localUIEcdsa, sessionID, hexEncryptionKey, localPartyId, false, messageID, partiesJoined)
+	})
+	if ecdsaResult.err != nil {
+		return fmt.Errorf("failed to keygen ECDSA: %w", ecdsaResult.err)
 	}
+	if eddsaResult.err != nil {
+		return fmt.Errorf("failed to keygen EdDSA: %w", eddsaResult.err)
+	}
+	publicKeyECDSA, chainCodeECDSA, publicKeyEdDSA := ecdsaResult.publicKey, ecdsaResult.chainCode, eddsaResult.publicKey
+
+	isCompleted := finishSession(t.logger, relayClient, sessionID, localPartyId, partiesJoined)
 	if t.backup == nil {
 		return nil
 	}
@@ -132,14 +110,16 @@ func (t *DKLSTssService) ProceeMigration(vault *vaultType.Vault,
 	if err != nil {
 		return fmt.Errorf("failed to get local sate: %w", err)
 	}
-	if ecdsaKeyShare == "" {
+	defer ecdsaKeyShare.Wipe()
+	if ecdsaKeyShare.Len() == 0 {
 		return fmt.Errorf("failed to get ecdsa keyshare")
 	}
 	eddsaKeyShare, err := t.localStateAccessor.GetLocalCacheState(publicKeyEdDSA)
 	if err != nil {
 		return fmt.Errorf("failed to get local sate: %w", err)
 	}
-	if eddsaKeyShare == "" {
+	defer eddsaKeyShare.Wipe()
+	if eddsaKeyShare.Len() == 0 {
 		return fmt.Errorf("failed to get eddsa keyshare")
 	}
 	newVault := &vaultType.Vault{
@@ -149,21 +129,23 @@ func (t *DKLSTssService) ProceeMigration(vault *vaultType.Vault,
 		Signers:        partiesJoined,
 		CreatedAt:      timestamppb.Now(),
 		HexChainCode:   chainCodeECDSA,
-		KeyShares: []*vaultType.Vault_KeyShare{
-			{
-				PublicKey: publicKeyECDSA,
-				Keyshare:  ecdsaKeyShare,
-			},
-			{
-				PublicKey: publicKeyEdDSA,
-				Keyshare:  eddsaKeyShare,
-			},
-		},
-		LocalPartyId:  vault.LocalPartyId,
-		LibType:       keygenType.LibType_LIB_TYPE_DKLS,
-		ResharePrefix: "",
-	}
-	return t.backup.SaveVaultAndScheduleEmail(newVault, encryptionPassword, email)
+		LocalPartyId:   vault.LocalPartyId,
+		LibType:        keygenType.LibType_LIB_TYPE_DKLS,
+		ResharePrefix:  "",
+	}
+	threshold, err := t.blockStorage.GetVaultThreshold(vault)
+	if err != nil {
+		return fmt.Errorf("failed to get vault threshold: %w", err)
+	}
+	revokedParties, err := t.blockStorage.GetRevokedParties(vault.PublicKeyEcdsa)
+	if err != nil {
+		return fmt.Errorf("failed to get revoked parties: %w", err)
+	}
+	keyshares := []common.VaultKeyshare{
+		{PublicKey: publicKeyECDSA, Keyshare: ecdsaKeyShare},
+		{PublicKey: publicKeyEdDSA, Keyshare: eddsaKeyShare},
+	}
+	return t.backup.SaveVaultShare(newVault, keyshares, threshold, revokedParties, encryptionPassword, email, delivery, sessionID, partiesJoined, isCompleted)
 }
 
 func (t *DKLSTssService) migrateWithRetry(publicKey string,
@@ -173,6 +155,7 @@ func (t *DKLSTssService) migrateWithRetry(publicKey string,
 	hexEncryptionKey string,
 	localPartyID string,
 	isEdDSA bool,
+	messageID string,
 	keygenCommittee []string) (string, string, error) {
 	for i := 0; i < 3; i++ {
 		publicKey, chainCode, err := t.migrate(publicKey,
@@ -182,6 +165,7 @@ func (t *DKLSTssService) migrateWithRetry(publicKey string,
 			hexEncryptionKey,
 			localPartyID,
 			isEdDSA,
+			messageID,
 			keygenCommittee, i)
 		if err != nil {
 			t.logger.WithFields(logrus.Fields{
@@ -207,6 +191,7 @@ func (t *DKLSTssService) migrate(
 	hexEncryptionKey string,
 	localPartyID string,
 	isEdDSA bool,
+	messageID string,
 	keygenCommittee []string,
 	attempt int) (string, string, error) {
 	t.logger.WithFields(logrus.Fields{
@@ -215,15 +200,15 @@ func (t *DKLSTssService) migrate(
 		"keygen_committee": keygenCommittee,
 		"publicKey":        publicKey,
 		"hexChainCode":     hexChainCode,
+		"is_eddsa":         isEdDSA,
 		"attempt":          attempt,
 	}).Info("migrate")
-	t.isKeygenFinished.Store(false)
 	relayClient := relay.NewRelayClient(t.cfg.Relay.Server)
 	mpcKeygenWrapper := t.GetMPCKeygenWrapper(isEdDSA)
 	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
 	defer cancel()
 	// retrieve the setup Message
-	encryptedEncodedSetupMsg, err := relayClient.WaitForSetupMessage(ctx, sessionID, "")
+	encryptedEncodedSetupMsg, err := relayClient.WaitForSetupMessage(ctx, sessionID, messageID)
 	if err != nil {
 		return "", "", fmt.Errorf("failed to get setup message: %w", err)
 	}
@@ -239,7 +224,8 @@ func (t *DKLSTssService) migrate(
 	if err != nil {
 		return "", "", fmt.Errorf("failed to decode chain code")
 	}
-	localUIBytes, err := hex.DecodeString(localUI)
+	// the local UI is the secret share of the GG20 keyshare
+	localUISecret, err := common.DecodeHexSecret(localUI)
 	if err != nil {
 		return "", "", fmt.Errorf("failed to decode local UI")
 	}
@@ -247,7 +233,8 @@ func (t *DKLSTssService) migrate(
 		[]byte(localPartyID),
 		publicKeyBytes,
 		chainCodeBytes,
-		localUIBytes)
+		localUISecret.Bytes())
+	localUISecret.Wipe()
 	if err != nil {
 		return "", "", fmt.Errorf("failed to create session from setup message: %w", err)
 	}
@@ -256,14 +243,5 @@ func (t *DKLSTssService) migrate(
 			t.logger.Error("failed to free keygen session", "error", err)
 		}
 	}()
-	wg := &sync.WaitGroup{}
-	wg.Add(2)
-	go func() {
-		if err := t.processKeygenOutbound(handle, sessionID, hexEncryptionKey, keygenCommittee, localPartyID, isEdDSA, wg); err != nil {
-			t.logger.Error("failed to process keygen outbound", "error", err)
-		}
-	}()
-	publicKey, chainCode, err := t.processKeygenInbound(handle, sessionID, hexEncryptionKey, isEdDSA, localPartyID, wg)
-	wg.Wait()
-	return publicKey, chainCode, err
+	return t.runKeygenSession(handle, sessionID, hexEncryptionKey, keygenCommittee, localPartyID, isEdDSA, messageID)
 }
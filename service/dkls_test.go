@@ -0,0 +1,73 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/vultisig/vultisigner/config"
+)
+
+func newCeremonyTestService(t *testing.T, concurrent bool) *DKLSTssService {
+	relayServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("message-id") == dklsEdDSAMessageID && !concurrent {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte("setup"))
+	}))
+	t.Cleanup(relayServer.Close)
+	var cfg config.Config
+	cfg.Relay.Server = relayServer.URL
+	return &DKLSTssService{cfg: cfg, logger: logrus.StandardLogger()}
+}
+
+func TestRunKeyPairCeremoniesConcurrent(t *testing.T) {
+	s := newCeremonyTestService(t, true)
+	var running sync.WaitGroup
+	running.Add(2)
+	ecdsa, eddsa := s.runKeyPairCeremonies("session", func(isEdDSA bool, messageID string) (string, string, error) {
+		if isEdDSA != (messageID == dklsEdDSAMessageID) {
+			t.Errorf("EdDSA %v runs with message id %q", isEdDSA, messageID)
+		}
+		// both ceremonies must be running at the same time to get here
+		running.Done()
+		running.Wait()
+		if isEdDSA {
+			return "eddsa-key", "", nil
+		}
+		return "ecdsa-key", "chain-code", nil
+	})
+	if ecdsa.err != nil || eddsa.err != nil {
+		t.Fatalf("ecdsa: %v, eddsa: %v", ecdsa.err, eddsa.err)
+	}
+	if ecdsa.publicKey != "ecdsa-key" || ecdsa.chainCode != "chain-code" || eddsa.publicKey != "eddsa-key" {
+		t.Fatalf("unexpected results: %+v %+v", ecdsa, eddsa)
+	}
+}
+
+func TestRunKeyPairCeremoniesSequential(t *testing.T) {
+	s := newCeremonyTestService(t, false)
+	var ecdsaDone atomic.Bool
+	_, eddsa := s.runKeyPairCeremonies("session", func(isEdDSA bool, messageID string) (string, string, error) {
+		if messageID != "" {
+			t.Errorf("sequential mode runs with message id %q", messageID)
+		}
+		if isEdDSA && !ecdsaDone.Load() {
+			t.Error("EdDSA started before ECDSA finished")
+		}
+		if !isEdDSA {
+			time.Sleep(600 * time.Millisecond)
+			ecdsaDone.Store(true)
+		}
+		return "key", "", nil
+	})
+	if eddsa.err != nil {
+		t.Fatal(eddsa.err)
+	}
+}
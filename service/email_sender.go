@@ -0,0 +1,180 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/vultisig/vultisigner/config"
+)
+
+// EmailKind selects the template of an email
+type EmailKind string
+
+const (
+	EmailBackup          EmailKind = "backup"
+	EmailBackupLink      EmailKind = "backup_link"
+	EmailBackupPGP       EmailKind = "backup_pgp"
+	EmailVerification    EmailKind = "verification"
+	EmailAlert           EmailKind = "alert"
+	EmailRefreshReminder EmailKind = "refresh_reminder"
+)
+
+// ErrEmailPermanent marks a delivery failure that won't succeed on retry , like a rejected recipient or a broken template
+var ErrEmailPermanent = errors.New("permanent email delivery failure")
+
+// EmailAttachment is a file attached to an email
+type EmailAttachment struct {
+	Name        string
+	ContentType string
+	Content     []byte
+}
+
+// EmailMessage is an email to one recipient , the merge variables fill the template of its kind
+type EmailMessage struct {
+	Kind        EmailKind
+	To          string
+	Vars        map[string]string
+	Attachments []EmailAttachment
+}
+
+// EmailSender delivers emails , errors wrapping ErrEmailPermanent must not be retried
+type EmailSender interface {
+	Send(ctx context.Context, msg EmailMessage) error
+}
+
+// NewEmailSender returns the email backend selected by the config
+func NewEmailSender(cfg config.Config) (EmailSender, error) {
+	templates := emailTemplates(cfg)
+	switch cfg.EmailServer.Backend {
+	case "", "mandrill":
+		return NewMandrillSender(cfg, templates), nil
+	case "smtp":
+		return NewSMTPSender(cfg, templates)
+	case "sink":
+		return NewSinkSender(cfg, templates)
+	default:
+		return nil, fmt.Errorf("unknown email backend: %s", cfg.EmailServer.Backend)
+	}
+}
+
+func emailTemplates(cfg config.Config) map[EmailKind]config.EmailTemplate {
+	return map[EmailKind]config.EmailTemplate{
+		EmailBackup:          cfg.EmailServer.Templates.Backup,
+		EmailBackupLink:      cfg.EmailServer.Templates.BackupLink,
+		EmailBackupPGP:       cfg.EmailServer.Templates.BackupPGP,
+		EmailVerification:    cfg.EmailServer.Templates.Verification,
+		EmailAlert:           cfg.EmailServer.Templates.Alert,
+		EmailRefreshReminder: cfg.EmailServer.Templates.RefreshReminder,
+	}
+}
+
+func lookupTemplate(templates map[EmailKind]config.EmailTemplate, kind EmailKind) (config.EmailTemplate, error) {
+	tmpl, ok := templates[kind]
+	if !ok {
+		return config.EmailTemplate{}, fmt.Errorf("no template for %s email: %w", kind, ErrEmailPermanent)
+	}
+	return tmpl, nil
+}
+
+// sortedVars returns the merge variable names in a stable order
+func sortedVars(vars map[string]string) []string {
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func renderText(text string, vars map[string]string) (string, error) {
+	tmpl, err := template.New("email").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template: %v: %w", err, ErrEmailPermanent)
+	}
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return "", fmt.Errorf("failed to render template: %v: %w", err, ErrEmailPermanent)
+	}
+	return buf.String(), nil
+}
+
+// buildMIMEMessage renders the template of the email and returns it as RFC 5322 message ,
+// a plain text body followed by the base64 encoded attachments
+func buildMIMEMessage(from string, tmpl config.EmailTemplate, msg EmailMessage) ([]byte, error) {
+	subject, err := renderText(tmpl.Subject, msg.Vars)
+	if err != nil {
+		return nil, err
+	}
+	body, err := renderText(tmpl.Body, msg.Vars)
+	if err != nil {
+		return nil, err
+	}
+	messageID := make([]byte, 16)
+	if _, err := rand.Read(messageID); err != nil {
+		return nil, fmt.Errorf("failed to create message id: %w", err)
+	}
+	domain := "localhost"
+	if idx := strings.LastIndex(from, "@"); idx >= 0 {
+		domain = strings.TrimRight(from[idx+1:], ">")
+	}
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%s@%s>\r\n", hex.EncodeToString(messageID), domain)
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", writer.Boundary())
+
+	part, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"8bit"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create body part: %w", err)
+	}
+	if _, err := part.Write([]byte(strings.ReplaceAll(body, "\n", "\r\n"))); err != nil {
+		return nil, fmt.Errorf("failed to write body part: %w", err)
+	}
+	for _, attachment := range msg.Attachments {
+		contentType := attachment.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		part, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {contentType},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Name})},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create attachment part: %w", err)
+		}
+		encoded := base64.StdEncoding.EncodeToString(attachment.Content)
+		for len(encoded) > 76 {
+			if _, err := part.Write([]byte(encoded[:76] + "\r\n")); err != nil {
+				return nil, fmt.Errorf("failed to write attachment part: %w", err)
+			}
+			encoded = encoded[76:]
+		}
+		if _, err := part.Write([]byte(encoded + "\r\n")); err != nil {
+			return nil, fmt.Errorf("failed to write attachment part: %w", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close multipart writer: %w", err)
+	}
+	return buf.Bytes(), nil
+}
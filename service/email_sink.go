@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/vultisig/vultisigner/config"
+)
+
+// SinkSender writes emails to the local disk instead of sending them , for development and tests.
+// The file format writes one .eml file per email , the maildir format delivers to the new folder of a maildir.
+type SinkSender struct {
+	from      string
+	path      string
+	maildir   bool
+	templates map[EmailKind]config.EmailTemplate
+}
+
+// NewSinkSender creates a local email sink
+func NewSinkSender(cfg config.Config, templates map[EmailKind]config.EmailTemplate) (*SinkSender, error) {
+	sinkCfg := cfg.EmailServer.Sink
+	if sinkCfg.Path == "" {
+		return nil, fmt.Errorf("email sink path is required")
+	}
+	sender := &SinkSender{
+		from:      cfg.EmailServer.From,
+		path:      sinkCfg.Path,
+		templates: templates,
+	}
+	switch sinkCfg.Format {
+	case "", "file":
+		if err := os.MkdirAll(sinkCfg.Path, 0700); err != nil {
+			return nil, fmt.Errorf("failed to create email sink directory: %w", err)
+		}
+	case "maildir":
+		sender.maildir = true
+		for _, dir := range []string{"tmp", "new", "cur"} {
+			if err := os.MkdirAll(filepath.Join(sinkCfg.Path, dir), 0700); err != nil {
+				return nil, fmt.Errorf("failed to create maildir: %w", err)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("invalid email sink format: %s", sinkCfg.Format)
+	}
+	return sender, nil
+}
+
+func (s *SinkSender) Send(ctx context.Context, msg EmailMessage) error {
+	tmpl, err := lookupTemplate(s.templates, msg.Kind)
+	if err != nil {
+		return err
+	}
+	data, err := buildMIMEMessage(s.from, tmpl, msg)
+	if err != nil {
+		return err
+	}
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return fmt.Errorf("rand.Read failed: %w", err)
+	}
+	name := fmt.Sprintf("%d.%s.%s", time.Now().UnixNano(), hex.EncodeToString(suffix), msg.Kind)
+	if !s.maildir {
+		recipient := strings.NewReplacer("/", "_", string(os.PathSeparator), "_").Replace(msg.To)
+		return os.WriteFile(filepath.Join(s.path, name+"."+recipient+".eml"), data, 0600)
+	}
+	// maildir delivery writes to tmp , then moves the complete file to new
+	tmpPath := filepath.Join(s.path, "tmp", name)
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write email: %w", err)
+	}
+	if err := os.Rename(tmpPath, filepath.Join(s.path, "new", name)); err != nil {
+		return fmt.Errorf("failed to deliver email: %w", err)
+	}
+	return nil
+}
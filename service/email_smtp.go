@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"time"
+
+	"github.com/vultisig/vultisigner/config"
+)
+
+// SMTPSender sends emails through an smtp server , with STARTTLS or implicit TLS
+type SMTPSender struct {
+	from      string
+	fromAddr  string
+	host      string
+	port      int
+	username  string
+	password  string
+	tlsMode   string
+	timeout   time.Duration
+	templates map[EmailKind]config.EmailTemplate
+}
+
+// NewSMTPSender creates an smtp email backend
+func NewSMTPSender(cfg config.Config, templates map[EmailKind]config.EmailTemplate) (*SMTPSender, error) {
+	smtpCfg := cfg.EmailServer.SMTP
+	if smtpCfg.Host == "" {
+		return nil, errors.New("smtp host is required")
+	}
+	switch smtpCfg.TLS {
+	case "starttls", "implicit", "none":
+	default:
+		return nil, fmt.Errorf("invalid smtp tls mode: %s", smtpCfg.TLS)
+	}
+	from, err := mail.ParseAddress(cfg.EmailServer.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid from address: %w", err)
+	}
+	return &SMTPSender{
+		from:      cfg.EmailServer.From,
+		fromAddr:  from.Address,
+		host:      smtpCfg.Host,
+		port:      smtpCfg.Port,
+		username:  smtpCfg.Username,
+		password:  smtpCfg.Password,
+		tlsMode:   smtpCfg.TLS,
+		timeout:   time.Duration(smtpCfg.Timeout) * time.Second,
+		templates: templates,
+	}, nil
+}
+
+func (s *SMTPSender) Send(ctx context.Context, msg EmailMessage) error {
+	tmpl, err := lookupTemplate(s.templates, msg.Kind)
+	if err != nil {
+		return err
+	}
+	data, err := buildMIMEMessage(s.from, tmpl, msg)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	if err := s.send(ctx, msg.To, data); err != nil {
+		return classifySMTPError(err)
+	}
+	return nil
+}
+
+func (s *SMTPSender) send(ctx context.Context, to string, data []byte) error {
+	addr := net.JoinHostPort(s.host, strconv.Itoa(s.port))
+	tlsConfig := &tls.Config{ServerName: s.host, MinVersion: tls.VersionTLS12}
+	var conn net.Conn
+	var err error
+	if s.tlsMode == "implicit" {
+		dialer := &tls.Dialer{Config: tlsConfig}
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	} else {
+		var dialer net.Dialer
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to smtp server: %w", err)
+	}
+	// the deadline bounds the whole smtp conversation
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			_ = conn.Close()
+			return fmt.Errorf("failed to set deadline: %w", err)
+		}
+	}
+	client, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("smtp.NewClient failed: %w", err)
+	}
+	defer func() {
+		_ = client.Close()
+	}()
+	if s.tlsMode == "starttls" {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("smtp server doesn't support STARTTLS: %w", ErrEmailPermanent)
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("STARTTLS failed: %w", err)
+		}
+	}
+	if s.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.username, s.password, s.host)); err != nil {
+			return fmt.Errorf("smtp auth failed: %w", err)
+		}
+	}
+	if err := client.Mail(s.fromAddr); err != nil {
+		return fmt.Errorf("smtp MAIL failed: %w", err)
+	}
+	if err := client.Rcpt(to); err != nil {
+		return fmt.Errorf("smtp RCPT failed: %w", err)
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("smtp DATA failed: %w", err)
+	}
+	if _, err := writer.Write(data); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	return client.Quit()
+}
+
+// classifySMTPError marks 5xx smtp replies as permanent , connection failures and 4xx replies can be retried
+func classifySMTPError(err error) error {
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) && protoErr.Code >= 500 {
+		return fmt.Errorf("%v: %w", err, ErrEmailPermanent)
+	}
+	return err
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vultisig/vultisigner/config"
+)
+
+func testEmailConfig() config.Config {
+	var cfg config.Config
+	cfg.EmailServer.From = "VultiServer <no-reply@vultisig.com>"
+	cfg.EmailServer.Templates.Backup = config.EmailTemplate{
+		MandrillTemplate: "fastvault",
+		Subject:          "Vault share of {{.VAULT_NAME}}",
+		Body:             "Verification code: {{.VERIFICATION_CODE}}\n",
+	}
+	return cfg
+}
+
+func TestSinkSender(t *testing.T) {
+	cfg := testEmailConfig()
+	cfg.EmailServer.Sink.Path = t.TempDir()
+	cfg.EmailServer.Sink.Format = "maildir"
+	sender, err := NewSinkSender(cfg, emailTemplates(cfg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := EmailMessage{
+		Kind: EmailBackup,
+		To:   "user@example.com",
+		Vars: map[string]string{"VAULT_NAME": "test", "VERIFICATION_CODE": "1234"},
+		Attachments: []EmailAttachment{
+			{Name: "test.vult", Content: []byte("vault content")},
+		},
+	}
+	if err := sender.Send(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	files, err := os.ReadDir(filepath.Join(cfg.EmailServer.Sink.Path, "new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("%d emails delivered, expected: 1", len(files))
+	}
+	f, err := os.Open(filepath.Join(cfg.EmailServer.Sink.Path, "new", files[0].Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	email, err := mail.ReadMessage(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if email.Header.Get("Subject") != "Vault share of test" {
+		t.Fatalf("subject: %s", email.Header.Get("Subject"))
+	}
+	_, params, err := mime.ParseMediaType(email.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := multipart.NewReader(email.Body, params["boundary"])
+	body, err := reader.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := io.ReadAll(body); !strings.Contains(string(content), "Verification code: 1234") {
+		t.Fatalf("body: %s", content)
+	}
+	attachment, err := reader.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attachment.FileName() != "test.vult" {
+		t.Fatalf("attachment name: %s", attachment.FileName())
+	}
+
+	// a missing merge variable can't be fixed by a retry
+	delete(msg.Vars, "VERIFICATION_CODE")
+	if err := sender.Send(context.Background(), msg); !errors.Is(err, ErrEmailPermanent) {
+		t.Fatalf("expected permanent error, got: %v", err)
+	}
+}
+
+func TestMandrillSenderRetryClassification(t *testing.T) {
+	status := http.StatusOK
+	response := `[{"email":"user@example.com","status":"sent"}]`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(response))
+	}))
+	defer server.Close()
+	cfg := testEmailConfig()
+	cfg.EmailServer.MandrillURL = server.URL
+	cfg.EmailServer.Timeout = 5
+	sender := NewMandrillSender(cfg, emailTemplates(cfg))
+	msg := EmailMessage{Kind: EmailBackup, To: "user@example.com"}
+
+	if err := sender.Send(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	status = http.StatusInternalServerError
+	if err := sender.Send(context.Background(), msg); err == nil || errors.Is(err, ErrEmailPermanent) {
+		t.Fatalf("expected retryable error, got: %v", err)
+	}
+	status = http.StatusBadRequest
+	if err := sender.Send(context.Background(), msg); !errors.Is(err, ErrEmailPermanent) {
+		t.Fatalf("expected permanent error, got: %v", err)
+	}
+	status = http.StatusOK
+	response = `[{"email":"user@example.com","status":"rejected","reject_reason":"hard-bounce"}]`
+	if err := sender.Send(context.Background(), msg); !errors.Is(err, ErrEmailPermanent) {
+		t.Fatalf("expected permanent error, got: %v", err)
+	}
+}
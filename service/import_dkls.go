@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/vultisig/vultisigner/common"
+	"github.com/vultisig/vultisigner/internal/types"
+	"github.com/vultisig/vultisigner/relay"
+)
+
+// ProcessKeyImport joins a key import session , the importing device splits its private key and uploads the
+// secret coefficient of this server as setup message `import-ecdsa` ( and `import-eddsa` ) , then every party runs
+// the migrate protocol with its coefficient. The resulting public keys must match the imported keys ,
+// and the request is only valid when the declared address belongs to them.
+func (t *DKLSTssService) ProcessKeyImport(req types.KeyImportRequest) (string, string, error) {
+	serverURL := t.cfg.Relay.Server
+	relayClient := relay.NewRelayClient(serverURL)
+
+	// Let's register session here
+	if err := relayClient.RegisterSession(req.SessionID, req.LocalPartyId); err != nil {
+		return "", "", fmt.Errorf("failed to register session: %w", err)
+	}
+	if err := t.startPairwiseEncryption(relayClient, req.SessionID, req.LocalPartyId, req.HexEncryptionKey); err != nil {
+		return "", "", err
+	}
+	partiesJoined, err := waitForSession(t.logger, relayClient, req.SessionID, 5*time.Minute)
+	if err != nil {
+		return "", "", err
+	}
+	threshold, err := common.ResolveThreshold(req.Threshold, len(partiesJoined))
+	if err != nil {
+		return "", "", fmt.Errorf("invalid threshold: %w", err)
+	}
+	req.Threshold = threshold
+	if err := t.exchangePairwiseKeys(relayClient, req.SessionID, partiesJoined, req.HexEncryptionKey, ""); err != nil {
+		return "", "", err
+	}
+
+	publicKeyECDSA, err := t.importKey(relayClient, req, req.PublicKeyEcdsa, false, partiesJoined)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to import ECDSA key: %w", err)
+	}
+	time.Sleep(500 * time.Millisecond)
+	var publicKeyEdDSA string
+	if req.PublicKeyEddsa != "" {
+		publicKeyEdDSA, err = t.importKey(relayClient, req, req.PublicKeyEddsa, true, partiesJoined)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to import EdDSA key: %w", err)
+		}
+	} else {
+		// single-signer wallets usually have no EdDSA key , create a new one
+		publicKeyEdDSA, _, err = t.keygenWithRetry(req.SessionID, req.HexEncryptionKey, req.LocalPartyId, true, "", partiesJoined, threshold)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to keygen EdDSA: %w", err)
+		}
+	}
+	if err := t.pinPartyIdentities(publicKeyECDSA); err != nil {
+		return "", "", fmt.Errorf("failed to pin party identities: %w", err)
+	}
+
+	if !finishSession(t.logger, relayClient, req.SessionID, req.LocalPartyId, partiesJoined) {
+		return "", "", fmt.Errorf("not every party completed the key import")
+	}
+	if t.backup == nil {
+		return publicKeyECDSA, publicKeyEdDSA, nil
+	}
+
+	if err := t.backup.BackupVault(req.VaultCreateRequest(), partiesJoined, publicKeyECDSA, publicKeyEdDSA, req.HexChainCode, t.localStateAccessor); err != nil {
+		return "", "", fmt.Errorf("failed to backup vault: %w", err)
+	}
+	return publicKeyECDSA, publicKeyEdDSA, nil
+}
+
+// importKey runs the migrate protocol with the secret coefficient uploaded by the importing device ,
+// and checks the resulting public key and chain code are the imported ones
+func (t *DKLSTssService) importKey(relayClient *relay.Client,
+	req types.KeyImportRequest,
+	publicKey string,
+	isEdDSA bool,
+	partiesJoined []string) (string, error) {
+	messageID := "import-ecdsa"
+	if isEdDSA {
+		messageID = "import-eddsa"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	encryptedSecret, err := relayClient.WaitForSetupMessage(ctx, req.SessionID, messageID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get secret coefficient: %w", err)
+	}
+	secret, err := t.decodeDecryptMessage(encryptedSecret, req.HexEncryptionKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode secret coefficient: %w", err)
+	}
+	defer common.Wipe(secret)
+	if len(secret) != 32 {
+		return "", fmt.Errorf("invalid secret coefficient length: %d", len(secret))
+	}
+	resultPublicKey, chainCode, err := t.migrateWithRetry(publicKey,
+		req.HexChainCode,
+		hex.EncodeToString(secret),
+		req.SessionID,
+		req.HexEncryptionKey,
+		req.LocalPartyId,
+		isEdDSA,
+		"",
+		partiesJoined)
+	if err != nil {
+		return "", err
+	}
+	if !strings.EqualFold(resultPublicKey, publicKey) {
+		return "", fmt.Errorf("imported public key %s doesn't match the declared public key %s", resultPublicKey, publicKey)
+	}
+	if !isEdDSA && !strings.EqualFold(chainCode, req.HexChainCode) {
+		return "", fmt.Errorf("imported chain code doesn't match the declared chain code")
+	}
+	return resultPublicKey, nil
+}
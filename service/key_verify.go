@@ -0,0 +1,62 @@
+package service
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	keygenType "github.com/vultisig/commondata/go/vultisig/keygen/v1"
+	vaultType "github.com/vultisig/commondata/go/vultisig/vault/v1"
+	"github.com/vultisig/mobile-tss-lib/tss"
+
+	"github.com/vultisig/vultisigner/common"
+)
+
+// VerifyVaultKeyshares makes sure every keyshare of the vault decodes , and belongs to the public key it is saved under
+func VerifyVaultKeyshares(vault *vaultType.Vault) error {
+	if len(vault.KeyShares) == 0 {
+		return fmt.Errorf("vault has no keyshare")
+	}
+	for _, item := range vault.KeyShares {
+		if item.PublicKey != vault.PublicKeyEcdsa && item.PublicKey != vault.PublicKeyEddsa {
+			return fmt.Errorf("keyshare %s doesn't belong to the vault", item.PublicKey)
+		}
+		publicKey, err := keysharePublicKey(vault.LibType, item.Keyshare, item.PublicKey == vault.PublicKeyEddsa)
+		if err != nil {
+			return fmt.Errorf("keyshare %s: %w", item.PublicKey, err)
+		}
+		if !strings.EqualFold(publicKey, item.PublicKey) {
+			return fmt.Errorf("keyshare %s has public key %s", item.PublicKey, publicKey)
+		}
+	}
+	return nil
+}
+
+func keysharePublicKey(libType keygenType.LibType, keyshare string, isEdDSA bool) (string, error) {
+	if libType != keygenType.LibType_LIB_TYPE_DKLS {
+		var localState tss.LocalState
+		if err := json.Unmarshal([]byte(keyshare), &localState); err != nil {
+			return "", fmt.Errorf("failed to unmarshal local state: %w", err)
+		}
+		return localState.PubKey, nil
+	}
+	keyshareBytes, err := common.DecodeBase64Secret(keyshare)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode keyshare: %w", err)
+	}
+	mpcWrapper := NewMPCWrapperImp(isEdDSA)
+	handle, err := mpcWrapper.KeyshareFromBytes(keyshareBytes.Bytes())
+	keyshareBytes.Wipe()
+	if err != nil {
+		return "", fmt.Errorf("failed to create keyshare from bytes: %w", err)
+	}
+	defer func() {
+		_ = mpcWrapper.KeyshareFree(handle)
+	}()
+	publicKey, err := mpcWrapper.KeysharePublicKey(handle)
+	if err != nil {
+		return "", fmt.Errorf("failed to get keyshare public key: %w", err)
+	}
+	return hex.EncodeToString(publicKey), nil
+}
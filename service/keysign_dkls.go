@@ -12,6 +12,7 @@ import (
 	"math/big"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/decred/dcrd/dcrec/secp256k1/v4"
@@ -23,56 +24,165 @@ import (
 	"github.com/vultisig/vultisigner/relay"
 )
 
-func (t *DKLSTssService) ProcessDKLSKeysign(req types.KeysignRequest) (map[string]tss.KeysignResponse, error) {
-	result := map[string]tss.KeysignResponse{}
+// ProcessDKLSKeysign signs the messages of the request , and returns the party ids that joined the keysign
+func (t *DKLSTssService) ProcessDKLSKeysign(req types.KeysignRequest) (*types.KeysignResponse, []string, error) {
 	keyFolder := t.cfg.Server.VaultsFilePath
-	localStateAccessor, err := relay.NewLocalStateAccessorImp(keyFolder, req.PublicKey, req.VaultPassword, t.blockStorage)
+	vault, err := t.vaultCache.LoadVault(context.Background(), req.VaultFile(), req.VaultPassword)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create localStateAccessor: %w", err)
+		return nil, nil, fmt.Errorf("failed to load vault: %w", err)
+	}
+	localStateAccessor, err := relay.NewLocalStateAccessorWithVault(keyFolder, vault, t.blockStorage)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create localStateAccessor: %w", err)
 	}
 	t.localStateAccessor = localStateAccessor
 	localPartyID := localStateAccessor.Vault.LocalPartyId
 	relayClient := relay.NewRelayClient(t.cfg.Relay.Server)
 	if err := relayClient.RegisterSession(req.SessionID, localPartyID); err != nil {
-		return nil, fmt.Errorf("failed to start session: %w", err)
+		return nil, nil, fmt.Errorf("failed to start session: %w", err)
 	}
-	// wait longer for keysign start
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute+3*time.Second)
-	defer cancel()
-
-	partiesJoined, err := relayClient.WaitForSessionStart(ctx, req.SessionID)
-	t.logger.WithFields(logrus.Fields{
-		"session":        req.SessionID,
-		"parties_joined": partiesJoined,
-	}).Info("Session started")
-
+	if err := t.startPairwiseEncryption(relayClient, req.SessionID, localPartyID, req.HexEncryptionKey); err != nil {
+		return nil, nil, err
+	}
+	partiesJoined, err := waitForSession(t.logger, relayClient, req.SessionID, 3*time.Minute+3*time.Second)
 	if err != nil {
-		return nil, fmt.Errorf("failed to wait for session start: %w", err)
+		return nil, nil, err
+	}
+	if err := checkSigningThreshold(t.blockStorage, localStateAccessor.Vault, partiesJoined); err != nil {
+		return nil, nil, err
+	}
+	if err := t.exchangePairwiseKeys(relayClient, req.SessionID, partiesJoined, req.HexEncryptionKey, localStateAccessor.Vault.PublicKeyEcdsa); err != nil {
+		return nil, nil, err
 	}
 	publicKey := req.PublicKey
 	if !req.IsECDSA {
 		publicKey = localStateAccessor.Vault.PublicKeyEddsa
 	}
 	// start to do keysign
-	for _, msg := range req.Messages {
-		sig, err := t.keysignWithRetry(req.SessionID, req.HexEncryptionKey, publicKey, !req.IsECDSA, msg, req.DerivePath, localPartyID, partiesJoined)
-		if err != nil {
-			return result, fmt.Errorf("failed to keysign: %w", err)
-		}
-		if sig == nil {
-			return result, fmt.Errorf("failed to keysign: signature is nil")
-		}
-		result[msg] = *sig
-	}
+	result := t.keysignMessages(req, publicKey, localPartyID, partiesJoined)
 	if err := relayClient.CompleteSession(req.SessionID, localPartyID); err != nil {
 		t.logger.WithFields(logrus.Fields{
 			"session": req.SessionID,
 			"error":   err,
 		}).Error("Failed to complete session")
 	}
+	if result.Succeeded == 0 {
+		return result, partiesJoined, fmt.Errorf("failed to keysign all %d messages", len(req.Messages))
+	}
+	return result, partiesJoined, nil
+}
+
+// keysignMessages signs every unique message in its own session, at most KeysignParallelism at a time.
+func (t *DKLSTssService) keysignMessages(req types.KeysignRequest,
+	publicKey string,
+	localPartyID string,
+	partiesJoined []string) *types.KeysignResponse {
+	return signMessages(req, t.cfg.Keysign.Parallelism, func(msg string) (*tss.KeysignResponse, string, error) {
+		return t.keysignWithRetry(req.SessionID, req.HexEncryptionKey, publicKey, !req.IsECDSA, msg, req.DerivePath, localPartyID, partiesJoined)
+	})
+}
 
-	return result, nil
+// signMessages signs every unique message of the request with sign , at most parallelism at a time.
+// Results keep the order of req.Messages , duplicated messages are signed once and share the signature.
+func signMessages(req types.KeysignRequest, parallelism int, sign func(msg string) (*tss.KeysignResponse, string, error)) *types.KeysignResponse {
+	results := make([]types.KeysignMessageResult, len(req.Messages))
+	messageIndexes := make(map[string][]int)
+	uniqueMessages := make([]string, 0, len(req.Messages))
+	for idx, msg := range req.Messages {
+		if _, ok := messageIndexes[msg]; !ok {
+			uniqueMessages = append(uniqueMessages, msg)
+		}
+		messageIndexes[msg] = append(messageIndexes[msg], idx)
+	}
+
+	if parallelism <= 0 {
+		parallelism = 1
+	}
+	semaphore := make(chan struct{}, parallelism)
+	wg := &sync.WaitGroup{}
+	for _, msg := range uniqueMessages {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(msg string) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			sig, childPublicKey, err := sign(msg)
+			if err == nil && sig == nil {
+				err = fmt.Errorf("signature is nil")
+			}
+			var formatted string
+			if err == nil {
+				formatted, err = formatSignature(req, sig)
+			}
+			for _, idx := range messageIndexes[msg] {
+				results[idx] = types.KeysignMessageResult{
+					Message:            msg,
+					Signature:          sig,
+					FormattedSignature: formatted,
+					PublicKey:          childPublicKey,
+				}
+				if err != nil {
+					results[idx].Signature = nil
+					results[idx].FormattedSignature = ""
+					results[idx].Error = err.Error()
+				}
+			}
+		}(msg)
+	}
+	wg.Wait()
+
+	response := &types.KeysignResponse{
+		Results: results,
+	}
+	for _, item := range results {
+		if item.Error == "" {
+			response.Succeeded++
+		} else {
+			response.Failed++
+		}
+	}
+	return response
+}
+
+// formatSignature encodes the signature in the format requested by the keysign request , it returns empty when no format is requested
+func formatSignature(req types.KeysignRequest, sig *tss.KeysignResponse) (string, error) {
+	if req.SignatureFormat == "" {
+		return "", nil
+	}
+	r, err := hex.DecodeString(sig.R)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode r: %w", err)
+	}
+	s, err := hex.DecodeString(sig.S)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode s: %w", err)
+	}
+	switch req.SignatureFormat {
+	case types.SignatureFormatCompact, types.SignatureFormatEd25519:
+		return hex.EncodeToString(append(r, s...)), nil
+	case types.SignatureFormatEthereum:
+		recoveryID, err := hex.DecodeString(sig.RecoveryID)
+		if err != nil || len(recoveryID) != 1 {
+			return "", fmt.Errorf("invalid recovery id: %s", sig.RecoveryID)
+		}
+		s, recovery := common.NormalizeLowS(s, recoveryID[0])
+		return hex.EncodeToString(common.EthereumSignature(r, s, recovery, req.ChainID)), nil
+	case types.SignatureFormatBitcoin:
+		sigHashType := req.SigHashType
+		if sigHashType == 0 {
+			sigHashType = 0x01 // SIGHASH_ALL
+		}
+		s, _ = common.NormalizeLowS(s, 0)
+		result, err := common.BitcoinSignature(r, s, sigHashType)
+		if err != nil {
+			return "", fmt.Errorf("failed to get bitcoin signature: %w", err)
+		}
+		return hex.EncodeToString(result), nil
+	default:
+		return "", fmt.Errorf("invalid signature format: %s", req.SignatureFormat)
+	}
 }
+
 func (t *DKLSTssService) keysignWithRetry(sessionID string,
 	hexEncryptionKey string,
 	publicKey string,
@@ -80,9 +190,9 @@ func (t *DKLSTssService) keysignWithRetry(sessionID string,
 	message string,
 	derivePath string,
 	localPartyID string,
-	keysignCommittee []string) (*tss.KeysignResponse, error) {
+	keysignCommittee []string) (*tss.KeysignResponse, string, error) {
 	for i := 0; i < 3; i++ {
-		keysignResult, err := t.keysign(sessionID,
+		keysignResult, childPublicKey, err := t.keysign(sessionID,
 			hexEncryptionKey,
 			publicKey,
 			isEdDSA,
@@ -103,10 +213,10 @@ func (t *DKLSTssService) keysignWithRetry(sessionID string,
 			time.Sleep(50 * time.Millisecond)
 			continue
 		} else {
-			return keysignResult, nil
+			return keysignResult, childPublicKey, nil
 		}
 	}
-	return nil, fmt.Errorf("fail to keysign after max retry")
+	return nil, "", fmt.Errorf("fail to keysign after max retry")
 }
 
 func (t *DKLSTssService) keysign(sessionID string,
@@ -117,21 +227,21 @@ func (t *DKLSTssService) keysign(sessionID string,
 	derivePath string,
 	localPartyID string,
 	keysignCommittee []string,
-	attempt int) (*tss.KeysignResponse, error) {
+	attempt int) (*tss.KeysignResponse, string, error) {
 	if publicKey == "" {
-		return nil, fmt.Errorf("public key is empty")
+		return nil, "", fmt.Errorf("public key is empty")
 	}
 	if message == "" {
-		return nil, fmt.Errorf("message is empty")
+		return nil, "", fmt.Errorf("message is empty")
 	}
 	if derivePath == "" {
-		return nil, fmt.Errorf("derive path is empty")
+		return nil, "", fmt.Errorf("derive path is empty")
 	}
 	if localPartyID == "" {
-		return nil, fmt.Errorf("local party id is empty")
+		return nil, "", fmt.Errorf("local party id is empty")
 	}
 	if len(keysignCommittee) == 0 {
-		return nil, fmt.Errorf("keysign committee is empty")
+		return nil, "", fmt.Errorf("keysign committee is empty")
 	}
 
 	relayClient := relay.NewRelayClient(t.cfg.Relay.Server)
@@ -147,17 +257,14 @@ func (t *DKLSTssService) keysign(sessionID string,
 	}).Info("Keysign")
 
 	// we need to get the shares
-	keyshare, err := t.localStateAccessor.GetLocalState(publicKey)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get keyshare: %w", err)
-	}
-	keyshareBytes, err := base64.StdEncoding.DecodeString(keyshare)
+	keyshare, err := t.localStateAccessor.GetKeyshare(publicKey)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode keyshare: %w", err)
+		return nil, "", fmt.Errorf("failed to get keyshare: %w", err)
 	}
-	keyshareHandle, err := mpcWrapper.KeyshareFromBytes(keyshareBytes)
+	keyshareHandle, err := mpcWrapper.KeyshareFromBytes(keyshare.Bytes())
+	keyshare.Wipe()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create keyshare from bytes: %w", err)
+		return nil, "", fmt.Errorf("failed to create keyshare from bytes: %w", err)
 	}
 	defer func() {
 		if err := mpcWrapper.KeyshareFree(keyshareHandle); err != nil {
@@ -171,80 +278,94 @@ func (t *DKLSTssService) keysign(sessionID string,
 	// retrieve the setup Message
 	encryptedEncodedSetupMsg, err := relayClient.WaitForSetupMessage(ctx, sessionID, messageID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get setup message: %w", err)
+		return nil, "", fmt.Errorf("failed to get setup message: %w", err)
 	}
 
 	setupMessageBytes, err := t.decodeDecryptMessage(encryptedEncodedSetupMsg, hexEncryptionKey)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode setup message: %w", err)
+		return nil, "", fmt.Errorf("failed to decode setup message: %w", err)
 	}
 	messageHashInSetupMsg, err := mpcWrapper.DecodeMessage(setupMessageBytes)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode message: %w", err)
+		return nil, "", fmt.Errorf("failed to decode message: %w", err)
 	}
 	msgRawBytes, err := hex.DecodeString(message)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode message: %w", err)
+		return nil, "", fmt.Errorf("failed to decode message: %w", err)
 	}
 	if !bytes.Equal(messageHashInSetupMsg, msgRawBytes) {
-		return nil, fmt.Errorf("message hash in setup message is not equal to the message, stop keysign")
+		return nil, "", fmt.Errorf("message hash in setup message is not equal to the message, stop keysign")
 	}
+	publicKeyDerivePath := strings.Replace(derivePath, "'", "", -1)
 	sessionHandle, err := mpcWrapper.SignSessionFromSetup(setupMessageBytes, []byte(localPartyID), keyshareHandle)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create session from setup message: %w", err)
+		return nil, "", fmt.Errorf("failed to create session from setup message: %w", err)
 	}
 	defer func() {
 		if err := mpcWrapper.SignSessionFree(sessionHandle); err != nil {
 			t.logger.Error("failed to free keysign session", "error", err)
 		}
 	}()
+	isKeysignFinished := &atomic.Bool{}
 	wg := &sync.WaitGroup{}
 	wg.Add(2)
 	go func() {
-		if err := t.processKeysignOutbound(sessionHandle, sessionID, hexEncryptionKey, keysignCommittee, localPartyID, messageID, wg, isEdDSA); err != nil {
+		if err := t.processKeysignOutbound(sessionHandle, sessionID, hexEncryptionKey, keysignCommittee, localPartyID, messageID, isKeysignFinished, wg, isEdDSA); err != nil {
 			t.logger.Error("failed to process keygen outbound", "error", err)
 		}
 	}()
-	sig, err := t.processKeysignInbound(sessionHandle, sessionID, hexEncryptionKey, localPartyID, isEdDSA, messageID, wg)
+	sig, err := t.processKeysignInbound(sessionHandle, sessionID, hexEncryptionKey, localPartyID, isEdDSA, messageID, isKeysignFinished, wg)
 	wg.Wait()
-	t.logger.Infoln("Keysign result is:", len(sig))
-	rBytes := sig[:32]
-	sBytes := sig[32:64]
-	derBytes, err := common.GetDerSignature(rBytes, sBytes)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get der signature: %w", err)
+		return nil, "", fmt.Errorf("failed to process keysign inbound: %w", err)
 	}
-	resp := &tss.KeysignResponse{
-		Msg:          message,
-		R:            hex.EncodeToString(sig[:32]),
-		S:            hex.EncodeToString(sig[32:64]),
-		DerSignature: hex.EncodeToString(derBytes),
+	if len(sig) < 64 {
+		return nil, "", fmt.Errorf("signature length is too short: %d", len(sig))
 	}
+	t.logger.Infoln("Keysign result is:", len(sig))
+	rBytes := sig[:32]
+	sBytes := sig[32:64]
 	if isEdDSA {
 		pubKeyBytes, err := hex.DecodeString(publicKey)
 		if err != nil {
-			return nil, fmt.Errorf("failed to decode public key: %w", err)
+			return nil, "", fmt.Errorf("failed to decode public key: %w", err)
 		}
-
-		if ed25519.Verify(pubKeyBytes, msgRawBytes, sig) {
-			t.logger.Infoln("Signature is valid")
-		} else {
+		if !ed25519.Verify(pubKeyBytes, msgRawBytes, sig[:64]) {
+			if t.cfg.Keysign.StrictVerification {
+				return nil, "", fmt.Errorf("ed25519 signature is invalid")
+			}
 			t.logger.Error("Signature is invalid")
+		} else {
+			t.logger.Infoln("Signature is valid")
 		}
-	} else {
-		publicKeyDerivePath := strings.Replace(derivePath, "'", "", -1)
-		childPublicKey, err := mpcWrapper.KeyshareDeriveChildPublicKey(keyshareHandle, []byte(publicKeyDerivePath))
+		derBytes, err := common.GetDerSignature(rBytes, sBytes)
 		if err != nil {
-			return nil, fmt.Errorf("failed to derive child public key: %w", err)
+			return nil, "", fmt.Errorf("failed to get der signature: %w", err)
 		}
-		if len(sig) != 65 {
-			return nil, fmt.Errorf("signature length is not 64")
+		return &tss.KeysignResponse{
+			Msg:          message,
+			R:            hex.EncodeToString(rBytes),
+			S:            hex.EncodeToString(sBytes),
+			DerSignature: hex.EncodeToString(derBytes),
+		}, publicKey, nil
+	}
+	childPublicKey, err := mpcWrapper.KeyshareDeriveChildPublicKey(keyshareHandle, []byte(publicKeyDerivePath))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to derive child public key: %w", err)
+	}
+	if len(sig) != 65 {
+		return nil, "", fmt.Errorf("signature length is not 65")
+	}
+	recovery := sig[64]
+	if t.cfg.Keysign.StrictVerification {
+		sBytes, recovery = common.NormalizeLowS(sBytes, recovery)
+		if err := common.VerifyECDSA(childPublicKey, msgRawBytes, rBytes, sBytes, recovery); err != nil {
+			return nil, "", fmt.Errorf("failed to verify signature: %w", err)
 		}
-		recovery := sig[64]
-		resp.RecoveryID = hex.EncodeToString([]byte{recovery})
+	} else {
 		publicKeyECDSA, err := secp256k1.ParsePubKey(childPublicKey)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse public key: %w", err)
+			return nil, "", fmt.Errorf("failed to parse public key: %w", err)
 		}
 		if ecdsa.Verify(publicKeyECDSA.ToECDSA(), msgRawBytes, new(big.Int).SetBytes(rBytes), new(big.Int).SetBytes(sBytes)) {
 			t.logger.Infoln("Signature is valid")
@@ -252,17 +373,30 @@ func (t *DKLSTssService) keysign(sessionID string,
 			t.logger.Error("Signature is invalid")
 		}
 	}
-	return resp, nil
+	derBytes, err := common.GetDerSignature(rBytes, sBytes)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get der signature: %w", err)
+	}
+	return &tss.KeysignResponse{
+		Msg:          message,
+		R:            hex.EncodeToString(rBytes),
+		S:            hex.EncodeToString(sBytes),
+		DerSignature: hex.EncodeToString(derBytes),
+		RecoveryID:   hex.EncodeToString([]byte{recovery}),
+	}, hex.EncodeToString(childPublicKey), nil
 }
+
 func (t *DKLSTssService) processKeysignOutbound(handle Handle,
 	sessionID string,
 	hexEncryptionKey string,
 	parties []string,
 	localPartyID string,
 	messageID string,
+	isKeysignFinished *atomic.Bool,
 	wg *sync.WaitGroup, isEdDSA bool) error {
 	defer wg.Done()
 	messenger := relay.NewMessenger(t.cfg.Relay.Server, sessionID, hexEncryptionKey, true, messageID)
+	messenger.SetPairwiseKeys(t.pairwiseKeys)
 	mpcWrapper := t.GetMPCKeygenWrapper(isEdDSA)
 	for {
 		outbound, err := mpcWrapper.SignSessionOutputMessage(handle)
@@ -270,7 +404,7 @@ func (t *DKLSTssService) processKeysignOutbound(handle Handle,
 			t.logger.Error("failed to get output message", "error", err)
 		}
 		if len(outbound) == 0 {
-			if t.isKeysignFinished.Load() {
+			if isKeysignFinished.Load() {
 				// we are finished
 				return nil
 			}
@@ -278,6 +412,7 @@ func (t *DKLSTssService) processKeysignOutbound(handle Handle,
 			continue
 		}
 		encodedOutbound := base64.StdEncoding.EncodeToString(outbound)
+		receivers := make([]string, 0, len(parties))
 		for i := 0; i < len(parties); i++ {
 			receiver, err := mpcWrapper.SignSessionMessageReceiver(handle, outbound, i)
 			if err != nil {
@@ -286,13 +421,9 @@ func (t *DKLSTssService) processKeysignOutbound(handle Handle,
 			if len(receiver) == 0 {
 				continue
 			}
-
-			t.logger.Infoln("Sending message to", string(receiver))
-			// send the message to the receiver
-			if err := messenger.Send(localPartyID, string(receiver), encodedOutbound); err != nil {
-				t.logger.Errorf("failed to send message: %v", err)
-			}
+			receivers = append(receivers, string(receiver))
 		}
+		t.sendOutbound(messenger, localPartyID, receivers, encodedOutbound)
 	}
 }
 
@@ -302,6 +433,7 @@ func (t *DKLSTssService) processKeysignInbound(handle Handle,
 	localPartyID string,
 	isEdDSA bool,
 	messageID string,
+	isKeysignFinished *atomic.Bool,
 	wg *sync.WaitGroup) ([]byte, error) {
 	defer wg.Done()
 	var messageCache sync.Map
@@ -311,8 +443,12 @@ func (t *DKLSTssService) processKeysignInbound(handle Handle,
 	for {
 		select {
 		case <-time.After(time.Millisecond * 100):
+			if err := relay.SessionCancelled(sessionID); err != nil {
+				isKeysignFinished.Store(true)
+				return nil, err
+			}
 			if time.Since(start) > time.Minute {
-				t.isKeysignFinished.Store(true)
+				isKeysignFinished.Store(true)
 				return nil, TssKeyGenTimeout
 			}
 			messages, err := relayClient.DownloadMessages(sessionID, localPartyID, messageID)
@@ -333,7 +469,7 @@ func (t *DKLSTssService) processKeysignInbound(handle Handle,
 					continue
 				}
 
-				rawBody, err := t.decodeDecryptMessage(message.Body, hexEncryptionKey)
+				rawBody, err := t.decodeDecryptInboundMessage(message, hexEncryptionKey)
 				if err != nil {
 					t.logger.Error("fail to decode inbound message", "error", err)
 					continue
@@ -355,11 +491,12 @@ func (t *DKLSTssService) processKeysignInbound(handle Handle,
 					result, err := mpcWrapper.SignSessionFinish(handle)
 					if err != nil {
 						t.logger.Error("fail to finish keysign", "error", err)
+						isKeysignFinished.Store(true)
 						return nil, err
 					}
 					encodedKeysignResult := base64.StdEncoding.EncodeToString(result)
 					t.logger.Infof("Keysign result: %s", encodedKeysignResult)
-					t.isKeysignFinished.Store(true)
+					isKeysignFinished.Store(true)
 					return result, nil
 				}
 			}
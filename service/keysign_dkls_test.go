@@ -0,0 +1,44 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/vultisig/mobile-tss-lib/tss"
+
+	"github.com/vultisig/vultisigner/internal/types"
+)
+
+func TestSignMessagesKeepsOrder(t *testing.T) {
+	req := types.KeysignRequest{
+		Messages: []string{"aa", "bb", "cc", "dd", "bb"},
+	}
+	// later messages finish first , and cc fails
+	delays := map[string]time.Duration{"aa": 60 * time.Millisecond, "bb": 40 * time.Millisecond, "cc": 20 * time.Millisecond, "dd": 0}
+	result := signMessages(req, 4, func(msg string) (*tss.KeysignResponse, string, error) {
+		time.Sleep(delays[msg])
+		if msg == "cc" {
+			return nil, "", fmt.Errorf("keysign of %s failed", msg)
+		}
+		return &tss.KeysignResponse{Msg: msg, R: msg + "-r"}, "child-" + msg, nil
+	})
+	if result.Succeeded != 4 || result.Failed != 1 {
+		t.Fatalf("expected 4 succeeded and 1 failed , got %d and %d", result.Succeeded, result.Failed)
+	}
+	for idx, msg := range req.Messages {
+		item := result.Results[idx]
+		if item.Message != msg {
+			t.Fatalf("result %d is for message %s , expected %s", idx, item.Message, msg)
+		}
+		if msg == "cc" {
+			if item.Error != "keysign of cc failed" || item.Signature != nil {
+				t.Errorf("result %d: expected the failure of cc , got %+v", idx, item)
+			}
+			continue
+		}
+		if item.Error != "" || item.Signature == nil || item.Signature.R != msg+"-r" || item.PublicKey != "child-"+msg {
+			t.Errorf("result %d: unexpected result %+v", idx, item)
+		}
+	}
+}
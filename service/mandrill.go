@@ -1,5 +1,18 @@
 package service
 
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/vultisig/vultisigner/config"
+)
+
 type MandrillTo struct {
 	Email string `json:"email"`
 	Name  string `json:"name"`
@@ -32,3 +45,108 @@ type MandrillPayload struct {
 	TemplateContent []MandrilMergeVarContent `json:"template_content"`
 	Message         MandrillMessage          `json:"message"`
 }
+
+// MandrillRecipientStatus is one entry of the mandrill send-template response
+type MandrillRecipientStatus struct {
+	Email        string `json:"email"`
+	Status       string `json:"status"`
+	RejectReason string `json:"reject_reason"`
+}
+
+// MandrillSender sends emails through the mandrill send-template api , the template is rendered by mandrill
+type MandrillSender struct {
+	apiKey        string
+	url           string
+	sendingDomain string
+	templates     map[EmailKind]config.EmailTemplate
+	client        *http.Client
+}
+
+// NewMandrillSender creates a mandrill email backend
+func NewMandrillSender(cfg config.Config, templates map[EmailKind]config.EmailTemplate) *MandrillSender {
+	return &MandrillSender{
+		apiKey:        cfg.EmailServer.ApiKey,
+		url:           cfg.EmailServer.MandrillURL,
+		sendingDomain: cfg.EmailServer.SendingDomain,
+		templates:     templates,
+		client:        &http.Client{Timeout: time.Duration(cfg.EmailServer.Timeout) * time.Second},
+	}
+}
+
+func (m *MandrillSender) Send(ctx context.Context, msg EmailMessage) error {
+	tmpl, err := lookupTemplate(m.templates, msg.Kind)
+	if err != nil {
+		return err
+	}
+	vars := make([]MandrilMergeVarContent, 0, len(msg.Vars))
+	for _, name := range sortedVars(msg.Vars) {
+		vars = append(vars, MandrilMergeVarContent{Name: name, Content: msg.Vars[name]})
+	}
+	payload := MandrillPayload{
+		Key:             m.apiKey,
+		TemplateName:    tmpl.MandrillTemplate,
+		TemplateContent: vars,
+		Message: MandrillMessage{
+			To: []MandrillTo{
+				{
+					Email: msg.To,
+					Type:  "to",
+				},
+			},
+			MergeVars: []MandrillVar{
+				{
+					Rcpt: msg.To,
+					Vars: vars,
+				},
+			},
+			SendingDomain: m.sendingDomain,
+		},
+	}
+	for _, attachment := range msg.Attachments {
+		contentType := attachment.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		payload.Message.Attachments = append(payload.Message.Attachments, MandrillAttachment{
+			Type:    contentType,
+			Name:    attachment.Name,
+			Content: base64.StdEncoding.EncodeToString(attachment.Content),
+		})
+	}
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("json.Marshal failed: %v: %w", err, ErrEmailPermanent)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.url, bytes.NewReader(payloadBytes))
+	if err != nil {
+		return fmt.Errorf("http.NewRequest failed: %v: %w", err, ErrEmailPermanent)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("mandrill request failed: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	result, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("io.ReadAll failed: %w", err)
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("mandrill request failed: %s: %s", resp.Status, result)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("mandrill request failed: %s: %s: %w", resp.Status, result, ErrEmailPermanent)
+	}
+	var statuses []MandrillRecipientStatus
+	if err := json.Unmarshal(result, &statuses); err != nil {
+		return fmt.Errorf("fail to parse mandrill response: %v: %w", err, ErrEmailPermanent)
+	}
+	for _, status := range statuses {
+		if status.Status == "rejected" || status.Status == "invalid" {
+			return fmt.Errorf("mandrill %s %s: %s: %w", status.Status, status.Email, status.RejectReason, ErrEmailPermanent)
+		}
+	}
+	return nil
+}
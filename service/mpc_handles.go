@@ -0,0 +1,57 @@
+package service
+
+import (
+	"sync"
+)
+
+// mpcHandleKinds are the kinds of handles the mpc library allocates , every kind has its own free function
+var mpcHandleKinds = []string{"keygen_session", "sign_session", "keyshare"}
+
+// mpcHandles counts the live handles of the mpc library , a handle that's never freed leaks native memory
+var mpcHandles = &handleCounter{live: make(map[string]int)}
+
+// handleCounter counts the handles created and freed by kind
+type handleCounter struct {
+	mu   sync.Mutex
+	live map[string]int
+}
+
+// created counts a handle of the kind when it was created without error , and returns the error
+func (c *handleCounter) created(kind string, err error) error {
+	if err == nil {
+		c.add(kind, 1)
+	}
+	return err
+}
+
+// freed uncounts a handle of the kind when it was freed without error , and returns the error
+func (c *handleCounter) freed(kind string, err error) error {
+	if err == nil {
+		c.add(kind, -1)
+	}
+	return err
+}
+
+func (c *handleCounter) add(kind string, delta int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.live[kind] += delta
+}
+
+// LiveMPCHandles returns the number of handles of the mpc library that were created and not freed yet , by kind
+func LiveMPCHandles() map[string]int {
+	mpcHandles.mu.Lock()
+	defer mpcHandles.mu.Unlock()
+	live := make(map[string]int, len(mpcHandleKinds))
+	for _, kind := range mpcHandleKinds {
+		live[kind] = mpcHandles.live[kind]
+	}
+	return live
+}
+
+// reportMPCHandles reports the live handles of the mpc library , a gauge that keeps growing is a leak
+func (s *WorkerService) reportMPCHandles() {
+	for kind, count := range LiveMPCHandles() {
+		s.gauge("worker.mpc.handles", float64(count), []string{"kind:" + kind})
+	}
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"encoding/base64"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	vaultType "github.com/vultisig/commondata/go/vultisig/vault/v1"
+
+	"github.com/vultisig/vultisigner/relay"
+)
+
+const testPublicKey = "02a1633cafcc01ebfb6d78e39f687a1f0995c62fc95f51ead10a02ee0be551b5dc"
+
+func newHandleTestService(t *testing.T) *DKLSTssService {
+	s := newCeremonyTestService(t, true)
+	vault := &vaultType.Vault{
+		LocalPartyId: "server",
+		KeyShares: []*vaultType.Vault_KeyShare{
+			{PublicKey: testPublicKey, Keyshare: base64.StdEncoding.EncodeToString([]byte("keyshare"))},
+		},
+	}
+	localState, err := relay.NewLocalStateAccessorWithVault(t.TempDir(), vault, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.localStateAccessor = localState
+	s.isKeygenFinished = &atomic.Bool{}
+	return s
+}
+
+// assertNoLiveHandles fails the test when more handles are live than before it , other tests may hold handles of their own
+func assertNoLiveHandles(t *testing.T, before map[string]int) {
+	t.Helper()
+	for kind, count := range LiveMPCHandles() {
+		if leaked := count - before[kind]; leaked > 0 {
+			t.Errorf("%d %s handles leaked", leaked, kind)
+		}
+	}
+}
+
+// the test relay returns a setup message that doesn't decode , every operation fails after it loaded the keyshare
+func TestMPCHandlesFreedOnError(t *testing.T) {
+	s := newHandleTestService(t)
+	committee := []string{"server", "device"}
+	tests := map[string]func() error{
+		"keysign": func() error {
+			_, _, err := s.keysign("session", "", testPublicKey, false, "abcd", "m/44'/0'/0'/0/0", "server", committee, 0)
+			return err
+		},
+		"refresh": func() error {
+			return s.refresh("session", "", "server", testPublicKey, "", false, committee, 0)
+		},
+		"reshare": func() error {
+			_, _, err := s.reshare(&vaultType.Vault{LocalPartyId: "server"}, "session", "", committee, testPublicKey, true, 2, 0)
+			return err
+		},
+	}
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			before := LiveMPCHandles()
+			err := run()
+			if err == nil || !strings.Contains(err.Error(), "failed to decode setup message") {
+				t.Fatalf("expected the setup message to fail after the keyshare was loaded , got %v", err)
+			}
+			assertNoLiveHandles(t, before)
+		})
+	}
+}
+
+func TestSaveKeyshareFreesKeyshare(t *testing.T) {
+	s := newHandleTestService(t)
+	s.localStateAccessor.Vault = nil
+	mpcWrapper := NewMPCWrapperImp(true)
+	before := LiveMPCHandles()
+	handle, err := mpcWrapper.KeyshareFromBytes([]byte("keyshare"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if LiveMPCHandles()["keyshare"]-before["keyshare"] != 1 {
+		t.Fatalf("keyshare handle isn't counted: %v", LiveMPCHandles())
+	}
+	publicKey, chainCode, err := s.saveKeyshare(mpcWrapper, handle, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chainCode != "" {
+		t.Errorf("EdDSA keyshare has chain code %q", chainCode)
+	}
+	if _, err := s.localStateAccessor.GetKeyshare(publicKey); err != nil {
+		t.Errorf("keyshare wasn't saved: %v", err)
+	}
+	assertNoLiveHandles(t, before)
+	s.Wipe()
+	if _, err := s.localStateAccessor.GetKeyshare(publicKey); err == nil {
+		t.Error("keyshare wasn't wiped")
+	}
+}
@@ -36,6 +36,7 @@ type MPCQcWrapper interface {
 	QcSessionInputMessage(session Handle, message []byte) (bool, error)
 	QcSessionFinish(session Handle) (Handle, error)
 }
+
 type MPCKeyshareWrapper interface {
 	KeyshareFromBytes(buf []byte) (Handle, error)
 	KeyshareToBytes(share Handle) ([]byte, error)
@@ -53,6 +54,7 @@ type MPCSetupWrapper interface {
 	DecodeSessionID(setup []byte) ([]byte, error)
 	DecodeMessage(setup []byte) ([]byte, error)
 	DecodePartyName(setup []byte, index int) ([]byte, error)
+	DecodeThreshold(setup []byte) (int, error)
 }
 
 var _ MPCKeygenWrapper = &MPCWrapperImp{}
@@ -75,10 +77,10 @@ func (w *MPCWrapperImp) QcSetupMsgNew(keyshareHandle Handle, threshod int, ids [
 func (w *MPCWrapperImp) QcSessionFromSetup(setupMsg []byte, id string, keyshareHandle Handle) (Handle, error) {
 	if w.isEdDSA {
 		h, err := eddsaSession.SchnorrQcSessionFromSetup(setupMsg, id, eddsaSession.Handle(keyshareHandle))
-		return Handle(h), err
+		return Handle(h), mpcHandles.created("keygen_session", err)
 	}
 	h, err := session.DklsQcSessionFromSetup(setupMsg, id, session.Handle(keyshareHandle))
-	return Handle(h), err
+	return Handle(h), mpcHandles.created("keygen_session", err)
 }
 
 func (w *MPCWrapperImp) QcSessionOutputMessage(h Handle) ([]byte, error) {
@@ -105,10 +107,10 @@ func (w *MPCWrapperImp) QcSessionInputMessage(h Handle, message []byte) (bool, e
 func (w *MPCWrapperImp) QcSessionFinish(h Handle) (Handle, error) {
 	if w.isEdDSA {
 		h1, err := eddsaSession.SchnorrQcSessionFinish(eddsaSession.Handle(h))
-		return Handle(h1), err
+		return Handle(h1), mpcHandles.created("keyshare", err)
 	}
 	shareHandle, err := session.DklsQcSessionFinish(session.Handle(h))
-	return Handle(shareHandle), err
+	return Handle(shareHandle), mpcHandles.created("keyshare", err)
 }
 
 func NewMPCWrapperImp(isEdDSA bool) *MPCWrapperImp {
@@ -126,18 +128,18 @@ func (w *MPCWrapperImp) KeygenSetupMsgNew(threshold int, keyID []byte, ids []byt
 func (w *MPCWrapperImp) KeygenSessionFromSetup(setup []byte, id []byte) (Handle, error) {
 	if w.isEdDSA {
 		h, err := eddsaSession.SchnorrKeygenSessionFromSetup(setup, id)
-		return Handle(h), err
+		return Handle(h), mpcHandles.created("keygen_session", err)
 	}
 	h, err := session.DklsKeygenSessionFromSetup(setup, id)
-	return Handle(h), err
+	return Handle(h), mpcHandles.created("keygen_session", err)
 }
 func (w *MPCWrapperImp) KeyRefreshSessionFromSetup(setup []byte, id []byte, oldKeyshare Handle) (Handle, error) {
 	if w.isEdDSA {
 		h, err := eddsaSession.SchnorrKeyRefreshSessionFromSetup(setup, id, eddsaSession.Handle(oldKeyshare))
-		return Handle(h), err
+		return Handle(h), mpcHandles.created("keygen_session", err)
 	}
 	h, err := session.DklsKeyRefreshSessionFromSetup(setup, id, session.Handle(oldKeyshare))
-	return Handle(h), err
+	return Handle(h), mpcHandles.created("keygen_session", err)
 }
 func (w *MPCWrapperImp) KeygenSessionOutputMessage(h Handle) ([]byte, error) {
 	if w.isEdDSA {
@@ -162,26 +164,26 @@ func (w *MPCWrapperImp) KeygenSessionMessageReceiver(h Handle, message []byte, i
 func (w *MPCWrapperImp) KeygenSessionFinish(h Handle) (Handle, error) {
 	if w.isEdDSA {
 		h1, err := eddsaSession.SchnorrKeygenSessionFinish(eddsaSession.Handle(h))
-		return Handle(h1), err
+		return Handle(h1), mpcHandles.created("keyshare", err)
 	}
 	h1, err := session.DklsKeygenSessionFinish(session.Handle(h))
-	return Handle(h1), err
+	return Handle(h1), mpcHandles.created("keyshare", err)
 }
 
 func (w *MPCWrapperImp) KeygenSessionFree(h Handle) error {
 	if w.isEdDSA {
-		return eddsaSession.SchnorrKeygenSessionFree(eddsaSession.Handle(h))
+		return mpcHandles.freed("keygen_session", eddsaSession.SchnorrKeygenSessionFree(eddsaSession.Handle(h)))
 	}
-	return session.DklsKeygenSessionFree(session.Handle(h))
+	return mpcHandles.freed("keygen_session", session.DklsKeygenSessionFree(session.Handle(h)))
 }
 
 func (w *MPCWrapperImp) MigrateSessionFromSetup(setup []byte, id []byte, publicKey []byte, rootChainCode []byte, secretCoefficient []byte) (Handle, error) {
 	if w.isEdDSA {
 		h, err := eddsaSession.SchnorrKeyMigrateSessionFromSetup(setup, id, publicKey, rootChainCode, secretCoefficient)
-		return Handle(h), err
+		return Handle(h), mpcHandles.created("keygen_session", err)
 	}
 	h, err := session.DklsKeyMigrateSessionFromSetup(setup, id, publicKey, rootChainCode, secretCoefficient)
-	return Handle(h), err
+	return Handle(h), mpcHandles.created("keygen_session", err)
 }
 func (w *MPCWrapperImp) SignSetupMsgNew(keyID []byte, chainPath []byte, messageHash []byte, ids []byte) ([]byte, error) {
 	if w.isEdDSA {
@@ -193,10 +195,10 @@ func (w *MPCWrapperImp) SignSetupMsgNew(keyID []byte, chainPath []byte, messageH
 func (w *MPCWrapperImp) SignSessionFromSetup(setup []byte, id []byte, shareOrPresign Handle) (Handle, error) {
 	if w.isEdDSA {
 		h, err := eddsaSession.SchnorrSignSessionFromSetup(setup, id, eddsaSession.Handle(shareOrPresign))
-		return Handle(h), err
+		return Handle(h), mpcHandles.created("sign_session", err)
 	}
 	h, err := session.DklsSignSessionFromSetup(setup, id, session.Handle(shareOrPresign))
-	return Handle(h), err
+	return Handle(h), mpcHandles.created("sign_session", err)
 }
 func (w *MPCWrapperImp) SignSessionOutputMessage(h Handle) ([]byte, error) {
 	if w.isEdDSA {
@@ -224,17 +226,17 @@ func (w *MPCWrapperImp) SignSessionFinish(h Handle) ([]byte, error) {
 }
 func (w *MPCWrapperImp) SignSessionFree(h Handle) error {
 	if w.isEdDSA {
-		return eddsaSession.SchnorrSignSessionFree(eddsaSession.Handle(h))
+		return mpcHandles.freed("sign_session", eddsaSession.SchnorrSignSessionFree(eddsaSession.Handle(h)))
 	}
-	return session.DklsSignSessionFree(session.Handle(h))
+	return mpcHandles.freed("sign_session", session.DklsSignSessionFree(session.Handle(h)))
 }
 func (w *MPCWrapperImp) KeyshareFromBytes(buf []byte) (Handle, error) {
 	if w.isEdDSA {
 		h, err := eddsaSession.SchnorrKeyshareFromBytes(buf)
-		return Handle(h), err
+		return Handle(h), mpcHandles.created("keyshare", err)
 	}
 	h, err := session.DklsKeyshareFromBytes(buf)
-	return Handle(h), err
+	return Handle(h), mpcHandles.created("keyshare", err)
 }
 func (w *MPCWrapperImp) KeyshareToBytes(share Handle) ([]byte, error) {
 	if w.isEdDSA {
@@ -271,7 +273,7 @@ func (w *MPCWrapperImp) RefreshShareFromBytes(buf []byte) (Handle, error) {
 		return Handle(0), fmt.Errorf("Not implemented")
 	}
 	h, err := session.DklsRefreshShareFromBytes(buf)
-	return Handle(h), err
+	return Handle(h), mpcHandles.created("keyshare", err)
 }
 
 func (w *MPCWrapperImp) RefreshShareToBytes(share Handle) ([]byte, error) {
@@ -282,9 +284,9 @@ func (w *MPCWrapperImp) RefreshShareToBytes(share Handle) ([]byte, error) {
 }
 func (w *MPCWrapperImp) KeyshareFree(share Handle) error {
 	if w.isEdDSA {
-		return nil
+		return mpcHandles.freed("keyshare", eddsaSession.SchnorrKeyshareFree(eddsaSession.Handle(share)))
 	}
-	return session.DklsKeyshareFree(session.Handle(share))
+	return mpcHandles.freed("keyshare", session.DklsKeyshareFree(session.Handle(share)))
 }
 func (w *MPCWrapperImp) KeyshareChainCode(share Handle) ([]byte, error) {
 	if w.isEdDSA {
@@ -317,3 +319,11 @@ func (w *MPCWrapperImp) DecodePartyName(setup []byte, index int) ([]byte, error)
 	}
 	return session.DklsDecodePartyName(setup, index)
 }
+
+// DecodeThreshold returns the threshold encoded in a keygen or quorum change setup message
+func (w *MPCWrapperImp) DecodeThreshold(setup []byte) (int, error) {
+	if w.isEdDSA {
+		return eddsaSession.SchnorrDecodeThreshold(setup)
+	}
+	return session.DklsDecodeThreshold(setup)
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hibiken/asynq"
+
+	"github.com/vultisig/vultisigner/chainhelper/summary"
+	"github.com/vultisig/vultisigner/common"
+	"github.com/vultisig/vultisigner/contexthelper"
+	"github.com/vultisig/vultisigner/internal/tasks"
+	"github.com/vultisig/vultisigner/internal/types"
+)
+
+// notifyKeysign schedules the co-sign notifications of a completed keysign , when the vault opted in.
+// Notifications are best effort , failures are only logged.
+func (s *WorkerService) notifyKeysign(ctx context.Context, req types.KeysignRequest, partiesJoined []string) {
+	settings, err := s.redis.GetNotificationSettings(ctx, req.PublicKey)
+	if err != nil {
+		s.logger.Errorf("fail to get notification settings: %v", err)
+		return
+	}
+	if settings == nil {
+		return
+	}
+	notification := types.KeysignNotification{
+		PublicKeyECDSA: req.PublicKey,
+		SessionID:      req.SessionID,
+		SignedAt:       time.Now().UTC(),
+		Parties:        partiesJoined,
+		Messages:       len(req.Messages),
+	}
+	if metadata, err := s.redis.GetVaultMetadata(ctx, req.PublicKey); err == nil {
+		notification.VaultName = metadata.Name
+	}
+	if req.KeysignPayload != "" {
+		if notification.Summary, err = summary.Decode(req.KeysignPayload); err != nil {
+			s.logger.Errorf("fail to decode keysign payload: %v", err)
+		}
+	}
+	token, err := s.redis.CreateReportToken(ctx, req.PublicKey, req.SessionID, time.Duration(s.cfg.Notification.ReportTTLHours)*time.Hour)
+	if err != nil {
+		s.logger.Errorf("fail to create report token: %v", err)
+		return
+	}
+	notification.ReportURL = strings.TrimRight(s.cfg.Notification.BaseURL, "/") + "/vault/report/" + token
+	var channels []types.NotificationChannel
+	if settings.Email != "" {
+		channels = append(channels, types.NotificationChannelEmail)
+	}
+	if settings.WebhookURL != "" {
+		channels = append(channels, types.NotificationChannelWebhook)
+	}
+	for _, channel := range channels {
+		buf, err := json.Marshal(types.KeysignNotificationTask{Channel: channel, Notification: notification})
+		if err != nil {
+			s.logger.Errorf("json.Marshal failed: %v", err)
+			return
+		}
+		if _, err := s.queueClient.Enqueue(asynq.NewTask(tasks.TypeKeysignNotify, buf),
+			asynq.Retention(10*time.Minute),
+			asynq.Queue(tasks.EMAIL_QUEUE_NAME)); err != nil {
+			s.logger.Errorf("fail to enqueue %s notification: %v", channel, err)
+		}
+	}
+}
+
+// HandleKeysignNotification delivers a co-sign notification through one channel ,
+// the settings are read again so a vault that opted out meanwhile gets nothing
+func (s *WorkerService) HandleKeysignNotification(ctx context.Context, t *asynq.Task) error {
+	if err := contexthelper.CheckCancellation(ctx); err != nil {
+		return err
+	}
+	var req types.KeysignNotificationTask
+	if err := json.Unmarshal(t.Payload(), &req); err != nil {
+		s.logger.Errorf("json.Unmarshal failed: %v", err)
+		return fmt.Errorf("json.Unmarshal failed: %v: %w", err, asynq.SkipRetry)
+	}
+	settings, err := s.redis.GetNotificationSettings(ctx, req.Notification.PublicKeyECDSA)
+	if err != nil {
+		return fmt.Errorf("fail to get notification settings: %w", err)
+	}
+	if settings == nil {
+		return nil
+	}
+	s.incCounter("worker.vault.sign.notification", []string{"channel:" + string(req.Channel)})
+	switch req.Channel {
+	case types.NotificationChannelEmail:
+		if settings.Email == "" {
+			return nil
+		}
+		return s.sendEmail(ctx, EmailMessage{
+			Kind: EmailAlert,
+			To:   settings.Email,
+			Vars: map[string]string{
+				"VAULT_NAME":    req.Notification.VaultName,
+				"ALERT_MESSAGE": keysignNotificationText(req.Notification),
+				"REPORT_URL":    req.Notification.ReportURL,
+			},
+		})
+	case types.NotificationChannelWebhook:
+		if settings.WebhookURL == "" {
+			return nil
+		}
+		return s.postWebhook(ctx, settings.WebhookURL, req.Notification)
+	default:
+		return fmt.Errorf("unknown notification channel %s: %w", req.Channel, asynq.SkipRetry)
+	}
+}
+
+// postWebhook posts the notification as json , 4xx responses are not retried
+func (s *WorkerService) postWebhook(ctx context.Context, webhookURL string, notification types.KeysignNotification) error {
+	buf, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("json.Marshal failed: %v: %w", err, asynq.SkipRetry)
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(s.cfg.Notification.WebhookTimeout)*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(buf))
+	if err != nil {
+		return fmt.Errorf("http.NewRequest failed: %v: %w", err, asynq.SkipRetry)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.publicClient.Do(req)
+	if errors.Is(err, common.ErrNonPublicAddress) {
+		return fmt.Errorf("webhook request failed: %v: %w", err, asynq.SkipRetry)
+	}
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return httpStatusError("webhook request", resp)
+}
+
+// httpStatusError returns the error of a failed response , 4xx responses other than 429 won't succeed on retry so they skip it
+func httpStatusError(what string, resp *http.Response) error {
+	if resp.StatusCode >= http.StatusBadRequest && resp.StatusCode < http.StatusInternalServerError && resp.StatusCode != http.StatusTooManyRequests {
+		return fmt.Errorf("%s failed: %s: %w", what, resp.Status, asynq.SkipRetry)
+	}
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("%s failed: %s", what, resp.Status)
+	}
+	return nil
+}
+
+// keysignNotificationText is the plain text description of the notification used in emails
+func keysignNotificationText(notification types.KeysignNotification) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "VultiServer co-signed %d message(s) for your vault at %s.\n", notification.Messages, notification.SignedAt.Format(time.RFC1123))
+	fmt.Fprintf(&sb, "Devices that joined: %s\n", strings.Join(notification.Parties, ", "))
+	if tx := notification.Summary; tx != nil {
+		fmt.Fprintf(&sb, "As described by the signing device: %s %s %s on %s to %s\n", tx.Action, tx.Amount, tx.Ticker, tx.Chain, tx.ToAddress)
+		if tx.Fee != "" {
+			fmt.Fprintf(&sb, "Fee: %s\n", tx.Fee)
+		}
+		if tx.Memo != "" {
+			fmt.Fprintf(&sb, "Memo: %s\n", tx.Memo)
+		}
+	}
+	fmt.Fprintf(&sb, "If you didn't sign this , report it to freeze your vault: %s\n", notification.ReportURL)
+	return sb.String()
+}
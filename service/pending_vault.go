@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/sirupsen/logrus"
+	vaultType "github.com/vultisig/commondata/go/vultisig/vault/v1"
+
+	"github.com/vultisig/vultisigner/common"
+	"github.com/vultisig/vultisigner/contexthelper"
+	"github.com/vultisig/vultisigner/internal/tasks"
+	"github.com/vultisig/vultisigner/internal/types"
+	"github.com/vultisig/vultisigner/relay"
+	"github.com/vultisig/vultisigner/storage"
+)
+
+// pendingVaultFile is the backup file name of the pending share of the vault
+func pendingVaultFile(publicKeyECDSA string) string {
+	return publicKeyECDSA + types.PendingVaultSuffix + ".bak"
+}
+
+// StagePendingVault saves the share of a reshare or migration that not every party confirmed as pending ,
+// the active share and its backup stay untouched until the pending share is promoted
+func (s *WorkerService) StagePendingVault(vault *vaultType.Vault,
+	keyshares []common.VaultKeyshare,
+	threshold int,
+	revokedParties []string,
+	encryptionPassword *common.Secret,
+	email string,
+	delivery types.BackupDeliveryOptions,
+	sessionID string,
+	partiesJoined []string) error {
+	content, metadata, err := s.prepareBackup(vault, keyshares, threshold, revokedParties, encryptionPassword, email, delivery)
+	if err != nil {
+		return err
+	}
+	if err := s.blockStorage.UploadFileWithRetry([]byte(content), pendingVaultFile(vault.PublicKeyEcdsa), 5); err != nil {
+		return fmt.Errorf("fail to write pending vault file, err: %w", err)
+	}
+	ttl := time.Duration(s.cfg.Reshare.PendingTTLMinutes) * time.Minute
+	now := time.Now().UTC()
+	pending := types.PendingVault{
+		SessionID: sessionID,
+		Parties:   partiesJoined,
+		Email:     email,
+		Delivery:  delivery,
+		Metadata:  metadata,
+		StagedAt:  now,
+		ExpiresAt: now.Add(ttl),
+	}
+	if err := s.redis.SavePendingVault(context.Background(), vault.PublicKeyEcdsa, pending, ttl); err != nil {
+		return fmt.Errorf("fail to save pending vault: %w", err)
+	}
+	s.incCounter("worker.vault.pending", []string{})
+	s.logger.WithFields(logrus.Fields{
+		"public_key_ecdsa": vault.PublicKeyEcdsa,
+		"session":          sessionID,
+		"expires_at":       pending.ExpiresAt,
+	}).Warn("not every party completed , vault share is pending")
+
+	buf, err := json.Marshal(types.PendingVaultTask{PublicKeyECDSA: vault.PublicKeyEcdsa, SessionID: sessionID})
+	if err != nil {
+		return fmt.Errorf("json.Marshal failed: %w", err)
+	}
+	if _, err := s.queueClient.Enqueue(asynq.NewTask(tasks.TypePromotePendingVault, buf),
+		asynq.ProcessIn(time.Minute),
+		asynq.MaxRetry(5),
+		asynq.Deadline(pending.ExpiresAt),
+		asynq.Retention(10*time.Minute),
+		asynq.Queue(tasks.QUEUE_NAME)); err != nil {
+		s.logger.Errorf("fail to enqueue promote pending vault task: %v", err)
+	}
+	if _, err := s.queueClient.Enqueue(asynq.NewTask(tasks.TypeExpirePendingVault, buf),
+		asynq.ProcessAt(pending.ExpiresAt.Add(time.Minute)),
+		asynq.Retention(10*time.Minute),
+		asynq.Queue(tasks.QUEUE_NAME)); err != nil {
+		s.logger.Errorf("fail to enqueue expire pending vault task: %v", err)
+	}
+	return nil
+}
+
+// promotePendingVault makes the pending share the active share , and delivers its backup.
+// When sessionID isn't empty only the share staged by that session is promoted.
+func (s *WorkerService) promotePendingVault(ctx context.Context, publicKeyECDSA, sessionID string) error {
+	pending, err := s.redis.ClaimPendingVault(ctx, publicKeyECDSA, sessionID)
+	if err != nil {
+		return err
+	}
+	// only the claimer promotes the share , when that fails the share is pending again so the promotion can be retried
+	if err := s.activatePendingVault(publicKeyECDSA, pending); err != nil {
+		if err := s.redis.RestorePendingVault(context.Background(), publicKeyECDSA, *pending); err != nil {
+			s.logger.Errorf("fail to restore pending vault of %s: %v", publicKeyECDSA, err)
+		}
+		return err
+	}
+	if err := s.blockStorage.DeleteFile(pendingVaultFile(publicKeyECDSA)); err != nil {
+		s.logger.Errorf("fail to delete pending vault file: %v", err)
+	}
+	s.incCounter("worker.vault.pending.promoted", []string{})
+	s.logger.WithFields(logrus.Fields{
+		"public_key_ecdsa": publicKeyECDSA,
+		"session":          pending.SessionID,
+	}).Info("pending vault share promoted")
+	return nil
+}
+
+// activatePendingVault saves the claimed pending share as the active share , and delivers its backup
+func (s *WorkerService) activatePendingVault(publicKeyECDSA string, pending *types.PendingVault) error {
+	content, err := s.blockStorage.GetFile(pendingVaultFile(publicKeyECDSA))
+	if err != nil {
+		return fmt.Errorf("fail to read pending vault file, err: %w", err)
+	}
+	return s.activateBackup(string(content), pending.Metadata, pending.Email, pending.Delivery)
+}
+
+// discardPendingVault deletes the pending share of an earlier session once a newer share is active , so it can't be promoted over it
+func (s *WorkerService) discardPendingVault(publicKeyECDSA string) {
+	pending, err := s.redis.ClaimPendingVault(context.Background(), publicKeyECDSA, "")
+	if errors.Is(err, storage.ErrPendingVaultNotFound) {
+		return
+	}
+	if err != nil {
+		s.logger.Errorf("fail to discard pending vault of %s: %v", publicKeyECDSA, err)
+		return
+	}
+	if err := s.blockStorage.DeleteFile(pendingVaultFile(publicKeyECDSA)); err != nil {
+		s.logger.Errorf("fail to delete pending vault file: %v", err)
+	}
+	s.logger.WithFields(logrus.Fields{
+		"public_key_ecdsa": publicKeyECDSA,
+		"session":          pending.SessionID,
+	}).Warn("pending vault share discarded , a newer share is active")
+}
+
+// HandlePromotePendingVault promotes a pending share once all parties of its session report completion through the relay
+func (s *WorkerService) HandlePromotePendingVault(ctx context.Context, t *asynq.Task) error {
+	if err := contexthelper.CheckCancellation(ctx); err != nil {
+		return err
+	}
+	var req types.PendingVaultTask
+	if err := json.Unmarshal(t.Payload(), &req); err != nil {
+		s.logger.Errorf("json.Unmarshal failed: %v", err)
+		return fmt.Errorf("json.Unmarshal failed: %v: %w", err, asynq.SkipRetry)
+	}
+	pending, err := s.redis.GetPendingVault(ctx, req.PublicKeyECDSA)
+	if err != nil {
+		return fmt.Errorf("fail to get pending vault: %w", err)
+	}
+	if pending == nil || pending.SessionID != req.SessionID {
+		// promoted by a confirmation keysign , expired or replaced by a later session
+		return nil
+	}
+	isCompleted, err := relay.NewRelayClient(s.cfg.Relay.Server).CheckCompletedParties(req.SessionID, pending.Parties)
+	if err != nil {
+		return fmt.Errorf("fail to check completed parties: %w", err)
+	}
+	if !isCompleted {
+		return fmt.Errorf("not every party of session %s completed", req.SessionID)
+	}
+	err = s.promotePendingVault(ctx, req.PublicKeyECDSA, req.SessionID)
+	if errors.Is(err, storage.ErrPendingVaultNotFound) {
+		return nil
+	}
+	return err
+}
+
+// HandleExpirePendingVault deletes the file of an expired pending share , its backup is never delivered
+func (s *WorkerService) HandleExpirePendingVault(ctx context.Context, t *asynq.Task) error {
+	if err := contexthelper.CheckCancellation(ctx); err != nil {
+		return err
+	}
+	var req types.PendingVaultTask
+	if err := json.Unmarshal(t.Payload(), &req); err != nil {
+		s.logger.Errorf("json.Unmarshal failed: %v", err)
+		return fmt.Errorf("json.Unmarshal failed: %v: %w", err, asynq.SkipRetry)
+	}
+	pending, err := s.redis.GetPendingVault(ctx, req.PublicKeyECDSA)
+	if err != nil {
+		return fmt.Errorf("fail to get pending vault: %w", err)
+	}
+	if pending != nil && pending.SessionID != req.SessionID {
+		// a later session staged its own pending share , it expires on its own
+		return nil
+	}
+	if pending != nil {
+		if _, err := s.redis.ClaimPendingVault(ctx, req.PublicKeyECDSA, req.SessionID); err != nil {
+			if errors.Is(err, storage.ErrPendingVaultNotFound) {
+				return nil
+			}
+			return fmt.Errorf("fail to expire pending vault: %w", err)
+		}
+	}
+	// deleting a missing file succeeds , the share may have been promoted already
+	if err := s.blockStorage.DeleteFile(pendingVaultFile(req.PublicKeyECDSA)); err != nil {
+		return fmt.Errorf("fail to delete pending vault file: %w", err)
+	}
+	s.incCounter("worker.vault.pending.expired", []string{})
+	s.logger.WithFields(logrus.Fields{
+		"public_key_ecdsa": req.PublicKeyECDSA,
+		"session":          req.SessionID,
+	}).Warn("pending vault share expired")
+	return nil
+}
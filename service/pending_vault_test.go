@@ -0,0 +1,247 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/hibiken/asynq"
+	"github.com/sirupsen/logrus"
+	keygenType "github.com/vultisig/commondata/go/vultisig/keygen/v1"
+	vaultType "github.com/vultisig/commondata/go/vultisig/vault/v1"
+
+	"github.com/vultisig/vultisigner/common"
+	"github.com/vultisig/vultisigner/config"
+	"github.com/vultisig/vultisigner/internal/tasks"
+	"github.com/vultisig/vultisigner/internal/types"
+	"github.com/vultisig/vultisigner/storage"
+)
+
+const testPublicKeyECDSA = "027e897b35aa9f9fff223b6c826ff42da37e8169fae7be57cbd38be86938a746c6"
+
+// fakeS3 serves the objects of the block storage from memory , uploads of the files in failPut fail
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	failPut map[string]bool
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	// path style requests , /bucket/key
+	key := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)[1]
+	switch r.Method {
+	case http.MethodPut:
+		if f.failPut[key] {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		f.objects[key] = body
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet, http.MethodHead:
+		content, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			if r.Method == http.MethodGet {
+				_, _ = w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?><Error><Code>NoSuchKey</Code><Message>not found</Message></Error>`))
+			}
+			return
+		}
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(content)
+		}
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (f *fakeS3) setFailPut(key string, fail bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failPut[key] = fail
+}
+
+func (f *fakeS3) exists(key string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.objects[key]
+	return ok
+}
+
+// newTestWorker returns a worker service backed by an in-memory redis and block storage
+func newTestWorker(t *testing.T) (*WorkerService, *fakeS3, *miniredis.Miniredis) {
+	server := miniredis.RunT(t)
+	s3 := &fakeS3{objects: make(map[string][]byte), failPut: make(map[string]bool)}
+	s3Server := httptest.NewServer(s3)
+	t.Cleanup(s3Server.Close)
+
+	var cfg config.Config
+	cfg.Redis.Host = server.Host()
+	cfg.Redis.Port = server.Port()
+	cfg.BlockStorage.Host = s3Server.URL
+	cfg.BlockStorage.Region = "us-east-1"
+	cfg.BlockStorage.AccessKey = "access"
+	cfg.BlockStorage.SecretKey = "secret"
+	cfg.BlockStorage.Bucket = "vaults"
+	cfg.Server.VaultsFilePath = t.TempDir()
+	cfg.Reshare.PendingTTLMinutes = 60
+
+	redis, err := storage.NewRedisStorage(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blockStorage, err := storage.NewBlockStorage(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vaultCache, err := storage.NewVaultCache(blockStorage, redis, nil, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queueClient := asynq.NewClient(asynq.RedisClientOpt{Addr: server.Addr()})
+	t.Cleanup(func() {
+		_ = queueClient.Close()
+	})
+	return &WorkerService{
+		cfg:          cfg,
+		redis:        redis,
+		logger:       logrus.New(),
+		queueClient:  queueClient,
+		blockStorage: blockStorage,
+		vaultCache:   vaultCache,
+	}, s3, server
+}
+
+// stageTestVault stages a pending share of the session , delivered in the task result
+func stageTestVault(t *testing.T, s *WorkerService, sessionID string) {
+	vault := &vaultType.Vault{
+		Name:           "test vault",
+		PublicKeyEcdsa: testPublicKeyECDSA,
+		Signers:        []string{"server", "device-1", "device-2"},
+		LocalPartyId:   "server",
+		LibType:        keygenType.LibType_LIB_TYPE_DKLS,
+	}
+	if err := s.StagePendingVault(vault, nil, 2, []string{"device-0"}, common.NewSecretFromString("password"), "", types.BackupDeliveryOptions{BackupDelivery: types.BackupDeliveryResult},
+		sessionID, vault.Signers); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStagePendingVault(t *testing.T) {
+	s, s3, server := newTestWorker(t)
+	stageTestVault(t, s, "session-1")
+
+	if !s3.exists(pendingVaultFile(testPublicKeyECDSA)) {
+		t.Fatal("pending vault file wasn't written")
+	}
+	if s3.exists(testPublicKeyECDSA + ".bak") {
+		t.Fatal("pending share replaced the active share")
+	}
+	pending, err := s.redis.GetPendingVault(context.Background(), testPublicKeyECDSA)
+	if err != nil || pending == nil {
+		t.Fatalf("pending vault wasn't saved: %v", err)
+	}
+	if pending.SessionID != "session-1" || len(pending.Parties) != 3 || pending.Metadata.Threshold != 2 {
+		t.Fatalf("unexpected pending vault: %+v", pending)
+	}
+	if ttl := server.TTL("pending_vault_" + testPublicKeyECDSA); ttl <= 0 || ttl > time.Hour {
+		t.Fatalf("unexpected ttl %s", ttl)
+	}
+	scheduled, err := server.ZMembers("asynq:{" + tasks.QUEUE_NAME + "}:scheduled")
+	if err != nil || len(scheduled) != 2 {
+		t.Fatalf("expected the promote and expire tasks , got %v %v", scheduled, err)
+	}
+}
+
+func TestPromotePendingVault(t *testing.T) {
+	s, s3, _ := newTestWorker(t)
+	ctx := context.Background()
+	stageTestVault(t, s, "session-1")
+
+	if err := s.promotePendingVault(ctx, testPublicKeyECDSA, "session-2"); !errors.Is(err, storage.ErrPendingVaultNotFound) {
+		t.Fatalf("share of another session promoted: %v", err)
+	}
+	if err := s.promotePendingVault(ctx, testPublicKeyECDSA, "session-1"); err != nil {
+		t.Fatal(err)
+	}
+	if !s3.exists(testPublicKeyECDSA+".bak") || s3.exists(pendingVaultFile(testPublicKeyECDSA)) {
+		t.Fatal("pending share wasn't made the active share")
+	}
+	settings, err := s.blockStorage.GetVaultSettings(testPublicKeyECDSA)
+	if err != nil || settings.Threshold != 2 || len(settings.RevokedParties) != 1 || settings.RevokedParties[0] != "device-0" {
+		t.Fatalf("settings of the pending share weren't saved: %+v %v", settings, err)
+	}
+	if pending, err := s.redis.GetPendingVault(ctx, testPublicKeyECDSA); err != nil || pending != nil {
+		t.Fatalf("pending vault wasn't claimed: %+v %v", pending, err)
+	}
+	if err := s.promotePendingVault(ctx, testPublicKeyECDSA, "session-1"); !errors.Is(err, storage.ErrPendingVaultNotFound) {
+		t.Fatalf("share promoted twice: %v", err)
+	}
+}
+
+func TestPromotePendingVaultFailureKeepsItPending(t *testing.T) {
+	s, s3, _ := newTestWorker(t)
+	ctx := context.Background()
+	stageTestVault(t, s, "session-1")
+
+	s3.setFailPut(testPublicKeyECDSA+".bak", true)
+	if err := s.promotePendingVault(ctx, testPublicKeyECDSA, "session-1"); err == nil {
+		t.Fatal("promotion should fail")
+	}
+	pending, err := s.redis.GetPendingVault(ctx, testPublicKeyECDSA)
+	if err != nil || pending == nil || pending.SessionID != "session-1" {
+		t.Fatalf("failed promotion lost the pending vault: %+v %v", pending, err)
+	}
+	if !s3.exists(pendingVaultFile(testPublicKeyECDSA)) {
+		t.Fatal("failed promotion deleted the pending vault file")
+	}
+
+	// the retry promotes it
+	s3.setFailPut(testPublicKeyECDSA+".bak", false)
+	if err := s.promotePendingVault(ctx, testPublicKeyECDSA, "session-1"); err != nil {
+		t.Fatal(err)
+	}
+	if !s3.exists(testPublicKeyECDSA + ".bak") {
+		t.Fatal("pending share wasn't made the active share")
+	}
+}
+
+func TestExpirePendingVault(t *testing.T) {
+	s, s3, _ := newTestWorker(t)
+	ctx := context.Background()
+	stageTestVault(t, s, "session-1")
+
+	expire := func(sessionID string) {
+		buf, err := json.Marshal(types.PendingVaultTask{PublicKeyECDSA: testPublicKeyECDSA, SessionID: sessionID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.HandleExpirePendingVault(ctx, asynq.NewTask(tasks.TypeExpirePendingVault, buf)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the expiry of an earlier session leaves the share of a later session alone
+	expire("session-0")
+	if pending, err := s.redis.GetPendingVault(ctx, testPublicKeyECDSA); err != nil || pending == nil {
+		t.Fatalf("expiry of another session deleted the pending vault: %v", err)
+	}
+
+	expire("session-1")
+	if pending, err := s.redis.GetPendingVault(ctx, testPublicKeyECDSA); err != nil || pending != nil {
+		t.Fatalf("pending vault wasn't expired: %+v %v", pending, err)
+	}
+	if s3.exists(pendingVaultFile(testPublicKeyECDSA)) || s3.exists(testPublicKeyECDSA+".bak") {
+		t.Fatal("expired share was kept or promoted")
+	}
+}
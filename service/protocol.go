@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	vaultType "github.com/vultisig/commondata/go/vultisig/vault/v1"
+
+	"github.com/vultisig/vultisigner/internal/protocol"
+	"github.com/vultisig/vultisigner/internal/types"
+	"github.com/vultisig/vultisigner/relay"
+)
+
+// Protocol runs the vault operations of one MPC library
+type Protocol interface {
+	// Keygen creates a new vault , returns the ECDSA and EdDSA public keys
+	Keygen(req types.VaultCreateRequest) (string, string, error)
+	// Keysign signs the messages of the request , the result is written to the task result as json.
+	// It also returns the party ids that joined the keysign.
+	Keysign(ctx context.Context, req types.KeysignRequest) (any, []string, error)
+	// Reshare moves the vault to a new committee , localState.Vault is nil when this server joins the committee
+	Reshare(localState *relay.LocalStateAccessorImp, vault *vaultType.Vault, req types.ReshareRequest) error
+	// Refresh replaces the key shares of the vault , the public keys stay the same
+	Refresh(localState *relay.LocalStateAccessorImp, req types.RefreshRequest) error
+	// Migrate converts a vault of another protocol to this protocol , the public keys stay the same
+	Migrate(localState *relay.LocalStateAccessorImp, req types.MigrationRequest) error
+	// Import creates a vault from an existing single-signer key , returns the ECDSA and EdDSA public keys
+	Import(req types.KeyImportRequest) (string, string, error)
+}
+
+// protocolImplementations creates the implementation of every protocol , by lib type
+var protocolImplementations = map[types.LibType]func(s *WorkerService) Protocol{
+	types.GG20: func(s *WorkerService) Protocol {
+		return &gg20Protocol{worker: s}
+	},
+	types.DKLS: func(s *WorkerService) Protocol {
+		return &dklsProtocol{worker: s}
+	},
+}
+
+// newProtocol returns the implementation of the protocol
+func (s *WorkerService) newProtocol(info protocol.Info) (Protocol, error) {
+	newImplementation, ok := protocolImplementations[info.LibType]
+	if !ok {
+		return nil, fmt.Errorf("protocol %s has no implementation", info.Name)
+	}
+	return newImplementation(s), nil
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	vaultType "github.com/vultisig/commondata/go/vultisig/vault/v1"
+
+	"github.com/vultisig/vultisigner/internal/types"
+	"github.com/vultisig/vultisigner/relay"
+)
+
+// dklsProtocol runs vault operations with the DKLS wrapper
+type dklsProtocol struct {
+	worker *WorkerService
+}
+
+func (p *dklsProtocol) newService(localState *relay.LocalStateAccessorImp) (*DKLSTssService, error) {
+	if localState == nil {
+		var err error
+		localState, err = relay.NewLocalStateAccessorImp(p.worker.cfg.Server.VaultsFilePath, "", nil, p.worker.blockStorage)
+		if err != nil {
+			return nil, fmt.Errorf("relay.NewLocalStateAccessorImp failed: %w", err)
+		}
+	}
+	service, err := NewDKLSTssService(p.worker.cfg, p.worker.blockStorage, localState, p.worker, p.worker.vaultCache)
+	if err != nil {
+		return nil, fmt.Errorf("NewDKLSTssService failed: %w", err)
+	}
+	return service, nil
+}
+
+func (p *dklsProtocol) Keygen(req types.VaultCreateRequest) (string, string, error) {
+	service, err := p.newService(nil)
+	if err != nil {
+		return "", "", err
+	}
+	defer service.Wipe()
+	return service.ProceeDKLSKeygen(req)
+}
+
+func (p *dklsProtocol) Keysign(ctx context.Context, req types.KeysignRequest) (any, []string, error) {
+	service, err := p.newService(nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer service.Wipe()
+	signatures, partiesJoined, err := service.ProcessDKLSKeysign(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	if signatures.Failed > 0 {
+		p.worker.incCounter("worker.vault.sign.partial", []string{})
+	}
+	return signatures, partiesJoined, nil
+}
+
+func (p *dklsProtocol) Reshare(localState *relay.LocalStateAccessorImp, vault *vaultType.Vault, req types.ReshareRequest) error {
+	service, err := p.newService(localState)
+	if err != nil {
+		return err
+	}
+	return service.ProcessReshare(vault, req.SessionID, req.HexEncryptionKey, req.EncryptionPassword, req.Email, req.Threshold,
+		req.ExpectedParties, req.RevokedParty, req.BackupDeliveryOptions)
+}
+
+func (p *dklsProtocol) Refresh(localState *relay.LocalStateAccessorImp, req types.RefreshRequest) error {
+	service, err := p.newService(localState)
+	if err != nil {
+		return err
+	}
+	return service.ProcessRefresh(localState.Vault, req.SessionID, req.HexEncryptionKey, req.EncryptionPassword, req.Email)
+}
+
+func (p *dklsProtocol) Migrate(localState *relay.LocalStateAccessorImp, req types.MigrationRequest) error {
+	service, err := p.newService(localState)
+	if err != nil {
+		return err
+	}
+	return service.ProceeMigration(localState.Vault, req.SessionID, req.HexEncryptionKey, req.EncryptionPassword, req.Email, req.BackupDeliveryOptions)
+}
+
+func (p *dklsProtocol) Import(req types.KeyImportRequest) (string, string, error) {
+	service, err := p.newService(nil)
+	if err != nil {
+		return "", "", err
+	}
+	defer service.Wipe()
+	return service.ProcessKeyImport(req)
+}
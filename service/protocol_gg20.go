@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+
+	vaultType "github.com/vultisig/commondata/go/vultisig/vault/v1"
+
+	"github.com/vultisig/vultisigner/internal/protocol"
+	"github.com/vultisig/vultisigner/internal/types"
+	"github.com/vultisig/vultisigner/relay"
+)
+
+// gg20Protocol runs vault operations with mobile-tss-lib
+type gg20Protocol struct {
+	worker *WorkerService
+}
+
+func (p *gg20Protocol) Keygen(req types.VaultCreateRequest) (string, string, error) {
+	return p.worker.JoinKeyGeneration(req)
+}
+
+func (p *gg20Protocol) Keysign(_ context.Context, req types.KeysignRequest) (any, []string, error) {
+	return p.worker.JoinKeySign(req)
+}
+
+func (p *gg20Protocol) Reshare(_ *relay.LocalStateAccessorImp, vault *vaultType.Vault, req types.ReshareRequest) error {
+	return p.worker.Reshare(vault,
+		req.SessionID,
+		req.HexEncryptionKey,
+		p.worker.cfg.Relay.Server,
+		req.EncryptionPassword,
+		req.Email,
+		req.Threshold,
+		req.ExpectedParties,
+		req.RevokedParty,
+		req.BackupDeliveryOptions)
+}
+
+func (p *gg20Protocol) Refresh(_ *relay.LocalStateAccessorImp, _ types.RefreshRequest) error {
+	return protocol.ErrOperationNotSupported
+}
+
+func (p *gg20Protocol) Migrate(_ *relay.LocalStateAccessorImp, _ types.MigrationRequest) error {
+	return protocol.ErrOperationNotSupported
+}
+
+func (p *gg20Protocol) Import(_ types.KeyImportRequest) (string, string, error) {
+	return "", "", protocol.ErrOperationNotSupported
+}
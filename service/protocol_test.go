@@ -0,0 +1,22 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/vultisig/vultisigner/internal/protocol"
+)
+
+func TestEveryProtocolHasImplementation(t *testing.T) {
+	for _, libType := range protocol.LibTypes() {
+		info, err := protocol.Get(libType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := (&WorkerService{}).newProtocol(info); err != nil {
+			t.Error(err)
+		}
+	}
+	if len(protocolImplementations) != len(protocol.LibTypes()) {
+		t.Fatalf("%d implementations for %d protocols", len(protocolImplementations), len(protocol.LibTypes()))
+	}
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/hibiken/asynq"
+
+	"github.com/vultisig/vultisigner/internal/tasks"
+)
+
+// ReportQueueMetrics reports the depth , active tasks and latency of every queue as gauges until the context is done.
+// Autoscalers scale the workers on the gauges of a queue , like the keysign backlog. The live handles of the mpc library are reported along.
+func (s *WorkerService) ReportQueueMetrics(ctx context.Context, inspector *asynq.Inspector, interval time.Duration) {
+	queues := map[string]string{
+		tasks.QUEUE_NAME:       "default",
+		tasks.EMAIL_QUEUE_NAME: "email",
+	}
+	for operation := range s.cfg.Queues.Operations() {
+		queues[tasks.OperationQueue(operation)] = operation
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.reportQueues(inspector, queues)
+		s.reportMPCHandles()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *WorkerService) reportQueues(inspector *asynq.Inspector, queues map[string]string) {
+	existing, err := inspector.Queues()
+	if err != nil {
+		s.logger.Errorf("fail to list queues, err: %v", err)
+		return
+	}
+	for queue, operation := range queues {
+		info := &asynq.QueueInfo{Queue: queue} // nothing was enqueued to the queue yet
+		if slices.Contains(existing, queue) {
+			if info, err = inspector.GetQueueInfo(queue); err != nil {
+				s.logger.Errorf("fail to get queue info of %s, err: %v", queue, err)
+				continue
+			}
+		}
+		s.reportQueueMetrics(info, operation)
+	}
+}
+
+func (s *WorkerService) reportQueueMetrics(info *asynq.QueueInfo, operation string) {
+	tags := []string{"queue:" + info.Queue, "operation:" + operation}
+	s.gauge("worker.queue.depth", float64(info.Pending), tags)
+	s.gauge("worker.queue.active", float64(info.Active), tags)
+	s.gauge("worker.queue.scheduled", float64(info.Scheduled+info.Retry), tags)
+	s.gauge("worker.queue.latency", info.Latency.Seconds(), tags)
+}
+
+func (s *WorkerService) gauge(name string, value float64, tags []string) {
+	if err := s.sdClient.Gauge(name, value, tags, 1); err != nil {
+		s.logger.Errorf("fail to report gauge metric, err: %v", err)
+	}
+}
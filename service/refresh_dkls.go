@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	vaultType "github.com/vultisig/commondata/go/vultisig/vault/v1"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/vultisig/vultisigner/common"
+	"github.com/vultisig/vultisigner/internal/types"
+	"github.com/vultisig/vultisigner/relay"
+)
+
+// ProcessRefresh joins a key refresh session , the key shares are re-randomized while the public keys and chain code stay the same
+func (t *DKLSTssService) ProcessRefresh(vault *vaultType.Vault,
+	sessionID string,
+	hexEncryptionKey string,
+	encryptionPassword *common.Secret,
+	email string) error {
+	if vault.LocalPartyId == "" {
+		return fmt.Errorf("local party id is empty")
+	}
+	if vault.PublicKeyEcdsa == "" || vault.PublicKeyEddsa == "" {
+		return fmt.Errorf("vault public keys are empty")
+	}
+	localPartyID := vault.LocalPartyId
+	client := relay.NewRelayClient(t.cfg.Relay.Server)
+	if err := client.RegisterSession(sessionID, localPartyID); err != nil {
+		return fmt.Errorf("failed to register session: %w", err)
+	}
+	if err := t.startPairwiseEncryption(client, sessionID, localPartyID, hexEncryptionKey); err != nil {
+		return err
+	}
+	partiesJoined, err := waitForSession(t.logger, client, sessionID, 5*time.Minute)
+	if err != nil {
+		return err
+	}
+	if err := t.blockStorage.CheckRevokedParties(vault.PublicKeyEcdsa, partiesJoined); err != nil {
+		return err
+	}
+	if err := t.exchangePairwiseKeys(client, sessionID, partiesJoined, hexEncryptionKey, vault.PublicKeyEcdsa); err != nil {
+		return err
+	}
+	t.logger.Infof("start refresh ecdsa")
+	if err := t.refreshWithRetry(sessionID, hexEncryptionKey, localPartyID, vault.PublicKeyEcdsa, vault.HexChainCode, false, partiesJoined); err != nil {
+		return fmt.Errorf("failed to refresh ECDSA: %w", err)
+	}
+	t.logger.Infof("start refresh eddsa")
+	if err := t.refreshWithRetry(sessionID, hexEncryptionKey, localPartyID, vault.PublicKeyEddsa, "", true, partiesJoined); err != nil {
+		return fmt.Errorf("failed to refresh EDDSA: %w", err)
+	}
+	isCompleted := finishSession(t.logger, client, sessionID, localPartyID, partiesJoined)
+	if t.backup == nil {
+		t.logger.Infof("Backup is disabled")
+		return nil
+	}
+	ecdsaKeyShare, err := t.localStateAccessor.GetLocalCacheState(vault.PublicKeyEcdsa)
+	if err != nil {
+		return fmt.Errorf("failed to get local sate: %w", err)
+	}
+	defer ecdsaKeyShare.Wipe()
+	eddsaKeyShare, err := t.localStateAccessor.GetLocalCacheState(vault.PublicKeyEddsa)
+	if err != nil {
+		return fmt.Errorf("failed to get local sate: %w", err)
+	}
+	defer eddsaKeyShare.Wipe()
+	if ecdsaKeyShare.Len() == 0 || eddsaKeyShare.Len() == 0 {
+		return fmt.Errorf("failed to get refreshed keyshares")
+	}
+	// the refreshed keyshares replace the key shares of the vault
+	newVault := proto.Clone(vault).(*vaultType.Vault)
+	newVault.KeyShares = nil
+	keyshares := []common.VaultKeyshare{
+		{PublicKey: vault.PublicKeyEcdsa, Keyshare: ecdsaKeyShare},
+		{PublicKey: vault.PublicKeyEddsa, Keyshare: eddsaKeyShare},
+	}
+	threshold, err := t.blockStorage.GetVaultThreshold(vault)
+	if err != nil {
+		return fmt.Errorf("failed to get vault threshold: %w", err)
+	}
+	revokedParties, err := t.blockStorage.GetRevokedParties(vault.PublicKeyEcdsa)
+	if err != nil {
+		return fmt.Errorf("failed to get revoked parties: %w", err)
+	}
+	return t.backup.SaveVaultShare(newVault, keyshares, threshold, revokedParties, encryptionPassword, email, types.BackupDeliveryOptions{}, sessionID, partiesJoined, isCompleted)
+}
+
+func (t *DKLSTssService) refreshWithRetry(sessionID string,
+	hexEncryptionKey string,
+	localPartyID string,
+	publicKey string,
+	hexChainCode string,
+	isEdDSA bool,
+	committee []string) error {
+	for attempt := 0; attempt < 3; attempt++ {
+		err := t.refresh(sessionID, hexEncryptionKey, localPartyID, publicKey, hexChainCode, isEdDSA, committee, attempt)
+		if err == nil {
+			return nil
+		}
+		t.logger.WithFields(logrus.Fields{
+			"session_id":     sessionID,
+			"local_party_id": localPartyID,
+			"attempt":        attempt,
+		}).Error(err)
+		time.Sleep(50 * time.Millisecond)
+	}
+	return fmt.Errorf("fail to refresh after max retry")
+}
+
+func (t *DKLSTssService) refresh(sessionID string,
+	hexEncryptionKey string,
+	localPartyID string,
+	publicKey string,
+	hexChainCode string,
+	isEdDSA bool,
+	committee []string,
+	attempt int) error {
+	t.logger.WithFields(logrus.Fields{
+		"session_id":        sessionID,
+		"public_key":        publicKey,
+		"refresh_committee": committee,
+		"attempt":           attempt,
+	}).Info("Refresh")
+	mpcWrapper := t.GetMPCKeygenWrapper(isEdDSA)
+	keyshare, err := t.localStateAccessor.GetKeyshare(publicKey)
+	if err != nil {
+		return fmt.Errorf("failed to get keyshare: %w", err)
+	}
+	keyshareHandle, err := mpcWrapper.KeyshareFromBytes(keyshare.Bytes())
+	keyshare.Wipe()
+	if err != nil {
+		return fmt.Errorf("failed to create keyshare from bytes: %w", err)
+	}
+	defer func() {
+		if err := mpcWrapper.KeyshareFree(keyshareHandle); err != nil {
+			t.logger.Error("failed to free keyshare", "error", err)
+		}
+	}()
+	relayClient := relay.NewRelayClient(t.cfg.Relay.Server)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	// the EdDSA refresh uses its own setup message, same as reshare
+	additionalHeader := ""
+	if isEdDSA {
+		additionalHeader = "eddsa"
+	}
+	encryptedEncodedSetupMsg, err := relayClient.WaitForSetupMessage(ctx, sessionID, additionalHeader)
+	if err != nil {
+		return fmt.Errorf("failed to get setup message: %w", err)
+	}
+	setupMessageBytes, err := t.decodeDecryptMessage(encryptedEncodedSetupMsg, hexEncryptionKey)
+	if err != nil {
+		return fmt.Errorf("failed to decode setup message: %w", err)
+	}
+	handle, err := mpcWrapper.KeyRefreshSessionFromSetup(setupMessageBytes, []byte(localPartyID), keyshareHandle)
+	if err != nil {
+		return fmt.Errorf("failed to create refresh session from setup message: %w", err)
+	}
+	defer func() {
+		if err := mpcWrapper.KeygenSessionFree(handle); err != nil {
+			t.logger.Error("failed to free refresh session", "error", err)
+		}
+	}()
+	newPublicKey, chainCode, err := t.runKeygenSession(handle, sessionID, hexEncryptionKey, committee, localPartyID, isEdDSA, "")
+	if err != nil {
+		return err
+	}
+	return checkRefreshedKey(publicKey, hexChainCode, newPublicKey, chainCode, isEdDSA)
+}
+
+// checkRefreshedKey fails when the refresh changed the public key , or the chain code of the ECDSA key
+func checkRefreshedKey(publicKey, hexChainCode, newPublicKey, newChainCode string, isEdDSA bool) error {
+	if newPublicKey != publicKey {
+		return fmt.Errorf("refresh changed public key from %s to %s", publicKey, newPublicKey)
+	}
+	if !isEdDSA && newChainCode != hexChainCode {
+		return fmt.Errorf("refresh changed chain code")
+	}
+	return nil
+}
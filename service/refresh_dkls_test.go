@@ -0,0 +1,37 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckRefreshedKey(t *testing.T) {
+	tests := map[string]struct {
+		newPublicKey string
+		newChainCode string
+		isEdDSA      bool
+		err          string
+	}{
+		"unchanged":                  {newPublicKey: testPublicKey, newChainCode: "chain-code"},
+		"public key changed":         {newPublicKey: "other", newChainCode: "chain-code", err: "changed public key"},
+		"chain code changed":         {newPublicKey: testPublicKey, newChainCode: "other", err: "changed chain code"},
+		"EdDSA without chain code":   {newPublicKey: testPublicKey, isEdDSA: true},
+		"EdDSA public key changed":   {newPublicKey: "other", isEdDSA: true, err: "changed public key"},
+		"ECDSA chain code went away": {newPublicKey: testPublicKey, err: "changed chain code"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			hexChainCode := "chain-code"
+			if test.isEdDSA {
+				hexChainCode = ""
+			}
+			err := checkRefreshedKey(testPublicKey, hexChainCode, test.newPublicKey, test.newChainCode, test.isEdDSA)
+			if test.err == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+				t.Fatalf("expected %q, got %v", test.err, err)
+			}
+		})
+	}
+}
@@ -2,8 +2,7 @@ package service
 
 import (
 	"context"
-	"encoding/base64"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"math/rand"
 	"os"
@@ -12,16 +11,13 @@ import (
 	"strings"
 	"time"
 
-	"github.com/hibiken/asynq"
 	"github.com/sirupsen/logrus"
 	keygenType "github.com/vultisig/commondata/go/vultisig/keygen/v1"
 	vaultType "github.com/vultisig/commondata/go/vultisig/vault/v1"
 	mtss "github.com/vultisig/mobile-tss-lib/tss"
-	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
 	"github.com/vultisig/vultisigner/common"
-	"github.com/vultisig/vultisigner/internal/tasks"
 	"github.com/vultisig/vultisigner/internal/types"
 	"github.com/vultisig/vultisigner/relay"
 )
@@ -30,7 +26,11 @@ func (s *WorkerService) Reshare(vault *vaultType.Vault,
 	sessionID,
 	hexEncryptionKey,
 	serverURL string,
-	encryptionPassword string, email string) error {
+	encryptionPassword *common.Secret, email string,
+	threshold int,
+	expectedParties []string,
+	revokedParty string,
+	delivery types.BackupDeliveryOptions) error {
 	if vault.Name == "" {
 		return fmt.Errorf("vault name is empty")
 	}
@@ -48,22 +48,25 @@ func (s *WorkerService) Reshare(vault *vaultType.Vault,
 	if err := client.RegisterSession(sessionID, vault.LocalPartyId); err != nil {
 		return fmt.Errorf("failed to register session: %w", err)
 	}
-	// wait longer for keygen start
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-	defer cancel()
-
-	partiesJoined, err := client.WaitForSessionStart(ctx, sessionID)
-	s.logger.WithFields(logrus.Fields{
-		"session":        sessionID,
-		"parties_joined": partiesJoined,
-	}).Info("Session started")
+	partiesJoined, err := waitForSession(s.logger, client, sessionID, 5*time.Minute)
+	if err != nil {
+		return err
+	}
+	if err := checkCommittee(expectedParties, partiesJoined); err != nil {
+		return err
+	}
+	if err := s.blockStorage.CheckRevokedParties(vault.PublicKeyEcdsa, partiesJoined); err != nil {
+		return err
+	}
+	threshold, err = checkGG20Threshold(threshold, len(partiesJoined))
 	if err != nil {
-		return fmt.Errorf("failed to wait for session start: %w", err)
+		return err
 	}
 	localStateAccessor, err := relay.NewLocalStateAccessorImp(s.cfg.Server.VaultsFilePath, vault.PublicKeyEcdsa, encryptionPassword, s.blockStorage)
 	if err != nil {
 		return fmt.Errorf("failed to create localStateAccessor: %w", err)
 	}
+	defer localStateAccessor.Wipe()
 
 	tssServerImp, err := s.createTSSService(serverURL, sessionID, hexEncryptionKey, localStateAccessor, true, "")
 	if err != nil {
@@ -75,10 +78,11 @@ func (s *WorkerService) Reshare(vault *vaultType.Vault,
 	for attempt := 0; attempt < 3; attempt++ {
 		ecdsaPubkey, eddsaPubkey, newResharePrefix, err = s.reshareWithRetry(
 			tssServerImp,
+			sessionID,
 			vault,
 			partiesJoined,
 		)
-		if err == nil {
+		if err == nil || errors.Is(err, relay.ErrSessionCancelled) {
 			break
 		}
 		s.logger.WithFields(logrus.Fields{
@@ -93,33 +97,22 @@ func (s *WorkerService) Reshare(vault *vaultType.Vault,
 		return err
 	}
 
-	if err := client.CompleteSession(sessionID, localPartyID); err != nil {
-		s.logger.WithFields(logrus.Fields{
-			"session": sessionID,
-			"error":   err,
-		}).Error("Failed to complete session")
-	}
-
-	if isCompleted, err := client.CheckCompletedParties(sessionID, partiesJoined); err != nil || !isCompleted {
-		s.logger.WithFields(logrus.Fields{
-			"session":     sessionID,
-			"isCompleted": isCompleted,
-			"error":       err,
-		}).Error("Failed to check completed parties")
-	}
+	isCompleted := finishSession(s.logger, client, sessionID, localPartyID, partiesJoined)
 
 	ecdsaKeyShare, err := localStateAccessor.GetLocalCacheState(ecdsaPubkey)
 	if err != nil {
 		return fmt.Errorf("failed to get local sate: %w", err)
 	}
-	if ecdsaKeyShare == "" {
+	defer ecdsaKeyShare.Wipe()
+	if ecdsaKeyShare.Len() == 0 {
 		return fmt.Errorf("ecdsaKeyShare is empty")
 	}
 	eddsaKeyShare, err := localStateAccessor.GetLocalCacheState(eddsaPubkey)
 	if err != nil {
 		return fmt.Errorf("failed to get local sate: %w", err)
 	}
-	if eddsaKeyShare == "" {
+	defer eddsaKeyShare.Wipe()
+	if eddsaKeyShare.Len() == 0 {
 		return fmt.Errorf("eddsaKeyShare is empty")
 	}
 
@@ -130,21 +123,19 @@ func (s *WorkerService) Reshare(vault *vaultType.Vault,
 		Signers:        partiesJoined,
 		CreatedAt:      timestamppb.Now(),
 		HexChainCode:   vault.HexChainCode,
-		KeyShares: []*vaultType.Vault_KeyShare{
-			{
-				PublicKey: ecdsaPubkey,
-				Keyshare:  ecdsaKeyShare,
-			},
-			{
-				PublicKey: eddsaPubkey,
-				Keyshare:  eddsaKeyShare,
-			},
-		},
-		LocalPartyId:  vault.LocalPartyId,
-		LibType:       keygenType.LibType_LIB_TYPE_GG20,
-		ResharePrefix: newResharePrefix,
-	}
-	return s.SaveVaultAndScheduleEmail(newVault, encryptionPassword, email)
+		LocalPartyId:   vault.LocalPartyId,
+		LibType:        keygenType.LibType_LIB_TYPE_GG20,
+		ResharePrefix:  newResharePrefix,
+	}
+	revokedParties, err := reshareRevokedParties(s.blockStorage, vault.PublicKeyEcdsa, revokedParty)
+	if err != nil {
+		return err
+	}
+	keyshares := []common.VaultKeyshare{
+		{PublicKey: ecdsaPubkey, Keyshare: ecdsaKeyShare},
+		{PublicKey: eddsaPubkey, Keyshare: eddsaKeyShare},
+	}
+	return s.SaveVaultShare(newVault, keyshares, threshold, revokedParties, encryptionPassword, email, delivery, sessionID, partiesJoined, isCompleted)
 }
 func (s *WorkerService) createVerificationCode(publicKeyECDSA string) (string, error) {
 	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
@@ -157,61 +148,70 @@ func (s *WorkerService) createVerificationCode(publicKeyECDSA string) (string, e
 	}
 	return verificationCode, nil
 }
-func (s *WorkerService) SaveVaultAndScheduleEmail(vault *vaultType.Vault,
-	encryptionPassword string,
-	email string) error {
-	vaultData, err := proto.Marshal(vault)
-	if err != nil {
-		return fmt.Errorf("failed to Marshal vault: %w", err)
-	}
 
-	vaultData, err = common.EncryptVault(encryptionPassword, vaultData)
+// SaveVaultAndDeliverBackup saves the vault share , and delivers the backup to the owner through the selected channel
+func (s *WorkerService) SaveVaultAndDeliverBackup(vault *vaultType.Vault,
+	keyshares []common.VaultKeyshare,
+	threshold int,
+	revokedParties []string,
+	encryptionPassword *common.Secret,
+	email string,
+	delivery types.BackupDeliveryOptions) error {
+	content, metadata, err := s.prepareBackup(vault, keyshares, threshold, revokedParties, encryptionPassword, email, delivery)
 	if err != nil {
-		return fmt.Errorf("common.EncryptVault failed: %w", err)
+		return err
 	}
-
-	vaultBackup := &vaultType.VaultContainer{
-		Version:     1,
-		Vault:       base64.StdEncoding.EncodeToString(vaultData),
-		IsEncrypted: true,
+	if err := s.activateBackup(content, metadata, email, delivery); err != nil {
+		return err
 	}
-	filePathName := vault.PublicKeyEcdsa + ".bak"
+	s.discardPendingVault(vault.PublicKeyEcdsa)
+	return nil
+}
 
-	vaultBackupData, err := proto.Marshal(vaultBackup)
+// prepareBackup encrypts the vault share backup , and returns it with the vault index entry of the vault
+func (s *WorkerService) prepareBackup(vault *vaultType.Vault,
+	keyshares []common.VaultKeyshare,
+	threshold int,
+	revokedParties []string,
+	encryptionPassword *common.Secret,
+	email string,
+	delivery types.BackupDeliveryOptions) (string, types.VaultMetadata, error) {
+	pgpFingerprint, err := s.savePGPPublicKey(vault.PublicKeyEcdsa, delivery.PGPPublicKey)
 	if err != nil {
-		return fmt.Errorf("failed to Marshal vaultBackup: %w", err)
+		return "", types.VaultMetadata{}, fmt.Errorf("fail to save OpenPGP key: %w", err)
 	}
+	vaultBackupData, err := common.EncryptVaultToBackupSecret(encryptionPassword, 1, vault, keyshares)
+	if err != nil {
+		return "", types.VaultMetadata{}, fmt.Errorf("common.EncryptVaultToBackupSecret failed: %w", err)
+	}
+	return string(vaultBackupData), s.vaultMetadata(vault, threshold, revokedParties, email, pgpFingerprint), nil
+}
 
-	base64VaultContent := base64.StdEncoding.EncodeToString(vaultBackupData)
+// activateBackup saves the backup as the active vault share , and delivers it
+func (s *WorkerService) activateBackup(base64VaultContent string,
+	metadata types.VaultMetadata,
+	email string,
+	delivery types.BackupDeliveryOptions) error {
+	filePathName := metadata.PublicKeyEcdsa + ".bak"
 	if err := s.blockStorage.UploadFileWithRetry([]byte(base64VaultContent), filePathName, 5); err != nil {
 		if err := os.WriteFile(s.cfg.Server.VaultsFilePath+"/"+filePathName, []byte(base64VaultContent), 0644); err != nil {
 			s.logger.Errorf("fail to write file: %s", err)
 		}
 		return fmt.Errorf("fail to write file, err: %w", err)
 	}
-	code, err := s.createVerificationCode(vault.PublicKeyEcdsa)
-	if err != nil {
-		return fmt.Errorf("failed to create verification code: %w", err)
+	if err := s.vaultCache.Invalidate(context.Background(), metadata.PublicKeyEcdsa); err != nil {
+		s.logger.Errorf("fail to invalidate cached vault: %v", err)
 	}
-	emailRequest := types.EmailRequest{
-		Email:       email,
-		FileName:    common.GetVaultName(vault),
-		FileContent: base64VaultContent,
-		VaultName:   vault.Name,
-		Code:        code,
+	// the threshold and revoked parties of the new share apply from now on , sessions read them from the settings
+	if err := s.blockStorage.UpdateVaultSettings(metadata.PublicKeyEcdsa, func(settings *types.VaultSettings) {
+		settings.Threshold = metadata.Threshold
+		settings.RevokedParties = metadata.RevokedParties
+	}); err != nil {
+		return fmt.Errorf("fail to save vault settings: %w", err)
 	}
-	buf, err := json.Marshal(emailRequest)
-	if err != nil {
-		return fmt.Errorf("json.Marshal failed: %w", err)
-	}
-	taskInfo, err := s.queueClient.Enqueue(asynq.NewTask(tasks.TypeEmailVaultBackup, buf),
-		asynq.Retention(10*time.Minute),
-		asynq.Queue(tasks.EMAIL_QUEUE_NAME))
-	if err != nil {
-		s.logger.Errorf("fail to enqueue email task: %v", err)
-	}
-	s.logger.Info("Email task enqueued: ", taskInfo.ID)
-	return nil
+	s.indexVault(metadata)
+	s.scheduleRefreshReminder(context.Background(), metadata.PublicKeyEcdsa, metadata.Name, email)
+	return s.deliverBackup(metadata, base64VaultContent, email, delivery)
 }
 func getOldParties(newParties []string, oldSignerCommittee []string) []string {
 	oldParties := make([]string, 0)
@@ -224,18 +224,19 @@ func getOldParties(newParties []string, oldSignerCommittee []string) []string {
 }
 
 func (s *WorkerService) reshareWithRetry(tssService *mtss.ServiceImpl,
+	sessionID string,
 	vault *vaultType.Vault,
 	newParties []string,
 ) (string, string, string, error) {
 	oldParties := getOldParties(newParties, vault.Signers)
-	resp, err := s.reshareECDSAKey(tssService, vault.PublicKeyEcdsa, vault.LocalPartyId, vault.HexChainCode, vault.ResharePrefix,
+	resp, err := s.reshareECDSAKey(tssService, sessionID, vault.PublicKeyEcdsa, vault.LocalPartyId, vault.HexChainCode, vault.ResharePrefix,
 		newParties, oldParties)
 	if err != nil {
 		return "", "", "", fmt.Errorf("failed to reshare ECDSA key: %w", err)
 	}
 	newResharePrefix := resp.ResharePrefix
 	ecdsaPubkey := resp.PubKey
-	resp, err = s.reshareEDDSAKey(tssService, vault.PublicKeyEddsa, vault.LocalPartyId, vault.HexChainCode, vault.ResharePrefix,
+	resp, err = s.reshareEDDSAKey(tssService, sessionID, vault.PublicKeyEddsa, vault.LocalPartyId, vault.HexChainCode, vault.ResharePrefix,
 		newParties, oldParties, newResharePrefix)
 	if err != nil {
 		return "", "", "", fmt.Errorf("failed to reshare EDDSA key: %w", err)
@@ -245,6 +246,7 @@ func (s *WorkerService) reshareWithRetry(tssService *mtss.ServiceImpl,
 }
 
 func (s *WorkerService) reshareECDSAKey(tssService *mtss.ServiceImpl,
+	sessionID string,
 	publicKey string,
 	localPartyID, hexChainCode string,
 	resharePrefix string,
@@ -260,14 +262,16 @@ func (s *WorkerService) reshareECDSAKey(tssService *mtss.ServiceImpl,
 		"new_reshare_prefix": "",
 	}).Info("Start ECDSA reshare...")
 
-	resp, err := tssService.ReshareECDSA(&mtss.ReshareRequest{
-		PubKey:           publicKey,
-		LocalPartyID:     localPartyID,
-		NewParties:       strings.Join(partiesJoined, ","),
-		OldParties:       strings.Join(oldParties, ","),
-		ChainCodeHex:     hexChainCode,
-		ResharePrefix:    resharePrefix,
-		NewResharePrefix: "",
+	resp, err := runCeremony(sessionID, func() (*mtss.ReshareResponse, error) {
+		return tssService.ReshareECDSA(&mtss.ReshareRequest{
+			PubKey:           publicKey,
+			LocalPartyID:     localPartyID,
+			NewParties:       strings.Join(partiesJoined, ","),
+			OldParties:       strings.Join(oldParties, ","),
+			ChainCodeHex:     hexChainCode,
+			ResharePrefix:    resharePrefix,
+			NewResharePrefix: "",
+		})
 	})
 	if err != nil {
 		return nil, fmt.Errorf("fail to reshare ECDSA key: %w", err)
@@ -281,6 +285,7 @@ func (s *WorkerService) reshareECDSAKey(tssService *mtss.ServiceImpl,
 }
 
 func (s *WorkerService) reshareEDDSAKey(tssService *mtss.ServiceImpl,
+	sessionID string,
 	publicKey string,
 	localPartyID, hexChainCode string,
 	resharePrefix string,
@@ -296,14 +301,16 @@ func (s *WorkerService) reshareEDDSAKey(tssService *mtss.ServiceImpl,
 		"old_parties":        oldParties,
 		"new_reshare_prefix": newResharePrefix,
 	}).Info("Start EdDSA keygen...")
-	resp, err := tssService.ResharingEdDSA(&mtss.ReshareRequest{
-		PubKey:           publicKey,
-		LocalPartyID:     localPartyID,
-		NewParties:       strings.Join(partiesJoined, ","),
-		ChainCodeHex:     hexChainCode,
-		OldParties:       strings.Join(oldParties, ","),
-		ResharePrefix:    resharePrefix,
-		NewResharePrefix: newResharePrefix,
+	resp, err := runCeremony(sessionID, func() (*mtss.ReshareResponse, error) {
+		return tssService.ResharingEdDSA(&mtss.ReshareRequest{
+			PubKey:           publicKey,
+			LocalPartyID:     localPartyID,
+			NewParties:       strings.Join(partiesJoined, ","),
+			ChainCodeHex:     hexChainCode,
+			OldParties:       strings.Join(oldParties, ","),
+			ResharePrefix:    resharePrefix,
+			NewResharePrefix: newResharePrefix,
+		})
 	})
 	if err != nil {
 		return nil, fmt.Errorf("fail to reshare EdDSA key: %w", err)
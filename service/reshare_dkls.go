@@ -3,7 +3,6 @@ package service
 import (
 	"context"
 	"encoding/base64"
-	"encoding/hex"
 	"fmt"
 	"sync"
 	"time"
@@ -14,14 +13,19 @@ import (
 	"google.golang.org/protobuf/types/known/timestamppb"
 
 	"github.com/vultisig/vultisigner/common"
+	"github.com/vultisig/vultisigner/internal/types"
 	"github.com/vultisig/vultisigner/relay"
 )
 
 func (t *DKLSTssService) ProcessReshare(vault *vaultType.Vault,
 	sessionID string,
 	hexEncryptionKey string,
-	encryptionPassword string,
-	email string) error {
+	encryptionPassword *common.Secret,
+	email string,
+	threshold int,
+	expectedParties []string,
+	revokedParty string,
+	delivery types.BackupDeliveryOptions) error {
 	if vault.Name == "" {
 		return fmt.Errorf("vault name is empty")
 	}
@@ -37,44 +41,37 @@ func (t *DKLSTssService) ProcessReshare(vault *vaultType.Vault,
 	if err := client.RegisterSession(sessionID, vault.LocalPartyId); err != nil {
 		return fmt.Errorf("failed to register session: %w", err)
 	}
-	// wait longer for keygen start
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-	defer cancel()
-	partiesJoined, err := client.WaitForSessionStart(ctx, sessionID)
-	t.logger.WithFields(logrus.Fields{
-		"session":        sessionID,
-		"parties_joined": partiesJoined,
-	}).Info("Session started")
+	if err := t.startPairwiseEncryption(client, sessionID, vault.LocalPartyId, hexEncryptionKey); err != nil {
+		return err
+	}
+	partiesJoined, err := waitForSession(t.logger, client, sessionID, 5*time.Minute)
 	if err != nil {
-		return fmt.Errorf("failed to wait for session start: %w", err)
+		return err
+	}
+	if err := checkCommittee(expectedParties, partiesJoined); err != nil {
+		return err
 	}
-	if len(partiesJoined) == 0 {
-		return fmt.Errorf("keygen committee is empty")
+	if err := t.blockStorage.CheckRevokedParties(vault.PublicKeyEcdsa, partiesJoined); err != nil {
+		return err
+	}
+	threshold, err = common.ResolveThreshold(threshold, len(partiesJoined))
+	if err != nil {
+		return fmt.Errorf("invalid threshold: %w", err)
+	}
+	if err := t.exchangePairwiseKeys(client, sessionID, partiesJoined, hexEncryptionKey, vault.PublicKeyEcdsa); err != nil {
+		return err
 	}
 	t.logger.Infof("start reshare ecdsa")
-	ecdsaPubkey, chainCodeECDSA, err := t.reshareWithRetry(vault, sessionID, hexEncryptionKey, partiesJoined, vault.PublicKeyEcdsa, false)
+	ecdsaPubkey, chainCodeECDSA, err := t.reshareWithRetry(vault, sessionID, hexEncryptionKey, partiesJoined, vault.PublicKeyEcdsa, false, threshold)
 	if err != nil {
 		return fmt.Errorf("failed to reshare ECDSA: %w", err)
 	}
 	t.logger.Infof("start reshare eddsa")
-	eddsaPubkey, _, err := t.reshareWithRetry(vault, sessionID, hexEncryptionKey, partiesJoined, vault.PublicKeyEddsa, true)
+	eddsaPubkey, _, err := t.reshareWithRetry(vault, sessionID, hexEncryptionKey, partiesJoined, vault.PublicKeyEddsa, true, threshold)
 	if err != nil {
 		return fmt.Errorf("failed to reshare EDDSA: %w", err)
 	}
-	if err := client.CompleteSession(sessionID, localPartyID); err != nil {
-		t.logger.WithFields(logrus.Fields{
-			"session": sessionID,
-			"error":   err,
-		}).Error("Failed to complete session")
-	}
-
-	if isCompleted, err := client.CheckCompletedParties(sessionID, partiesJoined); err != nil || !isCompleted {
-		t.logger.WithFields(logrus.Fields{
-			"sessionID":   sessionID,
-			"isCompleted": isCompleted,
-			"error":       err,
-		}).Error("Failed to check completed parties")
-	}
+	isCompleted := finishSession(t.logger, client, sessionID, localPartyID, partiesJoined)
 	if t.backup == nil {
 		t.logger.Infof("Backup is disabled")
 		return nil
@@ -83,14 +80,16 @@ func (t *DKLSTssService) ProcessReshare(vault *vaultType.Vault,
 	if err != nil {
 		return fmt.Errorf("failed to get local sate: %w", err)
 	}
-	if ecdsaKeyShare == "" {
+	defer ecdsaKeyShare.Wipe()
+	if ecdsaKeyShare.Len() == 0 {
 		return fmt.Errorf("failed to get ecdsa keyshare")
 	}
 	eddsaKeyShare, err := t.localStateAccessor.GetLocalCacheState(eddsaPubkey)
 	if err != nil {
 		return fmt.Errorf("failed to get local sate: %w", err)
 	}
-	if eddsaKeyShare == "" {
+	defer eddsaKeyShare.Wipe()
+	if eddsaKeyShare.Len() == 0 {
 		return fmt.Errorf("failed to get eddsa keyshare")
 	}
 	newVault := &vaultType.Vault{
@@ -100,21 +99,19 @@ func (t *DKLSTssService) ProcessReshare(vault *vaultType.Vault,
 		Signers:        partiesJoined,
 		CreatedAt:      timestamppb.Now(),
 		HexChainCode:   chainCodeECDSA,
-		KeyShares: []*vaultType.Vault_KeyShare{
-			{
-				PublicKey: ecdsaPubkey,
-				Keyshare:  ecdsaKeyShare,
-			},
-			{
-				PublicKey: eddsaPubkey,
-				Keyshare:  eddsaKeyShare,
-			},
-		},
-		LocalPartyId:  vault.LocalPartyId,
-		LibType:       keygenType.LibType_LIB_TYPE_DKLS,
-		ResharePrefix: "",
-	}
-	return t.backup.SaveVaultAndScheduleEmail(newVault, encryptionPassword, email)
+		LocalPartyId:   vault.LocalPartyId,
+		LibType:        keygenType.LibType_LIB_TYPE_DKLS,
+		ResharePrefix:  "",
+	}
+	revokedParties, err := reshareRevokedParties(t.blockStorage, vault.PublicKeyEcdsa, revokedParty)
+	if err != nil {
+		return err
+	}
+	keyshares := []common.VaultKeyshare{
+		{PublicKey: ecdsaPubkey, Keyshare: ecdsaKeyShare},
+		{PublicKey: eddsaPubkey, Keyshare: eddsaKeyShare},
+	}
+	return t.backup.SaveVaultShare(newVault, keyshares, threshold, revokedParties, encryptionPassword, email, delivery, sessionID, partiesJoined, isCompleted)
 }
 func (t *DKLSTssService) reshareWithRetry(vault *vaultType.Vault,
 	sessionID string,
@@ -122,9 +119,10 @@ func (t *DKLSTssService) reshareWithRetry(vault *vaultType.Vault,
 	keygenCommittee []string,
 	publicKey string,
 	isEdDSA bool,
+	threshold int,
 ) (string, string, error) {
 	for attempt := 0; attempt < 3; attempt++ {
-		newPublicKey, chainCode, err := t.reshare(vault, sessionID, hexEncryptionKey, keygenCommittee, publicKey, isEdDSA, attempt)
+		newPublicKey, chainCode, err := t.reshare(vault, sessionID, hexEncryptionKey, keygenCommittee, publicKey, isEdDSA, threshold, attempt)
 		if err == nil {
 			return newPublicKey, chainCode, nil
 		}
@@ -138,6 +136,7 @@ func (t *DKLSTssService) reshare(vault *vaultType.Vault,
 	keygenCommittee []string,
 	publicKey string,
 	isEdDSA bool,
+	threshold int,
 	attempt int,
 ) (string, string, error) {
 	t.logger.
@@ -150,15 +149,12 @@ func (t *DKLSTssService) reshare(vault *vaultType.Vault,
 	t.isKeygenFinished.Store(false)
 	if len(publicKey) > 0 {
 		// we need to get the shares
-		keyshare, err := t.localStateAccessor.GetLocalState(publicKey)
+		keyshare, err := t.localStateAccessor.GetKeyshare(publicKey)
 		if err != nil {
 			return "", "", fmt.Errorf("failed to get keyshare: %w", err)
 		}
-		keyshareBytes, err := base64.StdEncoding.DecodeString(keyshare)
-		if err != nil {
-			return "", "", fmt.Errorf("failed to decode keyshare: %w", err)
-		}
-		keyshareHandle, err = mpcWrapper.KeyshareFromBytes(keyshareBytes)
+		keyshareHandle, err = mpcWrapper.KeyshareFromBytes(keyshare.Bytes())
+		keyshare.Wipe()
 		if err != nil {
 			return "", "", fmt.Errorf("failed to create keyshare from bytes: %w", err)
 		}
@@ -185,12 +181,21 @@ func (t *DKLSTssService) reshare(vault *vaultType.Vault,
 	if err != nil {
 		return "", "", fmt.Errorf("failed to decode setup message: %w", err)
 	}
+	if err := t.checkSetupCommittee(mpcWrapper, setupMessageBytes, threshold); err != nil {
+		return "", "", err
+	}
 	handle, err := mpcWrapper.QcSessionFromSetup(setupMessageBytes,
 		localPartyID,
 		keyshareHandle)
 	if err != nil {
 		return "", "", fmt.Errorf("failed to create session from setup message: %w", err)
 	}
+	defer func() {
+		// the quorum change session is a keygen session of the mpc library
+		if err := mpcWrapper.KeygenSessionFree(handle); err != nil {
+			t.logger.Error("failed to free reshare session", "error", err)
+		}
+	}()
 
 	wg := &sync.WaitGroup{}
 	wg.Add(2)
@@ -213,6 +218,7 @@ func (t *DKLSTssService) processQcOutbound(handle Handle,
 	wg *sync.WaitGroup) error {
 	defer wg.Done()
 	messenger := relay.NewMessenger(t.cfg.Relay.Server, sessionID, hexEncryptionKey, true, "")
+	messenger.SetPairwiseKeys(t.pairwiseKeys)
 	mpcKeygenWrapper := t.GetMPCKeygenWrapper(isEdDSA)
 	defer func() {
 		t.logger.Infof("finish processQcOutbound")
@@ -231,6 +237,7 @@ func (t *DKLSTssService) processQcOutbound(handle Handle,
 			continue
 		}
 		encodedOutbound := base64.StdEncoding.EncodeToString(outbound)
+		receivers := make([]string, 0, len(parties))
 		for i := 0; i < len(parties); i++ {
 			receiver, err := mpcKeygenWrapper.QcSessionMessageReceiver(handle, outbound, i)
 			if err != nil {
@@ -239,13 +246,9 @@ func (t *DKLSTssService) processQcOutbound(handle Handle,
 			if len(receiver) == 0 {
 				break
 			}
-
-			t.logger.Infoln("Sending message to", receiver)
-			// send the message to the receiver
-			if err := messenger.Send(localPartyID, receiver, encodedOutbound); err != nil {
-				t.logger.Errorf("failed to send message: %v", err)
-			}
+			receivers = append(receivers, receiver)
 		}
+		t.sendOutbound(messenger, localPartyID, receivers, encodedOutbound)
 	}
 }
 func (t *DKLSTssService) decodeDecryptMessage(body string, hexEncryptionKey string) ([]byte, error) {
@@ -257,6 +260,7 @@ func (t *DKLSTssService) decodeDecryptMessage(body string, hexEncryptionKey stri
 	if err != nil {
 		return nil, fmt.Errorf("fail to decrypt message: %w", err)
 	}
+	defer common.Wipe(rawBody)
 
 	inboundBody, err := base64.StdEncoding.DecodeString(string(rawBody))
 	if err != nil {
@@ -264,6 +268,20 @@ func (t *DKLSTssService) decodeDecryptMessage(body string, hexEncryptionKey stri
 	}
 	return inboundBody, nil
 }
+
+// decodeDecryptInboundMessage decrypts a message with the key shared with the sender once pairwise mode is negotiated ,
+// and with the session key otherwise. A pairwise session never accepts a message encrypted with the session key.
+func (t *DKLSTssService) decodeDecryptInboundMessage(message relay.Message, hexEncryptionKey string) ([]byte, error) {
+	if !t.pairwiseKeys.Negotiated() {
+		return t.decodeDecryptMessage(message.Body, hexEncryptionKey)
+	}
+	key, ok := t.pairwiseKeys.PeerKey(message.From)
+	if !ok {
+		return nil, fmt.Errorf("no pairwise key for %s", message.From)
+	}
+	return t.decodeDecryptMessage(message.Body, key)
+}
+
 func (t *DKLSTssService) processQcInbound(handle Handle,
 	sessionID string,
 	hexEncryptionKey string,
@@ -271,6 +289,8 @@ func (t *DKLSTssService) processQcInbound(handle Handle,
 	localPartyID string,
 	wg *sync.WaitGroup) (string, string, error) {
 	defer wg.Done()
+	// set isKeygenFinished to true on every return , so the other go routine can be stopped
+	defer t.isKeygenFinished.Store(true)
 	var messageCache sync.Map
 	mpcWrapper := t.GetMPCKeygenWrapper(isEdDSA)
 	relayClient := relay.NewRelayClient(t.cfg.Relay.Server)
@@ -278,9 +298,10 @@ func (t *DKLSTssService) processQcInbound(handle Handle,
 	for {
 		select {
 		case <-time.After(time.Millisecond * 100):
+			if err := relay.SessionCancelled(sessionID); err != nil {
+				return "", "", err
+			}
 			if time.Since(start) > time.Minute {
-				// set isKeygenFinished to true , so the other go routine can be stopped
-				t.isKeygenFinished.Store(true)
 				return "", "", TssKeyGenTimeout
 			}
 			messages, err := relayClient.DownloadMessages(sessionID, localPartyID, "")
@@ -298,7 +319,7 @@ func (t *DKLSTssService) processQcInbound(handle Handle,
 					t.logger.Infof("Message already applied, skipping,hash: %s", message.Hash)
 					continue
 				}
-				inboundBody, err := t.decodeDecryptMessage(message.Body, hexEncryptionKey)
+				inboundBody, err := t.decodeDecryptInboundMessage(message, hexEncryptionKey)
 				if err != nil {
 					t.logger.Error("fail to decode message", "error", err)
 					continue
@@ -320,34 +341,12 @@ func (t *DKLSTssService) processQcInbound(handle Handle,
 						t.logger.Error("fail to finish reshare", "error", err)
 						return "", "", err
 					}
-					buf, err := mpcWrapper.KeyshareToBytes(result)
-					if err != nil {
-						t.logger.Error("fail to convert keyshare to bytes", "error", err)
-						return "", "", err
-					}
-					encodedShare := base64.StdEncoding.EncodeToString(buf)
-					publicKeyBytes, err := mpcWrapper.KeysharePublicKey(result)
+					encodedPublicKey, chainCode, err := t.saveKeyshare(mpcWrapper, result, isEdDSA)
 					if err != nil {
-						t.logger.Error("fail to get public key", "error", err)
+						t.logger.Error("fail to save keyshare", "error", err)
 						return "", "", err
 					}
-					encodedPublicKey := hex.EncodeToString(publicKeyBytes)
 					t.logger.Infof("Public key: %s", encodedPublicKey)
-					chainCode := ""
-					if !isEdDSA {
-						chainCodeBytes, err := mpcWrapper.KeyshareChainCode(result)
-						if err != nil {
-							t.logger.Error("fail to get chain code", "error", err)
-							return "", "", err
-						}
-						chainCode = hex.EncodeToString(chainCodeBytes)
-					}
-					// This sleep give the local party a chance to send last message to others
-					t.isKeygenFinished.Store(true)
-					if err := t.localStateAccessor.SaveLocalState(encodedPublicKey, encodedShare); err != nil {
-						t.logger.Error("fail to save local state", "error", err)
-						return "", "", err
-					}
 					return encodedPublicKey, chainCode, nil
 				}
 			}
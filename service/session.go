@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	vaultType "github.com/vultisig/commondata/go/vultisig/vault/v1"
+
+	"github.com/vultisig/vultisigner/common"
+	"github.com/vultisig/vultisigner/internal/types"
+	"github.com/vultisig/vultisigner/relay"
+	"github.com/vultisig/vultisigner/storage"
+)
+
+// waitForSession waits until the session started , and returns the parties that joined it
+func waitForSession(logger *logrus.Logger, client *relay.Client, sessionID string, timeout time.Duration) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	partiesJoined, err := client.WaitForSessionStart(ctx, sessionID)
+	logger.WithFields(logrus.Fields{
+		"session":        sessionID,
+		"parties_joined": partiesJoined,
+	}).Info("Session started")
+	if err != nil {
+		return nil, fmt.Errorf("failed to wait for session start: %w", err)
+	}
+	if len(partiesJoined) == 0 {
+		return nil, fmt.Errorf("session committee is empty")
+	}
+	return partiesJoined, nil
+}
+
+// finishSession marks the local party as completed , and reports whether every party that joined completed too
+func finishSession(logger *logrus.Logger, client *relay.Client, sessionID, localPartyID string, partiesJoined []string) bool {
+	if err := client.CompleteSession(sessionID, localPartyID); err != nil {
+		logger.WithFields(logrus.Fields{
+			"session": sessionID,
+			"error":   err,
+		}).Error("Failed to complete session")
+	}
+	isCompleted, err := client.CheckCompletedParties(sessionID, partiesJoined)
+	if err != nil || !isCompleted {
+		logger.WithFields(logrus.Fields{
+			"session":     sessionID,
+			"isCompleted": isCompleted,
+			"error":       err,
+		}).Error("Failed to check completed parties")
+		return false
+	}
+	return true
+}
+
+// checkCommittee returns an error when the parties that joined aren't the expected committee , an empty committee accepts any parties
+func checkCommittee(expected, partiesJoined []string) error {
+	if len(expected) == 0 {
+		return nil
+	}
+	joined := slices.Clone(partiesJoined)
+	slices.Sort(joined)
+	committee := slices.Clone(expected)
+	slices.Sort(committee)
+	if !slices.Equal(joined, committee) {
+		return fmt.Errorf("parties %v joined , expected %v", partiesJoined, expected)
+	}
+	return nil
+}
+
+// reshareRevokedParties returns the revoked parties of the vault after a reshare , with the party the reshare revokes
+func reshareRevokedParties(blockStorage *storage.BlockStorage, publicKeyECDSA, revokedParty string) ([]string, error) {
+	revokedParties, err := blockStorage.GetRevokedParties(publicKeyECDSA)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get revoked parties: %w", err)
+	}
+	if revokedParty != "" && !slices.Contains(revokedParties, revokedParty) {
+		revokedParties = append(revokedParties, revokedParty)
+	}
+	return revokedParties, nil
+}
+
+// SaveVaultShare saves the new share of a reshare , migration or refresh.
+// When not every party completed , the share is staged as pending and the active share stays until the parties confirm.
+func (s *WorkerService) SaveVaultShare(vault *vaultType.Vault,
+	keyshares []common.VaultKeyshare,
+	threshold int,
+	revokedParties []string,
+	encryptionPassword *common.Secret,
+	email string,
+	delivery types.BackupDeliveryOptions,
+	sessionID string,
+	partiesJoined []string,
+	isCompleted bool) error {
+	if !isCompleted {
+		return s.StagePendingVault(vault, keyshares, threshold, revokedParties, encryptionPassword, email, delivery, sessionID, partiesJoined)
+	}
+	return s.SaveVaultAndDeliverBackup(vault, keyshares, threshold, revokedParties, encryptionPassword, email, delivery)
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hibiken/asynq"
+	"github.com/sirupsen/logrus"
+
+	"github.com/vultisig/vultisigner/internal/types"
+	"github.com/vultisig/vultisigner/relay"
+)
+
+// SessionJoined marks the session joined once the worker registered in the relay session
+func (s *WorkerService) SessionJoined(sessionID string) {
+	s.updateSessionStatus(sessionID, types.SessionJoined, "")
+}
+
+// SessionStarted marks the session running once all parties joined
+func (s *WorkerService) SessionStarted(sessionID string, _ []string) {
+	s.updateSessionStatus(sessionID, types.SessionRunning, "")
+}
+
+// runSession runs the task of a session , session tasks use the session id as task id.
+// Waits of the session stop when the task is cancelled , then the relay session is ended.
+func (s *WorkerService) runSession(ctx context.Context, handler func() error) error {
+	sessionID, ok := asynq.GetTaskID(ctx)
+	if !ok {
+		return handler()
+	}
+	if s.isSessionCancelled(sessionID) {
+		// cancelled while the worker picked the task up
+		return fmt.Errorf("session cancelled: %w", asynq.SkipRetry)
+	}
+	release := relay.BindSession(ctx, sessionID)
+	defer release()
+	err := handler()
+	if ctx.Err() != nil && s.isSessionCancelled(sessionID) {
+		s.incCounter("worker.session.cancelled", []string{})
+		s.logger.WithFields(logrus.Fields{
+			"session": sessionID,
+			"error":   err,
+		}).Info("session cancelled")
+		if err := relay.NewRelayClient(s.cfg.Relay.Server).EndSession(sessionID); err != nil {
+			s.logger.Errorf("fail to end session %s: %v", sessionID, err)
+		}
+		return fmt.Errorf("session cancelled: %w", asynq.SkipRetry)
+	}
+	s.finishSession(sessionID, err)
+	return err
+}
+
+func (s *WorkerService) isSessionCancelled(sessionID string) bool {
+	state, err := s.redis.GetSessionState(context.Background(), sessionID)
+	if err != nil {
+		return false
+	}
+	return state.Status == types.SessionCancelled
+}
+
+// finishSession marks the session completed or failed
+func (s *WorkerService) finishSession(sessionID string, err error) {
+	if err != nil {
+		s.updateSessionStatus(sessionID, types.SessionFailed, err.Error())
+		return
+	}
+	s.updateSessionStatus(sessionID, types.SessionCompleted, "")
+}
+
+func (s *WorkerService) updateSessionStatus(sessionID string, status types.SessionStatus, reason string) {
+	updated, err := s.redis.UpdateSessionStatus(context.Background(), sessionID, status, reason)
+	if err != nil {
+		s.logger.Errorf("fail to update session %s: %v", sessionID, err)
+		return
+	}
+	if updated {
+		s.logger.WithFields(logrus.Fields{
+			"session": sessionID,
+			"status":  status,
+		}).Info("session status updated")
+	}
+}
@@ -0,0 +1,26 @@
+package service
+
+import "testing"
+
+func TestCheckCommittee(t *testing.T) {
+	tests := map[string]struct {
+		expected      []string
+		partiesJoined []string
+		valid         bool
+	}{
+		"any committee":      {partiesJoined: []string{"server", "device-1"}, valid: true},
+		"expected committee": {expected: []string{"server", "device-1", "device-3"}, partiesJoined: []string{"device-3", "server", "device-1"}, valid: true},
+		"other party":        {expected: []string{"server", "device-1", "device-3"}, partiesJoined: []string{"server", "device-1", "device-4"}},
+		"missing party":      {expected: []string{"server", "device-1", "device-3"}, partiesJoined: []string{"server", "device-1"}},
+		"duplicate party":    {expected: []string{"server", "device-1", "device-3"}, partiesJoined: []string{"server", "device-1", "device-1"}},
+		"extra party":        {expected: []string{"server", "device-3"}, partiesJoined: []string{"server", "device-2", "device-3"}},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := checkCommittee(test.expected, test.partiesJoined)
+			if test.valid != (err == nil) {
+				t.Fatalf("expected valid %v , got %v", test.valid, err)
+			}
+		})
+	}
+}
@@ -18,15 +18,17 @@ import (
 	"github.com/vultisig/mobile-tss-lib/tss"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
+	"github.com/vultisig/vultisigner/common"
 	"github.com/vultisig/vultisigner/internal/types"
 	"github.com/vultisig/vultisigner/relay"
+	"github.com/vultisig/vultisigner/storage"
 
 	vaultType "github.com/vultisig/commondata/go/vultisig/vault/v1"
 )
 
 type VaultOperation interface {
 	BackupVault(req types.VaultCreateRequest, partiesJoined []string, ecdsaPubkey, eddsaPubkey, hexChainCode string, localStateAccessor *relay.LocalStateAccessorImp) error
-	SaveVaultAndScheduleEmail(vault *vaultType.Vault, encryptionPassword, email string) error
+	SaveVaultShare(vault *vaultType.Vault, keyshares []common.VaultKeyshare, threshold int, revokedParties []string, encryptionPassword *common.Secret, email string, delivery types.BackupDeliveryOptions, sessionID string, partiesJoined []string, isCompleted bool) error
 }
 
 func (s *WorkerService) JoinKeyGeneration(req types.VaultCreateRequest) (string, string, error) {
@@ -38,24 +40,19 @@ func (s *WorkerService) JoinKeyGeneration(req types.VaultCreateRequest) (string,
 	if err := relayClient.RegisterSession(req.SessionID, req.LocalPartyId); err != nil {
 		return "", "", fmt.Errorf("failed to register session: %w", err)
 	}
-	// wait longer for keygen start
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-	defer cancel()
-
-	partiesJoined, err := relayClient.WaitForSessionStart(ctx, req.SessionID)
-	s.logger.WithFields(logrus.Fields{
-		"sessionID":      req.SessionID,
-		"parties_joined": partiesJoined,
-	}).Info("Session started")
-
+	partiesJoined, err := waitForSession(s.logger, relayClient, req.SessionID, 5*time.Minute)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to wait for session start: %w", err)
+		return "", "", err
+	}
+	if _, err := checkGG20Threshold(req.Threshold, len(partiesJoined)); err != nil {
+		return "", "", err
 	}
 
-	localStateAccessor, err := relay.NewLocalStateAccessorImp(keyFolder, "", "", s.blockStorage)
+	localStateAccessor, err := relay.NewLocalStateAccessorImp(keyFolder, "", nil, s.blockStorage)
 	if err != nil {
 		return "", "", fmt.Errorf("failed to create localStateAccessor: %w", err)
 	}
+	defer localStateAccessor.Wipe()
 
 	tssServerImp, err := s.createTSSService(serverURL, req.SessionID, req.HexEncryptionKey, localStateAccessor, true, "")
 	if err != nil {
@@ -66,7 +63,7 @@ func (s *WorkerService) JoinKeyGeneration(req types.VaultCreateRequest) (string,
 	endCh, wg := s.startMessageDownload(serverURL, req.SessionID, req.LocalPartyId, req.HexEncryptionKey, tssServerImp, "")
 	for attempt := 0; attempt < 3; attempt++ {
 		ecdsaPubkey, eddsaPubkey, err = s.keygenWithRetry(req, partiesJoined, tssServerImp)
-		if err == nil {
+		if err == nil || errors.Is(err, relay.ErrSessionCancelled) {
 			break
 		}
 	}
@@ -76,20 +73,7 @@ func (s *WorkerService) JoinKeyGeneration(req types.VaultCreateRequest) (string,
 		return "", "", err
 	}
 
-	if err := relayClient.CompleteSession(req.SessionID, req.LocalPartyId); err != nil {
-		s.logger.WithFields(logrus.Fields{
-			"session": req.SessionID,
-			"error":   err,
-		}).Error("Failed to complete session")
-	}
-
-	if isCompleted, err := relayClient.CheckCompletedParties(req.SessionID, partiesJoined); err != nil || !isCompleted {
-		s.logger.WithFields(logrus.Fields{
-			"sessionID":   req.SessionID,
-			"isCompleted": isCompleted,
-			"error":       err,
-		}).Error("Failed to check completed parties")
-	}
+	finishSession(s.logger, relayClient, req.SessionID, req.LocalPartyId, partiesJoined)
 
 	err = s.BackupVault(req, partiesJoined, ecdsaPubkey, eddsaPubkey, req.HexChainCode, localStateAccessor)
 	if err != nil {
@@ -119,10 +103,12 @@ func (s *WorkerService) generateECDSAKey(tssService tss.Service, req types.Vault
 		"chain_code":     req.HexChainCode,
 		"parties_joined": partiesJoined,
 	}).Info("Start ECDSA keygen...")
-	resp, err := tssService.KeygenECDSA(&tss.KeygenRequest{
-		LocalPartyID: req.LocalPartyId,
-		AllParties:   strings.Join(partiesJoined, ","),
-		ChainCodeHex: req.HexChainCode,
+	resp, err := runCeremony(req.SessionID, func() (*tss.KeygenResponse, error) {
+		return tssService.KeygenECDSA(&tss.KeygenRequest{
+			LocalPartyID: req.LocalPartyId,
+			AllParties:   strings.Join(partiesJoined, ","),
+			ChainCodeHex: req.HexChainCode,
+		})
 	})
 	if err != nil {
 		return nil, fmt.Errorf("generate ECDSA key: %w", err)
@@ -142,10 +128,12 @@ func (s *WorkerService) generateEDDSAKey(tssService tss.Service, req types.Vault
 		"chain_code":     req.HexChainCode,
 		"parties_joined": partiesJoined,
 	}).Info("Start EDDSA keygen...")
-	resp, err := tssService.KeygenEdDSA(&tss.KeygenRequest{
-		LocalPartyID: req.LocalPartyId,
-		AllParties:   strings.Join(partiesJoined, ","),
-		ChainCodeHex: req.HexChainCode,
+	resp, err := runCeremony(req.SessionID, func() (*tss.KeygenResponse, error) {
+		return tssService.KeygenEdDSA(&tss.KeygenRequest{
+			LocalPartyID: req.LocalPartyId,
+			AllParties:   strings.Join(partiesJoined, ","),
+			ChainCodeHex: req.HexChainCode,
+		})
 	})
 	if err != nil {
 		return nil, fmt.Errorf("generate EDDSA key: %w", err)
@@ -163,15 +151,27 @@ func (s *WorkerService) BackupVault(req types.VaultCreateRequest,
 	ecdsaPubkey, eddsaPubkey string,
 	hexChainCode string,
 	localStateAccessor *relay.LocalStateAccessorImp) error {
-	ecdsaKeyShare, err := localStateAccessor.GetLocalState(ecdsaPubkey)
+	// a new vault never replaces the vault share of an existing vault , its funds would be lost with the old share
+	if exist, _ := s.blockStorage.FileExist(ecdsaPubkey + ".bak"); exist {
+		return fmt.Errorf("vault %s already exists , refusing to overwrite its vault share", ecdsaPubkey)
+	}
+	ecdsaKeyShare, err := localStateAccessor.GetLocalCacheState(ecdsaPubkey)
 	if err != nil {
 		return fmt.Errorf("failed to get local sate: %w", err)
 	}
+	defer ecdsaKeyShare.Wipe()
+	if ecdsaKeyShare.Len() == 0 {
+		return fmt.Errorf("ecdsaKeyShare is empty")
+	}
 
-	eddsaKeyShare, err := localStateAccessor.GetLocalState(eddsaPubkey)
+	eddsaKeyShare, err := localStateAccessor.GetLocalCacheState(eddsaPubkey)
 	if err != nil {
 		return fmt.Errorf("failed to get local sate: %w", err)
 	}
+	defer eddsaKeyShare.Wipe()
+	if eddsaKeyShare.Len() == 0 {
+		return fmt.Errorf("eddsaKeyShare is empty")
+	}
 
 	vault := &vaultType.Vault{
 		Name:           req.Name,
@@ -180,25 +180,50 @@ func (s *WorkerService) BackupVault(req types.VaultCreateRequest,
 		Signers:        partiesJoined,
 		CreatedAt:      timestamppb.New(time.Now()),
 		HexChainCode:   hexChainCode,
-		KeyShares: []*vaultType.Vault_KeyShare{
-			{
-				PublicKey: ecdsaPubkey,
-				Keyshare:  ecdsaKeyShare,
-			},
-			{
-				PublicKey: eddsaPubkey,
-				Keyshare:  eddsaKeyShare,
-			},
-		},
-		LocalPartyId:  req.LocalPartyId,
-		ResharePrefix: "",
+		LocalPartyId:   req.LocalPartyId,
+		ResharePrefix:  "",
 	}
 	if req.LibType == types.DKLS {
 		vault.LibType = keygen.LibType_LIB_TYPE_DKLS
 	} else {
 		vault.LibType = keygen.LibType_LIB_TYPE_GG20
 	}
-	return s.SaveVaultAndScheduleEmail(vault, req.EncryptionPassword, req.Email)
+	threshold, err := common.ResolveThreshold(req.Threshold, len(partiesJoined))
+	if err != nil {
+		return fmt.Errorf("invalid threshold: %w", err)
+	}
+	keyshares := []common.VaultKeyshare{
+		{PublicKey: ecdsaPubkey, Keyshare: ecdsaKeyShare},
+		{PublicKey: eddsaPubkey, Keyshare: eddsaKeyShare},
+	}
+	return s.SaveVaultAndDeliverBackup(vault, keyshares, threshold, nil, req.EncryptionPassword, req.Email, req.BackupDeliveryOptions)
+}
+
+// checkGG20Threshold returns the threshold GG20 derives from the committee size , GG20 can't use any other threshold
+func checkGG20Threshold(threshold, committeeSize int) (int, error) {
+	defaultThreshold, err := common.DefaultThreshold(committeeSize)
+	if err != nil {
+		return 0, fmt.Errorf("invalid committee size %d: %w", committeeSize, err)
+	}
+	if threshold != 0 && threshold != defaultThreshold {
+		return 0, fmt.Errorf("GG20 only supports threshold %d for %d parties", defaultThreshold, committeeSize)
+	}
+	return defaultThreshold, nil
+}
+
+// checkSigningThreshold refuses a signing session that a revoked party joined , or that doesn't have enough parties to reach the vault threshold
+func checkSigningThreshold(blockStorage *storage.BlockStorage, vault *vaultType.Vault, partiesJoined []string) error {
+	if err := blockStorage.CheckRevokedParties(vault.PublicKeyEcdsa, partiesJoined); err != nil {
+		return err
+	}
+	threshold, err := blockStorage.GetVaultThreshold(vault)
+	if err != nil {
+		return fmt.Errorf("failed to get vault threshold: %w", err)
+	}
+	if len(partiesJoined) < threshold {
+		return fmt.Errorf("%d parties joined, threshold requires %d", len(partiesJoined), threshold)
+	}
+	return nil
 }
 
 func (s *WorkerService) createTSSService(serverURL, Session, HexEncryptionKey string, localStateAccessor tss.LocalStateAccessor, createPreParam bool, messageID string) (*tss.ServiceImpl, error) {
@@ -210,6 +235,27 @@ func (s *WorkerService) createTSSService(serverURL, Session, HexEncryptionKey st
 	return tssService, nil
 }
 
+// runCeremony runs a GG20 ceremony of the session , and returns as soon as the session is cancelled.
+// mobile-tss-lib can't abort a ceremony , the abandoned ceremony times out once the relay session ended.
+func runCeremony[T any](sessionID string, ceremony func() (T, error)) (T, error) {
+	type result struct {
+		value T
+		err   error
+	}
+	resultCh := make(chan result, 1)
+	go func() {
+		value, err := ceremony()
+		resultCh <- result{value: value, err: err}
+	}()
+	select {
+	case r := <-resultCh:
+		return r.value, r.err
+	case <-relay.SessionDone(sessionID):
+		var zero T
+		return zero, relay.SessionCancelled(sessionID)
+	}
+}
+
 func (s *WorkerService) startMessageDownload(serverURL, session, key, hexEncryptionKey string, tssService tss.Service, messageID string) (chan struct{}, *sync.WaitGroup) {
 	s.logger.WithFields(logrus.Fields{
 		"session": session,
@@ -238,6 +284,11 @@ func (s *WorkerService) downloadMessages(server, session, localPartyID, hexEncry
 			logger.Info("Stop downloading messages")
 			return
 		case <-time.After(time.Second):
+			if err := relay.SessionCancelled(session); err != nil {
+				// the ceremony times out without messages
+				logger.Info("Session cancelled , stop downloading messages")
+				return
+			}
 			messages, err := relayClient.DownloadMessages(session, localPartyID, messageID)
 			if err != nil {
 				logger.Errorf("Failed to get messages: %v", err)
@@ -336,13 +387,18 @@ func unpad(data []byte) ([]byte, error) {
 	return data[:length-paddingLen], nil
 }
 
-func (s *WorkerService) JoinKeySign(req types.KeysignRequest) (map[string]tss.KeysignResponse, error) {
+// JoinKeySign signs the messages of the request , and returns the party ids that joined the keysign
+func (s *WorkerService) JoinKeySign(req types.KeysignRequest) (map[string]tss.KeysignResponse, []string, error) {
 	result := map[string]tss.KeysignResponse{}
 	keyFolder := s.cfg.Server.VaultsFilePath
 	serverURL := s.cfg.Relay.Server
-	localStateAccessor, err := relay.NewLocalStateAccessorImp(keyFolder, req.PublicKey, req.VaultPassword, s.blockStorage)
+	vault, err := s.vaultCache.LoadVault(context.Background(), req.VaultFile(), req.VaultPassword)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load vault: %w", err)
+	}
+	localStateAccessor, err := relay.NewLocalStateAccessorWithVault(keyFolder, vault, s.blockStorage)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create localStateAccessor: %w", err)
+		return nil, nil, fmt.Errorf("failed to create localStateAccessor: %w", err)
 	}
 
 	localPartyId := localStateAccessor.Vault.LocalPartyId
@@ -350,20 +406,14 @@ func (s *WorkerService) JoinKeySign(req types.KeysignRequest) (map[string]tss.Ke
 
 	// Let's register session here
 	if err := server.RegisterSessionWithRetry(req.SessionID, localPartyId); err != nil {
-		return nil, fmt.Errorf("failed to register session: %w", err)
+		return nil, nil, fmt.Errorf("failed to register session: %w", err)
 	}
-	// wait longer for keysign start
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute+3*time.Second)
-	defer cancel()
-
-	partiesJoined, err := server.WaitForSessionStart(ctx, req.SessionID)
-	s.logger.WithFields(logrus.Fields{
-		"session":        req.SessionID,
-		"parties_joined": partiesJoined,
-	}).Info("Session started")
-
+	partiesJoined, err := waitForSession(s.logger, server, req.SessionID, 3*time.Minute+3*time.Second)
 	if err != nil {
-		return nil, fmt.Errorf("failed to wait for session start: %w", err)
+		return nil, nil, err
+	}
+	if err := checkSigningThreshold(s.blockStorage, localStateAccessor.Vault, partiesJoined); err != nil {
+		return nil, nil, err
 	}
 
 	for _, message := range req.Messages {
@@ -376,15 +426,15 @@ func (s *WorkerService) JoinKeySign(req types.KeysignRequest) (map[string]tss.Ke
 				message,
 				localStateAccessor.Vault.PublicKeyEddsa,
 				localStateAccessor)
-			if err == nil {
+			if err == nil || errors.Is(err, relay.ErrSessionCancelled) {
 				break
 			}
 		}
 		if err != nil {
-			return result, err
+			return result, nil, err
 		}
 		if signature == nil {
-			return result, fmt.Errorf("signature is nil")
+			return result, nil, fmt.Errorf("signature is nil")
 		}
 		result[message] = *signature
 	}
@@ -396,7 +446,7 @@ func (s *WorkerService) JoinKeySign(req types.KeysignRequest) (map[string]tss.Ke
 		}).Error("Failed to complete session")
 	}
 
-	return result, nil
+	return result, partiesJoined, nil
 }
 
 func (s *WorkerService) keysignWithRetry(serverURL, localPartyId string,
@@ -418,23 +468,28 @@ func (s *WorkerService) keysignWithRetry(serverURL, localPartyId string,
 	messageToSign := base64.StdEncoding.EncodeToString(msgBuf)
 	endCh, wg := s.startMessageDownload(serverURL, req.SessionID, localPartyId, req.HexEncryptionKey, tssService, messageID)
 
-	var signature *tss.KeysignResponse
-	if req.IsECDSA {
-		signature, err = tssService.KeysignECDSA(&tss.KeysignRequest{
-			PubKey:               req.PublicKey,
-			MessageToSign:        messageToSign,
-			LocalPartyKey:        localPartyId,
-			KeysignCommitteeKeys: strings.Join(partiesJoined, ","),
-			DerivePath:           req.DerivePath,
-		})
-	} else {
-		signature, err = tssService.KeysignEdDSA(&tss.KeysignRequest{
+	signature, err := runCeremony(req.SessionID, func() (*tss.KeysignResponse, error) {
+		if req.IsECDSA {
+			return tssService.KeysignECDSA(&tss.KeysignRequest{
+				PubKey:               req.PublicKey,
+				MessageToSign:        messageToSign,
+				LocalPartyKey:        localPartyId,
+				KeysignCommitteeKeys: strings.Join(partiesJoined, ","),
+				DerivePath:           req.DerivePath,
+			})
+		}
+		return tssService.KeysignEdDSA(&tss.KeysignRequest{
 			PubKey:               publicKeyEdDSA, // request public key should be EdDSA public key
 			MessageToSign:        messageToSign,
 			LocalPartyKey:        localPartyId,
 			KeysignCommitteeKeys: strings.Join(partiesJoined, ","),
 			DerivePath:           req.DerivePath,
 		})
+	})
+	if errors.Is(err, relay.ErrSessionCancelled) {
+		close(endCh)
+		wg.Wait()
+		return nil, err
 	}
 
 	client := relay.NewRelayClient(serverURL)
@@ -1,10 +1,17 @@
 package service
 
 import (
+	"context"
 	"encoding/base64"
+	"errors"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
 
 	"github.com/vultisig/vultisigner/common"
+	"github.com/vultisig/vultisigner/relay"
 )
 
 func TestEncryptionGCM(t *testing.T) {
@@ -22,3 +29,70 @@ func TestEncryptionGCM(t *testing.T) {
 		t.Fatalf("decrypted: %s, expected: %s", encrypted, "helloworld")
 	}
 }
+
+func TestRunCeremony(t *testing.T) {
+	release := relay.BindSession(context.Background(), "session-1")
+	defer release()
+	value, err := runCeremony("session-1", func() (string, error) {
+		return "pubkey", nil
+	})
+	if err != nil || value != "pubkey" {
+		t.Fatalf("expected pubkey , got %s %v", value, err)
+	}
+	// a session that isn't bound waits for the ceremony
+	if _, err := runCeremony("session-2", func() (string, error) {
+		return "", errors.New("ceremony failed")
+	}); err == nil || errors.Is(err, relay.ErrSessionCancelled) {
+		t.Fatalf("expected the error of the ceremony , got %v", err)
+	}
+}
+
+func TestRunCeremonyCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	release := relay.BindSession(ctx, "session-1")
+	defer release()
+
+	blockCh := make(chan struct{})
+	defer close(blockCh)
+	resultCh := make(chan error, 1)
+	go func() {
+		_, err := runCeremony("session-1", func() (string, error) {
+			// a ceremony of mobile-tss-lib blocks until it times out
+			<-blockCh
+			return "pubkey", nil
+		})
+		resultCh <- err
+	}()
+	cancel()
+	select {
+	case err := <-resultCh:
+		if !errors.Is(err, relay.ErrSessionCancelled) {
+			t.Fatalf("expected ErrSessionCancelled , got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancelled ceremony didn't return")
+	}
+}
+
+func TestDownloadMessagesStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	release := relay.BindSession(ctx, "session-1")
+	defer release()
+	cancel()
+
+	s := &WorkerService{logger: logrus.New()}
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	// the relay isn't reachable , downloads would be retried until endCh is closed
+	go s.downloadMessages("http://127.0.0.1:1", "session-1", "server", "", nil, make(chan struct{}), "", wg)
+	doneCh := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(doneCh)
+	}()
+	select {
+	case <-doneCh:
+	case <-time.After(5 * time.Second):
+		t.Fatal("message download didn't stop on cancel")
+	}
+}
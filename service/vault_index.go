@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	vaultType "github.com/vultisig/commondata/go/vultisig/vault/v1"
+
+	"github.com/vultisig/vultisigner/common"
+	"github.com/vultisig/vultisigner/internal/types"
+	"github.com/vultisig/vultisigner/storage"
+)
+
+// vaultMetadata returns the vault index entry of the vault , the email hash is kept when the email is empty
+func (s *WorkerService) vaultMetadata(vault *vaultType.Vault, threshold int, revokedParties []string, email string, pgpFingerprint string) types.VaultMetadata {
+	ctx := context.Background()
+	now := time.Now()
+	metadata := types.VaultMetadata{
+		PublicKeyEcdsa: vault.PublicKeyEcdsa,
+		PublicKeyEddsa: vault.PublicKeyEddsa,
+		Name:           vault.Name,
+		FileName:       common.GetVaultName(vault),
+		LibType:        types.VaultLibType(vault.LibType),
+		PGPFingerprint: pgpFingerprint,
+		SignerCount:    len(vault.Signers),
+		Threshold:      threshold,
+		RevokedParties: revokedParties,
+		CreatedAt:      now,
+		LastUsedAt:     now,
+	}
+	if vault.CreatedAt != nil {
+		metadata.CreatedAt = vault.CreatedAt.AsTime()
+	}
+	if email != "" {
+		metadata.EmailHash = common.HashEmail(s.cfg.VaultIndex.EmailSalt, email)
+	} else if previous, err := s.redis.GetVaultMetadata(ctx, vault.PublicKeyEcdsa); err == nil {
+		metadata.EmailHash = previous.EmailHash
+	}
+	return metadata
+}
+
+// indexVault writes the metadata of a saved vault to the vault index , the index is best effort so failures are only logged
+func (s *WorkerService) indexVault(metadata types.VaultMetadata) {
+	if err := s.redis.SaveVaultMetadata(context.Background(), metadata); err != nil {
+		s.logger.Errorf("fail to index vault %s: %v", metadata.PublicKeyEcdsa, err)
+	}
+}
+
+// savePGPPublicKey saves the OpenPGP key of the request , and returns its fingerprint.
+// Without a key in the request the vault keeps the key it had , so its emailed vault shares stay encrypted.
+func (s *WorkerService) savePGPPublicKey(publicKeyEcdsa string, armored string) (string, error) {
+	ctx := context.Background()
+	if armored == "" {
+		previous, err := s.redis.GetVaultMetadata(ctx, publicKeyEcdsa)
+		if errors.Is(err, storage.ErrVaultMetadataNotFound) {
+			return "", nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("fail to get vault metadata: %w", err)
+		}
+		return previous.PGPFingerprint, nil
+	}
+	entity, err := common.ParsePGPPublicKey(armored)
+	if err != nil {
+		return "", err
+	}
+	fingerprint := common.PGPFingerprint(entity)
+	if err := s.redis.SavePGPPublicKey(ctx, fingerprint, armored); err != nil {
+		return "", err
+	}
+	return fingerprint, nil
+}
+
+// touchVault sets the last used time of the vault in the vault index
+func (s *WorkerService) touchVault(publicKeyEcdsa string) {
+	err := s.redis.TouchVaultMetadata(context.Background(), publicKeyEcdsa, time.Now())
+	if err != nil && !errors.Is(err, storage.ErrVaultMetadataNotFound) {
+		s.logger.Errorf("fail to touch vault %s: %v", publicKeyEcdsa, err)
+	}
+}
@@ -1,25 +1,21 @@
 package service
 
 import (
-	"bytes"
 	"context"
-	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"time"
 
 	"github.com/DataDog/datadog-go/statsd"
 	"github.com/hibiken/asynq"
 	"github.com/sirupsen/logrus"
-	keygenType "github.com/vultisig/commondata/go/vultisig/keygen/v1"
-	vaultType "github.com/vultisig/commondata/go/vultisig/vault/v1"
 
+	"github.com/vultisig/vultisigner/common"
 	"github.com/vultisig/vultisigner/config"
 	"github.com/vultisig/vultisigner/contexthelper"
 	"github.com/vultisig/vultisigner/internal/types"
-	"github.com/vultisig/vultisigner/relay"
 	"github.com/vultisig/vultisigner/storage"
 )
 
@@ -30,6 +26,10 @@ type WorkerService struct {
 	queueClient  *asynq.Client
 	sdClient     *statsd.Client
 	blockStorage *storage.BlockStorage
+	emailSender  EmailSender
+	vaultCache   *storage.VaultCache
+	publicClient *http.Client // client of user supplied urls , it only connects to public addresses
+	uploadClient *http.Client // public client of backup uploads , with the upload timeout
 }
 
 // NewWorker creates a new worker service
@@ -38,6 +38,18 @@ func NewWorker(cfg config.Config, queueClient *asynq.Client, sdClient *statsd.Cl
 	if err != nil {
 		return nil, fmt.Errorf("storage.NewRedisStorage failed: %w", err)
 	}
+	if cfg.VaultIndex.EmailSalt, err = redis.EmailSalt(context.Background()); err != nil {
+		return nil, fmt.Errorf("fail to get email salt: %w", err)
+	}
+	emailSender, err := NewEmailSender(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("NewEmailSender failed: %w", err)
+	}
+	vaultCache, err := storage.NewVaultCache(blockStorage, redis, sdClient,
+		time.Duration(cfg.VaultCache.TTLSeconds)*time.Second, cfg.VaultCache.MaxEntries)
+	if err != nil {
+		return nil, fmt.Errorf("storage.NewVaultCache failed: %w", err)
+	}
 
 	return &WorkerService{
 		redis:        redis,
@@ -46,12 +58,24 @@ func NewWorker(cfg config.Config, queueClient *asynq.Client, sdClient *statsd.Cl
 		queueClient:  queueClient,
 		sdClient:     sdClient,
 		blockStorage: blockStorage,
+		emailSender:  emailSender,
+		vaultCache:   vaultCache,
+		publicClient: common.NewPublicHTTPClient(time.Duration(cfg.Notification.WebhookTimeout) * time.Second),
+		uploadClient: common.NewPublicHTTPClient(time.Duration(cfg.BackupDelivery.UploadTimeout) * time.Second),
 	}, nil
 }
 
 type KeyGenerationTaskResult struct {
 	EDDSAPublicKey string
 	ECDSAPublicKey string
+	VaultBackup    string `json:",omitempty"` // the vault share backup , when the request selected the result backup delivery
+}
+
+// VaultBackupTaskResult is the result of reshare and migrate , when the request selected the result backup delivery
+type VaultBackupTaskResult struct {
+	ECDSAPublicKey string
+	VaultBackup    string
+	Pending        bool // the vault share is pending until every party confirms it
 }
 
 func (s *WorkerService) incCounter(name string, tags []string) {
@@ -64,99 +88,6 @@ func (s *WorkerService) measureTime(name string, start time.Time, tags []string)
 		s.logger.Errorf("fail to measure time metric, err: %v", err)
 	}
 }
-func (s *WorkerService) HandleKeyGeneration(ctx context.Context, t *asynq.Task) error {
-	if err := contexthelper.CheckCancellation(ctx); err != nil {
-		return err
-	}
-	defer s.measureTime("worker.vault.create.latency", time.Now(), []string{})
-	var req types.VaultCreateRequest
-	if err := json.Unmarshal(t.Payload(), &req); err != nil {
-		return fmt.Errorf("json.Unmarshal failed: %v: %w", err, asynq.SkipRetry)
-	}
-
-	s.logger.WithFields(logrus.Fields{
-		"name":           req.Name,
-		"session":        req.SessionID,
-		"local_party_id": req.LocalPartyId,
-		"email":          req.Email,
-	}).Info("Joining keygen")
-	s.incCounter("worker.vault.create", []string{})
-	if err := req.IsValid(); err != nil {
-		return fmt.Errorf("invalid vault create request: %s: %w", err, asynq.SkipRetry)
-	}
-	keyECDSA, keyEDDSA, err := s.JoinKeyGeneration(req)
-	if err != nil {
-		_ = s.sdClient.Count("worker.vault.create.error", 1, nil, 1)
-		s.logger.Errorf("keygen.JoinKeyGeneration failed: %v", err)
-		return fmt.Errorf("keygen.JoinKeyGeneration failed: %v: %w", err, asynq.SkipRetry)
-	}
-
-	s.logger.WithFields(logrus.Fields{
-		"keyECDSA": keyECDSA,
-		"keyEDDSA": keyEDDSA,
-	}).Info("localPartyID generation completed")
-
-	result := KeyGenerationTaskResult{
-		EDDSAPublicKey: keyEDDSA,
-		ECDSAPublicKey: keyECDSA,
-	}
-
-	resultBytes, err := json.Marshal(result)
-	if err != nil {
-		s.logger.Errorf("json.Marshal failed: %v", err)
-		return fmt.Errorf("json.Marshal failed: %v: %w", err, asynq.SkipRetry)
-	}
-
-	if _, err := t.ResultWriter().Write(resultBytes); err != nil {
-		s.logger.Errorf("t.ResultWriter.Write failed: %v", err)
-		return fmt.Errorf("t.ResultWriter.Write failed: %v: %w", err, asynq.SkipRetry)
-	}
-
-	return nil
-}
-
-func (s *WorkerService) HandleKeySign(ctx context.Context, t *asynq.Task) error {
-	if err := contexthelper.CheckCancellation(ctx); err != nil {
-		return err
-	}
-	var p types.KeysignRequest
-	if err := json.Unmarshal(t.Payload(), &p); err != nil {
-		s.logger.Errorf("json.Unmarshal failed: %v", err)
-		return fmt.Errorf("json.Unmarshal failed: %v: %w", err, asynq.SkipRetry)
-	}
-	defer s.measureTime("worker.vault.sign.latency", time.Now(), []string{})
-	s.incCounter("worker.vault.sign", []string{})
-	s.logger.WithFields(logrus.Fields{
-		"PublicKey":  p.PublicKey,
-		"session":    p.SessionID,
-		"Messages":   p.Messages,
-		"DerivePath": p.DerivePath,
-		"IsECDSA":    p.IsECDSA,
-	}).Info("joining keysign")
-
-	signatures, err := s.JoinKeySign(p)
-	if err != nil {
-		s.logger.Errorf("join keysign failed: %v", err)
-		return fmt.Errorf("join keysign failed: %v: %w", err, asynq.SkipRetry)
-	}
-
-	s.logger.WithFields(logrus.Fields{
-		"Signatures": signatures,
-	}).Info("localPartyID sign completed")
-
-	resultBytes, err := json.Marshal(signatures)
-	if err != nil {
-		s.logger.Errorf("json.Marshal failed: %v", err)
-		return fmt.Errorf("json.Marshal failed: %v: %w", err, asynq.SkipRetry)
-	}
-
-	if _, err := t.ResultWriter().Write(resultBytes); err != nil {
-		s.logger.Errorf("t.ResultWriter.Write failed: %v", err)
-		return fmt.Errorf("t.ResultWriter.Write failed: %v: %w", err, asynq.SkipRetry)
-	}
-
-	return nil
-}
 func (s *WorkerService) HandleEmailVaultBackup(ctx context.Context, t *asynq.Task) error {
 	if err := contexthelper.CheckCancellation(ctx); err != nil {
 		return err
@@ -171,234 +102,69 @@ func (s *WorkerService) HandleEmailVaultBackup(ctx context.Context, t *asynq.Tas
 		"email":    req.Email,
 		"filename": req.FileName,
 	}).Info("sending email")
-	emailServer := "https://mandrillapp.com/api/1.0/messages/send-template"
-	payload := MandrillPayload{
-		Key:          s.cfg.EmailServer.ApiKey,
-		TemplateName: "fastvault",
-		TemplateContent: []MandrilMergeVarContent{
+	msg := EmailMessage{
+		Kind: EmailBackup,
+		To:   req.Email,
+		Vars: map[string]string{
+			"VAULT_NAME":        req.VaultName,
+			"VERIFICATION_CODE": req.Code,
+		},
+	}
+	switch {
+	case req.FileContent == "" && req.DownloadURL == "":
+		// no vault share , the email only carries the verification code
+		msg.Kind = EmailVerification
+	case req.DownloadURL != "":
+		msg.Kind = EmailBackupLink
+		msg.Vars["DOWNLOAD_URL"] = req.DownloadURL
+	case req.PGPFingerprint != "":
+		// never fall back to the plain attachment , the owner asked for it to be encrypted
+		publicKey, err := s.redis.GetPGPPublicKey(ctx, req.PGPFingerprint)
+		if errors.Is(err, storage.ErrPGPKeyNotFound) {
+			return fmt.Errorf("OpenPGP key %s not found: %w", req.PGPFingerprint, asynq.SkipRetry)
+		}
+		if err != nil {
+			return fmt.Errorf("fail to get OpenPGP key: %w", err)
+		}
+		encrypted, err := common.EncryptPGP(publicKey, req.FileName, []byte(req.FileContent))
+		if err != nil {
+			return fmt.Errorf("fail to encrypt vault share: %v: %w", err, asynq.SkipRetry)
+		}
+		msg.Kind = EmailBackupPGP
+		msg.Vars["PGP_FINGERPRINT"] = req.PGPFingerprint
+		msg.Attachments = []EmailAttachment{
 			{
-				Name:    "VAULT_NAME",
-				Content: req.VaultName,
+				Name:        req.FileName + ".asc",
+				ContentType: "application/pgp-encrypted",
+				Content:     encrypted,
 			},
+		}
+	default:
+		msg.Attachments = []EmailAttachment{
 			{
-				Name:    "VERIFICATION_CODE",
-				Content: req.Code,
-			},
-		},
-		Message: MandrillMessage{
-			To: []MandrillTo{
-				{
-					Email: req.Email,
-					Type:  "to",
-				},
+				Name:        req.FileName,
+				ContentType: "application/octet-stream",
+				Content:     []byte(req.FileContent),
 			},
-			MergeVars: []MandrillVar{
-				{
-					Rcpt: req.Email,
-					Vars: []MandrilMergeVarContent{
-						{
-							Name:    "VAULT_NAME",
-							Content: req.VaultName,
-						},
-						{
-							Name:    "VERIFICATION_CODE",
-							Content: req.Code,
-						},
-					},
-				},
-			},
-			SendingDomain: "vultisig.com",
-			Attachments: []MandrillAttachment{
-				{
-					Type:    "application/octet-stream",
-					Name:    req.FileName,
-					Content: base64.StdEncoding.EncodeToString([]byte(req.FileContent)),
-				},
-			},
-		},
-	}
-	payloadBytes, err := json.Marshal(payload)
-	if err != nil {
-		s.logger.Errorf("json.Marshal failed: %v", err)
-		return fmt.Errorf("json.Marshal failed: %v: %w", err, asynq.SkipRetry)
-	}
-	resp, err := http.Post(emailServer, "application/json", bytes.NewReader(payloadBytes))
-	if err != nil {
-		s.logger.Errorf("http.Post failed: %v", err)
-		return fmt.Errorf("http.Post failed: %w", err)
-	}
-	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			s.logger.Errorf("failed to close body: %v", err)
 		}
-	}()
-	if resp.StatusCode != http.StatusOK {
-		s.logger.Errorf("http.Post failed: %s", resp.Status)
-		return fmt.Errorf("http.Post failed: %s: %w", resp.Status, asynq.SkipRetry)
 	}
-	result, err := io.ReadAll(resp.Body)
-	if err != nil {
-		s.logger.Errorf("io.ReadAll failed: %v", err)
-		return fmt.Errorf("io.ReadAll failed: %w", err)
+	if err := s.sendEmail(ctx, msg); err != nil {
+		return err
 	}
-	s.logger.Info(string(result))
 	if _, err := t.ResultWriter().Write([]byte("email sent")); err != nil {
 		return fmt.Errorf("t.ResultWriter.Write failed: %v", err)
 	}
 	return nil
 }
 
-func (s *WorkerService) HandleReshare(ctx context.Context, t *asynq.Task) error {
-	if err := contexthelper.CheckCancellation(ctx); err != nil {
-		return err
-	}
-	var req types.ReshareRequest
-	if err := json.Unmarshal(t.Payload(), &req); err != nil {
-		s.logger.Errorf("json.Unmarshal failed: %v", err)
-		return fmt.Errorf("json.Unmarshal failed: %v: %w", err, asynq.SkipRetry)
-	}
-
-	defer s.measureTime("worker.vault.reshare.latency", time.Now(), []string{})
-	s.incCounter("worker.vault.reshare", []string{})
-	s.logger.WithFields(logrus.Fields{
-		"name":           req.Name,
-		"session":        req.SessionID,
-		"local_party_id": req.LocalPartyId,
-		"email":          req.Email,
-	}).Info("reshare request")
-	if err := req.IsValid(); err != nil {
-		return fmt.Errorf("invalid reshare request: %s: %w", err, asynq.SkipRetry)
-	}
-	localState, err := relay.NewLocalStateAccessorImp(s.cfg.Server.VaultsFilePath, req.PublicKey, req.EncryptionPassword, s.blockStorage)
-	if err != nil {
-		s.logger.Errorf("relay.NewLocalStateAccessorImp failed: %v", err)
-		return fmt.Errorf("relay.NewLocalStateAccessorImp failed: %v: %w", err, asynq.SkipRetry)
-	}
-	var vault *vaultType.Vault
-	if localState.Vault != nil {
-		// reshare vault
-		vault = localState.Vault
-	} else {
-		vault = &vaultType.Vault{
-			Name:           req.Name,
-			PublicKeyEcdsa: "",
-			PublicKeyEddsa: "",
-			HexChainCode:   req.HexChainCode,
-			LocalPartyId:   req.LocalPartyId,
-			Signers:        req.OldParties,
-			ResharePrefix:  req.OldResharePrefix,
-		}
-	}
-	if err := s.Reshare(vault,
-		req.SessionID,
-		req.HexEncryptionKey,
-		s.cfg.Relay.Server,
-		req.EncryptionPassword,
-		req.Email); err != nil {
-		s.logger.Errorf("reshare failed: %v", err)
-		return fmt.Errorf("reshare failed: %v: %w", err, asynq.SkipRetry)
-	}
-
-	return nil
-}
-
-func (s *WorkerService) HandleReshareDKLS(ctx context.Context, t *asynq.Task) error {
-	if err := contexthelper.CheckCancellation(ctx); err != nil {
-		return err
-	}
-	var req types.ReshareRequest
-	if err := json.Unmarshal(t.Payload(), &req); err != nil {
-		s.logger.Errorf("json.Unmarshal failed: %v", err)
-		return fmt.Errorf("json.Unmarshal failed: %v: %w", err, asynq.SkipRetry)
-	}
-	if req.LibType != types.DKLS {
-		return fmt.Errorf("invalid lib type: %d: %w", req.LibType, asynq.SkipRetry)
-	}
-
-	defer s.measureTime("worker.vault.reshare.latency", time.Now(), []string{})
-	s.incCounter("worker.vault.reshare.dkls", []string{})
-	s.logger.WithFields(logrus.Fields{
-		"name":           req.Name,
-		"session":        req.SessionID,
-		"local_party_id": req.LocalPartyId,
-		"email":          req.Email,
-	}).Info("reshare request")
-	if err := req.IsValid(); err != nil {
-		return fmt.Errorf("invalid reshare request: %s: %w", err, asynq.SkipRetry)
-	}
-	localState, err := relay.NewLocalStateAccessorImp(s.cfg.Server.VaultsFilePath, req.PublicKey, req.EncryptionPassword, s.blockStorage)
-	if err != nil {
-		s.logger.Errorf("relay.NewLocalStateAccessorImp failed: %v", err)
-		return fmt.Errorf("relay.NewLocalStateAccessorImp failed: %v: %w", err, asynq.SkipRetry)
-	}
-	var vault *vaultType.Vault
-	if localState.Vault != nil {
-		// reshare vault
-		vault = localState.Vault
-	} else {
-		vault = &vaultType.Vault{
-			Name:           req.Name,
-			PublicKeyEcdsa: "",
-			PublicKeyEddsa: "",
-			HexChainCode:   req.HexChainCode,
-			LocalPartyId:   req.LocalPartyId,
-			Signers:        req.OldParties,
-			ResharePrefix:  req.OldResharePrefix,
-			LibType:        keygenType.LibType_LIB_TYPE_DKLS,
+// sendEmail sends the email through the configured backend , permanent failures are not retried
+func (s *WorkerService) sendEmail(ctx context.Context, msg EmailMessage) error {
+	if err := s.emailSender.Send(ctx, msg); err != nil {
+		s.logger.Errorf("fail to send %s email: %v", msg.Kind, err)
+		if errors.Is(err, ErrEmailPermanent) {
+			return fmt.Errorf("fail to send email: %v: %w", err, asynq.SkipRetry)
 		}
-		// create new vault
-	}
-	service, err := NewDKLSTssService(s.cfg, s.blockStorage, localState, s)
-	if err != nil {
-		s.logger.Errorf("NewDKLSTssService failed: %v", err)
-		return fmt.Errorf("NewDKLSTssService failed: %v: %w", err, asynq.SkipRetry)
-	}
-
-	if err := service.ProcessReshare(vault, req.SessionID, req.HexEncryptionKey, req.EncryptionPassword, req.Email); err != nil {
-		s.logger.Errorf("reshare failed: %v", err)
-		return fmt.Errorf("reshare failed: %v: %w", err, asynq.SkipRetry)
-	}
-
-	return nil
-}
-
-func (s *WorkerService) HandleMigrateDKLS(ctx context.Context, t *asynq.Task) error {
-	if err := contexthelper.CheckCancellation(ctx); err != nil {
-		return err
-	}
-	var req types.MigrationRequest
-	if err := json.Unmarshal(t.Payload(), &req); err != nil {
-		s.logger.Errorf("json.Unmarshal failed: %v", err)
-		return fmt.Errorf("json.Unmarshal failed: %v: %w", err, asynq.SkipRetry)
-	}
-	defer s.measureTime("worker.vault.migrate.latency", time.Now(), []string{})
-	s.incCounter("worker.vault.migrate.dkls", []string{})
-	s.logger.WithFields(logrus.Fields{
-		"session": req.SessionID,
-		"email":   req.Email,
-	}).Info("migrate request")
-	if err := req.IsValid(); err != nil {
-		return fmt.Errorf("invalid migrate request: %s: %w", err, asynq.SkipRetry)
-	}
-	localState, err := relay.NewLocalStateAccessorImp(s.cfg.Server.VaultsFilePath, req.PublicKey, req.EncryptionPassword, s.blockStorage)
-	if err != nil {
-		s.logger.Errorf("relay.NewLocalStateAccessorImp failed: %v", err)
-		return fmt.Errorf("relay.NewLocalStateAccessorImp failed: %v: %w", err, asynq.SkipRetry)
-	}
-	if localState.Vault == nil {
-		return fmt.Errorf("vault doesn't exist , fail to migrate: %w", asynq.SkipRetry)
-	}
-
-	service, err := NewDKLSTssService(s.cfg, s.blockStorage, localState, s)
-	if err != nil {
-		s.logger.Errorf("NewDKLSTssService failed: %v", err)
-		return fmt.Errorf("NewDKLSTssService failed: %v: %w", err, asynq.SkipRetry)
-	}
-
-	if err := service.ProceeMigration(localState.Vault, req.SessionID, req.HexEncryptionKey, req.EncryptionPassword, req.Email); err != nil {
-		s.logger.Errorf("migrate failed: %v", err)
-		return fmt.Errorf("migrate failed: %v: %w", err, asynq.SkipRetry)
+		return fmt.Errorf("fail to send email: %w", err)
 	}
-
 	return nil
 }
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"github.com/vultisig/vultisigner/config"
+	"github.com/vultisig/vultisigner/internal/tasks"
+)
+
+// WorkerPool is one asynq server of the worker , it serves its queues by weight
+type WorkerPool struct {
+	Name        string
+	Concurrency int
+	Queues      map[string]int
+}
+
+// WorkerPools returns the worker pools of the queue policies. Every enabled operation has its own pool that serves its queue.
+// The default pool serves the default and email queues , and the operation queues with a weight , so the spare default workers help
+// the operations by their weight. A queue of weight 0 is only served by its own pool.
+func WorkerPools(queues config.Queues) []WorkerPool {
+	defaultPool := WorkerPool{
+		Name:        "default",
+		Concurrency: queues.Default.Concurrency,
+		Queues: map[string]int{
+			tasks.QUEUE_NAME:       queues.Default.Weight,
+			tasks.EMAIL_QUEUE_NAME: queues.Email.Weight,
+		},
+	}
+	pools := []WorkerPool{defaultPool}
+	for operation, policy := range queues.Operations() {
+		if policy.Concurrency <= 0 {
+			// the operation runs on other workers
+			continue
+		}
+		queue := tasks.OperationQueue(operation)
+		pools = append(pools, WorkerPool{
+			Name:        operation,
+			Concurrency: policy.Concurrency,
+			Queues:      map[string]int{queue: 1},
+		})
+		if policy.Weight > 0 {
+			defaultPool.Queues[queue] = policy.Weight
+		}
+	}
+	return pools
+}
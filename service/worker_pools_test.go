@@ -0,0 +1,51 @@
+package service
+
+import (
+	"maps"
+	"testing"
+
+	"github.com/vultisig/vultisigner/config"
+	"github.com/vultisig/vultisigner/internal/tasks"
+)
+
+func TestWorkerPools(t *testing.T) {
+	queues := config.Queues{
+		Keygen:  config.QueuePolicy{Concurrency: 4},
+		Keysign: config.QueuePolicy{Concurrency: 16, Weight: 50},
+		Reshare: config.QueuePolicy{Concurrency: 4, Weight: 5},
+		Refresh: config.QueuePolicy{Concurrency: 2},
+		// import runs on other workers , its weight doesn't add it to the default pool
+		Import:  config.QueuePolicy{Weight: 20},
+		Default: config.QueuePolicy{Concurrency: 10, Weight: 10},
+		Email:   config.QueuePolicy{Weight: 100},
+	}
+	pools := map[string]WorkerPool{}
+	for _, pool := range WorkerPools(queues) {
+		pools[pool.Name] = pool
+	}
+
+	expected := map[string]WorkerPool{
+		"default": {Name: "default", Concurrency: 10, Queues: map[string]int{
+			tasks.QUEUE_NAME:                10,
+			tasks.EMAIL_QUEUE_NAME:          100,
+			tasks.OperationQueue("keysign"): 50,
+			tasks.OperationQueue("reshare"): 5,
+		}},
+		"keygen":  {Name: "keygen", Concurrency: 4, Queues: map[string]int{tasks.OperationQueue("keygen"): 1}},
+		"keysign": {Name: "keysign", Concurrency: 16, Queues: map[string]int{tasks.OperationQueue("keysign"): 1}},
+		"reshare": {Name: "reshare", Concurrency: 4, Queues: map[string]int{tasks.OperationQueue("reshare"): 1}},
+		"refresh": {Name: "refresh", Concurrency: 2, Queues: map[string]int{tasks.OperationQueue("refresh"): 1}},
+	}
+	if len(pools) != len(expected) {
+		t.Fatalf("expected %d pools , got %+v", len(expected), pools)
+	}
+	for name, want := range expected {
+		got, ok := pools[name]
+		if !ok {
+			t.Fatalf("pool %s is missing", name)
+		}
+		if got.Concurrency != want.Concurrency || !maps.Equal(got.Queues, want.Queues) {
+			t.Fatalf("pool %s: expected %+v , got %+v", name, want, got)
+		}
+	}
+}
@@ -0,0 +1,383 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/sirupsen/logrus"
+	vaultType "github.com/vultisig/commondata/go/vultisig/vault/v1"
+
+	"github.com/vultisig/vultisigner/contexthelper"
+	"github.com/vultisig/vultisigner/internal/protocol"
+	"github.com/vultisig/vultisigner/internal/types"
+	"github.com/vultisig/vultisigner/relay"
+)
+
+// HandleProtocolTask dispatches a vault operation task to the protocol that registered its task type
+func (s *WorkerService) HandleProtocolTask(ctx context.Context, t *asynq.Task) error {
+	return s.runSession(ctx, func() error {
+		return s.handleProtocolTask(ctx, t)
+	})
+}
+
+func (s *WorkerService) handleProtocolTask(ctx context.Context, t *asynq.Task) error {
+	if err := contexthelper.CheckCancellation(ctx); err != nil {
+		return err
+	}
+	reg, op, err := protocol.ForTask(t.Type())
+	if err != nil {
+		return fmt.Errorf("%v: %w", err, asynq.SkipRetry)
+	}
+	impl, err := s.newProtocol(reg)
+	if err != nil {
+		return fmt.Errorf("%v: %w", err, asynq.SkipRetry)
+	}
+	tags := []string{"protocol:" + reg.Name}
+	switch op {
+	case protocol.OperationKeygen:
+		return s.handleKeyGeneration(t, reg, impl, tags)
+	case protocol.OperationKeysign:
+		return s.handleKeySign(ctx, t, reg, impl, tags)
+	case protocol.OperationReshare:
+		return s.handleReshare(ctx, t, reg, impl, tags)
+	case protocol.OperationRefresh:
+		return s.handleRefresh(ctx, t, reg, impl, tags)
+	case protocol.OperationMigrate:
+		return s.handleMigrate(ctx, t, impl, tags)
+	case protocol.OperationImport:
+		return s.handleKeyImport(t, reg, impl, tags)
+	default:
+		return fmt.Errorf("unknown operation %s: %w", op, asynq.SkipRetry)
+	}
+}
+
+func (s *WorkerService) handleKeyGeneration(t *asynq.Task, reg protocol.Info, impl Protocol, tags []string) error {
+	defer s.measureTime("worker.vault.create.latency", time.Now(), tags)
+	var req types.VaultCreateRequest
+	if err := json.Unmarshal(t.Payload(), &req); err != nil {
+		return fmt.Errorf("json.Unmarshal failed: %v: %w", err, asynq.SkipRetry)
+	}
+	defer req.EncryptionPassword.Wipe()
+	if req.LibType != reg.LibType {
+		return fmt.Errorf("invalid lib type: %d: %w", req.LibType, asynq.SkipRetry)
+	}
+	s.logger.WithFields(logrus.Fields{
+		"name":           req.Name,
+		"session":        req.SessionID,
+		"local_party_id": req.LocalPartyId,
+		"email":          req.Email,
+		"protocol":       reg.Name,
+	}).Info("Joining keygen")
+	s.incCounter("worker.vault.create", tags)
+	if err := req.IsValid(); err != nil {
+		return fmt.Errorf("invalid vault create request: %s: %w", err, asynq.SkipRetry)
+	}
+	keyECDSA, keyEDDSA, err := impl.Keygen(req)
+	if err != nil {
+		s.incCounter("worker.vault.create.error", tags)
+		s.logger.Errorf("keygen failed: %v", err)
+		return fmt.Errorf("keygen failed: %v: %w", err, asynq.SkipRetry)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"keyECDSA": keyECDSA,
+		"keyEDDSA": keyEDDSA,
+	}).Info("localPartyID generation completed")
+
+	backup, err := s.readBackupResult(keyECDSA, req.BackupDeliveryOptions)
+	if err != nil {
+		return fmt.Errorf("%v: %w", err, asynq.SkipRetry)
+	}
+	return s.writeResult(t, KeyGenerationTaskResult{
+		EDDSAPublicKey: keyEDDSA,
+		ECDSAPublicKey: keyECDSA,
+		VaultBackup:    backup,
+	})
+}
+
+func (s *WorkerService) handleKeySign(ctx context.Context, t *asynq.Task, reg protocol.Info, impl Protocol, tags []string) error {
+	var p types.KeysignRequest
+	if err := json.Unmarshal(t.Payload(), &p); err != nil {
+		s.logger.Errorf("json.Unmarshal failed: %v", err)
+		return fmt.Errorf("json.Unmarshal failed: %v: %w", err, asynq.SkipRetry)
+	}
+	defer p.VaultPassword.Wipe()
+	defer s.measureTime("worker.vault.sign.latency", time.Now(), tags)
+	s.incCounter("worker.vault.sign", tags)
+	s.logger.WithFields(logrus.Fields{
+		"PublicKey":  p.PublicKey,
+		"session":    p.SessionID,
+		"Messages":   p.Messages,
+		"DerivePath": p.DerivePath,
+		"IsECDSA":    p.IsECDSA,
+		"protocol":   reg.Name,
+	}).Info("joining keysign")
+	if reg.CheckKeysign != nil {
+		if err := reg.CheckKeysign(p); err != nil {
+			return fmt.Errorf("%v: %w", err, asynq.SkipRetry)
+		}
+	}
+
+	if err := s.checkVaultFrozen(ctx, p.PublicKey); err != nil {
+		return err
+	}
+	if p.ConfirmPending {
+		pending, err := s.redis.GetPendingVault(ctx, p.PublicKey)
+		if err != nil {
+			return fmt.Errorf("fail to get pending vault: %w", err)
+		}
+		if pending == nil {
+			return fmt.Errorf("no pending vault share to confirm: %w", asynq.SkipRetry)
+		}
+	}
+
+	signatures, partiesJoined, err := impl.Keysign(ctx, p)
+	if err != nil {
+		s.logger.Errorf("join keysign failed: %v", err)
+		return fmt.Errorf("join keysign failed: %v: %w", err, asynq.SkipRetry)
+	}
+	if p.ConfirmPending {
+		// the parties signed with the pending share , so all of them hold it
+		if err := s.promotePendingVault(ctx, p.PublicKey, ""); err != nil {
+			s.logger.Errorf("fail to promote pending vault: %v", err)
+		}
+	}
+	s.touchVault(p.PublicKey)
+	s.notifyKeysign(ctx, p, partiesJoined)
+
+	s.logger.WithFields(logrus.Fields{
+		"Signatures": signatures,
+	}).Info("localPartyID sign completed")
+
+	return s.writeResult(t, signatures)
+}
+
+func (s *WorkerService) handleReshare(ctx context.Context, t *asynq.Task, reg protocol.Info, impl Protocol, tags []string) error {
+	var req types.ReshareRequest
+	if err := json.Unmarshal(t.Payload(), &req); err != nil {
+		s.logger.Errorf("json.Unmarshal failed: %v", err)
+		return fmt.Errorf("json.Unmarshal failed: %v: %w", err, asynq.SkipRetry)
+	}
+	defer req.EncryptionPassword.Wipe()
+	if req.LibType != reg.LibType {
+		return fmt.Errorf("invalid lib type: %d: %w", req.LibType, asynq.SkipRetry)
+	}
+
+	defer s.measureTime("worker.vault.reshare.latency", time.Now(), tags)
+	s.incCounter("worker.vault.reshare", tags)
+	s.logger.WithFields(logrus.Fields{
+		"name":           req.Name,
+		"session":        req.SessionID,
+		"local_party_id": req.LocalPartyId,
+		"email":          req.Email,
+		"protocol":       reg.Name,
+	}).Info("reshare request")
+	if err := req.IsValid(); err != nil {
+		return fmt.Errorf("invalid reshare request: %s: %w", err, asynq.SkipRetry)
+	}
+	if req.PublicKey != "" {
+		if err := s.checkVaultFrozen(ctx, req.PublicKey); err != nil {
+			return err
+		}
+	}
+	localState, err := relay.NewLocalStateAccessorImp(s.cfg.Server.VaultsFilePath, req.PublicKey, req.EncryptionPassword, s.blockStorage)
+	if err != nil {
+		s.logger.Errorf("relay.NewLocalStateAccessorImp failed: %v", err)
+		return fmt.Errorf("relay.NewLocalStateAccessorImp failed: %v: %w", err, asynq.SkipRetry)
+	}
+	defer localState.Wipe()
+	var vault *vaultType.Vault
+	if localState.Vault != nil {
+		// reshare vault
+		vault = localState.Vault
+	} else {
+		// create new vault
+		vault = &vaultType.Vault{
+			Name:           req.Name,
+			PublicKeyEcdsa: "",
+			PublicKeyEddsa: "",
+			HexChainCode:   req.HexChainCode,
+			LocalPartyId:   req.LocalPartyId,
+			Signers:        req.OldParties,
+			ResharePrefix:  req.OldResharePrefix,
+			LibType:        reg.LibType.KeygenLibType(),
+		}
+	}
+	if req.RevokedParty != "" {
+		if localState.Vault == nil {
+			return fmt.Errorf("vault doesn't exist , fail to replace party: %w", asynq.SkipRetry)
+		}
+		// the party is saved as revoked with the new share , so it is only revoked once the reshare succeeds
+		s.logger.WithFields(logrus.Fields{
+			"public_key":    req.PublicKey,
+			"session":       req.SessionID,
+			"revoked_party": req.RevokedParty,
+			"committee":     req.ExpectedParties,
+		}).Info("replacing party")
+	}
+	if err := impl.Reshare(localState, vault, req); err != nil {
+		s.logger.Errorf("reshare failed: %v", err)
+		return fmt.Errorf("reshare failed: %v: %w", err, asynq.SkipRetry)
+	}
+	return s.writeBackupResult(t, req.PublicKey, req.SessionID, req.BackupDeliveryOptions)
+}
+
+func (s *WorkerService) handleRefresh(ctx context.Context, t *asynq.Task, reg protocol.Info, impl Protocol, tags []string) error {
+	var req types.RefreshRequest
+	if err := json.Unmarshal(t.Payload(), &req); err != nil {
+		s.logger.Errorf("json.Unmarshal failed: %v", err)
+		return fmt.Errorf("json.Unmarshal failed: %v: %w", err, asynq.SkipRetry)
+	}
+	defer req.EncryptionPassword.Wipe()
+	defer s.measureTime("worker.vault.refresh.latency", time.Now(), tags)
+	s.incCounter("worker.vault.refresh", tags)
+	s.logger.WithFields(logrus.Fields{
+		"public_key": req.PublicKey,
+		"session":    req.SessionID,
+		"email":      req.Email,
+		"protocol":   reg.Name,
+	}).Info("refresh request")
+	if err := req.IsValid(); err != nil {
+		return fmt.Errorf("invalid refresh request: %s: %w", err, asynq.SkipRetry)
+	}
+	if err := s.checkVaultFrozen(ctx, req.PublicKey); err != nil {
+		return err
+	}
+	localState, err := relay.NewLocalStateAccessorImp(s.cfg.Server.VaultsFilePath, req.PublicKey, req.EncryptionPassword, s.blockStorage)
+	if err != nil {
+		s.logger.Errorf("relay.NewLocalStateAccessorImp failed: %v", err)
+		return fmt.Errorf("relay.NewLocalStateAccessorImp failed: %v: %w", err, asynq.SkipRetry)
+	}
+	defer localState.Wipe()
+	if localState.Vault == nil {
+		return fmt.Errorf("vault doesn't exist , fail to refresh: %w", asynq.SkipRetry)
+	}
+	if types.VaultLibType(localState.Vault.LibType) != reg.LibType {
+		return fmt.Errorf("vault is not a %s vault: %w", reg.Name, asynq.SkipRetry)
+	}
+	if err := impl.Refresh(localState, req); err != nil {
+		s.logger.Errorf("refresh failed: %v", err)
+		return fmt.Errorf("refresh failed: %v: %w", err, asynq.SkipRetry)
+	}
+	return nil
+}
+
+func (s *WorkerService) handleMigrate(ctx context.Context, t *asynq.Task, impl Protocol, tags []string) error {
+	var req types.MigrationRequest
+	if err := json.Unmarshal(t.Payload(), &req); err != nil {
+		s.logger.Errorf("json.Unmarshal failed: %v", err)
+		return fmt.Errorf("json.Unmarshal failed: %v: %w", err, asynq.SkipRetry)
+	}
+	defer req.EncryptionPassword.Wipe()
+	defer s.measureTime("worker.vault.migrate.latency", time.Now(), tags)
+	s.incCounter("worker.vault.migrate", tags)
+	s.logger.WithFields(logrus.Fields{
+		"session": req.SessionID,
+		"email":   req.Email,
+	}).Info("migrate request")
+	if err := req.IsValid(); err != nil {
+		return fmt.Errorf("invalid migrate request: %s: %w", err, asynq.SkipRetry)
+	}
+	if err := s.checkVaultFrozen(ctx, req.PublicKey); err != nil {
+		return err
+	}
+	localState, err := relay.NewLocalStateAccessorImp(s.cfg.Server.VaultsFilePath, req.PublicKey, req.EncryptionPassword, s.blockStorage)
+	if err != nil {
+		s.logger.Errorf("relay.NewLocalStateAccessorImp failed: %v", err)
+		return fmt.Errorf("relay.NewLocalStateAccessorImp failed: %v: %w", err, asynq.SkipRetry)
+	}
+	defer localState.Wipe()
+	if localState.Vault == nil {
+		return fmt.Errorf("vault doesn't exist , fail to migrate: %w", asynq.SkipRetry)
+	}
+
+	if err := impl.Migrate(localState, req); err != nil {
+		s.logger.Errorf("migrate failed: %v", err)
+		return fmt.Errorf("migrate failed: %v: %w", err, asynq.SkipRetry)
+	}
+	return s.writeBackupResult(t, req.PublicKey, req.SessionID, req.BackupDeliveryOptions)
+}
+
+// checkVaultFrozen fails the task when the vault is frozen , a frozen vault neither signs nor changes its key shares
+func (s *WorkerService) checkVaultFrozen(ctx context.Context, publicKeyECDSA string) error {
+	freeze, err := s.redis.GetVaultFreeze(ctx, publicKeyECDSA)
+	if err != nil {
+		return fmt.Errorf("fail to check vault freeze: %w", err)
+	}
+	if freeze != nil {
+		return fmt.Errorf("vault is frozen since %s: %w", freeze.FrozenAt, asynq.SkipRetry)
+	}
+	return nil
+}
+
+func (s *WorkerService) handleKeyImport(t *asynq.Task, reg protocol.Info, impl Protocol, tags []string) error {
+	defer s.measureTime("worker.vault.import.latency", time.Now(), tags)
+	var req types.KeyImportRequest
+	if err := json.Unmarshal(t.Payload(), &req); err != nil {
+		return fmt.Errorf("json.Unmarshal failed: %v: %w", err, asynq.SkipRetry)
+	}
+	defer req.EncryptionPassword.Wipe()
+	s.logger.WithFields(logrus.Fields{
+		"name":             req.Name,
+		"session":          req.SessionID,
+		"local_party_id":   req.LocalPartyId,
+		"email":            req.Email,
+		"public_key_ecdsa": req.PublicKeyEcdsa,
+		"protocol":         reg.Name,
+	}).Info("Joining key import")
+	s.incCounter("worker.vault.import", tags)
+	if err := req.IsValid(); err != nil {
+		return fmt.Errorf("invalid key import request: %s: %w", err, asynq.SkipRetry)
+	}
+	keyECDSA, keyEDDSA, err := impl.Import(req)
+	if err != nil {
+		s.incCounter("worker.vault.import.error", tags)
+		s.logger.Errorf("key import failed: %v", err)
+		return fmt.Errorf("key import failed: %v: %w", err, asynq.SkipRetry)
+	}
+
+	return s.writeResult(t, KeyGenerationTaskResult{
+		EDDSAPublicKey: keyEDDSA,
+		ECDSAPublicKey: keyECDSA,
+	})
+}
+
+// writeBackupResult writes the vault share backup to the task result , when the request selected the result backup delivery
+func (s *WorkerService) writeBackupResult(t *asynq.Task, publicKeyECDSA, sessionID string, delivery types.BackupDeliveryOptions) error {
+	backup, err := s.readPendingBackupResult(publicKeyECDSA, sessionID, delivery)
+	if err != nil {
+		return fmt.Errorf("%v: %w", err, asynq.SkipRetry)
+	}
+	pending := backup != ""
+	if !pending {
+		backup, err = s.readBackupResult(publicKeyECDSA, delivery)
+		if err != nil {
+			return fmt.Errorf("%v: %w", err, asynq.SkipRetry)
+		}
+	}
+	if backup == "" {
+		return nil
+	}
+	return s.writeResult(t, VaultBackupTaskResult{
+		ECDSAPublicKey: publicKeyECDSA,
+		VaultBackup:    backup,
+		Pending:        pending,
+	})
+}
+
+func (s *WorkerService) writeResult(t *asynq.Task, result any) error {
+	resultBytes, err := json.Marshal(result)
+	if err != nil {
+		s.logger.Errorf("json.Marshal failed: %v", err)
+		return fmt.Errorf("json.Marshal failed: %v: %w", err, asynq.SkipRetry)
+	}
+
+	if _, err := t.ResultWriter().Write(resultBytes); err != nil {
+		s.logger.Errorf("t.ResultWriter.Write failed: %v", err)
+		return fmt.Errorf("t.ResultWriter.Write failed: %v: %w", err, asynq.SkipRetry)
+	}
+	return nil
+}
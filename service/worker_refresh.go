@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hibiken/asynq"
+
+	"github.com/vultisig/vultisigner/contexthelper"
+	"github.com/vultisig/vultisigner/internal/tasks"
+	"github.com/vultisig/vultisigner/internal/types"
+)
+
+// scheduleRefreshReminder records the time a new share of the vault was saved , and schedules the next reminder when reminders are enabled.
+// Every new share counts , the first one of keygen , import or migration as well as the ones of reshare and refresh.
+func (s *WorkerService) scheduleRefreshReminder(ctx context.Context, publicKeyECDSA, vaultName, email string) {
+	now := time.Now()
+	if err := s.redis.Set(ctx, refreshKey(publicKeyECDSA), strconv.FormatInt(now.Unix(), 10), 0); err != nil {
+		s.logger.Errorf("fail to record refresh time: %v", err)
+	}
+	if s.cfg.Refresh.ReminderDays <= 0 || email == "" {
+		return
+	}
+	reminder := types.RefreshReminder{
+		PublicKey:   publicKeyECDSA,
+		VaultName:   vaultName,
+		Email:       email,
+		ScheduledAt: now.Unix(),
+	}
+	if err := s.enqueueRefreshReminder(reminder); err != nil {
+		s.logger.Errorf("fail to enqueue refresh reminder: %v", err)
+	}
+}
+
+func (s *WorkerService) enqueueRefreshReminder(reminder types.RefreshReminder) error {
+	buf, err := json.Marshal(reminder)
+	if err != nil {
+		return fmt.Errorf("json.Marshal failed: %w", err)
+	}
+	_, err = s.queueClient.Enqueue(asynq.NewTask(tasks.TypeRefreshReminder, buf),
+		asynq.ProcessIn(time.Duration(s.cfg.Refresh.ReminderDays)*24*time.Hour),
+		asynq.Retention(10*time.Minute),
+		asynq.Queue(tasks.EMAIL_QUEUE_NAME))
+	return err
+}
+
+// HandleRefreshReminder emails the user that the vault is due for a refresh.
+// A reminder scheduled before the latest refresh is dropped , that refresh already scheduled the next one.
+func (s *WorkerService) HandleRefreshReminder(ctx context.Context, t *asynq.Task) error {
+	if err := contexthelper.CheckCancellation(ctx); err != nil {
+		return err
+	}
+	var req types.RefreshReminder
+	if err := json.Unmarshal(t.Payload(), &req); err != nil {
+		s.logger.Errorf("json.Unmarshal failed: %v", err)
+		return fmt.Errorf("json.Unmarshal failed: %v: %w", err, asynq.SkipRetry)
+	}
+	lastRefresh, err := s.redis.Get(ctx, refreshKey(req.PublicKey))
+	if err == nil && lastRefresh != "" {
+		if refreshedAt, err := strconv.ParseInt(lastRefresh, 10, 64); err == nil && refreshedAt > req.ScheduledAt {
+			s.logger.Infof("vault %s refreshed since the reminder was scheduled, skipping", req.PublicKey)
+			return nil
+		}
+	}
+	s.incCounter("worker.vault.refresh.reminder", []string{})
+	msg := EmailMessage{
+		Kind: EmailRefreshReminder,
+		To:   req.Email,
+		Vars: map[string]string{
+			"VAULT_NAME": req.VaultName,
+		},
+	}
+	if err := s.sendEmail(ctx, msg); err != nil {
+		return err
+	}
+	// keep reminding until the vault is refreshed
+	if s.cfg.Refresh.ReminderDays > 0 {
+		req.ScheduledAt = time.Now().Unix()
+		if err := s.enqueueRefreshReminder(req); err != nil {
+			s.logger.Errorf("fail to enqueue refresh reminder: %v", err)
+		}
+	}
+	return nil
+}
+
+func refreshKey(publicKeyECDSA string) string {
+	return fmt.Sprintf("refresh_%s", publicKeyECDSA)
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hibiken/asynq"
+	keygenType "github.com/vultisig/commondata/go/vultisig/keygen/v1"
+	vaultType "github.com/vultisig/commondata/go/vultisig/vault/v1"
+
+	"github.com/vultisig/vultisigner/common"
+	"github.com/vultisig/vultisigner/internal/tasks"
+	"github.com/vultisig/vultisigner/internal/types"
+)
+
+// fakeEmailSender records the emails instead of sending them
+type fakeEmailSender struct {
+	mu   sync.Mutex
+	sent []EmailMessage
+}
+
+func (f *fakeEmailSender) Send(_ context.Context, msg EmailMessage) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = append(f.sent, msg)
+	return nil
+}
+
+func TestHandleRefreshReminder(t *testing.T) {
+	tests := map[string]struct {
+		refreshedAt  int64
+		reminderDays int
+		sent         bool
+		rescheduled  bool
+	}{
+		"refreshed since the reminder was scheduled": {refreshedAt: 2000, reminderDays: 30},
+		"due":                {refreshedAt: 1000, reminderDays: 30, sent: true, rescheduled: true},
+		"reminders disabled": {refreshedAt: 1000, sent: true},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			s, _, server := newTestWorker(t)
+			sender := &fakeEmailSender{}
+			s.emailSender = sender
+			s.cfg.Refresh.ReminderDays = test.reminderDays
+			ctx := context.Background()
+			if err := s.redis.Set(ctx, refreshKey(testPublicKeyECDSA), strconv.FormatInt(test.refreshedAt, 10), 0); err != nil {
+				t.Fatal(err)
+			}
+			buf, err := json.Marshal(types.RefreshReminder{
+				PublicKey:   testPublicKeyECDSA,
+				VaultName:   "test vault",
+				Email:       "user@example.com",
+				ScheduledAt: 1000,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := s.HandleRefreshReminder(ctx, asynq.NewTask(tasks.TypeRefreshReminder, buf)); err != nil {
+				t.Fatal(err)
+			}
+
+			if sent := len(sender.sent) == 1; sent != test.sent {
+				t.Fatalf("expected sent %v, got %d emails", test.sent, len(sender.sent))
+			}
+			if test.sent && (sender.sent[0].Kind != EmailRefreshReminder || sender.sent[0].To != "user@example.com" || sender.sent[0].Vars["VAULT_NAME"] != "test vault") {
+				t.Errorf("unexpected email: %+v", sender.sent[0])
+			}
+			inspector := asynq.NewInspector(asynq.RedisClientOpt{Addr: server.Addr()})
+			defer func() {
+				_ = inspector.Close()
+			}()
+			scheduled, err := inspector.ListScheduledTasks(tasks.EMAIL_QUEUE_NAME)
+			if err != nil && test.rescheduled {
+				t.Fatal(err)
+			}
+			if rescheduled := len(scheduled) == 1; rescheduled != test.rescheduled {
+				t.Fatalf("expected rescheduled %v, got %d tasks", test.rescheduled, len(scheduled))
+			}
+			if !test.rescheduled {
+				return
+			}
+			var next types.RefreshReminder
+			if err := json.Unmarshal(scheduled[0].Payload, &next); err != nil {
+				t.Fatal(err)
+			}
+			// the next reminder is only dropped by a refresh after it was scheduled
+			if next.PublicKey != testPublicKeyECDSA || next.ScheduledAt <= test.refreshedAt {
+				t.Errorf("unexpected next reminder: %+v", next)
+			}
+		})
+	}
+}
+
+// the API enqueues every refresh as a DKLS refresh , the worker rejects any other share before the session starts
+func TestHandleRefreshRejectsVault(t *testing.T) {
+	tests := map[string]struct {
+		libType  keygenType.LibType
+		password string
+		err      string
+	}{
+		"GG20 vault":     {libType: keygenType.LibType_LIB_TYPE_GG20, password: "password", err: "is not a"},
+		"wrong password": {libType: keygenType.LibType_LIB_TYPE_DKLS, password: "wrong", err: "fail to decrypt vault"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			s, _, _ := newTestWorker(t)
+			backup, err := common.EncryptVaultToBackup("password", 0, &vaultType.Vault{
+				Name:           "test vault",
+				PublicKeyEcdsa: testPublicKeyECDSA,
+				Signers:        []string{"server", "device-1"},
+				LocalPartyId:   "server",
+				LibType:        test.libType,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := s.blockStorage.UploadFile(backup, testPublicKeyECDSA+".bak"); err != nil {
+				t.Fatal(err)
+			}
+			buf, err := json.Marshal(types.RefreshRequest{
+				PublicKey:          testPublicKeyECDSA,
+				SessionID:          "ab2ec1a4-8ee4-4e4e-9a4c-2d9c3a6f1e11",
+				HexEncryptionKey:   "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+				EncryptionPassword: common.NewSecretFromString(test.password),
+				Email:              "user@example.com",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = s.handleProtocolTask(context.Background(), asynq.NewTask(tasks.TypeRefreshDKLS, buf))
+			if err == nil || !errors.Is(err, asynq.SkipRetry) || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("expected %q without retry, got %v", test.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/vultisig/vultisigner/contexthelper"
+)
+
+const backupDownloadPrefix = "backup_download_"
+
+var (
+	ErrBackupDownloadNotFound = errors.New("backup download link not found or expired")
+)
+
+// CreateBackupDownload returns a random token of a single-use download link for the vault share backup ,
+// downloading it needs the vault password. The token expires after the ttl.
+func (r *RedisStorage) CreateBackupDownload(ctx context.Context, publicKeyECDSA string, ttl time.Duration) (string, error) {
+	if err := contexthelper.CheckCancellation(ctx); err != nil {
+		return "", err
+	}
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("rand.Read failed: %w", err)
+	}
+	token := hex.EncodeToString(buf)
+	key := backupDownloadPrefix + token
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, "public_key_ecdsa", publicKeyECDSA, "attempts", 0)
+		pipe.Expire(ctx, key, ttl)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// GetBackupDownload returns the vault a download link downloads , without consuming the link
+func (r *RedisStorage) GetBackupDownload(ctx context.Context, token string) (string, error) {
+	if err := contexthelper.CheckCancellation(ctx); err != nil {
+		return "", err
+	}
+	publicKeyECDSA, err := r.client.HGet(ctx, backupDownloadPrefix+token, "public_key_ecdsa").Result()
+	if errors.Is(err, redis.Nil) {
+		return "", ErrBackupDownloadNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return publicKeyECDSA, nil
+}
+
+// failBackupDownloadScript counts a wrong password of a download link , and deletes the link after the last attempt.
+// It only touches an existing link , so an expired link isn't recreated without its ttl.
+var failBackupDownloadScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return 0
+end
+local attempts = redis.call("HINCRBY", KEYS[1], "attempts", 1)
+if attempts >= tonumber(ARGV[1]) then
+	redis.call("DEL", KEYS[1])
+end
+return attempts
+`)
+
+// FailBackupDownload records a wrong password for the download link , the link is deleted after maxAttempts wrong passwords
+func (r *RedisStorage) FailBackupDownload(ctx context.Context, token string, maxAttempts int) error {
+	if err := contexthelper.CheckCancellation(ctx); err != nil {
+		return err
+	}
+	return failBackupDownloadScript.Run(ctx, r.client, []string{backupDownloadPrefix + token}, maxAttempts).Err()
+}
+
+// ClaimBackupDownload consumes the download link , and returns the vault it downloads.
+// Only the request that deletes the link gets the download , so the link works once.
+func (r *RedisStorage) ClaimBackupDownload(ctx context.Context, token string) (string, error) {
+	if err := contexthelper.CheckCancellation(ctx); err != nil {
+		return "", err
+	}
+	key := backupDownloadPrefix + token
+	var publicKeyECDSA *redis.StringCmd
+	var deleted *redis.IntCmd
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		publicKeyECDSA = pipe.HGet(ctx, key, "public_key_ecdsa")
+		deleted = pipe.Del(ctx, key)
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return "", err
+	}
+	if deleted.Val() == 0 || publicKeyECDSA.Val() == "" {
+		return "", ErrBackupDownloadNotFound
+	}
+	return publicKeyECDSA.Val(), nil
+}
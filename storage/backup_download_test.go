@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestClaimBackupDownload(t *testing.T) {
+	r, _ := newTestRedisStorage(t)
+	ctx := context.Background()
+	token, err := r.CreateBackupDownload(ctx, "pubkey", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if publicKeyECDSA, err := r.GetBackupDownload(ctx, token); err != nil || publicKeyECDSA != "pubkey" {
+		t.Fatalf("expected pubkey , got %s %v", publicKeyECDSA, err)
+	}
+	publicKeyECDSA, err := r.ClaimBackupDownload(ctx, token)
+	if err != nil || publicKeyECDSA != "pubkey" {
+		t.Fatalf("expected pubkey , got %s %v", publicKeyECDSA, err)
+	}
+	if _, err := r.ClaimBackupDownload(ctx, token); !errors.Is(err, ErrBackupDownloadNotFound) {
+		t.Fatalf("link worked twice: %v", err)
+	}
+}
+
+func TestFailBackupDownload(t *testing.T) {
+	r, server := newTestRedisStorage(t)
+	ctx := context.Background()
+	token, err := r.CreateBackupDownload(ctx, "pubkey", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for idx := 0; idx < 3; idx++ {
+		if _, err := r.GetBackupDownload(ctx, token); err != nil {
+			t.Fatalf("attempt %d: link deleted early: %v", idx, err)
+		}
+		if err := r.FailBackupDownload(ctx, token, 3); err != nil {
+			t.Fatal(err)
+		}
+		if ttl := server.TTL(backupDownloadPrefix + token); idx < 2 && ttl <= 0 {
+			t.Fatalf("attempt %d: link lost its ttl", idx)
+		}
+	}
+	if _, err := r.GetBackupDownload(ctx, token); !errors.Is(err, ErrBackupDownloadNotFound) {
+		t.Fatalf("link not deleted after the last attempt: %v", err)
+	}
+}
+
+func TestFailExpiredBackupDownload(t *testing.T) {
+	r, server := newTestRedisStorage(t)
+	ctx := context.Background()
+	token, err := r.CreateBackupDownload(ctx, "pubkey", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.FastForward(time.Minute)
+	if err := r.FailBackupDownload(ctx, token, 3); err != nil {
+		t.Fatal(err)
+	}
+	if server.Exists(backupDownloadPrefix + token) {
+		t.Fatal("wrong password recreated the expired link")
+	}
+}
@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/vultisig/vultisigner/contexthelper"
+	"github.com/vultisig/vultisigner/internal/types"
+)
+
+const (
+	notificationPrefix = "notification_"
+	vaultFreezePrefix  = "vault_freeze_"
+	reportTokenPrefix  = "report_"
+)
+
+var ErrReportTokenNotFound = errors.New("report token not found or expired")
+
+// reportToken is what a report link points to
+type reportToken struct {
+	PublicKeyECDSA string `json:"public_key_ecdsa"`
+	SessionID      string `json:"session_id"`
+}
+
+// SaveNotificationSettings opts the vault in to co-sign notifications , empty settings opt it out
+func (r *RedisStorage) SaveNotificationSettings(ctx context.Context, publicKeyECDSA string, settings types.NotificationSettings) error {
+	if err := contexthelper.CheckCancellation(ctx); err != nil {
+		return err
+	}
+	if settings.Email == "" && settings.WebhookURL == "" {
+		return r.client.Del(ctx, notificationPrefix+publicKeyECDSA).Err()
+	}
+	buf, err := json.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("json.Marshal failed: %w", err)
+	}
+	return r.client.Set(ctx, notificationPrefix+publicKeyECDSA, buf, 0).Err()
+}
+
+// GetNotificationSettings returns the notification settings of the vault , nil when the vault didn't opt in
+func (r *RedisStorage) GetNotificationSettings(ctx context.Context, publicKeyECDSA string) (*types.NotificationSettings, error) {
+	if err := contexthelper.CheckCancellation(ctx); err != nil {
+		return nil, err
+	}
+	result, err := r.client.Get(ctx, notificationPrefix+publicKeyECDSA).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var settings types.NotificationSettings
+	if err := json.Unmarshal([]byte(result), &settings); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal failed: %w", err)
+	}
+	return &settings, nil
+}
+
+// CreateReportToken returns a random token that reports the keysign session as unauthorized , it expires after the ttl
+func (r *RedisStorage) CreateReportToken(ctx context.Context, publicKeyECDSA, sessionID string, ttl time.Duration) (string, error) {
+	if err := contexthelper.CheckCancellation(ctx); err != nil {
+		return "", err
+	}
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("rand.Read failed: %w", err)
+	}
+	token := hex.EncodeToString(buf)
+	value, err := json.Marshal(reportToken{PublicKeyECDSA: publicKeyECDSA, SessionID: sessionID})
+	if err != nil {
+		return "", fmt.Errorf("json.Marshal failed: %w", err)
+	}
+	if err := r.client.Set(ctx, reportTokenPrefix+token, value, ttl).Err(); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ClaimReportToken consumes the report token , and returns the vault and the keysign session it reports
+func (r *RedisStorage) ClaimReportToken(ctx context.Context, token string) (string, string, error) {
+	if err := contexthelper.CheckCancellation(ctx); err != nil {
+		return "", "", err
+	}
+	result, err := r.client.GetDel(ctx, reportTokenPrefix+token).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", "", ErrReportTokenNotFound
+	}
+	if err != nil {
+		return "", "", err
+	}
+	var value reportToken
+	if err := json.Unmarshal([]byte(result), &value); err != nil {
+		return "", "", fmt.Errorf("json.Unmarshal failed: %w", err)
+	}
+	return value.PublicKeyECDSA, value.SessionID, nil
+}
+
+// FreezeVault stops the vault from signing until UnfreezeVault
+func (r *RedisStorage) FreezeVault(ctx context.Context, publicKeyECDSA string, freeze types.VaultFreeze) error {
+	if err := contexthelper.CheckCancellation(ctx); err != nil {
+		return err
+	}
+	buf, err := json.Marshal(freeze)
+	if err != nil {
+		return fmt.Errorf("json.Marshal failed: %w", err)
+	}
+	return r.client.Set(ctx, vaultFreezePrefix+publicKeyECDSA, buf, 0).Err()
+}
+
+// GetVaultFreeze returns why the vault is frozen , nil when it isn't
+func (r *RedisStorage) GetVaultFreeze(ctx context.Context, publicKeyECDSA string) (*types.VaultFreeze, error) {
+	if err := contexthelper.CheckCancellation(ctx); err != nil {
+		return nil, err
+	}
+	result, err := r.client.Get(ctx, vaultFreezePrefix+publicKeyECDSA).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var freeze types.VaultFreeze
+	if err := json.Unmarshal([]byte(result), &freeze); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal failed: %w", err)
+	}
+	return &freeze, nil
+}
+
+// UnfreezeVault lets the vault sign again
+func (r *RedisStorage) UnfreezeVault(ctx context.Context, publicKeyECDSA string) error {
+	if err := contexthelper.CheckCancellation(ctx); err != nil {
+		return err
+	}
+	return r.client.Del(ctx, vaultFreezePrefix+publicKeyECDSA).Err()
+}
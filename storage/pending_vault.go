@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/vultisig/vultisigner/contexthelper"
+	"github.com/vultisig/vultisigner/internal/types"
+)
+
+const pendingVaultPrefix = "pending_vault_"
+
+var ErrPendingVaultNotFound = errors.New("pending vault not found or expired")
+
+// SavePendingVault stages a vault share , it replaces the pending share of an earlier session and expires after the ttl
+func (r *RedisStorage) SavePendingVault(ctx context.Context, publicKeyECDSA string, pending types.PendingVault, ttl time.Duration) error {
+	if err := contexthelper.CheckCancellation(ctx); err != nil {
+		return err
+	}
+	buf, err := json.Marshal(pending)
+	if err != nil {
+		return fmt.Errorf("json.Marshal failed: %w", err)
+	}
+	return r.client.Set(ctx, pendingVaultPrefix+publicKeyECDSA, buf, ttl).Err()
+}
+
+// GetPendingVault returns the pending vault share of the vault , nil when there is none
+func (r *RedisStorage) GetPendingVault(ctx context.Context, publicKeyECDSA string) (*types.PendingVault, error) {
+	if err := contexthelper.CheckCancellation(ctx); err != nil {
+		return nil, err
+	}
+	result, err := r.client.Get(ctx, pendingVaultPrefix+publicKeyECDSA).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var pending types.PendingVault
+	if err := json.Unmarshal([]byte(result), &pending); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal failed: %w", err)
+	}
+	return &pending, nil
+}
+
+// ClaimPendingVault removes the pending vault share and returns it , only one caller can claim it.
+// When sessionID isn't empty only the share staged by that session is claimed.
+func (r *RedisStorage) ClaimPendingVault(ctx context.Context, publicKeyECDSA, sessionID string) (*types.PendingVault, error) {
+	if err := contexthelper.CheckCancellation(ctx); err != nil {
+		return nil, err
+	}
+	key := pendingVaultPrefix + publicKeyECDSA
+	var pending types.PendingVault
+	err := r.client.Watch(ctx, func(tx *redis.Tx) error {
+		result, err := tx.Get(ctx, key).Result()
+		if errors.Is(err, redis.Nil) {
+			return ErrPendingVaultNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal([]byte(result), &pending); err != nil {
+			return fmt.Errorf("json.Unmarshal failed: %w", err)
+		}
+		if sessionID != "" && pending.SessionID != sessionID {
+			return ErrPendingVaultNotFound
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Del(ctx, key)
+			return nil
+		})
+		return err
+	}, key)
+	if errors.Is(err, redis.TxFailedErr) {
+		// the pending share changed while claiming it , another caller claimed or replaced it
+		return nil, ErrPendingVaultNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &pending, nil
+}
+
+// RestorePendingVault puts back a claimed pending share that couldn't be promoted , with its original expiry.
+// A share that expired meanwhile isn't restored , and neither is one replaced by the share of a later session.
+func (r *RedisStorage) RestorePendingVault(ctx context.Context, publicKeyECDSA string, pending types.PendingVault) error {
+	if err := contexthelper.CheckCancellation(ctx); err != nil {
+		return err
+	}
+	ttl := time.Until(pending.ExpiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+	buf, err := json.Marshal(pending)
+	if err != nil {
+		return fmt.Errorf("json.Marshal failed: %w", err)
+	}
+	return r.client.SetNX(ctx, pendingVaultPrefix+publicKeyECDSA, buf, ttl).Err()
+}
@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/vultisig/vultisigner/contexthelper"
+)
+
+const pgpKeyPrefix = "pgp_key_"
+
+var ErrPGPKeyNotFound = errors.New("OpenPGP key not found")
+
+// SavePGPPublicKey saves the ASCII-armored OpenPGP public key under its fingerprint
+func (r *RedisStorage) SavePGPPublicKey(ctx context.Context, fingerprint, armored string) error {
+	if err := contexthelper.CheckCancellation(ctx); err != nil {
+		return err
+	}
+	return r.client.Set(ctx, pgpKeyPrefix+fingerprint, armored, 0).Err()
+}
+
+// GetPGPPublicKey returns the ASCII-armored OpenPGP public key of the fingerprint
+func (r *RedisStorage) GetPGPPublicKey(ctx context.Context, fingerprint string) (string, error) {
+	if err := contexthelper.CheckCancellation(ctx); err != nil {
+		return "", err
+	}
+	result, err := r.client.Get(ctx, pgpKeyPrefix+fingerprint).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", ErrPGPKeyNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return result, nil
+}
@@ -44,6 +44,14 @@ func (r *RedisStorage) Set(ctx context.Context, key string, value string, expiry
 	}
 	return r.client.Set(ctx, key, value, expiry).Err()
 }
+
+// SetNX sets the key only when it doesn't exist , and reports whether it was set
+func (r *RedisStorage) SetNX(ctx context.Context, key string, value string, expiry time.Duration) (bool, error) {
+	if err := contexthelper.CheckCancellation(ctx); err != nil {
+		return false, err
+	}
+	return r.client.SetNX(ctx, key, value, expiry).Result()
+}
 func (r *RedisStorage) Expire(ctx context.Context, key string, expiry time.Duration) error {
 	if err := contexthelper.CheckCancellation(ctx); err != nil {
 		return err
@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// newTestRedisStorage returns a redis storage backed by an in-memory redis
+func newTestRedisStorage(t *testing.T) (*RedisStorage, *miniredis.Miniredis) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+	return &RedisStorage{client: client}, server
+}
+
+func TestSetNX(t *testing.T) {
+	r, server := newTestRedisStorage(t)
+	ctx := context.Background()
+	for idx, expected := range []bool{true, false} {
+		ok, err := r.SetNX(ctx, "rate_limit", "1", time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != expected {
+			t.Fatalf("attempt %d: expected %v , got %v", idx, expected, ok)
+		}
+	}
+	server.FastForward(time.Minute)
+	if ok, err := r.SetNX(ctx, "rate_limit", "1", time.Minute); err != nil || !ok {
+		t.Fatalf("key wasn't set after it expired: %v %v", ok, err)
+	}
+}
@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/vultisig/vultisigner/contexthelper"
+	"github.com/vultisig/vultisigner/internal/types"
+)
+
+const sessionStatePrefix = "session_state_"
+
+var ErrSessionStateNotFound = errors.New("session not found or expired")
+
+// CreateSessionState creates the state of the session when it doesn't exist yet.
+// It returns the existing state and false when the session was created before.
+func (r *RedisStorage) CreateSessionState(ctx context.Context, state types.SessionState, ttl time.Duration) (*types.SessionState, bool, error) {
+	if err := contexthelper.CheckCancellation(ctx); err != nil {
+		return nil, false, err
+	}
+	buf, err := json.Marshal(state)
+	if err != nil {
+		return nil, false, fmt.Errorf("json.Marshal failed: %w", err)
+	}
+	created, err := r.client.SetNX(ctx, sessionStatePrefix+state.SessionID, buf, ttl).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	if created {
+		return &state, true, nil
+	}
+	existing, err := r.GetSessionState(ctx, state.SessionID)
+	if err != nil {
+		return nil, false, err
+	}
+	return existing, false, nil
+}
+
+// GetSessionState returns the state of the session
+func (r *RedisStorage) GetSessionState(ctx context.Context, sessionID string) (*types.SessionState, error) {
+	if err := contexthelper.CheckCancellation(ctx); err != nil {
+		return nil, err
+	}
+	result, err := r.client.Get(ctx, sessionStatePrefix+sessionID).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrSessionStateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var state types.SessionState
+	if err := json.Unmarshal([]byte(result), &state); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal failed: %w", err)
+	}
+	return &state, nil
+}
+
+// UpdateSessionStatus moves the session to the status , it returns false when the session doesn't exist or can't move to it.
+// The ttl of the session is kept.
+func (r *RedisStorage) UpdateSessionStatus(ctx context.Context, sessionID string, status types.SessionStatus, reason string) (bool, error) {
+	if err := contexthelper.CheckCancellation(ctx); err != nil {
+		return false, err
+	}
+	for attempt := 0; attempt < 3; attempt++ {
+		updated, err := r.updateSessionStatus(ctx, sessionID, status, reason)
+		if errors.Is(err, redis.TxFailedErr) {
+			// the session changed while updating it , read it again
+			continue
+		}
+		return updated, err
+	}
+	return false, fmt.Errorf("fail to update session %s: %w", sessionID, redis.TxFailedErr)
+}
+
+func (r *RedisStorage) updateSessionStatus(ctx context.Context, sessionID string, status types.SessionStatus, reason string) (bool, error) {
+	key := sessionStatePrefix + sessionID
+	updated := false
+	err := r.client.Watch(ctx, func(tx *redis.Tx) error {
+		result, err := tx.Get(ctx, key).Result()
+		if errors.Is(err, redis.Nil) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var state types.SessionState
+		if err := json.Unmarshal([]byte(result), &state); err != nil {
+			return fmt.Errorf("json.Unmarshal failed: %w", err)
+		}
+		if !state.Status.CanMoveTo(status) {
+			return nil
+		}
+		state.Status = status
+		state.Error = reason
+		state.UpdatedAt = time.Now().UTC()
+		buf, err := json.Marshal(state)
+		if err != nil {
+			return fmt.Errorf("json.Marshal failed: %w", err)
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.SetArgs(ctx, key, buf, redis.SetArgs{KeepTTL: true})
+			return nil
+		})
+		if err == nil {
+			updated = true
+		}
+		return err
+	}, key)
+	if err != nil {
+		return false, err
+	}
+	return updated, nil
+}
+
+// DeleteSessionState deletes the state of the session , so the session id can be used again
+func (r *RedisStorage) DeleteSessionState(ctx context.Context, sessionID string) error {
+	if err := contexthelper.CheckCancellation(ctx); err != nil {
+		return err
+	}
+	return r.client.Del(ctx, sessionStatePrefix+sessionID).Err()
+}
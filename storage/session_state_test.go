@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/vultisig/vultisigner/internal/types"
+)
+
+func TestCreateSessionStateOnce(t *testing.T) {
+	r, _ := newTestRedisStorage(t)
+	ctx := context.Background()
+	first := types.SessionState{SessionID: "session", Operation: "keygen", TaskID: "session", Queue: "keygen", Status: types.SessionQueued, KeyHash: "first"}
+	state, created, err := r.CreateSessionState(ctx, first, time.Hour)
+	if err != nil || !created || state.KeyHash != "first" {
+		t.Fatalf("session wasn't created: %+v %v %v", state, created, err)
+	}
+	if _, err := r.UpdateSessionStatus(ctx, "session", types.SessionRunning, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// the duplicate enqueue gets the state of the first one , and doesn't replace it
+	duplicate := first
+	duplicate.Operation = "keysign"
+	duplicate.KeyHash = "second"
+	state, created, err = r.CreateSessionState(ctx, duplicate, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created {
+		t.Fatal("duplicate session was created")
+	}
+	if state.Operation != "keygen" || state.KeyHash != "first" || state.Status != types.SessionRunning {
+		t.Fatalf("duplicate didn't return the existing state: %+v", state)
+	}
+	stored, err := r.GetSessionState(ctx, "session")
+	if err != nil || stored.KeyHash != "first" {
+		t.Fatalf("duplicate replaced the session: %+v %v", stored, err)
+	}
+}
+
+func TestUpdateSessionStatus(t *testing.T) {
+	r, server := newTestRedisStorage(t)
+	ctx := context.Background()
+	if _, _, err := r.CreateSessionState(ctx, types.SessionState{SessionID: "session", Status: types.SessionQueued}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	steps := []struct {
+		status  types.SessionStatus
+		updated bool
+	}{
+		{types.SessionJoined, true},
+		{types.SessionQueued, false},
+		{types.SessionRunning, true},
+		{types.SessionFailed, true},
+		{types.SessionCompleted, false},
+		{types.SessionCancelled, false},
+	}
+	for _, step := range steps {
+		updated, err := r.UpdateSessionStatus(ctx, "session", step.status, "reason")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if updated != step.updated {
+			t.Fatalf("%s: expected updated %v", step.status, step.updated)
+		}
+	}
+	state, err := r.GetSessionState(ctx, "session")
+	if err != nil || state.Status != types.SessionFailed {
+		t.Fatalf("expected failed session , got %+v %v", state, err)
+	}
+	if ttl := server.TTL(sessionStatePrefix + "session"); ttl <= 0 {
+		t.Fatal("update lost the ttl of the session")
+	}
+	if updated, err := r.UpdateSessionStatus(ctx, "missing", types.SessionRunning, ""); err != nil || updated {
+		t.Fatalf("missing session updated: %v %v", updated, err)
+	}
+	if err := r.DeleteSessionState(ctx, "session"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.GetSessionState(ctx, "session"); !errors.Is(err, ErrSessionStateNotFound) {
+		t.Fatalf("session wasn't deleted: %v", err)
+	}
+}
@@ -0,0 +1,275 @@
+package storage
+
+import (
+	"container/list"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-go/statsd"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+	vaultType "github.com/vultisig/commondata/go/vultisig/vault/v1"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/vultisig/vultisigner/common"
+	"github.com/vultisig/vultisigner/contexthelper"
+	"github.com/vultisig/vultisigner/internal/types"
+)
+
+const vaultVersionPrefix = "vault_version_"
+
+// vaultVersionTTL outlives every cache entry , an expired version only turns entries into misses
+const vaultVersionTTL = 24 * time.Hour
+
+// VaultCache keeps decrypted vaults in memory for a short ttl , so keysign doesn't read and decrypt the backup every time.
+// Entries are keyed by the public key and a hash of the password , and encrypted with a key generated for the process.
+// Saving or deleting a vault bumps its version in redis , every process drops entries of an older version.
+type VaultCache struct {
+	blockStorage *BlockStorage
+	redis        *RedisStorage
+	sdClient     *statsd.Client
+	logger       *logrus.Entry
+	ttl          time.Duration
+	maxEntries   int
+	gcm          cipher.AEAD
+	hashKey      []byte
+	mu           sync.Mutex
+	entries      map[string]*list.Element
+	lru          *list.List // the least recently used entry is at the back
+}
+
+type vaultCacheEntry struct {
+	key       string
+	publicKey string
+	version   int64
+	sealed    []byte
+	expiresAt time.Time
+}
+
+// NewVaultCache creates the cache , a ttl or size of 0 disables it and every read goes to the block storage
+func NewVaultCache(blockStorage *BlockStorage, redis *RedisStorage, sdClient *statsd.Client, ttl time.Duration, maxEntries int) (*VaultCache, error) {
+	c := &VaultCache{
+		blockStorage: blockStorage,
+		redis:        redis,
+		sdClient:     sdClient,
+		logger:       logrus.WithField("service", "vault-cache"),
+		ttl:          ttl,
+		maxEntries:   maxEntries,
+		entries:      make(map[string]*list.Element),
+		lru:          list.New(),
+	}
+	if !c.enabled() {
+		return c, nil
+	}
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, fmt.Errorf("fail to generate cache key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if c.gcm, err = cipher.NewGCM(block); err != nil {
+		return nil, err
+	}
+	c.hashKey = make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, c.hashKey); err != nil {
+		return nil, fmt.Errorf("fail to generate cache key: %w", err)
+	}
+	return c, nil
+}
+
+func (c *VaultCache) enabled() bool {
+	return c != nil && c.ttl > 0 && c.maxEntries > 0
+}
+
+// LoadVault returns the vault of the backup file , vaultFileName is the file name without .bak.
+// Active vault shares are served from the cache , pending vault shares are always read from the block storage.
+func (c *VaultCache) LoadVault(ctx context.Context, vaultFileName string, password *common.Secret) (*vaultType.Vault, error) {
+	if !c.enabled() || strings.HasSuffix(vaultFileName, types.PendingVaultSuffix) {
+		return c.readVault(vaultFileName, password)
+	}
+	version, err := c.redis.VaultVersion(ctx, vaultFileName)
+	if err != nil {
+		// without the version the entry may be stale
+		c.logger.Errorf("fail to get vault version: %v", err)
+		return c.readVault(vaultFileName, password)
+	}
+	key := c.entryKey(vaultFileName, password)
+	if vault := c.get(key, version); vault != nil {
+		c.count("vault_cache.hit")
+		return vault, nil
+	}
+	c.count("vault_cache.miss")
+	vault, err := c.readVault(vaultFileName, password)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.put(key, vaultFileName, version, vault); err != nil {
+		c.logger.Errorf("fail to cache vault: %v", err)
+	}
+	return vault, nil
+}
+
+// Invalidate drops the cached vault in every process , after the vault was saved or deleted
+func (c *VaultCache) Invalidate(ctx context.Context, publicKeyECDSA string) error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	for key, element := range c.entries {
+		if element.Value.(*vaultCacheEntry).publicKey == publicKeyECDSA {
+			c.remove(key)
+		}
+	}
+	c.mu.Unlock()
+	return c.redis.BumpVaultVersion(ctx, publicKeyECDSA)
+}
+
+func (c *VaultCache) readVault(vaultFileName string, password *common.Secret) (*vaultType.Vault, error) {
+	content, err := c.blockStorage.GetFile(vaultFileName + ".bak")
+	if err != nil {
+		return nil, fmt.Errorf("fail to get vault file: %w", err)
+	}
+	vault, err := common.DecryptVaultFromBackupSecret(password, content)
+	if err != nil {
+		return nil, fmt.Errorf("fail to decrypt vault from the backup, err: %w", err)
+	}
+	return vault, nil
+}
+
+// entryKey keys the entry by the public key and a keyed hash of the password , a wrong password never hits
+func (c *VaultCache) entryKey(publicKeyECDSA string, password *common.Secret) string {
+	mac := hmac.New(sha256.New, c.hashKey)
+	mac.Write(password.Bytes())
+	return publicKeyECDSA + ":" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (c *VaultCache) get(key string, version int64) *vaultType.Vault {
+	c.mu.Lock()
+	element, ok := c.entries[key]
+	if !ok {
+		c.mu.Unlock()
+		return nil
+	}
+	entry := element.Value.(*vaultCacheEntry)
+	if entry.version != version || time.Now().After(entry.expiresAt) {
+		c.remove(key)
+		c.mu.Unlock()
+		return nil
+	}
+	c.lru.MoveToFront(element)
+	sealed := entry.sealed
+	c.mu.Unlock()
+
+	nonceSize := c.gcm.NonceSize()
+	buf, err := c.gcm.Open(nil, sealed[:nonceSize], sealed[nonceSize:], []byte(key))
+	if err != nil {
+		c.logger.Errorf("fail to decrypt cached vault: %v", err)
+		return nil
+	}
+	defer common.Wipe(buf)
+	var vault vaultType.Vault
+	if err := proto.Unmarshal(buf, &vault); err != nil {
+		c.logger.Errorf("fail to unmarshal cached vault: %v", err)
+		return nil
+	}
+	return &vault
+}
+
+func (c *VaultCache) put(key, publicKeyECDSA string, version int64, vault *vaultType.Vault) error {
+	buf, err := proto.Marshal(vault)
+	if err != nil {
+		return fmt.Errorf("proto.Marshal failed: %w", err)
+	}
+	defer common.Wipe(buf)
+	nonce := make([]byte, c.gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+	entry := &vaultCacheEntry{
+		key:       key,
+		publicKey: publicKeyECDSA,
+		version:   version,
+		sealed:    c.gcm.Seal(nonce, nonce, buf, []byte(key)),
+		expiresAt: time.Now().Add(c.ttl),
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.remove(key)
+	c.entries[key] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.maxEntries {
+		c.remove(c.lru.Back().Value.(*vaultCacheEntry).key)
+		c.count("vault_cache.evict")
+	}
+	c.gauge("vault_cache.entries", float64(c.lru.Len()))
+	return nil
+}
+
+// remove drops the entry , the caller holds the lock
+func (c *VaultCache) remove(key string) {
+	element, ok := c.entries[key]
+	if !ok {
+		return
+	}
+	c.lru.Remove(element)
+	delete(c.entries, key)
+}
+
+func (c *VaultCache) count(name string) {
+	if c.sdClient == nil {
+		return
+	}
+	if err := c.sdClient.Count(name, 1, nil, 1); err != nil {
+		c.logger.Errorf("fail to count metric, err: %v", err)
+	}
+}
+
+func (c *VaultCache) gauge(name string, value float64) {
+	if c.sdClient == nil {
+		return
+	}
+	if err := c.sdClient.Gauge(name, value, nil, 1); err != nil {
+		c.logger.Errorf("fail to report gauge metric, err: %v", err)
+	}
+}
+
+// VaultVersion returns the version of the vault , it changes every time the vault is saved or deleted
+func (r *RedisStorage) VaultVersion(ctx context.Context, publicKeyECDSA string) (int64, error) {
+	if err := contexthelper.CheckCancellation(ctx); err != nil {
+		return 0, err
+	}
+	value, err := r.client.Get(ctx, vaultVersionPrefix+publicKeyECDSA).Result()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// BumpVaultVersion changes the version of the vault
+func (r *RedisStorage) BumpVaultVersion(ctx context.Context, publicKeyECDSA string) error {
+	if err := contexthelper.CheckCancellation(ctx); err != nil {
+		return err
+	}
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Incr(ctx, vaultVersionPrefix+publicKeyECDSA)
+		pipe.Expire(ctx, vaultVersionPrefix+publicKeyECDSA, vaultVersionTTL)
+		return nil
+	})
+	return err
+}
@@ -0,0 +1,181 @@
+package storage
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	vaultType "github.com/vultisig/commondata/go/vultisig/vault/v1"
+
+	"github.com/vultisig/vultisigner/common"
+	"github.com/vultisig/vultisigner/config"
+)
+
+const testVaultPassword = "password"
+
+// countingS3 serves the vault backups from memory and counts the reads of every file
+type countingS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	reads   map[string]int
+}
+
+func (f *countingS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	// path style requests , /bucket/key
+	key := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)[1]
+	content, ok := f.objects[key]
+	if r.Method != http.MethodGet || !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	f.reads[key]++
+	_, _ = w.Write(content)
+}
+
+func (f *countingS3) putVault(t *testing.T, publicKeyECDSA, name string) {
+	content, err := common.EncryptVaultToBackup(testVaultPassword, 2, &vaultType.Vault{
+		Name:           name,
+		PublicKeyEcdsa: publicKeyECDSA,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.objects[publicKeyECDSA+".bak"] = content
+}
+
+func (f *countingS3) readCount(publicKeyECDSA string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.reads[publicKeyECDSA+".bak"]
+}
+
+// newTestVaultCache returns a vault cache backed by an in-memory redis and block storage
+func newTestVaultCache(t *testing.T, maxEntries int) (*VaultCache, *countingS3) {
+	s3 := &countingS3{objects: make(map[string][]byte), reads: make(map[string]int)}
+	s3Server := httptest.NewServer(s3)
+	t.Cleanup(s3Server.Close)
+
+	var cfg config.Config
+	cfg.BlockStorage.Host = s3Server.URL
+	cfg.BlockStorage.Region = "us-east-1"
+	cfg.BlockStorage.AccessKey = "access"
+	cfg.BlockStorage.SecretKey = "secret"
+	cfg.BlockStorage.Bucket = "vaults"
+	cfg.Server.VaultsFilePath = t.TempDir()
+	blockStorage, err := NewBlockStorage(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	redis, _ := newTestRedisStorage(t)
+	cache, err := NewVaultCache(blockStorage, redis, nil, time.Minute, maxEntries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cache, s3
+}
+
+func loadTestVault(t *testing.T, cache *VaultCache, publicKeyECDSA string) *vaultType.Vault {
+	vault, err := cache.LoadVault(context.Background(), publicKeyECDSA, common.NewSecretFromString(testVaultPassword))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return vault
+}
+
+func TestVaultCacheHit(t *testing.T) {
+	cache, s3 := newTestVaultCache(t, 10)
+	s3.putVault(t, "pubkey", "vault")
+
+	for idx := 0; idx < 3; idx++ {
+		if vault := loadTestVault(t, cache, "pubkey"); vault.Name != "vault" {
+			t.Fatalf("unexpected vault %s", vault.Name)
+		}
+	}
+	if reads := s3.readCount("pubkey"); reads != 1 {
+		t.Fatalf("expected 1 read , got %d", reads)
+	}
+}
+
+func TestVaultCacheWrongPassword(t *testing.T) {
+	cache, s3 := newTestVaultCache(t, 10)
+	s3.putVault(t, "pubkey", "vault")
+	loadTestVault(t, cache, "pubkey")
+
+	if _, err := cache.LoadVault(context.Background(), "pubkey", common.NewSecretFromString("wrong password")); err == nil {
+		t.Fatal("wrong password loaded the cached vault")
+	}
+	if reads := s3.readCount("pubkey"); reads != 2 {
+		t.Fatalf("wrong password should miss the cache , got %d reads", reads)
+	}
+}
+
+func TestVaultCacheExpiry(t *testing.T) {
+	cache, s3 := newTestVaultCache(t, 10)
+	s3.putVault(t, "pubkey", "vault")
+	loadTestVault(t, cache, "pubkey")
+
+	cache.mu.Lock()
+	for _, element := range cache.entries {
+		element.Value.(*vaultCacheEntry).expiresAt = time.Now().Add(-time.Second)
+	}
+	cache.mu.Unlock()
+	loadTestVault(t, cache, "pubkey")
+	if reads := s3.readCount("pubkey"); reads != 2 {
+		t.Fatalf("expired entry should miss the cache , got %d reads", reads)
+	}
+}
+
+func TestVaultCacheEviction(t *testing.T) {
+	cache, s3 := newTestVaultCache(t, 2)
+	for _, publicKeyECDSA := range []string{"pubkey-1", "pubkey-2", "pubkey-3"} {
+		s3.putVault(t, publicKeyECDSA, publicKeyECDSA)
+	}
+	loadTestVault(t, cache, "pubkey-1")
+	loadTestVault(t, cache, "pubkey-2")
+	// pubkey-2 is the least recently used once pubkey-1 is read again
+	loadTestVault(t, cache, "pubkey-1")
+	loadTestVault(t, cache, "pubkey-3")
+	if len(cache.entries) != 2 {
+		t.Fatalf("expected 2 entries , got %d", len(cache.entries))
+	}
+
+	loadTestVault(t, cache, "pubkey-1")
+	loadTestVault(t, cache, "pubkey-2")
+	if reads := s3.readCount("pubkey-1"); reads != 1 {
+		t.Fatalf("recently used entry was evicted , got %d reads", reads)
+	}
+	if reads := s3.readCount("pubkey-2"); reads != 2 {
+		t.Fatalf("least recently used entry wasn't evicted , got %d reads", reads)
+	}
+}
+
+func TestVaultCacheVersionBump(t *testing.T) {
+	cache, s3 := newTestVaultCache(t, 10)
+	s3.putVault(t, "pubkey", "vault")
+	loadTestVault(t, cache, "pubkey")
+
+	// another process saves the vault and bumps its version , the entry of this process is stale
+	s3.putVault(t, "pubkey", "reshared vault")
+	if err := cache.redis.BumpVaultVersion(context.Background(), "pubkey"); err != nil {
+		t.Fatal(err)
+	}
+	if vault := loadTestVault(t, cache, "pubkey"); vault.Name != "reshared vault" {
+		t.Fatalf("stale vault %s served after the version bump", vault.Name)
+	}
+
+	s3.putVault(t, "pubkey", "uploaded vault")
+	if err := cache.Invalidate(context.Background(), "pubkey"); err != nil {
+		t.Fatal(err)
+	}
+	if vault := loadTestVault(t, cache, "pubkey"); vault.Name != "uploaded vault" {
+		t.Fatalf("stale vault %s served after invalidate", vault.Name)
+	}
+}
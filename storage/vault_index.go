@@ -0,0 +1,296 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+
+	"github.com/vultisig/vultisigner/contexthelper"
+	"github.com/vultisig/vultisigner/internal/types"
+)
+
+// The vault index keeps the metadata of every vault as json , and secondary indexes to query it:
+// two sorted sets scored by the created and last used time , one set per lib type , and one set per email hash
+const (
+	vaultMetadataPrefix   = "vault_metadata_"
+	vaultIndexCreated     = "vault_index_created"
+	vaultIndexLastUsed    = "vault_index_last_used"
+	vaultIndexLibPrefix   = "vault_index_lib_"
+	vaultIndexEmailPrefix = "vault_index_email_"
+	emailVerifyPrefix     = "vault_index_email_verify_"
+	vaultIndexEmailSalt   = "vault_index_email_salt"
+)
+
+var (
+	ErrVaultMetadataNotFound = errors.New("vault metadata not found")
+	ErrEmailVerification     = errors.New("wrong or expired email verification code")
+)
+
+func vaultIndexLibKey(libType types.LibType) string {
+	return vaultIndexLibPrefix + strconv.Itoa(int(libType))
+}
+
+// SaveVaultMetadata adds the vault to the index , or replaces its metadata
+func (r *RedisStorage) SaveVaultMetadata(ctx context.Context, metadata types.VaultMetadata) error {
+	if err := contexthelper.CheckCancellation(ctx); err != nil {
+		return err
+	}
+	previous, err := r.GetVaultMetadata(ctx, metadata.PublicKeyEcdsa)
+	if err != nil && !errors.Is(err, ErrVaultMetadataNotFound) {
+		return err
+	}
+	buf, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("json.Marshal failed: %w", err)
+	}
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if previous != nil {
+			pipe.SRem(ctx, vaultIndexLibKey(previous.LibType), metadata.PublicKeyEcdsa)
+			if previous.EmailHash != "" {
+				pipe.SRem(ctx, vaultIndexEmailPrefix+previous.EmailHash, metadata.PublicKeyEcdsa)
+			}
+		}
+		pipe.Set(ctx, vaultMetadataPrefix+metadata.PublicKeyEcdsa, buf, 0)
+		pipe.ZAdd(ctx, vaultIndexCreated, redis.Z{Score: float64(metadata.CreatedAt.Unix()), Member: metadata.PublicKeyEcdsa})
+		pipe.ZAdd(ctx, vaultIndexLastUsed, redis.Z{Score: float64(metadata.LastUsedAt.Unix()), Member: metadata.PublicKeyEcdsa})
+		pipe.SAdd(ctx, vaultIndexLibKey(metadata.LibType), metadata.PublicKeyEcdsa)
+		if metadata.EmailHash != "" {
+			pipe.SAdd(ctx, vaultIndexEmailPrefix+metadata.EmailHash, metadata.PublicKeyEcdsa)
+		}
+		return nil
+	})
+	return err
+}
+
+// TouchVaultMetadata sets the last used time of the vault
+func (r *RedisStorage) TouchVaultMetadata(ctx context.Context, publicKeyEcdsa string, lastUsedAt time.Time) error {
+	metadata, err := r.GetVaultMetadata(ctx, publicKeyEcdsa)
+	if err != nil {
+		return err
+	}
+	metadata.LastUsedAt = lastUsedAt
+	buf, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("json.Marshal failed: %w", err)
+	}
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, vaultMetadataPrefix+publicKeyEcdsa, buf, 0)
+		pipe.ZAdd(ctx, vaultIndexLastUsed, redis.Z{Score: float64(lastUsedAt.Unix()), Member: publicKeyEcdsa})
+		return nil
+	})
+	return err
+}
+
+// GetVaultMetadata returns the metadata of the vault , or ErrVaultMetadataNotFound
+func (r *RedisStorage) GetVaultMetadata(ctx context.Context, publicKeyEcdsa string) (*types.VaultMetadata, error) {
+	if err := contexthelper.CheckCancellation(ctx); err != nil {
+		return nil, err
+	}
+	result, err := r.client.Get(ctx, vaultMetadataPrefix+publicKeyEcdsa).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrVaultMetadataNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var metadata types.VaultMetadata
+	if err := json.Unmarshal([]byte(result), &metadata); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal failed: %w", err)
+	}
+	return &metadata, nil
+}
+
+// QueryVaultMetadata returns the metadata of the vaults matching the query , the least recently used first
+func (r *RedisStorage) QueryVaultMetadata(ctx context.Context, query types.VaultMetadataQuery) ([]types.VaultMetadata, error) {
+	if err := contexthelper.CheckCancellation(ctx); err != nil {
+		return nil, err
+	}
+	publicKeys, err := r.queryVaultIndex(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if query.Limit > 0 && len(publicKeys) > query.Limit {
+		publicKeys = publicKeys[:query.Limit]
+	}
+	result := make([]types.VaultMetadata, 0, len(publicKeys))
+	if len(publicKeys) == 0 {
+		return result, nil
+	}
+	keys := make([]string, len(publicKeys))
+	for idx, publicKey := range publicKeys {
+		keys[idx] = vaultMetadataPrefix + publicKey
+	}
+	values, err := r.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for _, value := range values {
+		s, ok := value.(string)
+		if !ok {
+			continue
+		}
+		var metadata types.VaultMetadata
+		if err := json.Unmarshal([]byte(s), &metadata); err != nil {
+			return nil, fmt.Errorf("json.Unmarshal failed: %w", err)
+		}
+		result = append(result, metadata)
+	}
+	return result, nil
+}
+
+// queryVaultIndex returns the public keys of the vaults matching the query , the least recently used first.
+// A query by email or lib type starts from the members of its set , only the other queries range over every vault.
+func (r *RedisStorage) queryVaultIndex(ctx context.Context, query types.VaultMetadataQuery) ([]string, error) {
+	var setKeys []string
+	if query.EmailHash != "" {
+		setKeys = append(setKeys, vaultIndexEmailPrefix+query.EmailHash)
+	}
+	if query.LibType != nil {
+		setKeys = append(setKeys, vaultIndexLibKey(*query.LibType))
+	}
+	if len(setKeys) == 0 {
+		maxScore := "+inf"
+		if !query.UnusedSince.IsZero() {
+			maxScore = "(" + strconv.FormatInt(query.UnusedSince.Unix(), 10)
+		}
+		return r.client.ZRangeByScore(ctx, vaultIndexLastUsed, &redis.ZRangeBy{Min: "-inf", Max: maxScore}).Result()
+	}
+	members, err := r.client.SInter(ctx, setKeys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(members) == 0 {
+		return members, nil
+	}
+	scores, err := r.client.ZMScore(ctx, vaultIndexLastUsed, members...).Result()
+	if err != nil {
+		return nil, err
+	}
+	lastUsed := make(map[string]float64, len(members))
+	publicKeys := make([]string, 0, len(members))
+	for idx, member := range members {
+		if !query.UnusedSince.IsZero() && scores[idx] >= float64(query.UnusedSince.Unix()) {
+			continue
+		}
+		lastUsed[member] = scores[idx]
+		publicKeys = append(publicKeys, member)
+	}
+	slices.SortFunc(publicKeys, func(a, b string) int {
+		if lastUsed[a] != lastUsed[b] {
+			if lastUsed[a] < lastUsed[b] {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a, b)
+	})
+	return publicKeys, nil
+}
+
+// DeleteVaultMetadata removes the vault from the index
+func (r *RedisStorage) DeleteVaultMetadata(ctx context.Context, publicKeyEcdsa string) error {
+	previous, err := r.GetVaultMetadata(ctx, publicKeyEcdsa)
+	if errors.Is(err, ErrVaultMetadataNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, vaultMetadataPrefix+publicKeyEcdsa)
+		pipe.ZRem(ctx, vaultIndexCreated, publicKeyEcdsa)
+		pipe.ZRem(ctx, vaultIndexLastUsed, publicKeyEcdsa)
+		pipe.SRem(ctx, vaultIndexLibKey(previous.LibType), publicKeyEcdsa)
+		if previous.EmailHash != "" {
+			pipe.SRem(ctx, vaultIndexEmailPrefix+previous.EmailHash, publicKeyEcdsa)
+		}
+		return nil
+	})
+	return err
+}
+
+// EmailSalt returns the salt of the email hashes , vault_index.email_salt when it is set.
+// Otherwise a random salt is generated on the first start and saved in redis next to the vault index ,
+// so every server and worker hash emails with the same salt.
+func (r *RedisStorage) EmailSalt(ctx context.Context) (string, error) {
+	if r.cfg.VaultIndex.EmailSalt != "" {
+		return r.cfg.VaultIndex.EmailSalt, nil
+	}
+	if err := contexthelper.CheckCancellation(ctx); err != nil {
+		return "", err
+	}
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("rand.Read failed: %w", err)
+	}
+	created, err := r.client.SetNX(ctx, vaultIndexEmailSalt, hex.EncodeToString(buf), 0).Result()
+	if err != nil {
+		return "", fmt.Errorf("fail to save email salt, err: %w", err)
+	}
+	salt, err := r.client.Get(ctx, vaultIndexEmailSalt).Result()
+	if err != nil {
+		return "", fmt.Errorf("fail to get email salt, err: %w", err)
+	}
+	logrus.WithField("generated", created).Warnf("vault_index.email_salt isn't set , emails are hashed with the salt in redis key %s. Copy it to the config , email hashes are unusable once the key is lost", vaultIndexEmailSalt)
+	return salt, nil
+}
+
+// CreateEmailVerification saves the code that proves the requester owns the email , it replaces the previous code
+func (r *RedisStorage) CreateEmailVerification(ctx context.Context, emailHash, code string, ttl time.Duration) error {
+	if err := contexthelper.CheckCancellation(ctx); err != nil {
+		return err
+	}
+	return r.client.Set(ctx, emailVerifyPrefix+emailHash, code, ttl).Err()
+}
+
+// ClaimEmailVerification consumes the code of the email , a wrong code consumes it too so it can't be guessed
+func (r *RedisStorage) ClaimEmailVerification(ctx context.Context, emailHash, code string) error {
+	if err := contexthelper.CheckCancellation(ctx); err != nil {
+		return err
+	}
+	expected, err := r.client.GetDel(ctx, emailVerifyPrefix+emailHash).Result()
+	if errors.Is(err, redis.Nil) {
+		return ErrEmailVerification
+	}
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) != 1 {
+		return ErrEmailVerification
+	}
+	return nil
+}
+
+// VaultIndexStats returns the number of vaults in the index
+func (r *RedisStorage) VaultIndexStats(ctx context.Context) (*types.VaultIndexStats, error) {
+	if err := contexthelper.CheckCancellation(ctx); err != nil {
+		return nil, err
+	}
+	total, err := r.client.ZCard(ctx, vaultIndexCreated).Result()
+	if err != nil {
+		return nil, err
+	}
+	stats := &types.VaultIndexStats{
+		Total:     total,
+		ByLibType: make(map[types.LibType]int64),
+	}
+	for _, libType := range []types.LibType{types.GG20, types.DKLS} {
+		count, err := r.client.SCard(ctx, vaultIndexLibKey(libType)).Result()
+		if err != nil {
+			return nil, err
+		}
+		stats.ByLibType[libType] = count
+	}
+	return stats, nil
+}
@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/vultisig/vultisigner/internal/types"
+)
+
+func saveTestVaults(t *testing.T, r *RedisStorage, vaults ...types.VaultMetadata) {
+	t.Helper()
+	for _, vault := range vaults {
+		if err := r.SaveVaultMetadata(context.Background(), vault); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func publicKeysOf(vaults []types.VaultMetadata) []string {
+	result := make([]string, 0, len(vaults))
+	for _, vault := range vaults {
+		result = append(result, vault.PublicKeyEcdsa)
+	}
+	return result
+}
+
+func TestQueryVaultMetadata(t *testing.T) {
+	r, _ := newTestRedisStorage(t)
+	now := time.Now().Truncate(time.Second)
+	saveTestVaults(t, r,
+		types.VaultMetadata{PublicKeyEcdsa: "a", LibType: types.DKLS, EmailHash: "alice", LastUsedAt: now.AddDate(0, 0, -10)},
+		types.VaultMetadata{PublicKeyEcdsa: "b", LibType: types.GG20, EmailHash: "alice", LastUsedAt: now.AddDate(0, 0, -20)},
+		types.VaultMetadata{PublicKeyEcdsa: "c", LibType: types.DKLS, EmailHash: "bob", LastUsedAt: now},
+		types.VaultMetadata{PublicKeyEcdsa: "d", LibType: types.DKLS, LastUsedAt: now.AddDate(0, 0, -30)},
+	)
+	dkls := types.DKLS
+	tests := map[string]struct {
+		query    types.VaultMetadataQuery
+		expected []string
+	}{
+		"every vault":        {query: types.VaultMetadataQuery{}, expected: []string{"d", "b", "a", "c"}},
+		"by email":           {query: types.VaultMetadataQuery{EmailHash: "alice"}, expected: []string{"b", "a"}},
+		"by lib type":        {query: types.VaultMetadataQuery{LibType: &dkls}, expected: []string{"d", "a", "c"}},
+		"by email and lib":   {query: types.VaultMetadataQuery{EmailHash: "alice", LibType: &dkls}, expected: []string{"a"}},
+		"unused":             {query: types.VaultMetadataQuery{UnusedSince: now.AddDate(0, 0, -15)}, expected: []string{"d", "b"}},
+		"unused by lib type": {query: types.VaultMetadataQuery{LibType: &dkls, UnusedSince: now.AddDate(0, 0, -5)}, expected: []string{"d", "a"}},
+		"limit":              {query: types.VaultMetadataQuery{LibType: &dkls, Limit: 2}, expected: []string{"d", "a"}},
+		"unknown email":      {query: types.VaultMetadataQuery{EmailHash: "carol"}, expected: []string{}},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			result, err := r.QueryVaultMetadata(context.Background(), test.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := publicKeysOf(result); !slices.Equal(got, test.expected) {
+				t.Fatalf("expected %v , got %v", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestDeleteVaultMetadata(t *testing.T) {
+	r, _ := newTestRedisStorage(t)
+	ctx := context.Background()
+	saveTestVaults(t, r,
+		types.VaultMetadata{PublicKeyEcdsa: "a", LibType: types.DKLS, EmailHash: "alice", LastUsedAt: time.Now()},
+		types.VaultMetadata{PublicKeyEcdsa: "b", LibType: types.DKLS, EmailHash: "alice", LastUsedAt: time.Now()},
+	)
+	if err := r.DeleteVaultMetadata(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.GetVaultMetadata(ctx, "a"); !errors.Is(err, ErrVaultMetadataNotFound) {
+		t.Fatalf("expected ErrVaultMetadataNotFound , got %v", err)
+	}
+	dkls := types.DKLS
+	for _, query := range []types.VaultMetadataQuery{{}, {EmailHash: "alice"}, {LibType: &dkls}} {
+		result, err := r.QueryVaultMetadata(ctx, query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := publicKeysOf(result); !slices.Equal(got, []string{"b"}) {
+			t.Fatalf("deleted vault is still indexed: %v", got)
+		}
+	}
+	stats, err := r.VaultIndexStats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Total != 1 || stats.ByLibType[types.DKLS] != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	if err := r.DeleteVaultMetadata(ctx, "a"); err != nil {
+		t.Fatalf("deleting a missing vault failed: %v", err)
+	}
+}
+
+func TestEmailVerification(t *testing.T) {
+	r, server := newTestRedisStorage(t)
+	ctx := context.Background()
+	if err := r.CreateEmailVerification(ctx, "alice", "123456", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.ClaimEmailVerification(ctx, "alice", "654321"); !errors.Is(err, ErrEmailVerification) {
+		t.Fatalf("wrong code: expected ErrEmailVerification , got %v", err)
+	}
+	// the wrong code consumed the right one
+	if err := r.ClaimEmailVerification(ctx, "alice", "123456"); !errors.Is(err, ErrEmailVerification) {
+		t.Fatalf("consumed code: expected ErrEmailVerification , got %v", err)
+	}
+
+	if err := r.CreateEmailVerification(ctx, "alice", "123456", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.ClaimEmailVerification(ctx, "alice", "123456"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.ClaimEmailVerification(ctx, "alice", "123456"); !errors.Is(err, ErrEmailVerification) {
+		t.Fatalf("code used twice: %v", err)
+	}
+
+	if err := r.CreateEmailVerification(ctx, "alice", "123456", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	server.FastForward(time.Minute)
+	if err := r.ClaimEmailVerification(ctx, "alice", "123456"); !errors.Is(err, ErrEmailVerification) {
+		t.Fatalf("expired code: %v", err)
+	}
+}
+
+func TestEmailSalt(t *testing.T) {
+	r, server := newTestRedisStorage(t)
+	ctx := context.Background()
+	salt, err := r.EmailSalt(ctx)
+	if err != nil || len(salt) != 64 {
+		t.Fatalf("no salt generated: %q %v", salt, err)
+	}
+	// every server and worker gets the salt generated first
+	other := &RedisStorage{client: r.client}
+	if again, err := other.EmailSalt(ctx); err != nil || again != salt {
+		t.Fatalf("salt changed: %q %v", again, err)
+	}
+	if stored, err := server.Get(vaultIndexEmailSalt); err != nil || stored != salt {
+		t.Fatalf("salt wasn't saved: %q %v", stored, err)
+	}
+
+	r.cfg.VaultIndex.EmailSalt = "configured"
+	if configured, err := r.EmailSalt(ctx); err != nil || configured != "configured" {
+		t.Fatalf("configured salt wasn't used: %q %v", configured, err)
+	}
+}
@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	vaultType "github.com/vultisig/commondata/go/vultisig/vault/v1"
+
+	"github.com/vultisig/vultisigner/common"
+	"github.com/vultisig/vultisigner/internal/types"
+)
+
+var ErrVaultSettingsNotFound = errors.New("vault settings not found")
+
+// VaultSettingsFileName is the file the settings of the vault are kept in , next to the vault share backup
+func VaultSettingsFileName(publicKeyECDSA string) string {
+	return publicKeyECDSA + ".settings.json"
+}
+
+// SaveVaultSettings saves the server-side settings of the vault
+func (bs *BlockStorage) SaveVaultSettings(settings types.VaultSettings) error {
+	buf, err := json.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("fail to marshal vault settings: %w", err)
+	}
+	if err := bs.UploadFileWithRetry(buf, VaultSettingsFileName(settings.PublicKeyEcdsa), 3); err != nil {
+		return fmt.Errorf("fail to save vault settings: %w", err)
+	}
+	return nil
+}
+
+// GetVaultSettings returns the server-side settings of the vault , ErrVaultSettingsNotFound when the vault has none yet
+func (bs *BlockStorage) GetVaultSettings(publicKeyECDSA string) (*types.VaultSettings, error) {
+	buf, err := bs.GetFile(VaultSettingsFileName(publicKeyECDSA))
+	if err != nil {
+		var awsErr awserr.Error
+		if errors.As(err, &awsErr) && awsErr.Code() == s3.ErrCodeNoSuchKey {
+			return nil, ErrVaultSettingsNotFound
+		}
+		return nil, fmt.Errorf("fail to get vault settings: %w", err)
+	}
+	var settings types.VaultSettings
+	if err := json.Unmarshal(buf, &settings); err != nil {
+		return nil, fmt.Errorf("fail to unmarshal vault settings: %w", err)
+	}
+	return &settings, nil
+}
+
+// UpdateVaultSettings applies update to the saved settings of the vault , a vault without settings starts from empty settings
+func (bs *BlockStorage) UpdateVaultSettings(publicKeyECDSA string, update func(settings *types.VaultSettings)) error {
+	settings, err := bs.GetVaultSettings(publicKeyECDSA)
+	if errors.Is(err, ErrVaultSettingsNotFound) {
+		settings = &types.VaultSettings{PublicKeyEcdsa: publicKeyECDSA}
+	} else if err != nil {
+		return err
+	}
+	update(settings)
+	return bs.SaveVaultSettings(*settings)
+}
+
+// GetRevokedParties returns the party ids that were removed from the vault
+func (bs *BlockStorage) GetRevokedParties(publicKeyECDSA string) ([]string, error) {
+	settings, err := bs.GetVaultSettings(publicKeyECDSA)
+	if errors.Is(err, ErrVaultSettingsNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return settings.RevokedParties, nil
+}
+
+// CheckRevokedParties returns an error when a revoked party of the vault joined the session
+func (bs *BlockStorage) CheckRevokedParties(publicKeyECDSA string, partiesJoined []string) error {
+	revoked, err := bs.GetRevokedParties(publicKeyECDSA)
+	if err != nil {
+		return fmt.Errorf("failed to get revoked parties: %w", err)
+	}
+	for _, party := range partiesJoined {
+		if slices.Contains(revoked, party) {
+			return fmt.Errorf("party %s was revoked", party)
+		}
+	}
+	return nil
+}
+
+// GetVaultThreshold returns the threshold of the vault.
+// Vaults saved before the threshold was kept in the settings use the default threshold of their signers.
+func (bs *BlockStorage) GetVaultThreshold(vault *vaultType.Vault) (int, error) {
+	if vault == nil {
+		return 0, errors.New("vault is nil")
+	}
+	settings, err := bs.GetVaultSettings(vault.PublicKeyEcdsa)
+	if err != nil && !errors.Is(err, ErrVaultSettingsNotFound) {
+		return 0, err
+	}
+	if settings != nil && settings.Threshold > 0 {
+		return settings.Threshold, nil
+	}
+	return common.DefaultThreshold(len(vault.Signers))
+}